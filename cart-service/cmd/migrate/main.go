@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/emart/cart-service/internal/config"
+	"github.com/emart/cart-service/internal/migration"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const usage = `Usage: migrate <command> [flags]
+
+Commands:
+  status                     list every migration and its changelog state
+  up   [--to ID] [--dry-run] apply pending migrations, up to and including ID
+  down [--to ID] [--dry-run] roll back the latest migration, or everything after ID
+       [--force]             (--to base rolls back all migrations); --force also rolls
+                             back migrations with no record of what they created
+  repair                     re-baseline stored checksums after a reviewed edit
+  unlock                     release the migration lock left by a crashed run
+
+Connection settings come from the same environment as the server (MONGO_URI, MONGO_DATABASE).
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	command := os.Args[1]
+
+	fs := flag.NewFlagSet(command, flag.ExitOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	target := fs.String("to", "", "target migration ID")
+	dryRun := fs.Bool("dry-run", false, "print the plan without changing the database")
+	force := fs.Bool("force", false, "roll back migrations with no record of what they created")
+	timeout := fs.Duration("timeout", 10*time.Minute, "overall time limit")
+	fs.Parse(os.Args[2:])
+
+	logger := buildLogger()
+	defer logger.Sync()
+
+	cfg, err := config.Load()
+	if err != nil {
+		logger.Fatal("Invalid configuration", zap.Error(err))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	mongoClient, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.MongoDB.URI))
+	if err != nil {
+		logger.Fatal("Failed to connect to MongoDB", zap.Error(err))
+	}
+	defer mongoClient.Disconnect(context.Background())
+	if err := mongoClient.Ping(ctx, nil); err != nil {
+		logger.Fatal("MongoDB ping failed", zap.Error(err))
+	}
+	runner := migration.NewRunner(mongoClient.Database(cfg.MongoDB.Database), cfg, logger)
+
+	opts := migration.Options{Target: *target, DryRun: *dryRun, Force: *force}
+	switch command {
+	case "status":
+		err = printStatus(ctx, runner)
+	case "up":
+		err = runner.Up(ctx, opts)
+	case "down":
+		err = runner.Down(ctx, opts)
+	case "repair":
+		err = runner.Repair(ctx)
+	case "unlock":
+		err = runner.Unlock(ctx)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		logger.Fatal("Migration command failed", zap.String("command", command), zap.Error(err))
+	}
+}
+
+func printStatus(ctx context.Context, runner *migration.Runner) error {
+	statuses, err := runner.Status(ctx)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ORDER\tID\tSTATE\tAT\tDRIFT\tERROR")
+	for _, st := range statuses {
+		at := "-"
+		if st.ExecutedAt != nil {
+			at = st.ExecutedAt.Format(time.RFC3339)
+		}
+		drift := ""
+		if st.Drifted {
+			drift = "CHANGED"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", st.Order, st.ID, st.State, at, drift, st.Error)
+	}
+	return w.Flush()
+}
+
+// buildLogger mirrors the server's logger so both binaries log the same way
+func buildLogger() *zap.Logger {
+	if os.Getenv("APP_ENV") == "prod" {
+		cfg := zap.NewProductionConfig()
+		cfg.Level = zap.NewAtomicLevelAt(zapcore.InfoLevel)
+		l, _ := cfg.Build()
+		return l
+	}
+	l, _ := zap.NewDevelopmentConfig().Build()
+	return l
+}
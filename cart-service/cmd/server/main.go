@@ -9,14 +9,22 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/emart/cart-service/internal/catalog"
 	"github.com/emart/cart-service/internal/config"
+	"github.com/emart/cart-service/internal/events"
 	"github.com/emart/cart-service/internal/handler"
+	"github.com/emart/cart-service/internal/metrics"
 	"github.com/emart/cart-service/internal/middleware"
 	"github.com/emart/cart-service/internal/migration"
+	"github.com/emart/cart-service/internal/notify"
+	"github.com/emart/cart-service/internal/promotion"
+	"github.com/emart/cart-service/internal/ratelimit"
+	"github.com/emart/cart-service/internal/realtime"
 	mongorepo "github.com/emart/cart-service/internal/repository/mongo"
 	redisrepo "github.com/emart/cart-service/internal/repository/redis"
 	"github.com/emart/cart-service/internal/service"
 	"github.com/emart/cart-service/internal/sync"
+	"github.com/emart/cart-service/internal/tracing"
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -35,13 +43,21 @@ func main() {
 	// ============================================================
 	// Load Configuration
 	// ============================================================
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		logger.Fatal("Invalid configuration", zap.Error(err))
+	}
 	logger.Info("Starting Emart Cart Service",
 		zap.String("version", cfg.App.Version),
 		zap.String("env", cfg.App.Env),
 		zap.String("port", cfg.Server.Port),
 	)
 
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg, logger)
+	if err != nil {
+		logger.Fatal("Invalid tracing configuration", zap.Error(err))
+	}
+
 	// ============================================================
 	// Connect to Redis
 	// ============================================================
@@ -76,8 +92,9 @@ func main() {
 	// ============================================================
 	// Run Mongock-style Migrations
 	// ============================================================
-	migrationRunner := migration.NewRunner(db, logger)
-	migCtx, migCancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	migrationRunner := migration.NewRunner(db, cfg, logger)
+	// Waiting for another pod's lock must not eat into the migrations' own time
+	migCtx, migCancel := context.WithTimeout(context.Background(), cfg.Migration.LockWait+2*time.Minute)
 	defer migCancel()
 
 	if err := migrationRunner.Run(migCtx); err != nil {
@@ -90,35 +107,174 @@ func main() {
 	// ============================================================
 	redisRepo := redisrepo.NewCartRedisRepository(redisClient)
 	mongoRepo := mongorepo.NewCartMongoRepository(db)
+	// The cart service reads through the instrumented Redis repo; the syncer
+	// keeps the plain one so its reads don't count as cache lookups
+	cacheRepo := redisRepo
+	var appMetrics *metrics.Metrics
+	if cfg.Metrics.Enabled {
+		appMetrics = metrics.New()
+		cacheRepo = appMetrics.InstrumentRedis(redisRepo)
+		mongoRepo = appMetrics.InstrumentMongo(mongoRepo)
+		if statuses, err := migrationRunner.Status(migCtx); err != nil {
+			logger.Warn("Failed to read migration status for metrics", zap.Error(err))
+		} else {
+			appMetrics.RecordMigrations(statuses)
+		}
+	}
+	couponRepo := mongorepo.NewCouponMongoRepository(db)
+	outboxRepo := mongorepo.NewOutboxMongoRepository(db)
+	abandonedRepo := mongorepo.NewAbandonedCartMongoRepository(db)
+	sessionRepo := mongorepo.NewCheckoutSessionMongoRepository(db)
+	adminCartRepo := mongorepo.NewAdminCartMongoRepository(db)
+	auditRepo := mongorepo.NewAuditLogMongoRepository(db)
 
 	// ============================================================
 	// Initialize Services
 	// ============================================================
-	cartSvc := service.NewCartService(redisRepo, mongoRepo, cfg.Redis.TTL, logger)
+	catalogClient := catalog.NewHTTPClient(catalog.Endpoints{
+		Books:    cfg.Catalog.BooksURL,
+		Courses:  cfg.Catalog.CoursesURL,
+		Software: cfg.Catalog.SoftwareURL,
+	}, cfg.Catalog.ServiceToken, cfg.Catalog.Timeout)
+	promotions := promotion.NewEngine(couponRepo, cfg.Promotion.MaxCouponsPerCart)
+	streamPublisher := events.NewRedisStreamPublisher(redisClient, cfg.Events.StreamName, cfg.Events.StreamMaxLen)
+	var publisher events.EventPublisher
+	switch cfg.Events.Publisher {
+	case "redis":
+		publisher = streamPublisher
+	case "none":
+		publisher = events.NewNoopPublisher()
+	case "outbox":
+		// The outbox is only as good as the transaction it shares with the cart write
+		txCtx, txCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		supported, err := mongorepo.SupportsTransactions(txCtx, db)
+		txCancel()
+		if err != nil {
+			logger.Fatal("Failed to check MongoDB for transaction support", zap.Error(err))
+		}
+		if !supported {
+			logger.Fatal("EVENTS_PUBLISHER=outbox needs MongoDB running as a replica set; use redis or none with a standalone server")
+		}
+		publisher = events.NewOutboxPublisher(outboxRepo)
+	default:
+		logger.Fatal("Unknown EVENTS_PUBLISHER", zap.String("publisher", cfg.Events.Publisher))
+	}
+	// Live cart updates reach the streams open on every replica via Redis pub/sub
+	var updates realtime.Publisher = realtime.NewNoopPublisher()
+	var broker *realtime.RedisBroker
+	if cfg.Stream.Enabled {
+		broker = realtime.NewRedisBroker(redisClient, cfg.Stream.MaxPerUser, logger)
+		updates = broker
+	}
+	// Request paths are traced down to Redis, MongoDB and the catalog; the
+	// background jobs are not
+	cartSvc := tracing.TraceCartService(service.NewCartService(
+		tracing.TraceRedis(cacheRepo), tracing.TraceMongo(mongoRepo), sessionRepo,
+		tracing.TraceCatalog(catalogClient), promotions, publisher, updates, cfg, logger))
+	adminSvc := service.NewAdminService(cartSvc, adminCartRepo, auditRepo, cfg, logger)
 
 	// ============================================================
 	// Start Background Sync (Redis -> MongoDB)
 	// ============================================================
 	syncer := sync.NewCartSyncer(redisRepo, mongoRepo, cfg, logger)
+	if appMetrics != nil {
+		syncer.SetObserver(appMetrics)
+	}
 	syncCtx, syncCancel := context.WithCancel(context.Background())
 	go syncer.Start(syncCtx)
 
+	if appMetrics != nil {
+		countActive := func(ctx context.Context) (int64, error) {
+			return adminCartRepo.CountActive(ctx, time.Now().Add(-cfg.Abandoned.After))
+		}
+		go appMetrics.TrackActiveCarts(syncCtx, cfg.Metrics.ActiveCartsInterval, countActive, logger)
+	}
+
+	if broker != nil {
+		go broker.Start(syncCtx)
+	}
+
+	// Forward outbox events to the Redis Stream
+	if cfg.Events.Publisher == "outbox" {
+		relay := events.NewOutboxRelay(outboxRepo, streamPublisher, cfg, logger)
+		go relay.Start(syncCtx)
+	}
+
+	// Remind shoppers about carts they left behind
+	if cfg.Abandoned.Enabled {
+		var notifier notify.Notifier = notify.NewLogNotifier(logger)
+		if cfg.Abandoned.WebhookURL != "" {
+			notifier = notify.NewWebhookNotifier(cfg.Abandoned.WebhookURL, cfg.Abandoned.WebhookSecret, cfg.Abandoned.WebhookTimeout)
+		}
+		abandonedJob := sync.NewAbandonedCartJob(abandonedRepo, notifier, cfg, logger)
+		go abandonedJob.Start(syncCtx)
+	}
+
 	// ============================================================
 	// Initialize HTTP Handlers
 	// ============================================================
 	gin.SetMode(cfg.Server.GinMode)
 	router := gin.New()
+	// Without this gin believes X-Forwarded-For from anyone, and a guest could
+	// pick a fresh rate limit bucket per request
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		logger.Fatal("Invalid TRUSTED_PROXIES", zap.Error(err))
+	}
 	router.Use(gin.Recovery())
-	router.Use(middleware.CORSMiddleware())
+	if appMetrics != nil {
+		router.Use(appMetrics.Middleware())
+	}
+	router.Use(tracing.Middleware())
+	router.Use(middleware.RequestLogger(logger))
+	cors, err := middleware.CORSMiddleware(cfg.CORS)
+	if err != nil {
+		logger.Fatal("Invalid CORS configuration", zap.Error(err))
+	}
+	router.Use(cors)
 
 	// Health endpoints (no auth required)
 	healthH := handler.NewHealthHandler(redisRepo, mongoRepo, cfg.App.Name, cfg.App.Version)
 	healthH.RegisterRoutes(router)
+	if appMetrics != nil {
+		router.GET("/metrics", gin.WrapH(appMetrics.Handler()))
+	}
 
-	// API routes (JWT auth required)
+	// API routes (JWT auth, or a signed guest token for anonymous carts)
+	verifier, err := middleware.NewTokenVerifier(cfg, logger)
+	if err != nil {
+		logger.Fatal("Invalid JWT configuration", zap.Error(err))
+	}
+	if cfg.Guest.TokenSecret == "" {
+		// Defaults to JWT_SECRET, which asymmetric setups may leave empty
+		logger.Fatal("GUEST_TOKEN_SECRET is required when JWT_SECRET is not set")
+	}
+	// Rate limits run after authentication so logged-in users are limited by ID
+	limit := func(c *gin.Context) { c.Next() }
+	if cfg.RateLimit.Enabled {
+		policy, err := ratelimit.NewPolicy(cfg.RateLimit)
+		if err != nil {
+			logger.Fatal("Invalid rate limit configuration", zap.Error(err))
+		}
+		limiter := ratelimit.NewFallbackLimiter(ratelimit.NewRedisLimiter(redisClient), ratelimit.NewMemoryLimiter(), logger)
+		limit = middleware.RateLimitMiddleware(limiter, policy, logger)
+	}
+	guestTokens := middleware.NewGuestTokens(cfg.Guest.TokenSecret, cfg.Guest.CookieName, cfg.Guest.TTL, cfg.Guest.CookieSecure)
 	cartH := handler.NewCartHandler(cartSvc, logger)
-	api := router.Group("/api/v1", middleware.JWTAuthMiddleware(cfg.JWT.Secret))
+	idempotency := middleware.IdempotencyMiddleware(redisrepo.NewIdempotencyRedisRepository(redisClient), cfg.Idempotency, logger)
+	api := router.Group("/api/v1", middleware.CartIdentityMiddleware(verifier, guestTokens), limit, idempotency)
 	cartH.RegisterRoutes(api)
+	if broker != nil {
+		handler.NewStreamHandler(cartSvc, broker, cfg.Stream.Heartbeat, logger).RegisterRoutes(api)
+	}
+
+	// Support staff API: logged-in users holding the admin role only, never guests
+	adminH := handler.NewAdminHandler(adminSvc, logger)
+	adminAPI := router.Group("/api/v1", middleware.JWTAuthMiddleware(verifier), middleware.RequireRole(cfg.Admin.RoleClaim, cfg.Admin.Role), limit)
+	adminH.RegisterRoutes(adminAPI)
+
+	// Service API: calls payment-service makes for a shopper, which the shopper's own token must not reach
+	serviceAPI := router.Group("/api/v1", middleware.JWTAuthMiddleware(verifier), middleware.RequireRole(cfg.Admin.RoleClaim, cfg.Checkout.ServiceRole), limit)
+	cartH.RegisterServiceRoutes(serviceAPI)
 
 	// ============================================================
 	// Start HTTP Server
@@ -129,6 +285,10 @@ func main() {
 		ReadTimeout:  cfg.Server.ReadTimeout,
 		WriteTimeout: cfg.Server.WriteTimeout,
 	}
+	if broker != nil {
+		// Open streams would otherwise hold Shutdown until its deadline
+		srv.RegisterOnShutdown(broker.Close)
+	}
 
 	go func() {
 		logger.Info("HTTP server starting", zap.String("port", cfg.Server.Port))
@@ -154,6 +314,9 @@ func main() {
 		logger.Error("Server shutdown error", zap.Error(err))
 	}
 
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		logger.Error("Failed to flush traces", zap.Error(err))
+	}
 	mongoClient.Disconnect(shutdownCtx)
 	redisClient.Close()
 	logger.Info("Cart service stopped")
@@ -161,13 +324,17 @@ func main() {
 
 func buildLogger() *zap.Logger {
 	env := os.Getenv("APP_ENV")
+	cfg := zap.NewDevelopmentConfig()
 	if env == "prod" {
-		cfg := zap.NewProductionConfig()
+		cfg = zap.NewProductionConfig()
 		cfg.Level = zap.NewAtomicLevelAt(zapcore.WarnLevel)
-		l, _ := cfg.Build()
-		return l
 	}
-	cfg := zap.NewDevelopmentConfig()
+	// LOG_LEVEL=info in prod turns on the access log for successful requests
+	if name := os.Getenv("LOG_LEVEL"); name != "" {
+		if lvl, err := zapcore.ParseLevel(name); err == nil {
+			cfg.Level = zap.NewAtomicLevelAt(lvl)
+		}
+	}
 	l, _ := cfg.Build()
 	return l
 }
@@ -0,0 +1,48 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+
+	"github.com/emart/cart-service/internal/model"
+)
+
+var (
+	// ErrProductNotFound means the owning service does not know the product
+	ErrProductNotFound = errors.New("product not found")
+	// ErrProductInactive means the product exists but can no longer be sold
+	ErrProductInactive = errors.New("product is inactive")
+	// ErrCatalogUnavailable means the owning service could not be reached
+	ErrCatalogUnavailable = errors.New("catalog unavailable")
+)
+
+// Product is the authoritative view of a sellable item as owned by the
+// books, courses or software service.
+type Product struct {
+	ID       string
+	Category string
+	Name     string
+	Price    model.Money
+	ImageURL string
+	Stock    int
+	Active   bool
+}
+
+// Client resolves products from the service that owns their category.
+// The cart never trusts client-supplied names or prices; it asks the catalog.
+type Client interface {
+	GetProduct(ctx context.Context, category, productID string) (*Product, error)
+}
+
+type bearerTokenKey struct{}
+
+// WithBearerToken attaches the caller's JWT so catalog requests are made on
+// behalf of the same user (the catalog services require authentication).
+func WithBearerToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, bearerTokenKey{}, token)
+}
+
+func bearerTokenFrom(ctx context.Context) string {
+	token, _ := ctx.Value(bearerTokenKey{}).(string)
+	return token
+}
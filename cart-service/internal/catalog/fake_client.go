@@ -0,0 +1,41 @@
+package catalog
+
+import (
+	"context"
+	"sync"
+)
+
+// FakeClient is an in-memory Client for tests and local development
+type FakeClient struct {
+	mu       sync.RWMutex
+	products map[string]Product
+}
+
+func NewFakeClient(products ...Product) *FakeClient {
+	f := &FakeClient{products: make(map[string]Product)}
+	for _, p := range products {
+		f.Put(p)
+	}
+	return f
+}
+
+// Put adds or replaces a product
+func (f *FakeClient) Put(p Product) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.products[fakeKey(p.Category, p.ID)] = p
+}
+
+func (f *FakeClient) GetProduct(_ context.Context, category, productID string) (*Product, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	p, ok := f.products[fakeKey(category, productID)]
+	if !ok {
+		return nil, ErrProductNotFound
+	}
+	return &p, nil
+}
+
+func fakeKey(category, productID string) string {
+	return category + "/" + productID
+}
@@ -0,0 +1,148 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/emart/cart-service/internal/logging"
+	"github.com/emart/cart-service/internal/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Endpoints maps a cart category to the base URL of the service that owns it.
+// A category with an empty URL is treated as unavailable.
+type Endpoints struct {
+	Books    string
+	Courses  string
+	Software string
+}
+
+type httpClient struct {
+	endpoints    Endpoints
+	serviceToken string
+	client       *http.Client
+}
+
+// NewHTTPClient builds a Client that calls GET /api/v1/{books|courses|software}/:id.
+// serviceToken is used when the request context carries no user token.
+func NewHTTPClient(endpoints Endpoints, serviceToken string, timeout time.Duration) Client {
+	return &httpClient{
+		endpoints:    endpoints,
+		serviceToken: serviceToken,
+		client:       &http.Client{Timeout: timeout},
+	}
+}
+
+// productResponse mirrors the ApiResponse envelope shared by books-service and course-service
+type productResponse struct {
+	Success bool `json:"success"`
+	Data    struct {
+		ID       flexString `json:"id"`
+		Name     string     `json:"name"`
+		Cost     flexString `json:"cost"`
+		Stock    int        `json:"stock"`
+		ImageURL *string    `json:"image_url"`
+		IsActive bool       `json:"is_active"`
+	} `json:"data"`
+}
+
+func (c *httpClient) GetProduct(ctx context.Context, category, productID string) (*Product, error) {
+	endpoint, err := c.productURL(category, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("build catalog request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	// W3C traceparent, so the catalog service joins the caller's trace
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	if id := logging.RequestID(ctx); id != "" {
+		req.Header.Set("X-Request-ID", id)
+	}
+	if token := bearerTokenFrom(ctx); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else if c.serviceToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.serviceToken)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrCatalogUnavailable, category, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound, resp.StatusCode == http.StatusBadRequest:
+		// Both services answer 400 for malformed IDs and 404 for unknown or soft-deleted ones
+		return nil, ErrProductNotFound
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%w: %s returned %d", ErrCatalogUnavailable, category, resp.StatusCode)
+	}
+
+	var body productResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decode catalog response: %w", err)
+	}
+	if !body.Success {
+		return nil, ErrProductNotFound
+	}
+
+	price, err := model.ParseMoney(string(body.Data.Cost), model.DefaultCurrency)
+	if err != nil {
+		return nil, fmt.Errorf("parse price %q: %w", body.Data.Cost, err)
+	}
+	product := &Product{
+		ID:       string(body.Data.ID),
+		Category: category,
+		Name:     body.Data.Name,
+		Price:    price,
+		Stock:    body.Data.Stock,
+		Active:   body.Data.IsActive,
+	}
+	if body.Data.ImageURL != nil {
+		product.ImageURL = *body.Data.ImageURL
+	}
+	return product, nil
+}
+
+func (c *httpClient) productURL(category, productID string) (string, error) {
+	var base, resource string
+	switch category {
+	case "books":
+		base, resource = c.endpoints.Books, "books"
+	case "courses":
+		base, resource = c.endpoints.Courses, "courses"
+	case "software":
+		base, resource = c.endpoints.Software, "software"
+	}
+	if base == "" {
+		return "", fmt.Errorf("%w: no catalog configured for category %q", ErrCatalogUnavailable, category)
+	}
+	return fmt.Sprintf("%s/api/v1/%s/%s", strings.TrimRight(base, "/"), resource, url.PathEscape(productID)), nil
+}
+
+// flexString accepts both JSON strings and numbers. PostgreSQL DECIMAL and
+// BIGSERIAL columns arrive as strings from node-postgres but as numbers elsewhere.
+type flexString string
+
+func (f *flexString) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*f = flexString(s)
+		return nil
+	}
+	*f = flexString(data)
+	return nil
+}
@@ -1,8 +1,11 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -13,6 +16,20 @@ type Config struct {
 	MongoDB  MongoDBConfig
 	JWT      JWTConfig
 	Sync     SyncConfig
+	Catalog  CatalogConfig
+	Guest    GuestConfig
+	Events   EventsConfig
+	Abandoned AbandonedConfig
+	Checkout CheckoutConfig
+	Promotion PromotionConfig
+	Migration MigrationConfig
+	Admin    AdminConfig
+	Metrics  MetricsConfig
+	Tracing  TracingConfig
+	CORS     CORSConfig
+	RateLimit RateLimitConfig
+	Idempotency IdempotencyConfig
+	Stream   StreamConfig
 	App      AppConfig
 }
 
@@ -21,6 +38,9 @@ type ServerConfig struct {
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
 	GinMode      string
+	// Peers (IPs or CIDRs) whose X-Forwarded-For is believed; nil = none, the
+	// TCP peer is the client. ClientIP keys the guest rate limit.
+	TrustedProxies []string
 }
 
 type RedisConfig struct {
@@ -37,12 +57,116 @@ type MongoDBConfig struct {
 }
 
 type JWTConfig struct {
-	Secret string
+	Secret        string        // HMAC key (HS256/384/512)
+	Algorithm     string        // HS256 | RS256 | ES256
+	PublicKeyFile string        // PEM public key for RS256/ES256
+	JWKSURL       string        // JWKS endpoint for RS256/ES256; takes precedence over PublicKeyFile
+	JWKSRefresh   time.Duration // How often the JWKS is re-fetched
+	Issuer        string        // Expected iss claim (empty = not checked)
+	Audience      string        // Expected aud claim (empty = not checked)
+	Leeway        time.Duration // Clock skew tolerated on exp/nbf/iat
 }
 
 type SyncConfig struct {
-	Interval      time.Duration // How often to sync Redis -> MongoDB
-	BatchSize     int           // How many carts to sync at once
+	Interval         time.Duration // How often to drain dirty carts Redis -> MongoDB
+	BatchSize        int           // How many carts to sync at once
+	FullSyncInterval time.Duration // How often to SCAN every cart for reconciliation (0 = never)
+}
+
+type CatalogConfig struct {
+	BooksURL     string        // Books service base URL
+	CoursesURL   string        // Course service base URL
+	SoftwareURL  string        // Software service base URL (empty = not deployed)
+	ServiceToken string        // Bearer token used when no user token is available
+	Timeout      time.Duration
+}
+
+type GuestConfig struct {
+	TokenSecret     string        // HMAC key for guest tokens (defaults to JWT secret)
+	TTL             time.Duration // Guest cart TTL in Redis and MongoDB (default 2 days)
+	CookieName      string
+	CookieSecure    bool
+	MergeStrategy   string // Default conflict rule on merge: sum | newest
+	MaxItemQuantity int    // Quantity cap applied to merged lines
+}
+
+type EventsConfig struct {
+	Publisher      string        // redis (default) | outbox (needs a replica set) | none
+	StreamName     string        // Redis Stream that receives cart events
+	StreamMaxLen   int64         // Approximate stream cap (0 = unbounded)
+	RelayInterval  time.Duration // How often the outbox relay polls MongoDB
+	RelayBatchSize int
+	RelayLeaseTTL  time.Duration // How long a replica stays the relay without renewing
+}
+
+type AbandonedConfig struct {
+	Enabled        bool
+	After          time.Duration // Carts untouched this long count as abandoned
+	Interval       time.Duration // How often the detection job runs
+	BatchSize      int
+	MaxAttempts    int           // Failed reminders per cart before the job gives up on it
+	WebhookURL     string        // notification-service base URL (empty = log only)
+	WebhookSecret  string        // X-Service-Secret shared with notification-service
+	WebhookTimeout time.Duration
+}
+
+type CheckoutConfig struct {
+	SessionTTL  time.Duration // How long a checkout session holds the cart
+	ServiceRole string        // Role required to complete a session (held by payment-service, never by shoppers)
+}
+
+type PromotionConfig struct {
+	MaxCouponsPerCart int // Coupons a cart can hold at once (0 = unlimited)
+}
+
+type MigrationConfig struct {
+	AllowDrift        bool          // Start even if an executed migration's checksum changed (logs a warning)
+	LockTTL           time.Duration // Lock expiry, extended by a heartbeat while migrations run
+	LockWait          time.Duration // How long to wait for another instance's lock before failing
+	LockRetryInterval time.Duration
+}
+
+type AdminConfig struct {
+	RoleClaim string // JWT claim holding the caller's roles (string or array)
+	Role      string // Role required for /api/v1/admin
+}
+
+type MetricsConfig struct {
+	Enabled             bool          // Serve Prometheus metrics on /metrics
+	ActiveCartsInterval time.Duration // How often the active carts gauge is recounted
+}
+
+type TracingConfig struct {
+	Exporter     string  // otlp | stdout | none
+	OTLPEndpoint string  // OTLP/HTTP collector host:port
+	OTLPInsecure bool    // Send to the collector over plain HTTP
+	SampleRatio  float64 // Share of new traces sampled; incoming traceparent decisions are kept
+}
+
+type CORSConfig struct {
+	AllowedOrigins   []string      // Exact origins or wildcard subdomains ("https://*.emart.com"); "*" = any
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string      // Response headers browser scripts may read
+	AllowCredentials bool          // Allow cookies and Authorization on cross-origin requests; not with "*"
+	MaxAge           time.Duration // How long browsers may cache a preflight (0 = not sent)
+}
+
+type RateLimitConfig struct {
+	Enabled bool
+	Default string            // "<requests>/<period>[:burst]" for routes without their own rule; "off" = unlimited
+	Routes  map[string]string // "METHOD /route/template" -> rule, with buckets of their own
+}
+
+type IdempotencyConfig struct {
+	TTL     time.Duration // How long a response is kept for replay
+	LockTTL time.Duration // How long a key stays reserved if its request never finishes
+}
+
+type StreamConfig struct {
+	Enabled    bool
+	Heartbeat  time.Duration // Comment line sent on idle streams; keep it under the proxy read timeout
+	MaxPerUser int           // Open streams per cart on one replica; more are refused with 429
 }
 
 type AppConfig struct {
@@ -51,19 +175,24 @@ type AppConfig struct {
 	Env     string
 }
 
-// Load reads configuration from environment variables
-func Load() *Config {
-	return &Config{
+// Load reads configuration from environment variables and rejects settings
+// the service cannot run with
+func Load() (*Config, error) {
+	jwtSecret := getEnv("JWT_SECRET", "")
+	appEnv := getEnv("APP_ENV", "dev")
+	cfg := &Config{
 		App: AppConfig{
 			Name:    getEnv("APP_NAME", "emart-cart-service"),
 			Version: getEnv("APP_VERSION", "1.0.0"),
-			Env:     getEnv("APP_ENV", "dev"),
+			Env:     appEnv,
 		},
 		Server: ServerConfig{
 			Port:         getEnv("SERVER_PORT", "8081"),
 			ReadTimeout:  getDurationEnv("SERVER_READ_TIMEOUT", 15*time.Second),
 			WriteTimeout: getDurationEnv("SERVER_WRITE_TIMEOUT", 15*time.Second),
 			GinMode:      getEnv("GIN_MODE", "debug"),
+			// nginx runs on the same host
+			TrustedProxies: trustedProxies(getListEnv("TRUSTED_PROXIES", []string{"127.0.0.1", "::1"})),
 		},
 		Redis: RedisConfig{
 			Addr:     getEnv("REDIS_ADDR", "localhost:6379"),
@@ -77,13 +206,147 @@ func Load() *Config {
 			Timeout:  getDurationEnv("MONGO_TIMEOUT", 10*time.Second),
 		},
 		JWT: JWTConfig{
-			Secret: getEnv("JWT_SECRET", ""),
+			Secret:        jwtSecret,
+			Algorithm:     getEnv("JWT_ALGORITHM", "HS256"),
+			PublicKeyFile: getEnv("JWT_PUBLIC_KEY_FILE", ""),
+			JWKSURL:       getEnv("JWT_JWKS_URL", ""),
+			JWKSRefresh:   getDurationEnv("JWT_JWKS_REFRESH", 10*time.Minute),
+			Issuer:        getEnv("JWT_ISSUER", ""),
+			Audience:      getEnv("JWT_AUDIENCE", ""),
+			Leeway:        getDurationEnv("JWT_LEEWAY", 30*time.Second),
+		},
+		Guest: GuestConfig{
+			TokenSecret:     getEnv("GUEST_TOKEN_SECRET", jwtSecret),
+			TTL:             getDurationEnv("GUEST_CART_TTL", 48*time.Hour),
+			CookieName:      getEnv("GUEST_COOKIE_NAME", "emart_guest"),
+			CookieSecure:    getBoolEnv("GUEST_COOKIE_SECURE", true),
+			MergeStrategy:   getEnv("GUEST_MERGE_STRATEGY", "sum"),
+			MaxItemQuantity: getIntEnv("CART_MAX_ITEM_QUANTITY", 100),
+		},
+		Catalog: CatalogConfig{
+			BooksURL:     getEnv("CATALOG_BOOKS_URL", "http://localhost:8082"),
+			CoursesURL:   getEnv("CATALOG_COURSES_URL", "http://localhost:8083"),
+			SoftwareURL:  getEnv("CATALOG_SOFTWARE_URL", ""),
+			ServiceToken: getEnv("CATALOG_SERVICE_TOKEN", ""),
+			Timeout:      getDurationEnv("CATALOG_TIMEOUT", 3*time.Second),
+		},
+		Events: EventsConfig{
+			Publisher:      getEnv("EVENTS_PUBLISHER", "redis"),
+			StreamName:     getEnv("EVENTS_STREAM", "cart-events"),
+			StreamMaxLen:   int64(getIntEnv("EVENTS_STREAM_MAXLEN", 100000)),
+			RelayInterval:  getDurationEnv("EVENTS_RELAY_INTERVAL", time.Second),
+			RelayBatchSize: getIntEnv("EVENTS_RELAY_BATCH_SIZE", 100),
+			RelayLeaseTTL:  getDurationEnv("EVENTS_RELAY_LEASE_TTL", 30*time.Second),
+		},
+		Abandoned: AbandonedConfig{
+			Enabled:        getBoolEnv("ABANDONED_CART_ENABLED", true),
+			After:          getDurationEnv("ABANDONED_CART_AFTER", 24*time.Hour),
+			Interval:       getDurationEnv("ABANDONED_CART_INTERVAL", 15*time.Minute),
+			BatchSize:      getIntEnv("ABANDONED_CART_BATCH_SIZE", 100),
+			MaxAttempts:    getIntEnv("ABANDONED_CART_MAX_ATTEMPTS", 5),
+			WebhookURL:     getEnv("NOTIFICATION_SERVICE_URL", ""),
+			WebhookSecret:  getEnv("NOTIFICATION_SERVICE_SECRET", ""),
+			WebhookTimeout: getDurationEnv("NOTIFICATION_TIMEOUT", 5*time.Second),
+		},
+		Checkout: CheckoutConfig{
+			SessionTTL:  getDurationEnv("CHECKOUT_SESSION_TTL", 15*time.Minute),
+			ServiceRole: getEnv("CHECKOUT_SERVICE_ROLE", "ROLE_SERVICE"),
+		},
+		Promotion: PromotionConfig{
+			MaxCouponsPerCart: getIntEnv("PROMOTION_MAX_COUPONS_PER_CART", 2),
+		},
+		Migration: MigrationConfig{
+			AllowDrift:        getBoolEnv("MIGRATION_ALLOW_DRIFT", false),
+			LockTTL:           getDurationEnv("MIGRATION_LOCK_TTL", time.Minute),
+			LockWait:          getDurationEnv("MIGRATION_LOCK_WAIT", 2*time.Minute),
+			LockRetryInterval: getDurationEnv("MIGRATION_LOCK_RETRY", 5*time.Second),
+		},
+		Admin: AdminConfig{
+			RoleClaim: getEnv("ADMIN_ROLE_CLAIM", "roles"),
+			Role:      getEnv("ADMIN_ROLE", "ROLE_ADMIN"),
+		},
+		Metrics: MetricsConfig{
+			Enabled:             getBoolEnv("METRICS_ENABLED", true),
+			ActiveCartsInterval: getDurationEnv("METRICS_ACTIVE_CARTS_INTERVAL", time.Minute),
+		},
+		Tracing: TracingConfig{
+			Exporter:     getEnv("TRACING_EXPORTER", "none"),
+			OTLPEndpoint: getEnv("TRACING_OTLP_ENDPOINT", "localhost:4318"),
+			OTLPInsecure: getBoolEnv("TRACING_OTLP_INSECURE", true),
+			SampleRatio:  getFloatEnv("TRACING_SAMPLE_RATIO", 1.0),
+		},
+		CORS: CORSConfig{
+			AllowedOrigins:   getListEnv("CORS_ALLOWED_ORIGINS", defaultCORSOrigins(appEnv)),
+			AllowedMethods:   getListEnv("CORS_ALLOWED_METHODS", []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}),
+			AllowedHeaders:   getListEnv("CORS_ALLOWED_HEADERS", []string{"Authorization", "Content-Type", "X-Requested-With", "X-Guest-Token", "X-Request-ID", "Idempotency-Key", "If-Match", "If-None-Match"}),
+			ExposedHeaders:   getListEnv("CORS_EXPOSED_HEADERS", []string{"X-Guest-Token", "X-Request-ID", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After", "Idempotent-Replayed", "ETag"}),
+			AllowCredentials: getBoolEnv("CORS_ALLOW_CREDENTIALS", true),
+			MaxAge:           getDurationEnv("CORS_MAX_AGE", 10*time.Minute),
+		},
+		RateLimit: RateLimitConfig{
+			Enabled: getBoolEnv("RATE_LIMIT_ENABLED", true),
+			Default: getEnv("RATE_LIMIT_DEFAULT", "300/1m:60"),
+			Routes: getMapEnv("RATE_LIMIT_ROUTES", map[string]string{
+				"POST /api/v1/cart/items":            "60/1m:10",
+				"POST /api/v1/cart/merge":            "10/1m",
+				"POST /api/v1/cart/coupons":          "10/1m",
+				"POST /api/v1/cart/checkout-session": "10/1m",
+			}),
+		},
+		Idempotency: IdempotencyConfig{
+			TTL:     getDurationEnv("IDEMPOTENCY_TTL", 24*time.Hour),
+			LockTTL: getDurationEnv("IDEMPOTENCY_LOCK_TTL", time.Minute),
+		},
+		Stream: StreamConfig{
+			Enabled:    getBoolEnv("STREAM_ENABLED", true),
+			Heartbeat:  getDurationEnv("STREAM_HEARTBEAT", 15*time.Second),
+			MaxPerUser: getIntEnv("STREAM_MAX_PER_USER", 10),
 		},
 		Sync: SyncConfig{
-			Interval:  getDurationEnv("SYNC_INTERVAL", 30*time.Second),
-			BatchSize: getIntEnv("SYNC_BATCH_SIZE", 100),
+			Interval:         getDurationEnv("SYNC_INTERVAL", 30*time.Second),
+			BatchSize:        getIntEnv("SYNC_BATCH_SIZE", 100),
+			FullSyncInterval: getDurationEnv("SYNC_FULL_INTERVAL", time.Hour),
 		},
 	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// validate rejects values that would only fail later, such as a non-positive
+// interval, which makes time.NewTicker panic
+func (c *Config) validate() error {
+	var errs []error
+	positive := func(key string, d time.Duration) {
+		if d <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive, got %s", key, d))
+		}
+	}
+	positive("EVENTS_RELAY_INTERVAL", c.Events.RelayInterval)
+	positive("ABANDONED_CART_INTERVAL", c.Abandoned.Interval)
+	positive("STREAM_HEARTBEAT", c.Stream.Heartbeat)
+	return errors.Join(errs...)
+}
+
+// defaultCORSOrigins allows the React dev server outside production. In
+// production the frontend is served by nginx from the same origin as the API,
+// so no cross-origin caller is trusted unless CORS_ALLOWED_ORIGINS names it.
+func defaultCORSOrigins(env string) []string {
+	switch env {
+	case "prod", "production", "staging":
+		return nil
+	default:
+		return []string{"http://localhost:3000", "http://127.0.0.1:3000"}
+	}
+}
+
+// trustedProxies maps TRUSTED_PROXIES=none, for a service reached directly, to nil
+func trustedProxies(list []string) []string {
+	if len(list) == 1 && strings.EqualFold(list[0], "none") {
+		return nil
+	}
+	return list
 }
 
 func getEnv(key, fallback string) string {
@@ -102,6 +365,55 @@ func getIntEnv(key string, fallback int) int {
 	return fallback
 }
 
+func getBoolEnv(key string, fallback bool) bool {
+	if val := os.Getenv(key); val != "" {
+		if b, err := strconv.ParseBool(val); err == nil {
+			return b
+		}
+	}
+	return fallback
+}
+
+func getFloatEnv(key string, fallback float64) float64 {
+	if val := os.Getenv(key); val != "" {
+		if f, err := strconv.ParseFloat(val, 64); err == nil {
+			return f
+		}
+	}
+	return fallback
+}
+
+// getListEnv reads a comma-separated list; empty entries are dropped
+func getListEnv(key string, fallback []string) []string {
+	val := os.Getenv(key)
+	if val == "" {
+		return fallback
+	}
+	var list []string
+	for _, item := range strings.Split(val, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getMapEnv reads "key=value" pairs separated by semicolons
+func getMapEnv(key string, fallback map[string]string) map[string]string {
+	val := os.Getenv(key)
+	if val == "" {
+		return fallback
+	}
+	m := make(map[string]string)
+	for _, pair := range strings.Split(val, ";") {
+		k, v, found := strings.Cut(pair, "=")
+		if k = strings.TrimSpace(k); found && k != "" {
+			m[k] = strings.TrimSpace(v)
+		}
+	}
+	return m
+}
+
 func getDurationEnv(key string, fallback time.Duration) time.Duration {
 	if val := os.Getenv(key); val != "" {
 		if d, err := time.ParseDuration(val); err == nil {
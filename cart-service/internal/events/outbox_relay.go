@@ -0,0 +1,143 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/emart/cart-service/internal/config"
+	mongorepo "github.com/emart/cart-service/internal/repository/mongo"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// OutboxRelay periodically forwards PENDING outbox events to a publisher
+// (normally the Redis Stream). Events are delivered in order; on failure the
+// batch stops and the event is retried on the next tick, so delivery is
+// at-least-once. Every replica runs a relay, but only the one holding the
+// outbox lease delivers, which keeps the order across replicas.
+type OutboxRelay struct {
+	outbox    mongorepo.OutboxMongoRepository
+	publisher EventPublisher
+	owner     string
+	cfg       *config.Config
+	logger    *zap.Logger
+}
+
+func NewOutboxRelay(
+	outbox mongorepo.OutboxMongoRepository,
+	publisher EventPublisher,
+	cfg *config.Config,
+	logger *zap.Logger,
+) *OutboxRelay {
+	return &OutboxRelay{
+		outbox:    outbox,
+		publisher: publisher,
+		owner:     relayOwner(),
+		cfg:       cfg,
+		logger:    logger,
+	}
+}
+
+// Start launches the relay loop; it returns when ctx is cancelled
+func (r *OutboxRelay) Start(ctx context.Context) {
+	r.logger.Info("Outbox relay started",
+		zap.Duration("interval", r.cfg.Events.RelayInterval),
+		zap.Int("batchSize", r.cfg.Events.RelayBatchSize),
+	)
+
+	ticker := time.NewTicker(r.cfg.Events.RelayInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Let another replica take over without waiting for the lease to expire
+			if err := r.outbox.ReleaseLease(context.WithoutCancel(ctx), r.owner); err != nil {
+				r.logger.Warn("Failed to release outbox lease", zap.Error(err))
+			}
+			r.logger.Info("Outbox relay stopped")
+			return
+		case <-ticker.C:
+			r.relay(ctx)
+		}
+	}
+}
+
+// relay drains the outbox until it is empty or a delivery fails. The lease is
+// renewed before every batch; without it this replica leaves the outbox alone.
+func (r *OutboxRelay) relay(ctx context.Context) {
+	published := 0
+	for ctx.Err() == nil {
+		held, err := r.outbox.AcquireLease(ctx, r.owner, r.leaseTTL())
+		if err != nil {
+			r.logger.Error("Failed to acquire outbox lease", zap.Error(err))
+			break
+		}
+		if !held {
+			break
+		}
+
+		pending, err := r.outbox.FetchPending(ctx, r.batchSize())
+		if err != nil {
+			r.logger.Error("Failed to fetch pending outbox events", zap.Error(err))
+			break
+		}
+		if len(pending) == 0 {
+			break
+		}
+
+		ids := make([]string, 0, len(pending))
+		var failed bool
+		for _, e := range pending {
+			if err := r.publisher.Publish(ctx, e); err != nil {
+				r.logger.Warn("Failed to relay outbox event",
+					zap.String("eventID", e.ID), zap.String("type", string(e.Type)), zap.Error(err))
+				if markErr := r.outbox.MarkFailed(ctx, e.ID, err); markErr != nil {
+					r.logger.Error("Failed to record outbox failure", zap.String("eventID", e.ID), zap.Error(markErr))
+				}
+				failed = true
+				break
+			}
+			ids = append(ids, e.ID)
+		}
+
+		if err := r.outbox.MarkPublished(ctx, ids...); err != nil {
+			// Already delivered; they will be delivered again next tick
+			r.logger.Error("Failed to mark outbox events published", zap.Error(err))
+			break
+		}
+		published += len(ids)
+		if failed || len(pending) < r.batchSize() {
+			break
+		}
+	}
+
+	if published > 0 {
+		r.logger.Info("Outbox events relayed", zap.Int("published", published))
+	}
+}
+
+func (r *OutboxRelay) leaseTTL() time.Duration {
+	if r.cfg.Events.RelayLeaseTTL <= 0 {
+		return 30 * time.Second
+	}
+	return r.cfg.Events.RelayLeaseTTL
+}
+
+// relayOwner names this process uniquely: hostname:pid:uuid
+func relayOwner() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s:%d:%s", host, os.Getpid(), uuid.NewString())
+}
+
+func (r *OutboxRelay) batchSize() int {
+	if r.cfg.Events.RelayBatchSize <= 0 {
+		return 100
+	}
+	return r.cfg.Events.RelayBatchSize
+}
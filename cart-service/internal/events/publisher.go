@@ -0,0 +1,49 @@
+package events
+
+import (
+	"context"
+
+	"github.com/emart/cart-service/internal/model"
+	mongorepo "github.com/emart/cart-service/internal/repository/mongo"
+)
+
+// EventPublisher delivers cart domain events to downstream services
+type EventPublisher interface {
+	Publish(ctx context.Context, events ...model.CartEvent) error
+}
+
+// Transactional is implemented by publishers whose Publish is a MongoDB write
+// that joins the cart write transaction. Any other publisher talks to Redis and
+// is called only after the cart write has committed.
+type Transactional interface {
+	Transactional() bool
+}
+
+// IsTransactional reports whether p publishes inside the cart write transaction
+func IsTransactional(p EventPublisher) bool {
+	t, ok := p.(Transactional)
+	return ok && t.Transactional()
+}
+
+// noopPublisher drops every event (EVENTS_PUBLISHER=none)
+type noopPublisher struct{}
+
+func NewNoopPublisher() EventPublisher { return noopPublisher{} }
+
+func (noopPublisher) Publish(ctx context.Context, events ...model.CartEvent) error { return nil }
+
+// outboxPublisher writes events to the Mongo outbox; OutboxRelay forwards them.
+// Called inside the cart write transaction, events survive a Redis outage.
+type outboxPublisher struct {
+	outbox mongorepo.OutboxMongoRepository
+}
+
+func NewOutboxPublisher(outbox mongorepo.OutboxMongoRepository) EventPublisher {
+	return &outboxPublisher{outbox: outbox}
+}
+
+func (p *outboxPublisher) Transactional() bool { return true }
+
+func (p *outboxPublisher) Publish(ctx context.Context, events ...model.CartEvent) error {
+	return p.outbox.Enqueue(ctx, events...)
+}
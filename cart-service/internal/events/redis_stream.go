@@ -0,0 +1,52 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/emart/cart-service/internal/model"
+	"github.com/redis/go-redis/v9"
+)
+
+// redisStreamPublisher appends events to a Redis Stream. Each entry carries the
+// event ID, type and user for cheap filtering plus the full event as JSON payload.
+type redisStreamPublisher struct {
+	client *redis.Client
+	stream string
+	maxLen int64
+}
+
+// NewRedisStreamPublisher publishes to stream, approximately trimmed to maxLen entries (0 = no trimming)
+func NewRedisStreamPublisher(client *redis.Client, stream string, maxLen int64) EventPublisher {
+	return &redisStreamPublisher{client: client, stream: stream, maxLen: maxLen}
+}
+
+func (p *redisStreamPublisher) Publish(ctx context.Context, events ...model.CartEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	pipe := p.client.Pipeline()
+	for _, e := range events {
+		payload, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("marshal event %s: %w", e.ID, err)
+		}
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: p.stream,
+			MaxLen: p.maxLen,
+			Approx: p.maxLen > 0,
+			Values: map[string]interface{}{
+				"event_id": e.ID,
+				"type":     string(e.Type),
+				"user_id":  e.UserID,
+				"payload":  payload,
+			},
+		})
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("redis xadd %s: %w", p.stream, err)
+	}
+	return nil
+}
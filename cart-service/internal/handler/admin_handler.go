@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/emart/cart-service/internal/logging"
+	"github.com/emart/cart-service/internal/model"
+	"github.com/emart/cart-service/internal/service"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// AdminHandler serves the support staff API. Routes must be mounted behind
+// JWTAuthMiddleware and RequireRole.
+type AdminHandler struct {
+	adminService service.AdminService
+	logger       *zap.Logger
+}
+
+func NewAdminHandler(adminService service.AdminService, logger *zap.Logger) *AdminHandler {
+	return &AdminHandler{adminService: adminService, logger: logger}
+}
+
+// RegisterRoutes sets up admin cart routes
+func (h *AdminHandler) RegisterRoutes(router *gin.RouterGroup) {
+	carts := router.Group("/admin/carts")
+	{
+		carts.GET("", h.ListCarts)
+		carts.GET("/:userId", h.GetCart)
+		carts.GET("/:userId/audit", h.GetAuditLog)
+		carts.PUT("/:userId/items/:itemId", h.UpdateItemQuantity)
+		carts.DELETE("/:userId/items/:itemId", h.RemoveItem)
+		carts.DELETE("/:userId", h.ClearCart)
+	}
+}
+
+// ListCarts pages through carts, filtered by status (active | abandoned) and update time
+func (h *AdminHandler) ListCarts(c *gin.Context) {
+	var filter model.AdminCartFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse("Invalid query: "+err.Error()))
+		return
+	}
+	page, err := h.adminService.ListCarts(c.Request.Context(), actorOf(c), filter)
+	if errors.Is(err, model.ErrInvalidAdminFilter) {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse(err.Error()))
+		return
+	}
+	if err != nil {
+		h.log(c).Error("Admin ListCarts failed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse("Failed to list carts"))
+		return
+	}
+	c.JSON(http.StatusOK, model.SuccessResponse(page, "Carts retrieved"))
+}
+
+// GetCart returns any customer's cart
+func (h *AdminHandler) GetCart(c *gin.Context) {
+	userID := c.Param("userId")
+	cart, err := h.adminService.GetCart(c.Request.Context(), actorOf(c), userID)
+	if err != nil {
+		h.log(c).Error("Admin GetCart failed", zap.String("userID", userID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse("Failed to retrieve cart"))
+		return
+	}
+	c.JSON(http.StatusOK, model.SuccessResponse(cart, "Cart retrieved successfully"))
+}
+
+// GetAuditLog lists the latest admin actions on a customer's cart
+func (h *AdminHandler) GetAuditLog(c *gin.Context) {
+	userID := c.Param("userId")
+	entries, err := h.adminService.AuditLog(c.Request.Context(), actorOf(c), userID)
+	if err != nil {
+		h.log(c).Error("Admin GetAuditLog failed", zap.String("userID", userID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse("Failed to retrieve audit log"))
+		return
+	}
+	c.JSON(http.StatusOK, model.SuccessResponse(entries, "Audit log retrieved"))
+}
+
+// UpdateItemQuantity sets the quantity of a line in a customer's cart (0 = remove)
+func (h *AdminHandler) UpdateItemQuantity(c *gin.Context) {
+	userID := c.Param("userId")
+	var req model.UpdateQuantityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse("Invalid request"))
+		return
+	}
+
+	cart, err := h.adminService.UpdateItemQuantity(c.Request.Context(), actorOf(c), userID, c.Param("itemId"), req.Quantity)
+	if err != nil {
+		h.respondCartError(c, userID, err)
+		return
+	}
+	c.JSON(http.StatusOK, model.SuccessResponse(cart, "Cart updated"))
+}
+
+// RemoveItem removes a line from a customer's cart
+func (h *AdminHandler) RemoveItem(c *gin.Context) {
+	userID := c.Param("userId")
+	cart, err := h.adminService.RemoveItem(c.Request.Context(), actorOf(c), userID, c.Param("itemId"))
+	if err != nil {
+		h.respondCartError(c, userID, err)
+		return
+	}
+	c.JSON(http.StatusOK, model.SuccessResponse(cart, "Item removed from cart"))
+}
+
+// ClearCart empties a customer's cart
+func (h *AdminHandler) ClearCart(c *gin.Context) {
+	userID := c.Param("userId")
+	err := h.adminService.ClearCart(c.Request.Context(), actorOf(c), userID)
+	if errors.Is(err, model.ErrCartLocked) {
+		h.respondCartError(c, userID, err)
+		return
+	}
+	if err != nil {
+		h.log(c).Error("Admin ClearCart failed", zap.String("userID", userID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse("Failed to clear cart"))
+		return
+	}
+	c.JSON(http.StatusOK, model.SuccessResponse(nil, "Cart cleared successfully"))
+}
+
+// respondCartError maps errors from admin cart edits the way the shopper API does
+func (h *AdminHandler) respondCartError(c *gin.Context, userID string, err error) {
+	switch {
+	case errors.Is(err, model.ErrVersionConflict):
+		h.log(c).Warn("Admin cart mutation conflicted", zap.String("userID", userID), zap.Error(err))
+		c.JSON(http.StatusConflict, model.ErrorResponse("Cart was modified concurrently, please retry"))
+	case errors.Is(err, model.ErrCartLocked):
+		c.JSON(http.StatusLocked, model.ErrorResponse("Cart is locked for checkout"))
+	default:
+		c.JSON(http.StatusBadRequest, model.ErrorResponse(err.Error()))
+	}
+}
+
+// log returns the request-scoped logger, or the handler logger outside RequestLogger
+func (h *AdminHandler) log(c *gin.Context) *zap.Logger {
+	return logging.FromContext(c.Request.Context(), h.logger)
+}
+
+// actorOf identifies the staff member from the claims set by JWTAuthMiddleware
+func actorOf(c *gin.Context) model.Actor {
+	return model.Actor{UserID: c.GetString("user_id"), Email: c.GetString("email"), IP: c.ClientIP()}
+}
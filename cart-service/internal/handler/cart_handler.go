@@ -1,9 +1,17 @@
 package handler
 
 import (
+	"context"
+	"errors"
+	"io"
 	"net/http"
+	"slices"
+	"strings"
 
+	"github.com/emart/cart-service/internal/catalog"
+	"github.com/emart/cart-service/internal/logging"
 	"github.com/emart/cart-service/internal/model"
+	"github.com/emart/cart-service/internal/promotion"
 	"github.com/emart/cart-service/internal/service"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -28,9 +36,22 @@ func (h *CartHandler) RegisterRoutes(router *gin.RouterGroup) {
 		cart.PUT("/items/:itemId", h.UpdateItemQuantity)
 		cart.DELETE("/items/:itemId", h.RemoveItem)
 		cart.DELETE("",            h.ClearCart)
+		cart.POST("/merge",        h.MergeCart)
+		cart.POST("/coupons",      h.ApplyCoupon)
+		cart.DELETE("/coupons/:code", h.RemoveCoupon)
+		cart.POST("/checkout",     h.CheckoutCart)
+		cart.POST("/checkout-session", h.CreateCheckoutSession)
+		cart.GET("/checkout-session/:sessionId", h.GetCheckoutSession)
+		cart.POST("/checkout-session/:sessionId/cancel", h.CancelCheckoutSession)
 	}
 }
 
+// RegisterServiceRoutes sets up the routes payment-service calls; the group
+// must admit only its service role
+func (h *CartHandler) RegisterServiceRoutes(router *gin.RouterGroup) {
+	router.POST("/cart/checkout-session/:sessionId/complete", h.CompleteCheckoutSession)
+}
+
 // GetCart godoc
 // @Summary Get full cart for authenticated user
 // @Tags cart
@@ -42,10 +63,13 @@ func (h *CartHandler) GetCart(c *gin.Context) {
 	userID := c.GetString("user_id")
 	cart, err := h.cartService.GetCart(c.Request.Context(), userID)
 	if err != nil {
-		h.logger.Error("GetCart failed", zap.String("userID", userID), zap.Error(err))
+		h.log(c).Error("GetCart failed", zap.String("userID", userID), zap.Error(err))
 		c.JSON(http.StatusInternalServerError, model.ErrorResponse("Failed to retrieve cart"))
 		return
 	}
+	if h.notModified(c, cart.ETag()) {
+		return
+	}
 	c.JSON(http.StatusOK, model.SuccessResponse(cart, "Cart retrieved successfully"))
 }
 
@@ -54,9 +78,13 @@ func (h *CartHandler) GetCartSummary(c *gin.Context) {
 	userID := c.GetString("user_id")
 	summary, err := h.cartService.GetCartSummary(c.Request.Context(), userID)
 	if err != nil {
+		h.log(c).Error("GetCartSummary failed", zap.String("userID", userID), zap.Error(err))
 		c.JSON(http.StatusInternalServerError, model.ErrorResponse("Failed to retrieve cart summary"))
 		return
 	}
+	if h.notModified(c, summary.ETag) {
+		return
+	}
 	c.JSON(http.StatusOK, model.SuccessResponse(summary, "Cart summary retrieved"))
 }
 
@@ -69,12 +97,26 @@ func (h *CartHandler) AddItem(c *gin.Context) {
 		return
 	}
 
-	cart, err := h.cartService.AddItem(c.Request.Context(), userID, &req)
+	// Catalog lookups are made on behalf of the caller
+	ctx := catalog.WithBearerToken(c.Request.Context(), c.GetString("token"))
+	ctx = service.WithShopperEmail(ctx, c.GetString("email"))
+	cart, err := h.cartService.AddItem(ctx, userID, &req)
+	switch {
+	case errors.Is(err, model.ErrVersionConflict):
+		h.respondConflict(c, userID, err)
+		return
+	case errors.Is(err, model.ErrCartLocked):
+		h.respondLocked(c)
+		return
+	case h.respondStockError(c, userID, err):
+		return
+	}
 	if err != nil {
-		h.logger.Error("AddItem failed", zap.String("userID", userID), zap.Error(err))
+		h.log(c).Error("AddItem failed", zap.String("userID", userID), zap.Error(err))
 		c.JSON(http.StatusInternalServerError, model.ErrorResponse("Failed to add item to cart"))
 		return
 	}
+	c.Header("ETag", cart.ETag())
 	c.JSON(http.StatusOK, model.SuccessResponse(cart, "Item added to cart"))
 }
 
@@ -89,11 +131,29 @@ func (h *CartHandler) UpdateItemQuantity(c *gin.Context) {
 		return
 	}
 
-	cart, err := h.cartService.UpdateItemQuantity(c.Request.Context(), userID, itemID, req.Quantity)
+	// Raising the quantity checks stock on behalf of the caller
+	ctx := catalog.WithBearerToken(ifMatchContext(c), c.GetString("token"))
+	cart, err := h.cartService.UpdateItemQuantity(ctx, userID, itemID, req.Quantity)
+	if errors.Is(err, model.ErrPreconditionFailed) {
+		h.respondPreconditionFailed(c)
+		return
+	}
+	if errors.Is(err, model.ErrVersionConflict) {
+		h.respondConflict(c, userID, err)
+		return
+	}
+	if errors.Is(err, model.ErrCartLocked) {
+		h.respondLocked(c)
+		return
+	}
+	if h.respondStockError(c, userID, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusBadRequest, model.ErrorResponse(err.Error()))
 		return
 	}
+	c.Header("ETag", cart.ETag())
 	c.JSON(http.StatusOK, model.SuccessResponse(cart, "Cart updated"))
 }
 
@@ -102,20 +162,341 @@ func (h *CartHandler) RemoveItem(c *gin.Context) {
 	userID := c.GetString("user_id")
 	itemID := c.Param("itemId")
 
-	cart, err := h.cartService.RemoveItem(c.Request.Context(), userID, itemID)
+	cart, err := h.cartService.RemoveItem(ifMatchContext(c), userID, itemID)
+	if errors.Is(err, model.ErrPreconditionFailed) {
+		h.respondPreconditionFailed(c)
+		return
+	}
+	if errors.Is(err, model.ErrVersionConflict) {
+		h.respondConflict(c, userID, err)
+		return
+	}
+	if errors.Is(err, model.ErrCartLocked) {
+		h.respondLocked(c)
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusBadRequest, model.ErrorResponse(err.Error()))
 		return
 	}
+	c.Header("ETag", cart.ETag())
 	c.JSON(http.StatusOK, model.SuccessResponse(cart, "Item removed from cart"))
 }
 
 // ClearCart empties the entire cart
 func (h *CartHandler) ClearCart(c *gin.Context) {
 	userID := c.GetString("user_id")
-	if err := h.cartService.ClearCart(c.Request.Context(), userID); err != nil {
+	err := h.cartService.ClearCart(c.Request.Context(), userID)
+	if errors.Is(err, model.ErrCartLocked) {
+		h.respondLocked(c)
+		return
+	}
+	if err != nil {
+		h.log(c).Error("ClearCart failed", zap.String("userID", userID), zap.Error(err))
 		c.JSON(http.StatusInternalServerError, model.ErrorResponse("Failed to clear cart"))
 		return
 	}
 	c.JSON(http.StatusOK, model.SuccessResponse(nil, "Cart cleared successfully"))
 }
+
+// MergeCart folds the caller's guest cart (X-Guest-Token header or guest cookie)
+// into their user cart after login
+func (h *CartHandler) MergeCart(c *gin.Context) {
+	if c.GetBool("is_guest") {
+		c.JSON(http.StatusUnauthorized, model.ErrorResponse("Login required to merge carts"))
+		return
+	}
+	guestCartID := c.GetString("guest_cart_id")
+	if guestCartID == "" {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse("Valid guest token required"))
+		return
+	}
+
+	var req model.MergeCartRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse("Invalid request: "+err.Error()))
+		return
+	}
+
+	userID := c.GetString("user_id")
+	ctx := catalog.WithBearerToken(c.Request.Context(), c.GetString("token"))
+	ctx = service.WithShopperEmail(ctx, c.GetString("email"))
+	cart, err := h.cartService.MergeCart(ctx, userID, guestCartID, req.Strategy)
+	if errors.Is(err, model.ErrVersionConflict) {
+		h.respondConflict(c, userID, err)
+		return
+	}
+	if errors.Is(err, model.ErrCartLocked) {
+		h.respondLocked(c)
+		return
+	}
+	if h.respondStockError(c, userID, err) {
+		return
+	}
+	if err != nil {
+		h.log(c).Error("MergeCart failed", zap.String("userID", userID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse("Failed to merge guest cart"))
+		return
+	}
+	c.Header("ETag", cart.ETag())
+	c.JSON(http.StatusOK, model.SuccessResponse(cart, "Guest cart merged"))
+}
+
+// ApplyCoupon validates a coupon code and adds it to the cart
+func (h *CartHandler) ApplyCoupon(c *gin.Context) {
+	userID := c.GetString("user_id")
+	var req model.ApplyCouponRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse("Invalid request: "+err.Error()))
+		return
+	}
+
+	cart, err := h.cartService.ApplyCoupon(c.Request.Context(), userID, req.Code)
+	switch {
+	case errors.Is(err, model.ErrVersionConflict):
+		h.respondConflict(c, userID, err)
+		return
+	case errors.Is(err, model.ErrCartLocked):
+		h.respondLocked(c)
+		return
+	case errors.Is(err, promotion.ErrCouponNotFound):
+		c.JSON(http.StatusNotFound, model.ErrorResponse("Coupon not found"))
+		return
+	case errors.Is(err, promotion.ErrCouponInactive),
+		errors.Is(err, promotion.ErrCouponExpired),
+		errors.Is(err, promotion.ErrUsageLimitReached),
+		errors.Is(err, promotion.ErrMinSpendNotMet),
+		errors.Is(err, promotion.ErrCouponNotApplicable),
+		errors.Is(err, promotion.ErrTooManyCoupons),
+		errors.Is(err, promotion.ErrCouponNotCombinable):
+		c.JSON(http.StatusUnprocessableEntity, model.ErrorResponse(err.Error()))
+		return
+	}
+	if err != nil {
+		h.log(c).Error("ApplyCoupon failed", zap.String("userID", userID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse("Failed to apply coupon"))
+		return
+	}
+	c.Header("ETag", cart.ETag())
+	c.JSON(http.StatusOK, model.SuccessResponse(cart, "Coupon applied"))
+}
+
+// RemoveCoupon takes a coupon off the cart
+func (h *CartHandler) RemoveCoupon(c *gin.Context) {
+	userID := c.GetString("user_id")
+	code := c.Param("code")
+
+	cart, err := h.cartService.RemoveCoupon(c.Request.Context(), userID, code)
+	if errors.Is(err, model.ErrVersionConflict) {
+		h.respondConflict(c, userID, err)
+		return
+	}
+	if errors.Is(err, model.ErrCartLocked) {
+		h.respondLocked(c)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse(err.Error()))
+		return
+	}
+	c.Header("ETag", cart.ETag())
+	c.JSON(http.StatusOK, model.SuccessResponse(cart, "Coupon removed"))
+}
+
+// CheckoutCart is called by payment-service after a successful payment: it redeems
+// coupons, deletes the cart and publishes CartCheckedOut
+func (h *CartHandler) CheckoutCart(c *gin.Context) {
+	if c.GetBool("is_guest") {
+		c.JSON(http.StatusUnauthorized, model.ErrorResponse("Login required to check out"))
+		return
+	}
+	userID := c.GetString("user_id")
+	var req model.CheckoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse("Invalid request: "+err.Error()))
+		return
+	}
+
+	err := h.cartService.CheckoutCart(c.Request.Context(), userID, req.OrderID)
+	if errors.Is(err, model.ErrCartEmpty) {
+		c.JSON(http.StatusUnprocessableEntity, model.ErrorResponse("Cart is empty"))
+		return
+	}
+	if errors.Is(err, model.ErrCartLocked) {
+		h.respondLocked(c)
+		return
+	}
+	if errors.Is(err, promotion.ErrUsageLimitReached) {
+		c.JSON(http.StatusConflict, model.ErrorResponse(err.Error()))
+		return
+	}
+	if err != nil {
+		h.log(c).Error("CheckoutCart failed", zap.String("userID", userID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse("Failed to check out cart"))
+		return
+	}
+	c.JSON(http.StatusOK, model.SuccessResponse(nil, "Cart checked out"))
+}
+
+// CreateCheckoutSession freezes the cart into a snapshot that payment-service
+// charges against. The cart stays locked until the session closes or expires.
+func (h *CartHandler) CreateCheckoutSession(c *gin.Context) {
+	if c.GetBool("is_guest") {
+		c.JSON(http.StatusUnauthorized, model.ErrorResponse("Login required to check out"))
+		return
+	}
+	userID := c.GetString("user_id")
+	session, err := h.cartService.CreateCheckoutSession(c.Request.Context(), userID)
+	switch {
+	case errors.Is(err, model.ErrVersionConflict):
+		h.respondConflict(c, userID, err)
+		return
+	case errors.Is(err, model.ErrCartLocked):
+		h.respondLocked(c)
+		return
+	case errors.Is(err, model.ErrCartEmpty):
+		c.JSON(http.StatusUnprocessableEntity, model.ErrorResponse("Cart is empty"))
+		return
+	}
+	if err != nil {
+		h.log(c).Error("CreateCheckoutSession failed", zap.String("userID", userID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse("Failed to start checkout"))
+		return
+	}
+	c.JSON(http.StatusCreated, model.SuccessResponse(session, "Checkout session created"))
+}
+
+// GetCheckoutSession returns a checkout snapshot owned by the caller
+func (h *CartHandler) GetCheckoutSession(c *gin.Context) {
+	userID := c.GetString("user_id")
+	session, err := h.cartService.GetCheckoutSession(c.Request.Context(), userID, c.Param("sessionId"))
+	if err != nil {
+		h.respondSessionError(c, userID, "GetCheckoutSession", err)
+		return
+	}
+	c.JSON(http.StatusOK, model.SuccessResponse(session, "Checkout session retrieved"))
+}
+
+// CompleteCheckoutSession is called by payment-service after a successful
+// payment, with its own token; the shopper is named in the body
+func (h *CartHandler) CompleteCheckoutSession(c *gin.Context) {
+	var req model.CompleteCheckoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse("Invalid request: "+err.Error()))
+		return
+	}
+
+	session, err := h.cartService.CompleteCheckoutSession(c.Request.Context(), req.UserID, c.Param("sessionId"), req.OrderID)
+	if err != nil {
+		h.respondSessionError(c, req.UserID, "CompleteCheckoutSession", err)
+		return
+	}
+	c.JSON(http.StatusOK, model.SuccessResponse(session, "Checkout session completed"))
+}
+
+// CancelCheckoutSession abandons checkout and unlocks the cart
+func (h *CartHandler) CancelCheckoutSession(c *gin.Context) {
+	userID := c.GetString("user_id")
+	session, err := h.cartService.CancelCheckoutSession(c.Request.Context(), userID, c.Param("sessionId"))
+	if err != nil {
+		h.respondSessionError(c, userID, "CancelCheckoutSession", err)
+		return
+	}
+	c.JSON(http.StatusOK, model.SuccessResponse(session, "Checkout session cancelled"))
+}
+
+func (h *CartHandler) respondSessionError(c *gin.Context, userID string, op string, err error) {
+	switch {
+	case errors.Is(err, model.ErrCheckoutSessionNotFound):
+		c.JSON(http.StatusNotFound, model.ErrorResponse("Checkout session not found"))
+	case errors.Is(err, model.ErrCheckoutSessionExpired):
+		c.JSON(http.StatusGone, model.ErrorResponse("Checkout session has expired"))
+	case errors.Is(err, model.ErrCheckoutSessionClosed):
+		c.JSON(http.StatusConflict, model.ErrorResponse("Checkout session is already closed"))
+	case errors.Is(err, model.ErrVersionConflict):
+		h.respondConflict(c, userID, err)
+	case errors.Is(err, promotion.ErrUsageLimitReached):
+		c.JSON(http.StatusConflict, model.ErrorResponse(err.Error()))
+	default:
+		h.log(c).Error(op+" failed", zap.String("userID", userID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse("Failed to update checkout session"))
+	}
+}
+
+// respondStockError answers the catalog and stock errors of a mutation that
+// adds quantity, and reports whether err was one of them
+func (h *CartHandler) respondStockError(c *gin.Context, userID string, err error) bool {
+	switch {
+	case errors.Is(err, catalog.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, model.ErrorResponse("Product not found"))
+	case errors.Is(err, catalog.ErrProductInactive):
+		c.JSON(http.StatusUnprocessableEntity, model.ErrorResponse("Product is no longer available"))
+	case errors.Is(err, model.ErrInsufficientStock):
+		c.JSON(http.StatusUnprocessableEntity, model.ErrorResponse("Not enough stock for the requested quantity"))
+	case errors.Is(err, catalog.ErrCatalogUnavailable):
+		h.log(c).Error("Catalog unavailable", zap.String("userID", userID), zap.Error(err))
+		c.JSON(http.StatusServiceUnavailable, model.ErrorResponse("Product catalog is temporarily unavailable"))
+	default:
+		return false
+	}
+	return true
+}
+
+// respondLocked answers 423 while a checkout session holds the cart
+func (h *CartHandler) respondLocked(c *gin.Context) {
+	c.JSON(http.StatusLocked, model.ErrorResponse("Cart is locked for checkout; cancel the checkout session to make changes"))
+}
+
+// respondConflict answers 409 when concurrent writers kept winning the race
+// and the service gave up retrying.
+func (h *CartHandler) respondConflict(c *gin.Context, userID string, err error) {
+	h.log(c).Warn("Cart mutation conflicted", zap.String("userID", userID), zap.Error(err))
+	c.JSON(http.StatusConflict, model.ErrorResponse("Cart was modified concurrently, please retry"))
+}
+
+// respondPreconditionFailed answers 412 when the cart changed since the
+// client read the ETag it sent in If-Match
+func (h *CartHandler) respondPreconditionFailed(c *gin.Context) {
+	c.JSON(http.StatusPreconditionFailed, model.ErrorResponse("Cart has changed since it was last read; reload it and retry"))
+}
+
+// notModified sets the cart's ETag and answers 304 when If-None-Match already
+// holds it. Responses are per user, so shared caches must not store them.
+func (h *CartHandler) notModified(c *gin.Context, etag string) bool {
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "private, no-cache")
+	for _, candidate := range etagList(c.GetHeader("If-None-Match")) {
+		// If-None-Match uses the weak comparison
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatchContext hands an If-Match header to the service, which checks it
+// against the same read it writes from. "*" matches any cart.
+func ifMatchContext(c *gin.Context) context.Context {
+	etags := etagList(c.GetHeader("If-Match"))
+	if len(etags) == 0 || slices.Contains(etags, "*") {
+		return c.Request.Context()
+	}
+	return service.WithIfMatch(c.Request.Context(), etags)
+}
+
+// etagList splits an If-Match or If-None-Match header into its entity tags
+func etagList(header string) []string {
+	var etags []string
+	for _, etag := range strings.Split(header, ",") {
+		if etag = strings.TrimSpace(etag); etag != "" {
+			etags = append(etags, etag)
+		}
+	}
+	return etags
+}
+
+// log returns the request-scoped logger, or the handler logger outside RequestLogger
+func (h *CartHandler) log(c *gin.Context) *zap.Logger {
+	return logging.FromContext(c.Request.Context(), h.logger)
+}
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/emart/cart-service/internal/logging"
+	"github.com/emart/cart-service/internal/model"
+	"github.com/emart/cart-service/internal/realtime"
+	"github.com/emart/cart-service/internal/service"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// streamRetry is how long a disconnected EventSource waits before reconnecting
+const streamRetry = 3 * time.Second
+
+// StreamHandler pushes cart changes to open tabs as Server-Sent Events
+type StreamHandler struct {
+	cartService service.CartService
+	updates     realtime.Subscriber
+	heartbeat   time.Duration
+	logger      *zap.Logger
+}
+
+func NewStreamHandler(cartService service.CartService, updates realtime.Subscriber, heartbeat time.Duration, logger *zap.Logger) *StreamHandler {
+	return &StreamHandler{cartService: cartService, updates: updates, heartbeat: heartbeat, logger: logger}
+}
+
+// RegisterRoutes sets up the cart stream route
+func (h *StreamHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/cart/stream", h.StreamCart)
+}
+
+// StreamCart godoc
+// @Summary Stream the cart and its summary whenever they change
+// @Description Server-Sent Events: a "cart" and a "summary" event per change,
+// @Description both with the cart's ETag (unquoted) as the event ID. A client
+// @Description reconnecting with Last-Event-ID gets the current cart only if it
+// @Description changed meanwhile. The native EventSource cannot send an
+// @Description Authorization header, so logged-in clients need a fetch-based one.
+// @Tags cart
+// @Produce text/event-stream
+// @Security BearerAuth
+// @Router /api/v1/cart/stream [get]
+func (h *StreamHandler) StreamCart(c *gin.Context) {
+	userID := c.GetString("user_id")
+	ctx := c.Request.Context()
+
+	sub, err := h.updates.Subscribe(ctx, userID)
+	switch {
+	case errors.Is(err, realtime.ErrTooManyStreams):
+		c.JSON(http.StatusTooManyRequests, model.ErrorResponse("Too many open cart streams; close another tab"))
+		return
+	case errors.Is(err, realtime.ErrClosed):
+		c.JSON(http.StatusServiceUnavailable, model.ErrorResponse("Server is shutting down, please reconnect"))
+		return
+	case err != nil:
+		h.log(c).Error("Cart stream subscribe failed", zap.String("userID", userID), zap.Error(err))
+		c.JSON(http.StatusServiceUnavailable, model.ErrorResponse("Live cart updates are temporarily unavailable"))
+		return
+	}
+	defer sub.Close()
+
+	// Loaded after subscribing so a change made in between is not missed
+	cart, err := h.cartService.GetCart(ctx, userID)
+	if err != nil {
+		h.log(c).Error("Cart stream load failed", zap.String("userID", userID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse("Failed to retrieve cart"))
+		return
+	}
+
+	// The server's write timeout is meant for ordinary requests
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		h.log(c).Warn("Cannot lift write deadline for cart stream", zap.Error(err))
+	}
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no") // nginx would buffer the events otherwise
+	c.Status(http.StatusOK)
+	fmt.Fprintf(c.Writer, "retry: %d\n\n", streamRetry.Milliseconds())
+	if c.GetHeader("Last-Event-ID") != eventID(cart) {
+		if err := writeCartEvents(c.Writer, cart); err != nil {
+			return
+		}
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+	last := cart
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sub.Done():
+			// Shutting down; the client reconnects to another replica
+			return
+		case <-heartbeat.C:
+			if _, err := io.WriteString(c.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case cart := <-sub.Updates():
+			// Replicas publish independently, so an older save can arrive late
+			if cart.UpdatedAt.Before(last.UpdatedAt) || cart.ETag() == last.ETag() {
+				continue
+			}
+			last = cart
+			if err := writeCartEvents(c.Writer, cart); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
+
+func (h *StreamHandler) log(c *gin.Context) *zap.Logger {
+	return logging.FromContext(c.Request.Context(), h.logger)
+}
+
+// eventID is the cart's ETag without quotes, so a client can send it back as
+// Last-Event-ID or compare it with the ETag of a GET
+func eventID(cart *model.Cart) string {
+	return strings.Trim(cart.ETag(), `"`)
+}
+
+func writeCartEvents(w io.Writer, cart *model.Cart) error {
+	id := eventID(cart)
+	if err := writeEvent(w, id, "cart", cart); err != nil {
+		return err
+	}
+	return writeEvent(w, id, "summary", cart.Summary())
+}
+
+func writeEvent(w io.Writer, id, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", id, event, payload)
+	return err
+}
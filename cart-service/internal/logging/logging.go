@@ -0,0 +1,58 @@
+package logging
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+type loggerKey struct{}
+
+type requestIDKey struct{}
+
+// NewContext returns ctx carrying l as the request-scoped logger
+func NewContext(ctx context.Context, l *zap.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// With adds fields to the logger carried by ctx. Without one ctx is returned
+// unchanged, so callers outside a request need no special casing.
+func With(ctx context.Context, fields ...zap.Field) context.Context {
+	l, ok := ctx.Value(loggerKey{}).(*zap.Logger)
+	if !ok {
+		return ctx
+	}
+	return NewContext(ctx, l.With(fields...))
+}
+
+// FromContext returns the logger carried by ctx, or fallback when there is none,
+// tagged with the trace in ctx
+func FromContext(ctx context.Context, fallback *zap.Logger) *zap.Logger {
+	l, ok := ctx.Value(loggerKey{}).(*zap.Logger)
+	if !ok {
+		l = fallback
+	}
+	return WithTrace(ctx, l)
+}
+
+// WithTrace returns l with the trace_id and span_id of the span in ctx, so log
+// lines can be matched to traces. Without a span l is returned unchanged.
+func WithTrace(ctx context.Context, l *zap.Logger) *zap.Logger {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return l
+	}
+	return l.With(zap.String("trace_id", sc.TraceID().String()), zap.String("span_id", sc.SpanID().String()))
+}
+
+// WithRequestID returns ctx carrying the request's correlation ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the correlation ID carried by ctx, or "" outside a request
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
@@ -0,0 +1,153 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/emart/cart-service/internal/migration"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+)
+
+const namespace = "cart"
+
+// Metrics owns the service's Prometheus collectors. Business code never touches
+// it directly: HTTP traffic is measured by Middleware, storage by the repository
+// wrappers in repositories.go, and background jobs report through small
+// observer interfaces.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+
+	cacheLookups   *prometheus.CounterVec
+	cacheFallbacks *prometheus.CounterVec
+
+	mongoDuration *prometheus.HistogramVec
+	mongoErrors   *prometheus.CounterVec
+
+	syncDuration *prometheus.HistogramVec
+	syncCarts    *prometheus.CounterVec
+
+	migrationState *prometheus.GaugeVec
+	activeCarts    prometheus.Gauge
+}
+
+// New registers all collectors, plus the Go runtime and process collectors,
+// on a registry of their own
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "http_requests_total",
+			Help: "HTTP requests by method, route template and status code.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Name: "http_request_duration_seconds",
+			Help:    "HTTP request latency by method, route template and status code.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		cacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "redis_cache_lookups_total",
+			Help: "Cart reads served from Redis, by result (hit, miss, error).",
+		}, []string{"result"}),
+		cacheFallbacks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "mongo_fallback_reads_total",
+			Help: "Cart reads that fell back to MongoDB after a Redis miss or error, by result (found, not_found, error).",
+		}, []string{"result"}),
+		mongoDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Name: "mongo_operation_duration_seconds",
+			Help:    "MongoDB cart repository latency by operation.",
+			Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"operation"}),
+		mongoErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "mongo_operation_errors_total",
+			Help: "MongoDB cart repository errors by operation. Version conflicts are counted separately as error=\"conflict\".",
+		}, []string{"operation", "error"}),
+		syncDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Name: "sync_run_duration_seconds",
+			Help:    "Duration of Redis to MongoDB sync passes, by kind (dirty, full).",
+			Buckets: []float64{.01, .05, .1, .5, 1, 5, 10, 30, 60, 300},
+		}, []string{"kind"}),
+		syncCarts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "sync_carts_total",
+			Help: "Carts handled by sync passes, by kind and result (synced, skipped, failed).",
+		}, []string{"kind", "result"}),
+		migrationState: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "migration_state",
+			Help: "1 for the current state of each known migration, 0 otherwise.",
+		}, []string{"id", "state"}),
+		activeCarts: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "active_carts",
+			Help: "Carts with items updated within the abandoned cart window.",
+		}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests, m.httpDuration,
+		m.cacheLookups, m.cacheFallbacks,
+		m.mongoDuration, m.mongoErrors,
+		m.syncDuration, m.syncCarts,
+		m.migrationState, m.activeCarts,
+	)
+	return m
+}
+
+// Handler serves the registry in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// ObserveSync records one CartSyncer pass; it satisfies sync.Observer
+func (m *Metrics) ObserveSync(kind string, duration time.Duration, synced, skipped, failed int) {
+	m.syncDuration.WithLabelValues(kind).Observe(duration.Seconds())
+	m.syncCarts.WithLabelValues(kind, "synced").Add(float64(synced))
+	m.syncCarts.WithLabelValues(kind, "skipped").Add(float64(skipped))
+	m.syncCarts.WithLabelValues(kind, "failed").Add(float64(failed))
+}
+
+// migrationStates are every state a migration can report, so stale series reset to 0
+var migrationStates = []string{"PENDING", "EXECUTED", "ROLLED_BACK", "ROLLBACK_FAILED", "IN_PROGRESS", "FAILED"}
+
+// RecordMigrations publishes the state of every known migration
+func (m *Metrics) RecordMigrations(statuses []migration.Status) {
+	for _, st := range statuses {
+		for _, state := range migrationStates {
+			v := 0.0
+			if state == st.State {
+				v = 1
+			}
+			m.migrationState.WithLabelValues(st.ID, state).Set(v)
+		}
+	}
+}
+
+// TrackActiveCarts refreshes the active carts gauge every interval until ctx is
+// cancelled. Counting is a MongoDB query, so it runs here rather than per scrape.
+func (m *Metrics) TrackActiveCarts(ctx context.Context, interval time.Duration, count func(ctx context.Context) (int64, error), logger *zap.Logger) {
+	refresh := func() {
+		n, err := count(ctx)
+		if err != nil {
+			logger.Warn("Failed to count active carts", zap.Error(err))
+			return
+		}
+		m.activeCarts.Set(float64(n))
+	}
+
+	refresh()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			refresh()
+		}
+	}
+}
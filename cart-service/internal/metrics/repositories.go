@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/emart/cart-service/internal/model"
+	mongorepo "github.com/emart/cart-service/internal/repository/mongo"
+	redisrepo "github.com/emart/cart-service/internal/repository/redis"
+)
+
+// instrumentedRedisRepo counts cart cache lookups. Give it only to the cart
+// service: the syncer's reads would otherwise skew the hit ratio.
+type instrumentedRedisRepo struct {
+	redisrepo.CartRedisRepository
+	m *Metrics
+}
+
+// InstrumentRedis wraps repo so that GetCart reports hit, miss or error
+func (m *Metrics) InstrumentRedis(repo redisrepo.CartRedisRepository) redisrepo.CartRedisRepository {
+	return &instrumentedRedisRepo{CartRedisRepository: repo, m: m}
+}
+
+func (r *instrumentedRedisRepo) GetCart(ctx context.Context, userID string) (*model.Cart, error) {
+	cart, err := r.CartRedisRepository.GetCart(ctx, userID)
+	switch {
+	case err != nil:
+		r.m.cacheLookups.WithLabelValues("error").Inc()
+	case cart == nil:
+		r.m.cacheLookups.WithLabelValues("miss").Inc()
+	default:
+		r.m.cacheLookups.WithLabelValues("hit").Inc()
+	}
+	return cart, err
+}
+
+// instrumentedMongoRepo times every cart repository call. The cart service only
+// reads MongoDB after Redis missed, so GetCart doubles as the fallback count.
+type instrumentedMongoRepo struct {
+	mongorepo.CartMongoRepository
+	m *Metrics
+}
+
+// InstrumentMongo wraps repo with latency and error metrics per operation
+func (m *Metrics) InstrumentMongo(repo mongorepo.CartMongoRepository) mongorepo.CartMongoRepository {
+	return &instrumentedMongoRepo{CartMongoRepository: repo, m: m}
+}
+
+func (r *instrumentedMongoRepo) GetCart(ctx context.Context, userID string) (*model.Cart, error) {
+	start := time.Now()
+	cart, err := r.CartMongoRepository.GetCart(ctx, userID)
+	r.observe("get_cart", start, err)
+	switch {
+	case err != nil:
+		r.m.cacheFallbacks.WithLabelValues("error").Inc()
+	case cart == nil:
+		r.m.cacheFallbacks.WithLabelValues("not_found").Inc()
+	default:
+		r.m.cacheFallbacks.WithLabelValues("found").Inc()
+	}
+	return cart, err
+}
+
+func (r *instrumentedMongoRepo) UpsertCart(ctx context.Context, cart *model.Cart) error {
+	start := time.Now()
+	err := r.CartMongoRepository.UpsertCart(ctx, cart)
+	r.observe("upsert_cart", start, err)
+	return err
+}
+
+func (r *instrumentedMongoRepo) DeleteCart(ctx context.Context, userID string) error {
+	start := time.Now()
+	err := r.CartMongoRepository.DeleteCart(ctx, userID)
+	r.observe("delete_cart", start, err)
+	return err
+}
+
+func (r *instrumentedMongoRepo) observe(op string, start time.Time, err error) {
+	r.m.mongoDuration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+	switch {
+	case err == nil:
+	case errors.Is(err, model.ErrVersionConflict):
+		r.m.mongoErrors.WithLabelValues(op, "conflict").Inc()
+	default:
+		r.m.mongoErrors.WithLabelValues(op, "error").Inc()
+	}
+}
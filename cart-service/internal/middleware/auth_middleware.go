@@ -4,63 +4,56 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/emart/cart-service/internal/logging"
 	"github.com/emart/cart-service/internal/model"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
 )
 
 // JWTAuthMiddleware validates the Bearer JWT token from the Login service
-func JWTAuthMiddleware(jwtSecret string) gin.HandlerFunc {
+func JWTAuthMiddleware(verifier *TokenVerifier) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, model.ErrorResponse("Authorization header required"))
-			return
-		}
-
-		tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
-
-		token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
-			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return []byte(jwtSecret), nil
-		})
-
-		if err != nil || !token.Valid {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, model.ErrorResponse("Invalid or expired token"))
+		if !authenticateBearer(c, verifier) {
 			return
 		}
+		c.Next()
+	}
+}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, model.ErrorResponse("Invalid token claims"))
-			return
-		}
+// authenticateBearer validates the Bearer token and sets the user claims in the context.
+// On failure it aborts the request with 401 and returns false.
+func authenticateBearer(c *gin.Context, verifier *TokenVerifier) bool {
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, model.ErrorResponse("Authorization header required"))
+		return false
+	}
 
-		// Extract user info from JWT claims (set by Login service)
-		userID, _ := claims["userId"].(string)
-		email, _ := claims["sub"].(string)
-		name, _ := claims["name"].(string)
+	tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
 
-		// Set in context for handlers to use
-		c.Set("user_id", userID)
-		c.Set("email", email)
-		c.Set("name", name)
-		c.Next()
+	claims, err := verifier.Verify(tokenStr)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, model.ErrorResponse("Invalid or expired token"))
+		return false
 	}
+
+	// Extract user info from JWT claims (set by Login service)
+	userID, _ := claims["userId"].(string)
+	email, _ := claims["sub"].(string)
+	name, _ := claims["name"].(string)
+
+	// Set in context for handlers to use
+	c.Set("user_id", userID)
+	c.Set("email", email)
+	c.Set("name", name)
+	c.Set("token", tokenStr)
+	c.Set("claims", claims)
+	tagRequestLogger(c, userID)
+	return true
 }
 
-// CORSMiddleware handles CORS for browser requests
-func CORSMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET,POST,PUT,DELETE,OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Authorization,Content-Type,X-Requested-With")
-		if c.Request.Method == http.MethodOptions {
-			c.AbortWithStatus(http.StatusNoContent)
-			return
-		}
-		c.Next()
-	}
+// tagRequestLogger adds the caller to the request-scoped logger, so service
+// logs for the request carry the user ID
+func tagRequestLogger(c *gin.Context, userID string) {
+	c.Request = c.Request.WithContext(logging.With(c.Request.Context(), zap.String("user_id", userID)))
 }
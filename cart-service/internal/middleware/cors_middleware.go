@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/emart/cart-service/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// originPattern matches one configured origin. Wildcard patterns keep the text
+// around the "*" as prefix and suffix, so "https://*.emart.com" matches any
+// subdomain of emart.com over https but not emart.com itself.
+type originPattern struct {
+	exact    string
+	prefix   string
+	suffix   string
+	wildcard bool
+}
+
+func parseOriginPattern(origin string) (originPattern, error) {
+	origin = strings.ToLower(strings.TrimRight(origin, "/"))
+	star := strings.Index(origin, "*")
+	if star < 0 {
+		return originPattern{exact: origin}, nil
+	}
+	if strings.Count(origin, "*") > 1 || !strings.HasSuffix(origin[:star], "://") || !strings.HasPrefix(origin[star+1:], ".") {
+		return originPattern{}, fmt.Errorf("invalid CORS origin %q: wildcards must look like https://*.example.com", origin)
+	}
+	return originPattern{prefix: origin[:star], suffix: origin[star+1:], wildcard: true}, nil
+}
+
+func (p originPattern) matches(origin string) bool {
+	if !p.wildcard {
+		return origin == p.exact
+	}
+	if !strings.HasPrefix(origin, p.prefix) || !strings.HasSuffix(origin, p.suffix) {
+		return false
+	}
+	sub := origin[len(p.prefix) : len(origin)-len(p.suffix)]
+	if sub == "" {
+		return false
+	}
+	for _, r := range sub {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// CORSMiddleware applies the configured CORS policy. Only origins on the
+// allow-list are echoed back, so responses carry Vary: Origin. A preflight from
+// any other origin is refused with 403; other requests from it are served
+// without CORS headers, which leaves the browser to block the response.
+func CORSMiddleware(cfg config.CORSConfig) (gin.HandlerFunc, error) {
+	var patterns []originPattern
+	anyOrigin := false
+	for _, o := range cfg.AllowedOrigins {
+		if o == "*" {
+			anyOrigin = true
+			continue
+		}
+		p, err := parseOriginPattern(o)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, p)
+	}
+	if anyOrigin && cfg.AllowCredentials {
+		return nil, fmt.Errorf("CORS origin \"*\" cannot be combined with credentials; list the allowed origins instead")
+	}
+
+	allowed := func(origin string) bool {
+		if anyOrigin {
+			return true
+		}
+		origin = strings.ToLower(origin)
+		for _, p := range patterns {
+			if p.matches(origin) {
+				return true
+			}
+		}
+		return false
+	}
+	methods := strings.Join(cfg.AllowedMethods, ",")
+	headers := strings.Join(cfg.AllowedHeaders, ",")
+	exposed := strings.Join(cfg.ExposedHeaders, ",")
+	maxAge := strconv.Itoa(int(cfg.MaxAge.Seconds()))
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		if !anyOrigin {
+			c.Writer.Header().Add("Vary", "Origin")
+		}
+		if preflight {
+			c.Writer.Header().Add("Vary", "Access-Control-Request-Method")
+			c.Writer.Header().Add("Vary", "Access-Control-Request-Headers")
+		}
+		if origin == "" {
+			c.Next()
+			return
+		}
+		if !allowed(origin) {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if anyOrigin {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Header("Access-Control-Allow-Origin", origin)
+		}
+		if cfg.AllowCredentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+		if !preflight {
+			if exposed != "" {
+				c.Header("Access-Control-Expose-Headers", exposed)
+			}
+			c.Next()
+			return
+		}
+
+		c.Header("Access-Control-Allow-Methods", methods)
+		c.Header("Access-Control-Allow-Headers", headers)
+		if cfg.MaxAge > 0 {
+			c.Header("Access-Control-Max-Age", maxAge)
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}, nil
+}
@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emart/cart-service/internal/model"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// GuestTokenHeader carries the guest token for clients that don't use cookies (mobile)
+const GuestTokenHeader = "X-Guest-Token"
+
+var (
+	errInvalidGuestToken = errors.New("invalid guest token")
+	errExpiredGuestToken = errors.New("guest token expired")
+)
+
+// GuestTokens issues and verifies signed guest tokens of the form
+// "<guestID>.<exp>.<hmac>", exp being Unix seconds. A token expires with the
+// guest cart: ttl after the cart was last saved.
+type GuestTokens struct {
+	secret       []byte
+	cookieName   string
+	ttl          time.Duration
+	secureCookie bool
+}
+
+func NewGuestTokens(secret, cookieName string, ttl time.Duration, secureCookie bool) *GuestTokens {
+	return &GuestTokens{
+		secret:       []byte(secret),
+		cookieName:   cookieName,
+		ttl:          ttl,
+		secureCookie: secureCookie,
+	}
+}
+
+// Issue creates a new guest ID and its signed token
+func (g *GuestTokens) Issue() (guestID, token string) {
+	guestID = uuid.New().String()
+	return guestID, g.tokenFor(guestID, time.Now())
+}
+
+// tokenFor signs guestID to expire when a guest cart saved at now does
+func (g *GuestTokens) tokenFor(guestID string, now time.Time) string {
+	payload := guestID + "." + strconv.FormatInt(now.Add(g.ttl).Unix(), 10)
+	return payload + "." + g.sign(payload)
+}
+
+// Verify returns the guest ID if the token signature is valid and the token
+// has not expired
+func (g *GuestTokens) Verify(token string) (string, error) {
+	dot := strings.LastIndexByte(token, '.')
+	if dot < 0 {
+		return "", errInvalidGuestToken
+	}
+	payload, sig := token[:dot], token[dot+1:]
+	guestID, exp, ok := strings.Cut(payload, ".")
+	if !ok || guestID == "" {
+		return "", errInvalidGuestToken
+	}
+	if !hmac.Equal([]byte(sig), []byte(g.sign(payload))) {
+		return "", errInvalidGuestToken
+	}
+	expiresAt, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return "", errInvalidGuestToken
+	}
+	if !time.Now().Before(time.Unix(expiresAt, 0)) {
+		return "", errExpiredGuestToken
+	}
+	return guestID, nil
+}
+
+func (g *GuestTokens) sign(payload string) string {
+	mac := hmac.New(sha256.New, g.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// fromRequest reads and verifies the guest token from the header or cookie.
+// An expired token is not honoured: its cart has expired as well.
+func (g *GuestTokens) fromRequest(c *gin.Context) (string, bool) {
+	token := c.GetHeader(GuestTokenHeader)
+	if token == "" {
+		token, _ = c.Cookie(g.cookieName)
+	}
+	if token == "" {
+		return "", false
+	}
+	guestID, err := g.Verify(token)
+	return guestID, err == nil
+}
+
+func (g *GuestTokens) setCookie(c *gin.Context, token string) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(g.cookieName, token, int(g.ttl.Seconds()), "/", "", g.secureCookie, true)
+	c.Header(GuestTokenHeader, token)
+}
+
+// CartIdentityMiddleware lets both logged-in users and anonymous shoppers use the cart.
+// A Bearer token must be valid when present. Without one, the caller is identified by
+// a signed guest token; a fresh one is issued (cookie + X-Guest-Token header) if missing
+// or expired. A guest's mutating request saves the cart, which extends its expiry,
+// so the token is reissued with the extended expiry too.
+// Sets user_id ("guest:<id>" for guests), is_guest and, when a valid guest token is present, guest_cart_id.
+func CartIdentityMiddleware(verifier *TokenVerifier, guests *GuestTokens) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		guestID, hasGuest := guests.fromRequest(c)
+		if hasGuest {
+			c.Set("guest_cart_id", model.GuestCartPrefix+guestID)
+		}
+
+		if c.GetHeader("Authorization") != "" {
+			if !authenticateBearer(c, verifier) {
+				return
+			}
+			c.Set("is_guest", false)
+			c.Next()
+			return
+		}
+
+		if !hasGuest {
+			var token string
+			guestID, token = guests.Issue()
+			guests.setCookie(c, token)
+		} else if isMutation(c.Request.Method) {
+			guests.setCookie(c, guests.tokenFor(guestID, time.Now()))
+		}
+		c.Set("user_id", model.GuestCartPrefix+guestID)
+		c.Set("is_guest", true)
+		tagRequestLogger(c, model.GuestCartPrefix+guestID)
+		c.Next()
+	}
+}
@@ -0,0 +1,162 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+
+	"github.com/emart/cart-service/internal/config"
+	"github.com/emart/cart-service/internal/logging"
+	"github.com/emart/cart-service/internal/model"
+	redisrepo "github.com/emart/cart-service/internal/repository/redis"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	// IdempotencyKeyHeader lets a client retry a mutation safely
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader marks a response replayed from an earlier request
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+)
+
+// replayedHeaders are the response headers stored alongside the body
+var replayedHeaders = []string{"Content-Type", "ETag", "Location"}
+
+// bodyRecorder keeps a copy of everything the handler writes
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// IdempotencyMiddleware honours an Idempotency-Key on POST, PUT, PATCH and
+// DELETE. The first request with a key runs and its response is stored for
+// cfg.TTL; a retry with the same key and body gets that response replayed
+// without running the handler again. Reusing a key for a different request, or
+// while the first is still running, is a 409. Keys are scoped to the caller, so
+// mount it after the identity middleware.
+//
+// Server errors and transient rejections (409, 423, 429) are not stored, so a
+// retry with the same key runs again. If Redis is unreachable the request runs
+// without protection.
+func IdempotencyMiddleware(store redisrepo.IdempotencyRedisRepository, cfg config.IdempotencyConfig, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" || !isMutation(c.Request.Method) {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, model.ErrorResponse("Idempotency-Key is too long"))
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, model.ErrorResponse("Failed to read request body"))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := requestFingerprint(c.Request.Method, c.Request.URL.Path, body)
+		storeKey := c.GetString("user_id") + ":" + key
+		log := logging.FromContext(c.Request.Context(), logger)
+
+		token := uuid.NewString()
+		existing, err := store.Reserve(c.Request.Context(), storeKey, fingerprint, token, cfg.LockTTL)
+		if err != nil {
+			log.Warn("Idempotency store unavailable, running request unprotected", zap.Error(err))
+			c.Next()
+			return
+		}
+		if existing != nil {
+			switch {
+			case existing.Fingerprint != fingerprint:
+				c.AbortWithStatusJSON(http.StatusConflict, model.ErrorResponse("Idempotency-Key was already used for a different request"))
+			case !existing.Completed:
+				c.Header("Retry-After", "1")
+				c.AbortWithStatusJSON(http.StatusConflict, model.ErrorResponse("A request with this Idempotency-Key is still in progress"))
+			default:
+				for name, value := range existing.Header {
+					c.Header(name, value)
+				}
+				c.Header(IdempotentReplayedHeader, "true")
+				c.Data(existing.Status, existing.Header["Content-Type"], existing.Body)
+				c.Abort()
+			}
+			return
+		}
+
+		recorder := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// The client may be gone, but its retry still needs the outcome
+		ctx := context.WithoutCancel(c.Request.Context())
+		status := recorder.Status()
+		if !storableStatus(status) {
+			if err := store.Release(ctx, storeKey, token); err != nil {
+				log.Warn("Failed to release idempotency key", zap.Error(err))
+			}
+			return
+		}
+		record := &model.IdempotencyRecord{
+			Fingerprint: fingerprint,
+			Completed:   true,
+			Status:      status,
+			Header:      make(map[string]string),
+			Body:        recorder.body.Bytes(),
+		}
+		for _, name := range replayedHeaders {
+			if value := recorder.Header().Get(name); value != "" {
+				record.Header[name] = value
+			}
+		}
+		stored, err := store.Complete(ctx, storeKey, token, record, cfg.TTL)
+		if err != nil {
+			log.Error("Failed to store idempotent response", zap.Error(err))
+		} else if !stored {
+			// The handler outlived IDEMPOTENCY_LOCK_TTL; a retry may already own the key
+			log.Warn("Idempotency reservation expired before the response was stored", zap.Duration("lockTTL", cfg.LockTTL))
+		}
+	}
+}
+
+func isMutation(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func storableStatus(status int) bool {
+	switch status {
+	case http.StatusConflict, http.StatusLocked, http.StatusTooManyRequests:
+		return false
+	}
+	return status < http.StatusInternalServerError
+}
+
+// requestFingerprint identifies a request so a reused key can be told apart
+func requestFingerprint(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,207 @@
+package middleware
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// minJWKSRefetch is the least time between two fetches, whatever the refresh
+// interval, so tokens with made-up kids cannot hammer the JWKS endpoint
+const minJWKSRefetch = 30 * time.Second
+
+// jwksKeySet caches the signing keys published at a JWKS endpoint. Keys are
+// re-fetched every refresh interval, and early when a token names a kid we have
+// not seen, which is how a rotated key is picked up.
+type jwksKeySet struct {
+	url     string
+	refresh time.Duration
+	client  *http.Client
+	logger  *zap.Logger
+
+	mu          sync.Mutex
+	keys        map[string]crypto.PublicKey
+	fetchedAt   time.Time
+	lastAttempt time.Time     // last fetch made on demand; the one at startup does not count
+	fetching    chan struct{} // closed when the fetch in flight ends; nil when none is
+}
+
+func newJWKSKeySet(url string, refresh time.Duration, logger *zap.Logger) *jwksKeySet {
+	s := &jwksKeySet{url: url, refresh: refresh, client: &http.Client{Timeout: 5 * time.Second}, logger: logger}
+	keys, err := s.fetch(context.Background())
+	if err != nil {
+		// Not fatal: login-service may start after us; keys are fetched on first use
+		logger.Warn("Initial JWKS fetch failed", zap.String("url", url), zap.Error(err))
+		return s
+	}
+	s.keys, s.fetchedAt = keys, time.Now()
+	return s
+}
+
+// key returns the public key for kid. An empty kid is accepted when the set
+// holds exactly one key. At most one fetch runs at a time and never under s.mu:
+// callers without the key wait for it, callers with a cached key do not.
+func (s *jwksKeySet) key(kid string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	key, ok := s.lookup(kid)
+	stale := time.Since(s.fetchedAt) > s.refresh
+	inFlight := s.fetching
+	start := (!ok || stale) && inFlight == nil && time.Since(s.lastAttempt) >= minJWKSRefetch
+	if start {
+		inFlight = make(chan struct{})
+		s.fetching, s.lastAttempt = inFlight, time.Now()
+	}
+	s.mu.Unlock()
+
+	switch {
+	case start:
+		s.refetch(inFlight)
+	case ok:
+		return key, nil
+	case inFlight != nil:
+		<-inFlight
+	}
+
+	s.mu.Lock()
+	key, ok = s.lookup(kid)
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %q", errUnknownSigningKey, kid)
+	}
+	return key, nil
+}
+
+// refetch runs the fetch key started and then releases the callers waiting on done
+func (s *jwksKeySet) refetch(done chan struct{}) {
+	keys, err := s.fetch(context.Background())
+	if err != nil {
+		// Keep serving the cached keys while the endpoint is down
+		s.logger.Warn("JWKS refresh failed", zap.String("url", s.url), zap.Error(err))
+	}
+
+	s.mu.Lock()
+	if err == nil {
+		s.keys, s.fetchedAt = keys, time.Now()
+	}
+	s.fetching = nil
+	s.mu.Unlock()
+	close(done)
+}
+
+func (s *jwksKeySet) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, k := range s.keys {
+			return k, true
+		}
+	}
+	k, ok := s.keys[kid]
+	return k, ok
+}
+
+// fetch downloads the key set; it touches no cached state, so runs without s.mu
+func (s *jwksKeySet) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("JWKS endpoint returned %d", resp.StatusCode)
+	}
+
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decode JWKS: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(doc.Keys))
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			s.logger.Warn("Skipping unusable JWKS key", zap.String("kid", k.Kid), zap.Error(err))
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS at %s has no usable signing keys", s.url)
+	}
+	return keys, nil
+}
+
+// jwk is one entry of a JSON Web Key Set (RFC 7517); only RSA and EC keys are used
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("decode key parameter: %w", err)
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/emart/cart-service/internal/config"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+var errUnknownSigningKey = errors.New("no key for token kid")
+
+// TokenVerifier checks Bearer tokens issued by login-service. HMAC tokens are
+// verified with the shared JWT_SECRET; RS256/ES256 tokens with a PEM public key
+// or keys fetched by kid from a JWKS endpoint. iss and aud are enforced when
+// configured, and exp/nbf are checked with JWT_LEEWAY of clock skew.
+type TokenVerifier struct {
+	parser  *jwt.Parser
+	keyfunc jwt.Keyfunc
+}
+
+func NewTokenVerifier(cfg *config.Config, logger *zap.Logger) (*TokenVerifier, error) {
+	jc := cfg.JWT
+	alg := strings.ToUpper(jc.Algorithm)
+	if alg == "" {
+		alg = "HS256"
+	}
+
+	opts := []jwt.ParserOption{jwt.WithLeeway(jc.Leeway)}
+	if jc.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(jc.Issuer))
+	}
+	if jc.Audience != "" {
+		opts = append(opts, jwt.WithAudience(jc.Audience))
+	}
+
+	v := &TokenVerifier{}
+	switch alg {
+	case "HS256", "HS384", "HS512":
+		if jc.Secret == "" {
+			return nil, fmt.Errorf("JWT_SECRET is required for %s", alg)
+		}
+		// login-service picks the HMAC size from its key length; accept any of them
+		opts = append(opts, jwt.WithValidMethods([]string{"HS256", "HS384", "HS512"}))
+		secret := []byte(jc.Secret)
+		v.keyfunc = func(*jwt.Token) (interface{}, error) { return secret, nil }
+
+	case "RS256", "ES256":
+		// Pinning the method stops HMAC tokens signed with the public key
+		opts = append(opts, jwt.WithValidMethods([]string{alg}))
+		switch {
+		case jc.JWKSURL != "":
+			keys := newJWKSKeySet(jc.JWKSURL, jc.JWKSRefresh, logger)
+			v.keyfunc = func(t *jwt.Token) (interface{}, error) {
+				kid, _ := t.Header["kid"].(string)
+				return keys.key(kid)
+			}
+		case jc.PublicKeyFile != "":
+			key, err := loadPublicKey(jc.PublicKeyFile, alg)
+			if err != nil {
+				return nil, err
+			}
+			v.keyfunc = func(*jwt.Token) (interface{}, error) { return key, nil }
+		default:
+			return nil, fmt.Errorf("%s needs JWT_JWKS_URL or JWT_PUBLIC_KEY_FILE", alg)
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported JWT_ALGORITHM %q", jc.Algorithm)
+	}
+
+	v.parser = jwt.NewParser(opts...)
+	return v, nil
+}
+
+// Verify parses and validates a raw token and returns its claims
+func (v *TokenVerifier) Verify(tokenStr string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	token, err := v.parser.ParseWithClaims(tokenStr, claims, v.keyfunc)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, jwt.ErrTokenUnverifiable
+	}
+	return claims, nil
+}
+
+func loadPublicKey(path, alg string) (crypto.PublicKey, error) {
+	pemBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read JWT public key: %w", err)
+	}
+	var key crypto.PublicKey
+	if alg == "RS256" {
+		key, err = jwt.ParseRSAPublicKeyFromPEM(pemBytes)
+	} else {
+		key, err = jwt.ParseECPublicKeyFromPEM(pemBytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse JWT public key %s: %w", path, err)
+	}
+	return key, nil
+}
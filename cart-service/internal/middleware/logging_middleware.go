@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/emart/cart-service/internal/logging"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// RequestIDHeader carries the correlation ID in and out of the service
+const RequestIDHeader = "X-Request-ID"
+
+const maxRequestIDLength = 128
+
+// redactedHeaders never reach the logs; they carry credentials
+var redactedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+	"X-Guest-Token":       true,
+	"X-Service-Secret":    true,
+	"X-Api-Key":           true,
+}
+
+// RequestLogger assigns every request a correlation ID (the caller's
+// X-Request-ID when usable, otherwise a new UUID), echoes it in the response
+// and puts a request-scoped logger carrying it into the request context. Once
+// the request completes it writes one access log line: 5xx at error, 4xx at
+// warn, health probes and scrapes at debug, everything else at info.
+func RequestLogger(logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = uuid.New().String()
+		}
+		c.Header(RequestIDHeader, requestID)
+
+		ctx := logging.WithRequestID(c.Request.Context(), requestID)
+		ctx = logging.NewContext(ctx, logger.With(zap.String("request_id", requestID)))
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := c.Writer.Status()
+		fields := []zap.Field{
+			zap.String("method", c.Request.Method),
+			zap.String("route", route),
+			zap.String("path", c.Request.URL.Path),
+			zap.Int("status", status),
+			zap.Duration("latency", time.Since(start)),
+			zap.Int("bytes", c.Writer.Size()),
+			zap.String("client_ip", c.ClientIP()),
+			zap.String("user_id", c.GetString("user_id")),
+			zap.String("user_agent", c.Request.UserAgent()),
+			zap.Any("headers", redactHeaders(c.Request.Header)),
+		}
+		if len(c.Errors) > 0 {
+			fields = append(fields, zap.String("errors", c.Errors.String()))
+		}
+
+		level := zapcore.InfoLevel
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = zapcore.ErrorLevel
+		case status >= http.StatusBadRequest:
+			level = zapcore.WarnLevel
+		case strings.HasPrefix(route, "/health") || route == "/metrics":
+			level = zapcore.DebugLevel
+		}
+		if ce := logging.FromContext(ctx, logger).Check(level, "HTTP request"); ce != nil {
+			ce.Write(fields...)
+		}
+	}
+}
+
+// validRequestID accepts short IDs of URL-safe characters, so a caller cannot
+// inject log lines or oversized values through the header
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// redactHeaders flattens h for logging, masking credential headers
+func redactHeaders(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for name, values := range h {
+		if redactedHeaders[http.CanonicalHeaderKey(name)] {
+			out[name] = "[REDACTED]"
+			continue
+		}
+		out[name] = strings.Join(values, ", ")
+	}
+	return out
+}
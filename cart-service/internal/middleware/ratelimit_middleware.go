@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/emart/cart-service/internal/logging"
+	"github.com/emart/cart-service/internal/model"
+	"github.com/emart/cart-service/internal/ratelimit"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// RateLimitMiddleware applies the policy's token buckets per caller: the user
+// ID for logged-in users, the client IP for guests (who can mint new guest IDs
+// at will) and anonymous callers. Mount it after the identity middleware.
+// Every limited response carries RateLimit-Limit/Remaining/Reset; a rejected
+// one is a 429 with Retry-After.
+func RateLimitMiddleware(limiter ratelimit.Limiter, policy *ratelimit.Policy, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bucket, rule, ok := policy.For(c.Request.Method, c.FullPath())
+		if !ok {
+			c.Next()
+			return
+		}
+		subject := "ip:" + c.ClientIP()
+		if userID := c.GetString("user_id"); userID != "" && !c.GetBool("is_guest") {
+			subject = "user:" + userID
+		}
+
+		res, err := limiter.Allow(c.Request.Context(), bucket+":"+subject, rule)
+		if err != nil {
+			// Fail open: a limiter fault must not take the cart down
+			logging.FromContext(c.Request.Context(), logger).Warn("Rate limit check failed, allowing request",
+				zap.String("bucket", bucket), zap.Error(err))
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.Itoa(rule.Burst))
+		c.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+		if !res.Allowed {
+			c.Header("Retry-After", strconv.Itoa(max(1, ceilSeconds(res.RetryAfter))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, model.ErrorResponse("Too many requests, please retry later"))
+			return
+		}
+		c.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
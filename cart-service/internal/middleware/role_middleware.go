@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/emart/cart-service/internal/model"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// RequireRole lets a request through only when the verified JWT lists role in
+// roleClaim. It must run after JWTAuthMiddleware.
+func RequireRole(roleClaim, role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, _ := c.MustGet("claims").(jwt.MapClaims)
+		if !hasRole(claims[roleClaim], role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, model.ErrorResponse("Insufficient role"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// hasRole accepts the claim as a JSON array or a space-separated string (OAuth scope style)
+func hasRole(claim interface{}, role string) bool {
+	switch v := claim.(type) {
+	case []interface{}:
+		for _, r := range v {
+			if s, ok := r.(string); ok && s == role {
+				return true
+			}
+		}
+	case string:
+		for _, r := range strings.Fields(v) {
+			if r == role {
+				return true
+			}
+		}
+	}
+	return false
+}
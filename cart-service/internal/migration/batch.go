@@ -0,0 +1,181 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// stateInProgress marks a batched data migration that has checkpointed but not finished
+const stateInProgress = "IN_PROGRESS"
+
+// maxRewriteAttempts bounds how often a document is re-read when a live writer
+// changes it between our read and our write
+const maxRewriteAttempts = 3
+
+// Transform rewrites one document in place and reports whether it changed.
+// It must be idempotent: after a crash the current batch is visited again.
+type Transform func(doc bson.M) (changed bool, err error)
+
+// BatchOptions tunes RewriteInBatches. Filter should select only documents that
+// still need the change (for carts, usually by schema_version) so a restarted
+// run has little to redo.
+type BatchOptions struct {
+	Collection string        // defaults to "carts"
+	Filter     bson.M        // documents to visit; nil visits all
+	BatchSize  int           // documents per batch, defaults to 500
+	Pause      time.Duration // sleep between batches to spare a live collection
+}
+
+// batchCheckpoint is stored on the migration's changelog entry after every batch
+type batchCheckpoint struct {
+	LastID    interface{} `bson:"lastId"`
+	Processed int64       `bson:"processed"`
+	Rewritten int64       `bson:"rewritten"`
+	At        time.Time   `bson:"at"`
+}
+
+// RewriteInBatches streams the collection in _id order, applies transform to each
+// document and writes back the ones it changed. Progress is checkpointed in the
+// changelog entry for changeID, so an interrupted run resumes after the last
+// finished batch. Where the server supports transactions a batch commits
+// together with its checkpoint; on a standalone server a crash can leave part
+// of a batch rewritten, which the next run visits again. Writes are guarded by
+// the document's version field, and a document updated concurrently is re-read
+// and transformed again.
+// It returns how many documents were rewritten in this run.
+func RewriteInBatches(ctx context.Context, db *mongo.Database, changeID string, opts BatchOptions, transform Transform) (int64, error) {
+	if opts.Collection == "" {
+		opts.Collection = "carts"
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+	coll := db.Collection(opts.Collection)
+	changeLog := db.Collection(changeLogCollection)
+
+	var progress batchCheckpoint
+	var entry struct {
+		Checkpoint *batchCheckpoint `bson:"checkpoint"`
+	}
+	err := changeLog.FindOne(ctx, bson.M{"changeId": changeID}).Decode(&entry)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return 0, fmt.Errorf("read checkpoint: %w", err)
+	}
+	if entry.Checkpoint != nil {
+		progress = *entry.Checkpoint
+	}
+	var rewritten int64
+
+	for {
+		filter := bson.M{}
+		for k, v := range opts.Filter {
+			filter[k] = v
+		}
+		if progress.LastID != nil {
+			filter = bson.M{"$and": bson.A{filter, bson.M{"_id": bson.M{"$gt": progress.LastID}}}}
+		}
+		findOpts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(opts.BatchSize))
+
+		var next batchCheckpoint
+		var found int
+		var batchRewritten int64
+		err := inTransaction(ctx, db, func(ctx context.Context) error {
+			// The batch is read inside the transaction: a retried attempt must not
+			// see documents an aborted one already transformed in memory
+			cursor, err := coll.Find(ctx, filter, findOpts)
+			if err != nil {
+				return fmt.Errorf("read batch: %w", err)
+			}
+			var docs []bson.M
+			if err := cursor.All(ctx, &docs); err != nil {
+				return fmt.Errorf("decode batch: %w", err)
+			}
+			next, found, batchRewritten = progress, len(docs), 0
+			if found == 0 {
+				return nil
+			}
+			for _, doc := range docs {
+				changed, err := rewrite(ctx, coll, doc, transform)
+				if err != nil {
+					return fmt.Errorf("rewrite %v: %w", doc["_id"], err)
+				}
+				if changed {
+					batchRewritten++
+					next.Rewritten++
+				}
+				next.Processed++
+				next.LastID = doc["_id"]
+			}
+
+			next.At = time.Now()
+			_, err = changeLog.UpdateOne(ctx, bson.M{"changeId": changeID},
+				bson.M{"$set": bson.M{"changeId": changeID, "state": stateInProgress, "checkpoint": next}},
+				options.Update().SetUpsert(true))
+			if err != nil {
+				return fmt.Errorf("save checkpoint: %w", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return rewritten, err
+		}
+		if found == 0 {
+			return rewritten, nil
+		}
+		progress = next
+		rewritten += batchRewritten
+
+		if found < opts.BatchSize {
+			return rewritten, nil
+		}
+		if opts.Pause > 0 {
+			select {
+			case <-ctx.Done():
+				return rewritten, ctx.Err()
+			case <-time.After(opts.Pause):
+			}
+		}
+	}
+}
+
+// rewrite applies transform to one document and replaces it if its version is
+// unchanged, re-reading it when a concurrent writer got there first.
+func rewrite(ctx context.Context, coll *mongo.Collection, doc bson.M, transform Transform) (bool, error) {
+	id := doc["_id"]
+	for attempt := 1; attempt <= maxRewriteAttempts; attempt++ {
+		guard := bson.M{"_id": id}
+		if version, ok := doc["version"]; ok {
+			guard["version"] = version
+		} else {
+			guard["version"] = bson.M{"$exists": false}
+		}
+
+		changed, err := transform(doc)
+		if err != nil || !changed {
+			return false, err
+		}
+		res, err := coll.ReplaceOne(ctx, guard, doc)
+		if err != nil {
+			return false, err
+		}
+		if res.MatchedCount == 1 {
+			return true, nil
+		}
+
+		// Modified or deleted since we read it
+		doc = bson.M{}
+		err = coll.FindOne(ctx, bson.M{"_id": id}).Decode(&doc)
+		if err == mongo.ErrNoDocuments {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	return false, fmt.Errorf("document kept changing after %d attempts", maxRewriteAttempts)
+}
@@ -0,0 +1,69 @@
+package migration
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"strings"
+)
+
+// sources holds the migration files so each one can be fingerprinted at runtime
+//
+//go:embed v*.go
+var sources embed.FS
+
+// checksumScheme prefixes every checksum. Entries recorded under an earlier
+// scheme cannot be compared and are re-baselined instead.
+const checksumScheme = "tok2:"
+
+// sourceChecksum returns the SHA-256 of a migration's Go tokens. Comments and
+// whitespace are skipped, so editing comments or formatting leaves it
+// unchanged while any change to the code does not. Only the token text is
+// hashed, which the language fixes, so a toolchain upgrade leaves it alone too.
+// `migrate repair` re-baselines the changelog after a deliberate edit.
+func sourceChecksum(file string) (string, error) {
+	src, err := sources.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("migration source %s not embedded: %w", file, err)
+	}
+
+	var errs scanner.ErrorList
+	var s scanner.Scanner
+	s.Init(token.NewFileSet().AddFile(file, -1, len(src)), src, errs.Add, 0)
+	h := sha256.New()
+	semicolon := false
+	for {
+		_, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.SEMICOLON {
+			// Written or inserted at a line end it is the same token, and
+			// before a closing bracket it is optional, so `{ return x }` and
+			// the same block split over lines hash alike
+			semicolon = true
+			continue
+		}
+		if semicolon && tok != token.RPAREN && tok != token.RBRACE {
+			fmt.Fprint(h, "1:;\n")
+		}
+		semicolon = false
+		if lit == "" {
+			lit = tok.String()
+		}
+		fmt.Fprintf(h, "%d:%s\n", len(lit), lit)
+	}
+	if err := errs.Err(); err != nil {
+		return "", fmt.Errorf("scan migration source %s: %w", file, err)
+	}
+	return checksumScheme + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// comparableChecksum reports whether a recorded checksum uses the current
+// scheme; anything else (none at all, or an earlier scheme) is re-baselined
+func comparableChecksum(recorded string) bool {
+	return strings.HasPrefix(recorded, checksumScheme)
+}
@@ -0,0 +1,179 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Migrations create collections and indexes through the helpers below, which
+// note on the changelog entry what the run actually created. Rollback then
+// removes only those, so rolling back never drops a collection (and its data)
+// or an index that was there before the migration ran. Every run starts from an
+// empty record (see trackCreated); an entry without one was executed before this
+// was tracked, and Down refuses to roll it back unless forced.
+
+// changeIDKey carries the ID of the migration being run or rolled back
+type changeIDKey struct{}
+
+func withChange(ctx context.Context, changeID string) context.Context {
+	return context.WithValue(ctx, changeIDKey{}, changeID)
+}
+
+func changeIDFrom(ctx context.Context) (string, error) {
+	id, ok := ctx.Value(changeIDKey{}).(string)
+	if !ok {
+		return "", errors.New("migration helper used outside the runner")
+	}
+	return id, nil
+}
+
+// createdObjects is the "created" field of a changelog entry
+type createdObjects struct {
+	Collections []string `bson:"collections"`
+	Indexes     []string `bson:"indexes"` // "<collection>.<index>"
+}
+
+// trackCreated gives the running migration's entry an empty created record
+// unless it has one, which a data migration resuming after a failure keeps
+func trackCreated(ctx context.Context, db *mongo.Database) error {
+	changeID, err := changeIDFrom(ctx)
+	if err != nil {
+		return err
+	}
+	changeLog := db.Collection(changeLogCollection)
+	empty := createdObjects{Collections: []string{}, Indexes: []string{}}
+	res, err := changeLog.UpdateOne(ctx, bson.M{"changeId": changeID, "created": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"created": empty}})
+	if err == nil && res.MatchedCount == 0 {
+		// No entry yet, or one that already tracks
+		_, err = changeLog.UpdateOne(ctx, bson.M{"changeId": changeID},
+			bson.M{"$setOnInsert": bson.M{"state": stateInProgress, "created": empty}},
+			options.Update().SetUpsert(true))
+	}
+	if err != nil {
+		return fmt.Errorf("start created record: %w", err)
+	}
+	return nil
+}
+
+// noteCreated adds name to created.<kind> on the running migration's entry
+func noteCreated(ctx context.Context, db *mongo.Database, kind, name string) error {
+	changeID, err := changeIDFrom(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = db.Collection(changeLogCollection).UpdateOne(ctx, bson.M{"changeId": changeID},
+		bson.M{"$addToSet": bson.M{"created." + kind: name}, "$setOnInsert": bson.M{"state": stateInProgress}},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("record created %s %s: %w", kind, name, err)
+	}
+	return nil
+}
+
+func createdByRun(ctx context.Context, db *mongo.Database) (createdObjects, error) {
+	changeID, err := changeIDFrom(ctx)
+	if err != nil {
+		return createdObjects{}, err
+	}
+	var entry struct {
+		Created createdObjects `bson:"created"`
+	}
+	err = db.Collection(changeLogCollection).FindOne(ctx, bson.M{"changeId": changeID}).Decode(&entry)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return createdObjects{}, fmt.Errorf("read created objects: %w", err)
+	}
+	return entry.Created, nil
+}
+
+// createCollection creates name unless it already exists. It is noted only
+// once created, so a collection someone else created in between is never ours.
+func createCollection(ctx context.Context, db *mongo.Database, name string, opts ...*options.CreateCollectionOptions) error {
+	names, err := db.ListCollectionNames(ctx, bson.M{"name": name})
+	if err != nil {
+		return fmt.Errorf("list collections: %w", err)
+	}
+	if len(names) > 0 {
+		return nil
+	}
+	if err := db.CreateCollection(ctx, name, opts...); err != nil {
+		return fmt.Errorf("create %s collection: %w", name, err)
+	}
+	return noteCreated(ctx, db, "collections", name)
+}
+
+// createIndexes builds the given indexes, which must be named, and notes the
+// ones that did not exist yet
+func createIndexes(ctx context.Context, coll *mongo.Collection, models []mongo.IndexModel) error {
+	specs, err := coll.Indexes().ListSpecifications(ctx)
+	if err != nil {
+		return fmt.Errorf("list %s indexes: %w", coll.Name(), err)
+	}
+	existing := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		existing[spec.Name] = true
+	}
+	if _, err := coll.Indexes().CreateMany(ctx, models); err != nil {
+		return fmt.Errorf("create %s indexes: %w", coll.Name(), err)
+	}
+	for _, index := range models {
+		if index.Options == nil || index.Options.Name == nil {
+			return fmt.Errorf("index on %s has no name", coll.Name())
+		}
+		name := *index.Options.Name
+		if existing[name] {
+			continue
+		}
+		if err := noteCreated(ctx, coll.Database(), "indexes", coll.Name()+"."+name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropCreatedCollection drops name if this migration's run created it
+func dropCreatedCollection(ctx context.Context, db *mongo.Database, name string) error {
+	created, err := createdByRun(ctx, db)
+	if err != nil {
+		return err
+	}
+	for _, c := range created.Collections {
+		if c == name {
+			return db.Collection(name).Drop(ctx)
+		}
+	}
+	return nil
+}
+
+// dropCreatedIndexes drops those of the named indexes that this migration's
+// run created on coll
+func dropCreatedIndexes(ctx context.Context, coll *mongo.Collection, names ...string) error {
+	created, err := createdByRun(ctx, coll.Database())
+	if err != nil {
+		return err
+	}
+	ours := make(map[string]bool, len(created.Indexes))
+	for _, idx := range created.Indexes {
+		ours[idx] = true
+	}
+	for _, name := range names {
+		if !ours[coll.Name()+"."+name] {
+			continue
+		}
+		if _, err := coll.Indexes().DropOne(ctx, name); err != nil && !isNotFound(err) {
+			return fmt.Errorf("drop index %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// isNotFound matches NamespaceNotFound and IndexNotFound: already gone
+func isNotFound(err error) bool {
+	var cmdErr mongo.CommandError
+	return errors.As(err, &cmdErr) && (cmdErr.Code == 26 || cmdErr.Code == 27)
+}
@@ -0,0 +1,172 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+// Defaults used when the config leaves the lock settings at zero
+const (
+	defaultLockTTL   = time.Minute
+	defaultLockRetry = 5 * time.Second
+)
+
+// lockOwner names this process uniquely: hostname:pid:uuid
+func lockOwner() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s:%d:%s", host, os.Getpid(), uuid.NewString())
+}
+
+// acquireLock takes the migration lock, retrying every MIGRATION_LOCK_RETRY
+// until MIGRATION_LOCK_WAIT elapses. While held, a heartbeat keeps extending
+// expiresAt so a slow migration is not taken over. The returned context is
+// cancelled if the heartbeat finds the lock gone; release stops the heartbeat
+// and unlocks only if this runner still owns the lock.
+func (r *Runner) acquireLock(ctx context.Context) (context.Context, func(), error) {
+	ttl, retry := r.cfg.Migration.LockTTL, r.cfg.Migration.LockRetryInterval
+	if ttl <= 0 {
+		ttl = defaultLockTTL
+	}
+	if retry <= 0 {
+		retry = defaultLockRetry
+	}
+	deadline := time.Now().Add(r.cfg.Migration.LockWait)
+
+	for {
+		acquired, holder, err := r.tryLock(ctx, ttl)
+		if err != nil {
+			return nil, nil, err
+		}
+		if acquired {
+			break
+		}
+		if !time.Now().Before(deadline) {
+			return nil, nil, fmt.Errorf("migration lock held by %s", holder)
+		}
+		r.logger.Info("Migration lock busy, waiting", zap.String("holder", holder), zap.Duration("retry", retry))
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-time.After(retry):
+		}
+	}
+	r.logger.Info("Migration lock acquired", zap.String("owner", r.owner))
+
+	lockCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go r.heartbeat(lockCtx, cancel, ttl, done)
+
+	release := func() {
+		cancel()
+		<-done
+		r.releaseLock(context.WithoutCancel(ctx))
+	}
+	return lockCtx, release, nil
+}
+
+// tryLock makes one attempt. A free, expired or already owned lock is taken;
+// otherwise the current holder is returned.
+func (r *Runner) tryLock(ctx context.Context, ttl time.Duration) (bool, string, error) {
+	coll := r.db.Collection(lockCollection)
+	now := time.Now()
+	filter := bson.M{"_id": lockID, "$or": bson.A{
+		bson.M{"locked": false},
+		bson.M{"expiresAt": bson.M{"$lt": now}},
+		bson.M{"owner": r.owner},
+	}}
+	update := bson.M{"$set": bson.M{"locked": true, "owner": r.owner, "lockedAt": now, "expiresAt": now.Add(ttl)}}
+	_, err := coll.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err == nil {
+		return true, "", nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return false, "", fmt.Errorf("acquire migration lock: %w", err)
+	}
+
+	// The filter missed an existing lock document, so the upsert collided with it
+	var held struct {
+		Owner     string    `bson:"owner"`
+		ExpiresAt time.Time `bson:"expiresAt"`
+	}
+	if err := coll.FindOne(ctx, bson.M{"_id": lockID}).Decode(&held); err != nil && err != mongo.ErrNoDocuments {
+		return false, "", fmt.Errorf("read migration lock: %w", err)
+	}
+	if held.Owner == "" {
+		held.Owner = "unknown owner"
+	}
+	return false, fmt.Sprintf("%s until %s", held.Owner, held.ExpiresAt.Format(time.RFC3339)), nil
+}
+
+// heartbeat extends the lock every third of its TTL until ctx ends. If the
+// lock was lost (force unlocked, or taken over after a stall) it cancels the
+// run so no further migrations execute without it.
+func (r *Runner) heartbeat(ctx context.Context, cancel context.CancelFunc, ttl time.Duration, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			res, err := r.db.Collection(lockCollection).UpdateOne(ctx,
+				bson.M{"_id": lockID, "owner": r.owner, "locked": true},
+				bson.M{"$set": bson.M{"expiresAt": time.Now().Add(ttl)}})
+			if err != nil {
+				// Transient; the next beat retries well before expiry
+				r.logger.Warn("Migration lock heartbeat failed", zap.Error(err))
+				continue
+			}
+			if res.MatchedCount == 0 {
+				r.logger.Error("Migration lock lost, aborting run", zap.String("owner", r.owner))
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+// releaseLock unlocks only if this runner still owns the lock
+func (r *Runner) releaseLock(ctx context.Context) {
+	res, err := r.db.Collection(lockCollection).UpdateOne(ctx,
+		bson.M{"_id": lockID, "owner": r.owner},
+		bson.M{"$set": bson.M{"locked": false}, "$unset": bson.M{"owner": ""}})
+	if err != nil {
+		r.logger.Warn("Failed to release migration lock", zap.Error(err))
+		return
+	}
+	if res.MatchedCount == 0 {
+		r.logger.Warn("Migration lock was no longer ours at release", zap.String("owner", r.owner))
+	}
+}
+
+// Unlock force-releases the migration lock left behind by a crashed run,
+// whoever holds it
+func (r *Runner) Unlock(ctx context.Context) error {
+	var held struct {
+		Owner string `bson:"owner"`
+	}
+	err := r.db.Collection(lockCollection).FindOneAndUpdate(ctx,
+		bson.M{"_id": lockID},
+		bson.M{"$set": bson.M{"locked": false}, "$unset": bson.M{"owner": ""}}).Decode(&held)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("release migration lock: %w", err)
+	}
+	r.logger.Info("Migration lock released", zap.String("previousOwner", held.Owner))
+	return nil
+}
@@ -2,9 +2,12 @@ package migration
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/emart/cart-service/internal/config"
 	"github.com/emart/cart-service/internal/model"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -16,41 +19,132 @@ type Migration interface {
 	ID() string
 	Order() string
 	Author() string
+	Checksum() (string, error)
 	Execute(ctx context.Context, db *mongo.Database) error
 	Rollback(ctx context.Context, db *mongo.Database) error
 }
 
+// Transactional is implemented by data migrations: they rewrite documents,
+// after at most an idempotent validator change. Their writes commit in
+// transactions when the server supports them (see inTransaction), and a failed
+// run is recorded FAILED and finished by the next Up rather than undone with
+// Rollback, which would also revert documents the application wrote since.
+// Execute must therefore be safe to run again. Schema migrations (collections,
+// indexes) are undone with Rollback, which removes only what the run created.
+type Transactional interface {
+	Transactional() bool
+}
+
+// ErrChecksumMismatch means an executed migration was edited after it ran
+var ErrChecksumMismatch = errors.New("migration checksum mismatch")
+
+// ErrUntracked means an executed migration ran before created objects were
+// recorded, so its Rollback cannot tell what the run created
+var ErrUntracked = errors.New("migration has no record of what it created")
+
 type Runner struct {
 	db         *mongo.Database
+	cfg        *config.Config
 	logger     *zap.Logger
 	migrations []Migration
+	owner      string            // identifies this process in the lock document
+	sums       map[string]string // checksum by migration ID, see loadChecksums
 }
 
 const (
 	changeLogCollection = "mongockChangeLog"
 	lockCollection      = "mongockLock"
+	lockID              = "migration-lock"
 	stateExecuted       = "EXECUTED"
-	stateFailed         = "FAILED"
+	stateRolledBack     = "ROLLED_BACK"
+	stateFailed         = "FAILED" // a data migration that stopped; Up resumes it
+	stateRollbackFailed = "ROLLBACK_FAILED"
+	statePending        = "PENDING" // reported only; never stored
 )
 
-func NewRunner(db *mongo.Database, logger *zap.Logger) *Runner {
-	r := &Runner{db: db, logger: logger}
-	r.migrations = []Migration{
+// Base is the Down target that rolls back every migration
+const Base = "base"
+
+// Options controls Up and Down. Up applies pending migrations up to and including
+// Target (all when empty). Down rolls back executed migrations newer than Target,
+// or only the latest one when Target is empty. DryRun logs the plan without
+// touching the database. Force lets Down roll back migrations that have no
+// record of what they created; their Rollback then acts on whatever is there.
+type Options struct {
+	Target string
+	DryRun bool
+	Force  bool
+}
+
+// Status is one known migration with its state from mongockChangeLog
+type Status struct {
+	ID         string
+	Order      string
+	Author     string
+	State      string
+	ExecutedAt *time.Time
+	Error      string
+	Drifted    bool // the code changed since the migration was executed
+}
+
+func NewRunner(db *mongo.Database, cfg *config.Config, logger *zap.Logger) *Runner {
+	return NewRunnerWith(db, cfg, logger,
 		NewV001CreateCartsCollection(),
 		NewV002AddCartIndexes(),
 		NewV003AddSchemaValidation(),
-	}
-	return r
+		NewV004AddGuestCartTTLIndex(),
+		NewV005ConvertPricesToDecimal(),
+		NewV006CreateCouponsCollection(),
+		NewV007CreateCartOutbox(),
+		NewV008CreateCheckoutSessions(),
+		NewV009CreateAdminAuditLog(),
+		NewV010CreateCouponUsage(),
+		NewV011BackfillSchemaVersion(),
+	)
+}
+
+// NewRunnerWith runs the given migrations instead of the built-in ones
+func NewRunnerWith(db *mongo.Database, cfg *config.Config, logger *zap.Logger, migrations ...Migration) *Runner {
+	return &Runner{db: db, cfg: cfg, logger: logger, owner: lockOwner(), migrations: migrations}
+}
+
+// Migrations lists the known migrations in execution order
+func (r *Runner) Migrations() []Migration {
+	return r.migrations
 }
 
+// Run applies every pending migration
 func (r *Runner) Run(ctx context.Context) error {
+	return r.Up(ctx, Options{})
+}
+
+// Up applies pending migrations in order, stopping after opts.Target
+func (r *Runner) Up(ctx context.Context, opts Options) error {
 	r.logger.Info("Mongock-Go migration runner starting")
-	if err := r.acquireLock(ctx); err != nil {
-		return fmt.Errorf("cannot acquire migration lock: %w", err)
+	if err := r.loadChecksums(); err != nil {
+		return err
+	}
+	last := len(r.migrations) - 1
+	if opts.Target != "" {
+		idx, err := r.indexOf(opts.Target)
+		if err != nil {
+			return err
+		}
+		last = idx
+	}
+	if !opts.DryRun {
+		lockCtx, release, err := r.acquireLock(ctx)
+		if err != nil {
+			return fmt.Errorf("cannot acquire migration lock: %w", err)
+		}
+		defer release()
+		ctx = lockCtx
+	}
+	if err := r.verifyChecksums(ctx, opts.DryRun); err != nil {
+		return err
 	}
-	defer r.releaseLock(ctx)
 
-	for _, m := range r.migrations {
+	for _, m := range r.migrations[:last+1] {
 		executed, err := r.isExecuted(ctx, m.ID())
 		if err != nil {
 			return fmt.Errorf("check migration state %s: %w", m.ID(), err)
@@ -59,10 +153,13 @@ func (r *Runner) Run(ctx context.Context) error {
 			r.logger.Info("Skipping already executed migration", zap.String("id", m.ID()))
 			continue
 		}
+		if opts.DryRun {
+			r.logger.Info("Would execute migration", zap.String("id", m.ID()))
+			continue
+		}
 		r.logger.Info("Executing migration", zap.String("id", m.ID()))
-		if err := m.Execute(ctx, r.db); err != nil {
-			r.recordState(ctx, m, stateFailed, err.Error())
-			return fmt.Errorf("migration %s failed: %w", m.ID(), err)
+		if err := r.execute(ctx, m); err != nil {
+			return err
 		}
 		r.recordState(ctx, m, stateExecuted, "")
 		r.logger.Info("Migration executed", zap.String("id", m.ID()))
@@ -71,6 +168,236 @@ func (r *Runner) Run(ctx context.Context) error {
 	return nil
 }
 
+// Down rolls back executed migrations newest first. With an empty opts.Target
+// only the latest executed migration is rolled back; Base rolls back all of them.
+func (r *Runner) Down(ctx context.Context, opts Options) error {
+	if err := r.loadChecksums(); err != nil {
+		return err
+	}
+	keep := -1
+	if opts.Target != Base && opts.Target != "" {
+		idx, err := r.indexOf(opts.Target)
+		if err != nil {
+			return err
+		}
+		keep = idx
+	}
+	if !opts.DryRun {
+		lockCtx, release, err := r.acquireLock(ctx)
+		if err != nil {
+			return fmt.Errorf("cannot acquire migration lock: %w", err)
+		}
+		defer release()
+		ctx = lockCtx
+	}
+
+	var plan []Migration
+	for i := len(r.migrations) - 1; i > keep; i-- {
+		m := r.migrations[i]
+		executed, err := r.isExecuted(ctx, m.ID())
+		if err != nil {
+			return fmt.Errorf("check migration state %s: %w", m.ID(), err)
+		}
+		if !executed {
+			continue
+		}
+		plan = append(plan, m)
+		if opts.Target == "" {
+			break
+		}
+	}
+	// Checked for the whole plan up front, so nothing is rolled back when the
+	// run would stop halfway
+	if !opts.Force {
+		var untracked []string
+		for _, m := range plan {
+			tracked, err := r.tracksCreated(ctx, m.ID())
+			if err != nil {
+				return fmt.Errorf("check migration state %s: %w", m.ID(), err)
+			}
+			if !tracked {
+				untracked = append(untracked, m.ID())
+			}
+		}
+		if len(untracked) > 0 {
+			return fmt.Errorf("%w: %s (it ran before this was tracked; use --force to roll back anyway)", ErrUntracked, strings.Join(untracked, ", "))
+		}
+	}
+
+	for _, m := range plan {
+		if opts.DryRun {
+			r.logger.Info("Would roll back migration", zap.String("id", m.ID()))
+			continue
+		}
+		r.logger.Info("Rolling back migration", zap.String("id", m.ID()))
+		if err := m.Rollback(withChange(ctx, m.ID()), r.db); err != nil {
+			r.recordState(ctx, m, stateRollbackFailed, err.Error())
+			return fmt.Errorf("rollback %s failed: %w", m.ID(), err)
+		}
+		r.recordState(ctx, m, stateRolledBack, "")
+		r.logger.Info("Migration rolled back", zap.String("id", m.ID()))
+	}
+	return nil
+}
+
+// Status reports every known migration in order; migrations without a
+// changelog entry are PENDING.
+func (r *Runner) Status(ctx context.Context) ([]Status, error) {
+	if err := r.loadChecksums(); err != nil {
+		return nil, err
+	}
+	cursor, err := r.db.Collection(changeLogCollection).Find(ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("read changelog: %w", err)
+	}
+	defer cursor.Close(ctx)
+	var records []model.MigrationRecord
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, fmt.Errorf("decode changelog: %w", err)
+	}
+
+	statuses := make([]Status, 0, len(r.migrations))
+	for _, m := range r.migrations {
+		st := Status{ID: m.ID(), Order: m.Order(), Author: m.Author(), State: statePending}
+		for _, rec := range records {
+			if rec.ChangeID == m.ID() {
+				executedAt := rec.ExecutedAt
+				st.State, st.ExecutedAt, st.Error = rec.State, &executedAt, rec.Error
+				st.Drifted = rec.State == stateExecuted && comparableChecksum(rec.Checksum) && rec.Checksum != r.sums[m.ID()]
+				break
+			}
+		}
+		statuses = append(statuses, st)
+	}
+	return statuses, nil
+}
+
+// Repair re-baselines the stored checksum of every executed migration to the
+// current code. Run it after reviewing a deliberate edit to an old migration.
+func (r *Runner) Repair(ctx context.Context) error {
+	if err := r.loadChecksums(); err != nil {
+		return err
+	}
+	ctx, release, err := r.acquireLock(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot acquire migration lock: %w", err)
+	}
+	defer release()
+
+	for _, m := range r.migrations {
+		res, err := r.db.Collection(changeLogCollection).UpdateOne(ctx,
+			bson.M{"changeId": m.ID(), "state": stateExecuted},
+			bson.M{"$set": bson.M{"checksum": r.sums[m.ID()]}})
+		if err != nil {
+			return fmt.Errorf("repair checksum %s: %w", m.ID(), err)
+		}
+		if res.ModifiedCount > 0 {
+			r.logger.Info("Migration checksum re-baselined", zap.String("id", m.ID()))
+		}
+	}
+	return nil
+}
+
+// verifyChecksums compares executed migrations with the code. Entries written
+// before checksums existed, or under an older checksum scheme, are backfilled.
+// Drift fails the run unless MIGRATION_ALLOW_DRIFT is set, in which case it is
+// only logged.
+func (r *Runner) verifyChecksums(ctx context.Context, dryRun bool) error {
+	var drifted []string
+	for _, m := range r.migrations {
+		var rec model.MigrationRecord
+		err := r.db.Collection(changeLogCollection).FindOne(ctx, bson.M{"changeId": m.ID(), "state": stateExecuted}).Decode(&rec)
+		if err == mongo.ErrNoDocuments {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("check migration checksum %s: %w", m.ID(), err)
+		}
+		current := r.sums[m.ID()]
+		switch {
+		case !comparableChecksum(rec.Checksum) && !dryRun:
+			_, err := r.db.Collection(changeLogCollection).UpdateOne(ctx,
+				bson.M{"changeId": m.ID()}, bson.M{"$set": bson.M{"checksum": current}})
+			if err != nil {
+				return fmt.Errorf("backfill migration checksum %s: %w", m.ID(), err)
+			}
+		case comparableChecksum(rec.Checksum) && rec.Checksum != current:
+			r.logger.Warn("Executed migration was modified", zap.String("id", m.ID()),
+				zap.String("recorded", rec.Checksum), zap.String("current", current))
+			drifted = append(drifted, m.ID())
+		}
+	}
+	if len(drifted) == 0 || r.cfg.Migration.AllowDrift {
+		return nil
+	}
+	return fmt.Errorf("%w: %s (run `migrate repair` once the change is reviewed)", ErrChecksumMismatch, strings.Join(drifted, ", "))
+}
+
+// execute runs one migration. A failed data migration (Transactional) is
+// recorded FAILED and left for the next Up to finish. Any other failed
+// migration is undone with its Rollback and recorded ROLLED_BACK, or
+// ROLLBACK_FAILED when the undo itself failed and the database needs manual
+// attention.
+func (r *Runner) execute(ctx context.Context, m Migration) error {
+	ctx = withChange(ctx, m.ID())
+	if err := trackCreated(ctx, r.db); err != nil {
+		return err
+	}
+	err := m.Execute(ctx, r.db)
+	if err == nil {
+		return nil
+	}
+	if t, ok := m.(Transactional); ok && t.Transactional() {
+		r.recordState(ctx, m, stateFailed, err.Error())
+		return fmt.Errorf("migration %s failed; run it again to resume: %w", m.ID(), err)
+	}
+
+	r.logger.Warn("Migration failed, rolling back", zap.String("id", m.ID()), zap.Error(err))
+	if rbErr := m.Rollback(ctx, r.db); rbErr != nil {
+		r.recordState(ctx, m, stateRollbackFailed, fmt.Sprintf("%v; rollback: %v", err, rbErr))
+		return fmt.Errorf("migration %s failed: %w (rollback also failed: %v)", m.ID(), err, rbErr)
+	}
+	r.recordState(ctx, m, stateRolledBack, err.Error())
+	return fmt.Errorf("migration %s failed and was rolled back: %w", m.ID(), err)
+}
+
+// inTransaction runs fn in a multi-document transaction when the server
+// supports one, and directly otherwise
+func inTransaction(ctx context.Context, db *mongo.Database, fn func(ctx context.Context) error) error {
+	if !supportsTransactions(ctx, db) {
+		return fn(ctx)
+	}
+	session, err := db.Client().StartSession()
+	if err != nil {
+		return fmt.Errorf("start session: %w", err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
+
+// supportsTransactions reports whether the server is a replica set member or mongos
+func supportsTransactions(ctx context.Context, db *mongo.Database) bool {
+	var hello bson.M
+	if err := db.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		return false
+	}
+	_, replicaSet := hello["setName"]
+	return replicaSet || hello["msg"] == "isdbgrid"
+}
+
+func (r *Runner) indexOf(id string) (int, error) {
+	for i, m := range r.migrations {
+		if m.ID() == id {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("unknown migration %q", id)
+}
+
 func (r *Runner) isExecuted(ctx context.Context, id string) (bool, error) {
 	res := r.db.Collection(changeLogCollection).FindOne(ctx, bson.M{"changeId": id, "state": stateExecuted})
 	if res.Err() == mongo.ErrNoDocuments {
@@ -79,29 +406,48 @@ func (r *Runner) isExecuted(ctx context.Context, id string) (bool, error) {
 	return res.Err() == nil, res.Err()
 }
 
-func (r *Runner) recordState(ctx context.Context, m Migration, state, errMsg string) {
-	doc := bson.M{"changeId": m.ID(), "author": m.Author(), "state": state, "executedAt": time.Now(), "order": m.Order(), "error": errMsg}
-	opts := options.Update().SetUpsert(true)
-	r.db.Collection(changeLogCollection).UpdateOne(ctx, bson.M{"changeId": m.ID()}, bson.M{"$set": doc}, opts)
+// tracksCreated reports whether the entry for id records what its run created
+func (r *Runner) tracksCreated(ctx context.Context, id string) (bool, error) {
+	res := r.db.Collection(changeLogCollection).FindOne(ctx, bson.M{"changeId": id, "created": bson.M{"$exists": true}})
+	if res.Err() == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	return res.Err() == nil, res.Err()
 }
 
-func (r *Runner) acquireLock(ctx context.Context) error {
-	coll := r.db.Collection(lockCollection)
-	lockDoc := bson.M{"_id": "migration-lock", "locked": true, "lockedAt": time.Now(), "expiresAt": time.Now().Add(5 * time.Minute)}
-	opts := options.Update().SetUpsert(true)
-	filter := bson.M{"_id": "migration-lock", "$or": bson.A{bson.M{"locked": false}, bson.M{"expiresAt": bson.M{"$lt": time.Now()}}}}
-	result, err := coll.UpdateOne(ctx, filter, bson.M{"$set": lockDoc}, opts)
-	if err != nil {
-		return err
+// loadChecksums fingerprints every migration once. A source that is missing
+// or does not parse fails the command before anything runs.
+func (r *Runner) loadChecksums() error {
+	if r.sums != nil {
+		return nil
 	}
-	if result.MatchedCount == 0 && result.UpsertedCount == 0 {
-		return fmt.Errorf("migration lock held by another process")
+	sums := make(map[string]string, len(r.migrations))
+	for _, m := range r.migrations {
+		sum, err := m.Checksum()
+		if err != nil {
+			return fmt.Errorf("checksum migration %s: %w", m.ID(), err)
+		}
+		sums[m.ID()] = sum
 	}
+	r.sums = sums
 	return nil
 }
 
-func (r *Runner) releaseLock(ctx context.Context) {
-	r.db.Collection(lockCollection).UpdateOne(ctx, bson.M{"_id": "migration-lock"}, bson.M{"$set": bson.M{"locked": false}})
+func (r *Runner) recordState(ctx context.Context, m Migration, state, errMsg string) {
+	doc := bson.M{"changeId": m.ID(), "author": m.Author(), "state": state, "executedAt": time.Now(), "order": m.Order(), "error": errMsg, "checksum": r.sums[m.ID()]}
+	opts := options.Update().SetUpsert(true)
+	update := bson.M{"$set": doc}
+	// A failed data migration resumes from its batch checkpoint; any other
+	// outcome leaves nothing to resume
+	if state != stateFailed {
+		unset := bson.M{"checkpoint": ""}
+		if state == stateRolledBack {
+			// Whatever the run created is gone again
+			unset["created"] = ""
+		}
+		update["$unset"] = unset
+	}
+	r.db.Collection(changeLogCollection).UpdateOne(ctx, bson.M{"changeId": m.ID()}, update, opts)
 }
 
 func (r *Runner) PrintStatus(ctx context.Context) {
@@ -11,6 +11,7 @@ func NewV001CreateCartsCollection() *V001CreateCartsCollection { return &V001Cre
 func (m *V001CreateCartsCollection) ID() string     { return "V001_CreateCartsCollection" }
 func (m *V001CreateCartsCollection) Order() string  { return "001" }
 func (m *V001CreateCartsCollection) Author() string { return "emart-db-team" }
+func (m *V001CreateCartsCollection) Checksum() (string, error) { return sourceChecksum("v001_create_carts_collection.go") }
 
 func (m *V001CreateCartsCollection) Execute(ctx context.Context, db *mongo.Database) error {
 	// Create carts collection
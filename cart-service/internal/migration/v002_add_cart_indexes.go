@@ -13,6 +13,7 @@ func NewV002AddCartIndexes() *V002AddCartIndexes { return &V002AddCartIndexes{}
 func (m *V002AddCartIndexes) ID() string     { return "V002_AddCartIndexes" }
 func (m *V002AddCartIndexes) Order() string  { return "002" }
 func (m *V002AddCartIndexes) Author() string { return "emart-db-team" }
+func (m *V002AddCartIndexes) Checksum() (string, error) { return sourceChecksum("v002_add_cart_indexes.go") }
 
 func (m *V002AddCartIndexes) Execute(ctx context.Context, db *mongo.Database) error {
 	coll := db.Collection("carts")
@@ -12,6 +12,7 @@ func NewV003AddSchemaValidation() *V003AddSchemaValidation { return &V003AddSche
 func (m *V003AddSchemaValidation) ID() string     { return "V003_AddSchemaValidation" }
 func (m *V003AddSchemaValidation) Order() string  { return "003" }
 func (m *V003AddSchemaValidation) Author() string { return "emart-db-team" }
+func (m *V003AddSchemaValidation) Checksum() (string, error) { return sourceChecksum("v003_add_schema_validation.go") }
 
 func (m *V003AddSchemaValidation) Execute(ctx context.Context, db *mongo.Database) error {
 	jsonSchema := bson.M{
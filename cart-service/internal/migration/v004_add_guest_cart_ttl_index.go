@@ -0,0 +1,32 @@
+package migration
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// V004AddGuestCartTTLIndex lets MongoDB expire guest carts via their expires_at field.
+// User carts never set expires_at, so the TTL index ignores them.
+type V004AddGuestCartTTLIndex struct{}
+
+func NewV004AddGuestCartTTLIndex() *V004AddGuestCartTTLIndex { return &V004AddGuestCartTTLIndex{} }
+func (m *V004AddGuestCartTTLIndex) ID() string               { return "V004_AddGuestCartTTLIndex" }
+func (m *V004AddGuestCartTTLIndex) Order() string            { return "004" }
+func (m *V004AddGuestCartTTLIndex) Author() string           { return "emart-db-team" }
+func (m *V004AddGuestCartTTLIndex) Checksum() (string, error) {
+	return sourceChecksum("v004_add_guest_cart_ttl_index.go")
+}
+
+func (m *V004AddGuestCartTTLIndex) Execute(ctx context.Context, db *mongo.Database) error {
+	index := mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0).SetName("idx_expires_at_ttl"),
+	}
+	return createIndexes(ctx, db.Collection("carts"), []mongo.IndexModel{index})
+}
+
+func (m *V004AddGuestCartTTLIndex) Rollback(ctx context.Context, db *mongo.Database) error {
+	return dropCreatedIndexes(ctx, db.Collection("carts"), "idx_expires_at_ttl")
+}
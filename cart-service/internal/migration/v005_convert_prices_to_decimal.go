@@ -0,0 +1,146 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/emart/cart-service/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// V005ConvertPricesToDecimal moves cart prices from double to Decimal128 (model.Money).
+// It replaces the V003 validator with one that requires decimal prices and a currency,
+// then rewrites existing documents in batches, rounding float drift to 2 decimals.
+type V005ConvertPricesToDecimal struct{}
+
+func NewV005ConvertPricesToDecimal() *V005ConvertPricesToDecimal {
+	return &V005ConvertPricesToDecimal{}
+}
+func (m *V005ConvertPricesToDecimal) ID() string     { return "V005_ConvertPricesToDecimal" }
+func (m *V005ConvertPricesToDecimal) Order() string  { return "005" }
+func (m *V005ConvertPricesToDecimal) Author() string { return "emart-db-team" }
+func (m *V005ConvertPricesToDecimal) Checksum() (string, error) {
+	return sourceChecksum("v005_convert_prices_to_decimal.go")
+}
+
+// Transactional: rolling back a failed run would also revert carts saved since;
+// the rewrite only selects unconverted documents, so it is resumed instead
+func (m *V005ConvertPricesToDecimal) Transactional() bool { return true }
+
+// numericTypes are the BSON types float-era documents may hold for prices
+var numericTypes = bson.A{"double", "int", "long"}
+
+func (m *V005ConvertPricesToDecimal) Execute(ctx context.Context, db *mongo.Database) error {
+	jsonSchema := bson.M{
+		"$jsonSchema": bson.M{
+			"bsonType": "object",
+			"required": []string{"user_id", "items", "total_items", "total_price"},
+			"properties": bson.M{
+				"user_id": bson.M{"bsonType": "string", "description": "User ID from login service"},
+				"items": bson.M{
+					"bsonType":    "array",
+					"description": "Array of cart items",
+					"items": bson.M{
+						"bsonType": "object",
+						"properties": bson.M{
+							"price": bson.M{"bsonType": "decimal", "minimum": 0},
+						},
+					},
+				},
+				"total_items":    bson.M{"bsonType": "int", "minimum": 0},
+				"total_price":    bson.M{"bsonType": "decimal", "minimum": 0},
+				"currency":       bson.M{"bsonType": "string", "minLength": 3, "maxLength": 3},
+				"schema_version": bson.M{"bsonType": "int", "minimum": 1},
+			},
+		},
+	}
+
+	// Swap the validator first: under "moderate" validation, documents that fail the
+	// new schema (still double) can be updated freely, which the rewrite below relies on.
+	// collMod cannot run in a transaction; only the batches below are transactional.
+	cmd := bson.D{
+		{Key: "collMod", Value: "carts"},
+		{Key: "validator", Value: jsonSchema},
+		{Key: "validationLevel", Value: "moderate"},
+		{Key: "validationAction", Value: "error"},
+	}
+	if err := db.RunCommand(ctx, cmd).Err(); err != nil {
+		return fmt.Errorf("update carts validator: %w", err)
+	}
+
+	filter := bson.M{"$or": bson.A{
+		bson.M{"total_price": bson.M{"$type": numericTypes}},
+		bson.M{"items.price": bson.M{"$type": numericTypes}},
+		bson.M{"currency": bson.M{"$exists": false}},
+	}}
+	_, err := RewriteInBatches(ctx, db, m.ID(), BatchOptions{Filter: filter}, convertPrices)
+	return err
+}
+
+// convertPrices rewrites a float-era cart with Decimal128 prices rounded to
+// 2 decimals, read the way model.Money reads legacy values
+func convertPrices(doc bson.M) (bool, error) {
+	total, err := decimalPrice(doc["total_price"])
+	if err != nil {
+		return false, fmt.Errorf("total_price: %w", err)
+	}
+	doc["total_price"] = total
+	items, _ := doc["items"].(bson.A)
+	for i, raw := range items {
+		item, ok := raw.(bson.M)
+		if !ok {
+			return false, fmt.Errorf("item %d is not a document", i)
+		}
+		if item["price"], err = decimalPrice(item["price"]); err != nil {
+			return false, fmt.Errorf("item %d price: %w", i, err)
+		}
+	}
+	if items == nil {
+		doc["items"] = bson.A{}
+	}
+	if _, ok := doc["currency"]; !ok {
+		doc["currency"] = model.DefaultCurrency
+	}
+	// The shape this migration produces, whatever CurrentSchemaVersion becomes later
+	doc["schema_version"] = 2
+	return true, nil
+}
+
+func (m *V005ConvertPricesToDecimal) Rollback(ctx context.Context, db *mongo.Database) error {
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"total_price": bson.M{"$toDouble": "$total_price"},
+			"items": bson.M{"$map": bson.M{
+				"input": bson.M{"$ifNull": bson.A{"$items", bson.A{}}},
+				"as":    "item",
+				"in": bson.M{"$mergeObjects": bson.A{
+					"$$item",
+					bson.M{"price": bson.M{"$toDouble": "$$item.price"}},
+				}},
+			}},
+			"schema_version": 1,
+		}}},
+		{{Key: "$unset", Value: "currency"}},
+	}
+	// Relax validation so documents can be rewritten, then restore the V003 schema
+	if err := db.RunCommand(ctx, bson.D{{Key: "collMod", Value: "carts"}, {Key: "validationLevel", Value: "off"}}).Err(); err != nil {
+		return err
+	}
+	if _, err := db.Collection("carts").UpdateMany(ctx, bson.M{}, pipeline); err != nil {
+		return fmt.Errorf("revert cart prices: %w", err)
+	}
+	return NewV003AddSchemaValidation().Execute(ctx, db)
+}
+
+// decimalPrice converts a double, int or decimal price to model.Money, which
+// is stored as Decimal128
+func decimalPrice(v interface{}) (model.Money, error) {
+	t, data, err := bson.MarshalValue(v)
+	if err != nil {
+		return model.Money{}, err
+	}
+	var price model.Money
+	err = price.UnmarshalBSONValue(t, data)
+	return price, err
+}
@@ -0,0 +1,67 @@
+package migration
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// V006CreateCouponsCollection creates the promotion collections: 'coupons' holds
+// coupon definitions keyed by code, 'coupon_redemptions' records each coupon redeemed by an order.
+type V006CreateCouponsCollection struct{}
+
+func NewV006CreateCouponsCollection() *V006CreateCouponsCollection {
+	return &V006CreateCouponsCollection{}
+}
+func (m *V006CreateCouponsCollection) ID() string     { return "V006_CreateCouponsCollection" }
+func (m *V006CreateCouponsCollection) Order() string  { return "006" }
+func (m *V006CreateCouponsCollection) Author() string { return "emart-db-team" }
+func (m *V006CreateCouponsCollection) Checksum() (string, error) {
+	return sourceChecksum("v006_create_coupons_collection.go")
+}
+
+func (m *V006CreateCouponsCollection) Execute(ctx context.Context, db *mongo.Database) error {
+	validator := bson.M{
+		"$jsonSchema": bson.M{
+			"bsonType": "object",
+			"required": []string{"_id", "type", "active"},
+			"properties": bson.M{
+				"_id":               bson.M{"bsonType": "string", "description": "Upper-cased coupon code"},
+				"type":              bson.M{"enum": bson.A{"percentage", "fixed_amount", "buy_x_get_y"}},
+				"percent_off":       bson.M{"bsonType": "int", "minimum": 1, "maximum": 100},
+				"amount_off":        bson.M{"bsonType": "decimal", "minimum": 0},
+				"min_spend":         bson.M{"bsonType": "decimal", "minimum": 0},
+				"category":          bson.M{"enum": bson.A{"books", "courses", "software"}},
+				"max_uses_per_user": bson.M{"bsonType": "int", "minimum": 0},
+				"active":            bson.M{"bsonType": "bool"},
+			},
+		},
+	}
+	if err := createCollection(ctx, db, "coupons", options.CreateCollection().SetValidator(validator)); err != nil {
+		return err
+	}
+	if err := createCollection(ctx, db, "coupon_redemptions"); err != nil {
+		return err
+	}
+
+	// One redemption per order, which makes redeeming at checkout idempotent
+	index := mongo.IndexModel{
+		Keys:    bson.D{{Key: "code", Value: 1}, {Key: "user_id", Value: 1}, {Key: "order_ref", Value: 1}},
+		Options: options.Index().SetName("idx_code_user_order_unique").SetUnique(true),
+	}
+	return createIndexes(ctx, db.Collection("coupon_redemptions"), []mongo.IndexModel{index})
+}
+
+// Rollback drops only what Execute created: coupons defined before this
+// migration ran are left alone
+func (m *V006CreateCouponsCollection) Rollback(ctx context.Context, db *mongo.Database) error {
+	if err := dropCreatedIndexes(ctx, db.Collection("coupon_redemptions"), "idx_code_user_order_unique"); err != nil {
+		return err
+	}
+	if err := dropCreatedCollection(ctx, db, "coupon_redemptions"); err != nil {
+		return err
+	}
+	return dropCreatedCollection(ctx, db, "coupons")
+}
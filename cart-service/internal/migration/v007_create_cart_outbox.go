@@ -0,0 +1,53 @@
+package migration
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// outboxRetention is how long published events are kept for troubleshooting
+const outboxRetention = 7 * 24 * time.Hour
+
+// V007CreateCartOutbox creates the transactional outbox for cart domain events.
+// The collection must exist up front: MongoDB < 4.4 cannot create collections
+// inside a transaction.
+type V007CreateCartOutbox struct{}
+
+func NewV007CreateCartOutbox() *V007CreateCartOutbox { return &V007CreateCartOutbox{} }
+func (m *V007CreateCartOutbox) ID() string           { return "V007_CreateCartOutbox" }
+func (m *V007CreateCartOutbox) Order() string        { return "007" }
+func (m *V007CreateCartOutbox) Author() string       { return "emart-db-team" }
+func (m *V007CreateCartOutbox) Checksum() (string, error) {
+	return sourceChecksum("v007_create_cart_outbox.go")
+}
+
+func (m *V007CreateCartOutbox) Execute(ctx context.Context, db *mongo.Database) error {
+	if err := createCollection(ctx, db, "cart_outbox"); err != nil {
+		return err
+	}
+
+	indexes := []mongo.IndexModel{
+		{
+			// Relay polls pending events oldest first
+			Keys:    bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: 1}},
+			Options: options.Index().SetName("idx_status_created_at"),
+		},
+		{
+			// Pending events have no published_at, so only delivered ones expire
+			Keys:    bson.D{{Key: "published_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(outboxRetention.Seconds())).SetName("idx_published_at_ttl"),
+		},
+	}
+	return createIndexes(ctx, db.Collection("cart_outbox"), indexes)
+}
+
+func (m *V007CreateCartOutbox) Rollback(ctx context.Context, db *mongo.Database) error {
+	if err := dropCreatedIndexes(ctx, db.Collection("cart_outbox"), "idx_status_created_at", "idx_published_at_ttl"); err != nil {
+		return err
+	}
+	return dropCreatedCollection(ctx, db, "cart_outbox")
+}
@@ -0,0 +1,51 @@
+package migration
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// checkoutSessionRetention is how long sessions are kept after they expire
+const checkoutSessionRetention = 30 * 24 * time.Hour
+
+// V008CreateCheckoutSessions creates 'checkout_sessions' for frozen cart snapshots
+type V008CreateCheckoutSessions struct{}
+
+func NewV008CreateCheckoutSessions() *V008CreateCheckoutSessions {
+	return &V008CreateCheckoutSessions{}
+}
+func (m *V008CreateCheckoutSessions) ID() string     { return "V008_CreateCheckoutSessions" }
+func (m *V008CreateCheckoutSessions) Order() string  { return "008" }
+func (m *V008CreateCheckoutSessions) Author() string { return "emart-db-team" }
+func (m *V008CreateCheckoutSessions) Checksum() (string, error) {
+	return sourceChecksum("v008_create_checkout_sessions.go")
+}
+
+func (m *V008CreateCheckoutSessions) Execute(ctx context.Context, db *mongo.Database) error {
+	if err := createCollection(ctx, db, "checkout_sessions"); err != nil {
+		return err
+	}
+
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "status", Value: 1}},
+			Options: options.Index().SetName("idx_user_status"),
+		},
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(checkoutSessionRetention.Seconds())).SetName("idx_expires_at_ttl"),
+		},
+	}
+	return createIndexes(ctx, db.Collection("checkout_sessions"), indexes)
+}
+
+func (m *V008CreateCheckoutSessions) Rollback(ctx context.Context, db *mongo.Database) error {
+	if err := dropCreatedIndexes(ctx, db.Collection("checkout_sessions"), "idx_user_status", "idx_expires_at_ttl"); err != nil {
+		return err
+	}
+	return dropCreatedCollection(ctx, db, "checkout_sessions")
+}
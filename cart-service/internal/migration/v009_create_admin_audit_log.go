@@ -0,0 +1,46 @@
+package migration
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// V009CreateAdminAuditLog creates 'admin_audit_log' for support staff actions.
+// Entries are kept indefinitely; there is deliberately no TTL index.
+type V009CreateAdminAuditLog struct{}
+
+func NewV009CreateAdminAuditLog() *V009CreateAdminAuditLog { return &V009CreateAdminAuditLog{} }
+func (m *V009CreateAdminAuditLog) ID() string              { return "V009_CreateAdminAuditLog" }
+func (m *V009CreateAdminAuditLog) Order() string           { return "009" }
+func (m *V009CreateAdminAuditLog) Author() string          { return "emart-db-team" }
+func (m *V009CreateAdminAuditLog) Checksum() (string, error) {
+	return sourceChecksum("v009_create_admin_audit_log.go")
+}
+
+func (m *V009CreateAdminAuditLog) Execute(ctx context.Context, db *mongo.Database) error {
+	if err := createCollection(ctx, db, "admin_audit_log"); err != nil {
+		return err
+	}
+
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "target_user_id", Value: 1}, {Key: "at", Value: -1}},
+			Options: options.Index().SetName("idx_target_at"),
+		},
+		{
+			Keys:    bson.D{{Key: "actor.user_id", Value: 1}, {Key: "at", Value: -1}},
+			Options: options.Index().SetName("idx_actor_at"),
+		},
+	}
+	return createIndexes(ctx, db.Collection("admin_audit_log"), indexes)
+}
+
+func (m *V009CreateAdminAuditLog) Rollback(ctx context.Context, db *mongo.Database) error {
+	if err := dropCreatedIndexes(ctx, db.Collection("admin_audit_log"), "idx_target_at", "idx_actor_at"); err != nil {
+		return err
+	}
+	return dropCreatedCollection(ctx, db, "admin_audit_log")
+}
@@ -0,0 +1,47 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// V010CreateCouponUsage creates 'coupon_usage', one counter per coupon and
+// user keyed by {code, user_id}. Checkout increments it only while it is below
+// the coupon's limit, which counting coupon_redemptions could not guarantee.
+// Existing redemptions are counted in.
+type V010CreateCouponUsage struct{}
+
+func NewV010CreateCouponUsage() *V010CreateCouponUsage { return &V010CreateCouponUsage{} }
+func (m *V010CreateCouponUsage) ID() string            { return "V010_CreateCouponUsage" }
+func (m *V010CreateCouponUsage) Order() string         { return "010" }
+func (m *V010CreateCouponUsage) Author() string        { return "emart-db-team" }
+func (m *V010CreateCouponUsage) Checksum() (string, error) {
+	return sourceChecksum("v010_create_coupon_usage.go")
+}
+
+func (m *V010CreateCouponUsage) Execute(ctx context.Context, db *mongo.Database) error {
+	if err := createCollection(ctx, db, "coupon_usage"); err != nil {
+		return err
+	}
+
+	// Counters that already exist were kept by the application; a re-run leaves them
+	pipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{{Key: "code", Value: "$code"}, {Key: "user_id", Value: "$user_id"}}},
+			{Key: "used", Value: bson.M{"$sum": 1}},
+		}}},
+		{{Key: "$merge", Value: bson.M{"into": "coupon_usage", "whenMatched": "keepExisting", "whenNotMatched": "insert"}}},
+	}
+	cursor, err := db.Collection("coupon_redemptions").Aggregate(ctx, pipeline)
+	if err != nil {
+		return fmt.Errorf("count existing coupon redemptions: %w", err)
+	}
+	return cursor.Close(ctx)
+}
+
+func (m *V010CreateCouponUsage) Rollback(ctx context.Context, db *mongo.Database) error {
+	return dropCreatedCollection(ctx, db, "coupon_usage")
+}
@@ -0,0 +1,52 @@
+package migration
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// V011BackfillSchemaVersion stamps schema_version on carts written before
+// the field existed, so readers and later data migrations can select by it.
+// By now V005 has converted the float-era carts, but the version is still
+// derived from each document's shape rather than assumed.
+type V011BackfillSchemaVersion struct{}
+
+func NewV011BackfillSchemaVersion() *V011BackfillSchemaVersion { return &V011BackfillSchemaVersion{} }
+func (m *V011BackfillSchemaVersion) ID() string                { return "V011_BackfillSchemaVersion" }
+func (m *V011BackfillSchemaVersion) Order() string             { return "011" }
+func (m *V011BackfillSchemaVersion) Author() string            { return "emart-db-team" }
+func (m *V011BackfillSchemaVersion) Checksum() (string, error) {
+	return sourceChecksum("v011_backfill_schema_version.go")
+}
+
+// Transactional: the rewrite only selects unstamped carts, so a failed run is
+// resumed rather than rolled back
+func (m *V011BackfillSchemaVersion) Transactional() bool { return true }
+
+func (m *V011BackfillSchemaVersion) Execute(ctx context.Context, db *mongo.Database) error {
+	opts := BatchOptions{Filter: bson.M{"schema_version": bson.M{"$exists": false}}}
+	_, err := RewriteInBatches(ctx, db, m.ID(), opts, stampSchemaVersion)
+	return err
+}
+
+// stampSchemaVersion sets schema_version 2 on carts with decimal prices (the
+// shape V005 produces) and 1 on float-era carts
+func stampSchemaVersion(doc bson.M) (bool, error) {
+	if _, ok := doc["schema_version"]; ok {
+		return false, nil
+	}
+	doc["schema_version"] = 1
+	if _, decimal := doc["total_price"].(primitive.Decimal128); decimal {
+		doc["schema_version"] = 2
+	}
+	return true, nil
+}
+
+// Rollback leaves the stamps: which carts were stamped is not recorded, and
+// readers treat a missing version as 1 anyway
+func (m *V011BackfillSchemaVersion) Rollback(ctx context.Context, db *mongo.Database) error {
+	return nil
+}
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrInvalidAdminFilter is returned for an admin cart listing with unusable parameters.
+var ErrInvalidAdminFilter = errors.New("invalid cart filter")
+
+// Actor identifies the support agent behind an admin request
+type Actor struct {
+	UserID string `json:"user_id" bson:"user_id"`
+	Email  string `json:"email"   bson:"email,omitempty"`
+	IP     string `json:"ip"      bson:"ip,omitempty"`
+}
+
+// Admin actions recorded in the audit log
+const (
+	AuditViewCart    = "VIEW_CART"
+	AuditListCarts   = "LIST_CARTS"
+	AuditUpdateItem  = "UPDATE_ITEM"
+	AuditRemoveItem  = "REMOVE_ITEM"
+	AuditClearCart   = "CLEAR_CART"
+	AuditViewHistory = "VIEW_AUDIT_LOG"
+)
+
+// AuditEntry is one admin action. Entries are written for failed attempts too,
+// with Error set, so the log shows everything staff tried to do.
+type AuditEntry struct {
+	ID           string                 `json:"id"                       bson:"_id"`
+	Actor        Actor                  `json:"actor"                    bson:"actor"`
+	Action       string                 `json:"action"                   bson:"action"`
+	TargetUserID string                 `json:"target_user_id,omitempty" bson:"target_user_id,omitempty"`
+	Details      map[string]interface{} `json:"details,omitempty"        bson:"details,omitempty"`
+	Error        string                 `json:"error,omitempty"          bson:"error,omitempty"`
+	At           time.Time              `json:"at"                       bson:"at"`
+}
+
+// AdminCartStatus narrows an admin cart listing
+type AdminCartStatus string
+
+const (
+	AdminCartsAll       AdminCartStatus = ""
+	AdminCartsActive    AdminCartStatus = "active"    // not flagged by the abandoned cart job
+	AdminCartsAbandoned AdminCartStatus = "abandoned" // flagged by the abandoned cart job
+)
+
+// AdminCartFilter selects carts for the support listing, newest update first
+type AdminCartFilter struct {
+	Status        AdminCartStatus `form:"status"`
+	UpdatedAfter  *time.Time      `form:"updated_after"  time_format:"2006-01-02T15:04:05Z07:00"`
+	UpdatedBefore *time.Time      `form:"updated_before" time_format:"2006-01-02T15:04:05Z07:00"`
+	Limit         int             `form:"limit"`
+	Offset        int             `form:"offset"`
+}
+
+// AdminCartPage is one page of an admin cart listing
+type AdminCartPage struct {
+	Carts  []*Cart `json:"carts"`
+	Total  int64   `json:"total"`
+	Limit  int     `json:"limit"`
+	Offset int     `json:"offset"`
+}
@@ -1,6 +1,45 @@
 package model
 
-import "time"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrVersionConflict is returned by the repositories when a cart was modified
+// by someone else between read and write (optimistic concurrency control).
+var ErrVersionConflict = errors.New("cart version conflict")
+
+// ErrInsufficientStock is returned when the requested quantity exceeds what the catalog has in stock.
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// ErrCartEmpty is returned when checking out a cart without items.
+var ErrCartEmpty = errors.New("cart is empty")
+
+// ErrCartLocked is returned when mutating a cart frozen by an active checkout session.
+var ErrCartLocked = errors.New("cart is locked for checkout")
+
+// ErrPreconditionFailed is returned when an If-Match precondition no longer holds.
+var ErrPreconditionFailed = errors.New("cart precondition failed")
+
+// ErrSchemaTooNew is returned when saving a cart written by a newer build: this
+// one would drop whatever the newer shape added.
+var ErrSchemaTooNew = errors.New("cart schema is newer than this build")
+
+// CurrentSchemaVersion is the cart document shape written by this build.
+// v1: float64 prices, v2: Money (Decimal128) prices plus a currency code.
+const CurrentSchemaVersion = 2
+
+// GuestCartPrefix marks carts owned by anonymous shoppers: "guest:<guestID>"
+const GuestCartPrefix = "guest:"
+
+// IsGuestCart reports whether the cart owner is an anonymous shopper
+func IsGuestCart(userID string) bool {
+	return strings.HasPrefix(userID, GuestCartPrefix)
+}
 
 // CartItem represents a single product in the cart
 type CartItem struct {
@@ -8,7 +47,7 @@ type CartItem struct {
 	ProductID   string    `json:"product_id"   bson:"product_id"`
 	ProductName string    `json:"product_name" bson:"product_name"`
 	Category    string    `json:"category"     bson:"category"`
-	Price       float64   `json:"price"        bson:"price"`
+	Price       Money     `json:"price"        bson:"price"`
 	Quantity    int       `json:"quantity"     bson:"quantity"`
 	ImageURL    string    `json:"image_url"    bson:"image_url"`
 	AddedAt     time.Time `json:"added_at"     bson:"added_at"`
@@ -16,24 +55,74 @@ type CartItem struct {
 
 // Cart is the full cart for a user
 type Cart struct {
-	ID            string     `json:"id"             bson:"_id,omitempty"`
-	UserID        string     `json:"user_id"        bson:"user_id"`
-	Items         []CartItem `json:"items"          bson:"items"`
-	TotalItems    int        `json:"total_items"    bson:"total_items"`
-	TotalPrice    float64    `json:"total_price"    bson:"total_price"`
-	CreatedAt     time.Time  `json:"created_at"     bson:"created_at"`
-	UpdatedAt     time.Time  `json:"updated_at"     bson:"updated_at"`
-	SyncedAt      *time.Time `json:"synced_at"      bson:"synced_at"`
-	Source        string     `json:"source"         bson:"source"`
-	SchemaVersion int        `json:"schema_version" bson:"schema_version"`
+	ID            string            `json:"id"             bson:"_id,omitempty"`
+	UserID        string            `json:"user_id"        bson:"user_id"`
+	Items         []CartItem        `json:"items"          bson:"items"`
+	TotalItems    int               `json:"total_items"    bson:"total_items"`
+	TotalPrice    Money             `json:"total_price"    bson:"total_price"` // subtotal before discounts
+	Coupons       []string          `json:"coupons"        bson:"coupons,omitempty"`
+	Discounts     []AppliedDiscount `json:"discounts"      bson:"discounts,omitempty"`
+	DiscountTotal Money             `json:"discount_total" bson:"discount_total"`
+	GrandTotal    Money             `json:"grand_total"    bson:"grand_total"`
+	Currency      string            `json:"currency"       bson:"currency"`
+	CreatedAt     time.Time         `json:"created_at"     bson:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"     bson:"updated_at"`
+	SyncedAt      *time.Time        `json:"synced_at"      bson:"synced_at"`
+	Source        string            `json:"source"         bson:"source"`
+	SchemaVersion int               `json:"schema_version" bson:"schema_version"`
+	Version       int64             `json:"version"        bson:"version"`
+	ExpiresAt     *time.Time        `json:"expires_at,omitempty" bson:"expires_at,omitempty"`     // guest carts only
+	AbandonedAt   *time.Time        `json:"abandoned_at,omitempty" bson:"abandoned_at,omitempty"` // set once by the abandoned cart job
+	Email         string            `json:"-"              bson:"email,omitempty"`                // shopper's login email, for the abandoned cart reminder
+
+	// Set while a checkout session holds the cart; the lock lapses on its own at LockedUntil
+	CheckoutSessionID string     `json:"checkout_session_id,omitempty" bson:"checkout_session_id,omitempty"`
+	LockedUntil       *time.Time `json:"locked_until,omitempty"        bson:"locked_until,omitempty"`
+
+	// The guest cart last merged in, as "<guest cart ID> <its ETag>", so a merge
+	// retried after the guest cart failed to delete adds nothing twice
+	MergedGuest string `json:"merged_guest,omitempty" bson:"merged_guest,omitempty"`
+}
+
+// IsLocked reports whether a checkout session currently freezes the cart
+func (c *Cart) IsLocked(now time.Time) bool {
+	return c.CheckoutSessionID != "" && c.LockedUntil != nil && now.Before(*c.LockedUntil)
 }
 
-// AddItemRequest DTO
+// ETag is a strong entity tag for the cart as served. Version changes on every
+// save and UpdatedAt tells apart a cart recreated after a clear (its version
+// starts over); the totals cover coupons that lapse between saves. UpdatedAt
+// is taken at millisecond precision, which is all MongoDB keeps.
+func (c *Cart) ETag() string {
+	var updatedAt int64
+	if c.Version > 0 {
+		updatedAt = c.UpdatedAt.UnixMilli()
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d|%d|%s|%s|%s", c.Version, updatedAt, c.TotalPrice, c.DiscountTotal, c.GrandTotal)))
+	return `"` + hex.EncodeToString(sum[:12]) + `"`
+}
+
+// Summary is the lightweight view of the cart shown in the header badge
+func (c *Cart) Summary() *CartSummary {
+	return &CartSummary{
+		UserID:        c.UserID,
+		TotalItems:    c.TotalItems,
+		TotalPrice:    c.TotalPrice,
+		DiscountTotal: c.DiscountTotal,
+		GrandTotal:    c.GrandTotal,
+		Currency:      c.Currency,
+		ETag:          c.ETag(),
+	}
+}
+
+// AddItemRequest DTO.
+// ProductName, Price and ImageURL are accepted for backward compatibility only;
+// the service always takes them from the catalog, so Price stays a plain number.
 type AddItemRequest struct {
 	ProductID   string  `json:"product_id"   binding:"required"`
-	ProductName string  `json:"product_name" binding:"required"`
+	ProductName string  `json:"product_name"`
 	Category    string  `json:"category"     binding:"required,oneof=books courses software"`
-	Price       float64 `json:"price"        binding:"required,gt=0"`
+	Price       float64 `json:"price"        binding:"omitempty,gt=0"`
 	Quantity    int     `json:"quantity"     binding:"required,min=1,max=100"`
 	ImageURL    string  `json:"image_url"`
 }
@@ -43,11 +132,28 @@ type UpdateQuantityRequest struct {
 	Quantity int `json:"quantity" binding:"required,min=0,max=100"`
 }
 
+// MergeStrategy decides what happens when guest and user carts hold the same product
+type MergeStrategy string
+
+const (
+	MergeSumQuantities MergeStrategy = "sum"    // add both quantities together
+	MergeKeepNewest    MergeStrategy = "newest" // keep whichever line was added last
+)
+
+// MergeCartRequest DTO. An empty strategy falls back to the configured default.
+type MergeCartRequest struct {
+	Strategy MergeStrategy `json:"strategy" binding:"omitempty,oneof=sum newest"`
+}
+
 // CartSummary lightweight for header
 type CartSummary struct {
-	UserID     string  `json:"user_id"`
-	TotalItems int     `json:"total_items"`
-	TotalPrice float64 `json:"total_price"`
+	UserID        string `json:"user_id"`
+	TotalItems    int    `json:"total_items"`
+	TotalPrice    Money  `json:"total_price"`
+	DiscountTotal Money  `json:"discount_total"`
+	GrandTotal    Money  `json:"grand_total"`
+	Currency      string `json:"currency"`
+	ETag          string `json:"-"` // of the cart it was taken from
 }
 
 // ApiResponse standard wrapper
@@ -84,4 +190,6 @@ type MigrationRecord struct {
 	State      string    `bson:"state"`
 	ExecutedAt time.Time `bson:"executedAt"`
 	Order      string    `bson:"order"`
+	Error      string    `bson:"error,omitempty"`
+	Checksum   string    `bson:"checksum,omitempty"`
 }
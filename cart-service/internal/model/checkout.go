@@ -0,0 +1,43 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrCheckoutSessionNotFound = errors.New("checkout session not found")
+	ErrCheckoutSessionExpired  = errors.New("checkout session expired")
+	ErrCheckoutSessionClosed   = errors.New("checkout session already completed or cancelled")
+)
+
+// CheckoutSessionStatus is the lifecycle state of a checkout session
+type CheckoutSessionStatus string
+
+const (
+	CheckoutActive    CheckoutSessionStatus = "ACTIVE"
+	CheckoutCompleted CheckoutSessionStatus = "COMPLETED"
+	CheckoutCancelled CheckoutSessionStatus = "CANCELLED"
+	CheckoutExpired   CheckoutSessionStatus = "EXPIRED" // reported only; never stored
+)
+
+// CheckoutSession is an immutable snapshot of the cart taken when checkout starts.
+// payment-service charges exactly Cart.GrandTotal for Cart.Items, then calls complete.
+type CheckoutSession struct {
+	ID        string                `json:"id"                   bson:"_id"`
+	UserID    string                `json:"user_id"              bson:"user_id"`
+	Status    CheckoutSessionStatus `json:"status"               bson:"status"`
+	Cart      Cart                  `json:"cart"                 bson:"cart"`
+	OrderID   string                `json:"order_id,omitempty"   bson:"order_id,omitempty"`
+	CreatedAt time.Time             `json:"created_at"           bson:"created_at"`
+	ExpiresAt time.Time             `json:"expires_at"           bson:"expires_at"`
+	ClosedAt  *time.Time            `json:"closed_at,omitempty"  bson:"closed_at,omitempty"`
+}
+
+// EffectiveStatus reports ACTIVE sessions past their TTL as EXPIRED
+func (s *CheckoutSession) EffectiveStatus(now time.Time) CheckoutSessionStatus {
+	if s.Status == CheckoutActive && !now.Before(s.ExpiresAt) {
+		return CheckoutExpired
+	}
+	return s.Status
+}
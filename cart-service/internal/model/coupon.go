@@ -0,0 +1,56 @@
+package model
+
+import "time"
+
+// DiscountType selects how a coupon computes its discount
+type DiscountType string
+
+const (
+	DiscountPercentage DiscountType = "percentage"   // PercentOff % of the eligible subtotal
+	DiscountFixed      DiscountType = "fixed_amount" // AmountOff, capped at the eligible subtotal
+	DiscountBuyXGetY   DiscountType = "buy_x_get_y"  // every BuyQuantity+GetQuantity units, the GetQuantity cheapest are free
+)
+
+// Coupon is a promotion definition stored in the coupons collection.
+// Codes are stored upper-cased and matched case-insensitively.
+type Coupon struct {
+	Code           string       `json:"code"              bson:"_id"`
+	Description    string       `json:"description"       bson:"description"`
+	Type           DiscountType `json:"type"              bson:"type"`
+	PercentOff     int          `json:"percent_off"       bson:"percent_off,omitempty"`
+	AmountOff      Money        `json:"amount_off"        bson:"amount_off"`
+	BuyQuantity    int          `json:"buy_quantity"      bson:"buy_quantity,omitempty"`
+	GetQuantity    int          `json:"get_quantity"      bson:"get_quantity,omitempty"`
+	Category       string       `json:"category"          bson:"category,omitempty"` // empty = whole cart
+	MinSpend       Money        `json:"min_spend"         bson:"min_spend"`
+	StartsAt       *time.Time   `json:"starts_at"         bson:"starts_at,omitempty"`
+	ExpiresAt      *time.Time   `json:"expires_at"        bson:"expires_at,omitempty"`
+	MaxUsesPerUser int          `json:"max_uses_per_user" bson:"max_uses_per_user"`   // 0 = unlimited
+	Exclusive      bool         `json:"exclusive"         bson:"exclusive,omitempty"` // cannot be combined with other coupons
+	Active         bool         `json:"active"            bson:"active"`
+}
+
+// AppliedDiscount is one line of the cart's discount breakdown.
+// A coupon that stopped qualifying (e.g. items removed below the minimum spend)
+// stays on the cart with Applied=false and the reason, so the shopper can see why.
+type AppliedDiscount struct {
+	Code        string `json:"code"                  bson:"code"`
+	Description string `json:"description"           bson:"description"`
+	Amount      Money  `json:"amount"                bson:"amount"`
+	Applied     bool   `json:"applied"               bson:"applied"`
+	Reason      string `json:"reason,omitempty"      bson:"reason,omitempty"`
+}
+
+// CouponRedemption records that a user consumed a coupon at checkout. Each one
+// is also counted in coupon_usage, which enforces MaxUsesPerUser atomically.
+type CouponRedemption struct {
+	Code       string    `bson:"code"`
+	UserID     string    `bson:"user_id"`
+	OrderRef   string    `bson:"order_ref"`
+	RedeemedAt time.Time `bson:"redeemed_at"`
+}
+
+// ApplyCouponRequest DTO
+type ApplyCouponRequest struct {
+	Code string `json:"code" binding:"required,max=64"`
+}
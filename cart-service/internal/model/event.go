@@ -0,0 +1,42 @@
+package model
+
+import "time"
+
+// CartEventType names a domain event emitted by the cart service
+type CartEventType string
+
+const (
+	EventItemAdded       CartEventType = "ItemAdded"
+	EventQuantityChanged CartEventType = "QuantityChanged"
+	EventItemRemoved     CartEventType = "ItemRemoved"
+	EventCartCleared     CartEventType = "CartCleared"
+	EventCartCheckedOut  CartEventType = "CartCheckedOut"
+)
+
+// CartEvent is published to downstream services (notification-service, analytics).
+// Delivery is at-least-once: consumers should de-duplicate on ID.
+type CartEvent struct {
+	ID               string        `json:"id"                          bson:"id"`
+	Type             CartEventType `json:"type"                        bson:"type"`
+	UserID           string        `json:"user_id"                     bson:"user_id"`
+	CartVersion      int64         `json:"cart_version"                bson:"cart_version"`
+	Item             *CartItem     `json:"item,omitempty"              bson:"item,omitempty"`
+	PreviousQuantity int           `json:"previous_quantity,omitempty" bson:"previous_quantity,omitempty"`
+	OrderID          string        `json:"order_id,omitempty"          bson:"order_id,omitempty"` // CartCheckedOut only
+	TotalItems       int           `json:"total_items"                 bson:"total_items"`
+	GrandTotal       Money         `json:"grand_total"                 bson:"grand_total"`
+	Currency         string        `json:"currency"                    bson:"currency"`
+	OccurredAt       time.Time     `json:"occurred_at"                 bson:"occurred_at"`
+}
+
+// CheckoutRequest DTO. OrderID is the payment-service order the cart was turned into.
+type CheckoutRequest struct {
+	OrderID string `json:"order_id" binding:"required,max=64"`
+}
+
+// CompleteCheckoutRequest DTO. payment-service completes the session on the
+// shopper's behalf, so it names the shopper the session must belong to.
+type CompleteCheckoutRequest struct {
+	OrderID string `json:"order_id" binding:"required,max=64"`
+	UserID  string `json:"user_id" binding:"required,max=128"`
+}
@@ -0,0 +1,13 @@
+package model
+
+// IdempotencyRecord is what is stored for a request sent with an
+// Idempotency-Key: a reservation while the first request runs, then its
+// response so that retries can be answered without repeating the mutation.
+type IdempotencyRecord struct {
+	Fingerprint string            `json:"fingerprint"`     // Hash of method, path and body
+	Token       string            `json:"token,omitempty"` // Names the request holding the reservation
+	Completed   bool              `json:"completed"`
+	Status      int               `json:"status,omitempty"`
+	Header      map[string]string `json:"header,omitempty"`
+	Body        []byte            `json:"body,omitempty"`
+}
@@ -0,0 +1,190 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DefaultCurrency is the ISO 4217 code every Emart catalog prices in
+const DefaultCurrency = "INR"
+
+// moneyScale is the number of minor units per major unit (2 decimal places)
+const moneyScale = 100
+
+// Money is a fixed-point amount held in minor units (paise, cents) of Currency.
+//
+// Encodings carry only the amount; the currency code travels next to it on
+// the owning Cart, and decoded values get DefaultCurrency:
+//   - JSON: decimal string "19.99" (numbers are accepted on input)
+//   - BSON: Decimal128 (doubles and ints from older documents are accepted)
+//
+// Mixing currencies in arithmetic is a programming error and panics.
+type Money struct {
+	Minor    int64
+	Currency string
+}
+
+// MoneyFromMinor builds Money from an amount in minor units
+func MoneyFromMinor(minor int64, currency string) Money {
+	return Money{Minor: minor, Currency: currency}
+}
+
+// ParseMoney parses a decimal string such as "19.99" or "-5". Digits beyond
+// the second decimal place are rounded half away from zero, which also cleans
+// up float artefacts like "59.970000000000006" from pre-decimal documents.
+func ParseMoney(s, currency string) (Money, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Money{}, fmt.Errorf("parse money: empty amount")
+	}
+	negative := false
+	if s[0] == '-' || s[0] == '+' {
+		negative = s[0] == '-'
+		s = s[1:]
+	}
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" {
+		whole = "0"
+	}
+	if !isDigits(whole) || !isDigits(frac) {
+		return Money{}, fmt.Errorf("parse money: invalid amount %q", s)
+	}
+
+	major, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("parse money: %w", err)
+	}
+	padded := (frac + "00")[:2]
+	minor, _ := strconv.ParseInt(padded, 10, 64)
+	if len(frac) > 2 && frac[2] >= '5' {
+		minor++
+	}
+
+	total := major*moneyScale + minor
+	if negative {
+		total = -total
+	}
+	return Money{Minor: total, Currency: currency}, nil
+}
+
+// MoneyFromFloat converts a legacy float64 price, rounding to minor units
+func MoneyFromFloat(f float64, currency string) Money {
+	m, _ := ParseMoney(strconv.FormatFloat(f, 'f', -1, 64), currency)
+	return m
+}
+
+// Add returns m + o
+func (m Money) Add(o Money) Money {
+	return Money{Minor: m.Minor + o.Minor, Currency: m.sameCurrency(o)}
+}
+
+// Sub returns m - o
+func (m Money) Sub(o Money) Money {
+	return Money{Minor: m.Minor - o.Minor, Currency: m.sameCurrency(o)}
+}
+
+// Mul returns m multiplied by a quantity
+func (m Money) Mul(qty int) Money {
+	return Money{Minor: m.Minor * int64(qty), Currency: m.Currency}
+}
+
+// IsZero reports whether the amount is zero
+func (m Money) IsZero() bool { return m.Minor == 0 }
+
+// String formats the amount as a plain decimal, e.g. "19.99"
+func (m Money) String() string {
+	sign := ""
+	minor := m.Minor
+	if minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, minor/moneyScale, minor%moneyScale)
+}
+
+// sameCurrency returns the shared currency; a zero-value Money adopts the other side's
+func (m Money) sameCurrency(o Money) string {
+	switch {
+	case m.Currency == "":
+		return o.Currency
+	case o.Currency == "" || o.Currency == m.Currency:
+		return m.Currency
+	}
+	panic(fmt.Sprintf("money: currency mismatch %s vs %s", m.Currency, o.Currency))
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	raw := string(data)
+	if raw == "null" {
+		*m = Money{Currency: DefaultCurrency}
+		return nil
+	}
+	if strings.HasPrefix(raw, `"`) {
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return err
+		}
+	}
+	parsed, err := ParseMoney(raw, DefaultCurrency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+func (m Money) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	d, err := primitive.ParseDecimal128(m.String())
+	if err != nil {
+		return 0, nil, fmt.Errorf("money to decimal128: %w", err)
+	}
+	return bson.MarshalValue(d)
+}
+
+func (m *Money) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	raw := bson.RawValue{Type: t, Value: data}
+	switch t {
+	case bsontype.Decimal128:
+		parsed, err := ParseMoney(raw.Decimal128().String(), DefaultCurrency)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+	case bsontype.Double:
+		*m = MoneyFromFloat(raw.Double(), DefaultCurrency)
+	case bsontype.Int32:
+		*m = MoneyFromMinor(int64(raw.Int32())*moneyScale, DefaultCurrency)
+	case bsontype.Int64:
+		*m = MoneyFromMinor(raw.Int64()*moneyScale, DefaultCurrency)
+	case bsontype.String:
+		parsed, err := ParseMoney(raw.StringValue(), DefaultCurrency)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+	case bsontype.Null:
+		*m = Money{Currency: DefaultCurrency}
+	default:
+		return fmt.Errorf("cannot decode %s into Money", t)
+	}
+	return nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,42 @@
+package model
+
+import "fmt"
+
+// Upcaster upgrades a decoded cart from one schema version to the next. It runs
+// after decoding, so the old shape must still decode into Cart; Money already
+// accepts the float prices of v1 documents.
+type Upcaster func(c *Cart)
+
+// upcasters maps a schema version to the step that lifts it to version+1.
+// When CurrentSchemaVersion is bumped, register the step from the previous
+// version here; old carts are then upgraded as they are read and written back
+// in the new shape on their next save, without a data migration.
+var upcasters = map[int]Upcaster{
+	1: upcastV1ToV2,
+}
+
+// Upcast brings a cart read from Redis or MongoDB up to CurrentSchemaVersion.
+// Carts without a version predate versioning and are treated as v1. Carts from
+// a newer build are left untouched, and saving them fails with ErrSchemaTooNew.
+func Upcast(c *Cart) error {
+	if c.SchemaVersion == 0 {
+		c.SchemaVersion = 1
+	}
+	for c.SchemaVersion < CurrentSchemaVersion {
+		step, ok := upcasters[c.SchemaVersion]
+		if !ok {
+			return fmt.Errorf("no upcaster for cart schema v%d", c.SchemaVersion)
+		}
+		step(c)
+		c.SchemaVersion++
+	}
+	return nil
+}
+
+// upcastV1ToV2: v1 carts priced in float64 with no currency code. The prices
+// decode into Money on their own; the cart just needs its currency.
+func upcastV1ToV2(c *Cart) {
+	if c.Currency == "" {
+		c.Currency = DefaultCurrency
+	}
+}
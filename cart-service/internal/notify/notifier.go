@@ -0,0 +1,36 @@
+package notify
+
+import (
+	"context"
+	"errors"
+
+	"github.com/emart/cart-service/internal/model"
+	"go.uber.org/zap"
+)
+
+// ErrNoRecipient means the cart has no email address to send a reminder to;
+// retrying will not help
+var ErrNoRecipient = errors.New("cart has no email address")
+
+// Notifier tells a shopper about their abandoned cart
+type Notifier interface {
+	NotifyAbandonedCart(ctx context.Context, cart *model.Cart) error
+}
+
+// logNotifier only logs; used when no notification-service is configured
+type logNotifier struct {
+	logger *zap.Logger
+}
+
+func NewLogNotifier(logger *zap.Logger) Notifier {
+	return &logNotifier{logger: logger}
+}
+
+func (n *logNotifier) NotifyAbandonedCart(ctx context.Context, cart *model.Cart) error {
+	n.logger.Info("Abandoned cart",
+		zap.String("userID", cart.UserID),
+		zap.Int("totalItems", cart.TotalItems),
+		zap.String("grandTotal", cart.GrandTotal.String()),
+	)
+	return nil
+}
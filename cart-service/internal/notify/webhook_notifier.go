@@ -0,0 +1,102 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/emart/cart-service/internal/model"
+)
+
+// abandonedCartPath is the notification-service endpoint for cart reminders
+const abandonedCartPath = "/api/v1/notify/cart-abandoned"
+
+// webhookNotifier POSTs abandoned carts to the notification-service,
+// authenticating with the shared X-Service-Secret like payment-service does.
+type webhookNotifier struct {
+	baseURL    string
+	secret     string
+	httpClient *http.Client
+}
+
+func NewWebhookNotifier(baseURL, secret string, timeout time.Duration) Notifier {
+	return &webhookNotifier{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		secret:     secret,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+type abandonedCartItem struct {
+	ProductID   string      `json:"product_id"`
+	ProductName string      `json:"product_name"`
+	Category    string      `json:"category"`
+	Price       model.Money `json:"price"`
+	Quantity    int         `json:"quantity"`
+	ImageURL    string      `json:"image_url"`
+}
+
+type abandonedCartPayload struct {
+	UserID      string              `json:"user_id"`
+	UserEmail   string              `json:"user_email"`
+	Items       []abandonedCartItem `json:"items"`
+	TotalItems  int                 `json:"total_items"`
+	GrandTotal  model.Money         `json:"grand_total"`
+	Currency    string              `json:"currency"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+	AbandonedAt *time.Time          `json:"abandoned_at"`
+}
+
+func (n *webhookNotifier) NotifyAbandonedCart(ctx context.Context, cart *model.Cart) error {
+	if cart.Email == "" {
+		return ErrNoRecipient
+	}
+	payload := abandonedCartPayload{
+		UserID:      cart.UserID,
+		UserEmail:   cart.Email,
+		Items:       make([]abandonedCartItem, 0, len(cart.Items)),
+		TotalItems:  cart.TotalItems,
+		GrandTotal:  cart.GrandTotal,
+		Currency:    cart.Currency,
+		UpdatedAt:   cart.UpdatedAt,
+		AbandonedAt: cart.AbandonedAt,
+	}
+	for _, item := range cart.Items {
+		payload.Items = append(payload.Items, abandonedCartItem{
+			ProductID:   item.ProductID,
+			ProductName: item.ProductName,
+			Category:    item.Category,
+			Price:       item.Price,
+			Quantity:    item.Quantity,
+			ImageURL:    item.ImageURL,
+		})
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal abandoned cart: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.baseURL+abandonedCartPath, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build notification request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.secret != "" {
+		req.Header.Set("X-Service-Secret", n.secret)
+	}
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("notify abandoned cart: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("notify abandoned cart: notification-service returned %d", resp.StatusCode)
+	}
+	return nil
+}
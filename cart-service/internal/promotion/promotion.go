@@ -0,0 +1,285 @@
+package promotion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/emart/cart-service/internal/model"
+	mongorepo "github.com/emart/cart-service/internal/repository/mongo"
+)
+
+var (
+	ErrCouponNotFound      = errors.New("coupon not found")
+	ErrCouponInactive      = errors.New("coupon is not active")
+	ErrCouponExpired       = errors.New("coupon has expired")
+	ErrUsageLimitReached   = errors.New("coupon usage limit reached")
+	ErrMinSpendNotMet      = errors.New("minimum spend not met")
+	ErrCouponNotApplicable = errors.New("coupon does not apply to this cart")
+	ErrTooManyCoupons      = errors.New("too many coupons on the cart")
+	ErrCouponNotCombinable = errors.New("coupon cannot be combined with other coupons")
+)
+
+// Engine validates coupon codes and prices carts against their applied coupons
+type Engine struct {
+	coupons    mongorepo.CouponMongoRepository
+	maxPerCart int // 0 = unlimited
+}
+
+func NewEngine(coupons mongorepo.CouponMongoRepository, maxPerCart int) *Engine {
+	return &Engine{coupons: coupons, maxPerCart: maxPerCart}
+}
+
+// Validate checks that userID may add code to a cart holding the applied
+// coupons and items right now, including the stacking rules and the per-user
+// usage limit. It returns the coupon on success.
+func (e *Engine) Validate(ctx context.Context, userID, code string, applied []string, items []model.CartItem, now time.Time) (*model.Coupon, error) {
+	coupon, err := e.coupons.GetCoupon(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("load coupon %s: %w", code, err)
+	}
+	if coupon == nil {
+		return nil, ErrCouponNotFound
+	}
+	if err := e.canStack(ctx, coupon, applied); err != nil {
+		return nil, err
+	}
+
+	if coupon.MaxUsesPerUser > 0 {
+		used, err := e.coupons.CountRedemptions(ctx, coupon.Code, userID)
+		if err != nil {
+			return nil, fmt.Errorf("count coupon redemptions: %w", err)
+		}
+		if used >= int64(coupon.MaxUsesPerUser) {
+			return nil, ErrUsageLimitReached
+		}
+	}
+
+	if _, err := Discount(coupon, items, now); err != nil {
+		return nil, err
+	}
+	return coupon, nil
+}
+
+// canStack checks coupon against the coupons already on the cart: at most
+// maxPerCart of them, and an exclusive coupon only on its own
+func (e *Engine) canStack(ctx context.Context, coupon *model.Coupon, applied []string) error {
+	if len(applied) == 0 {
+		return nil
+	}
+	if e.maxPerCart > 0 && len(applied) >= e.maxPerCart {
+		return fmt.Errorf("%w: at most %d", ErrTooManyCoupons, e.maxPerCart)
+	}
+	if coupon.Exclusive {
+		return ErrCouponNotCombinable
+	}
+	for _, code := range applied {
+		other, err := e.coupons.GetCoupon(ctx, code)
+		if err != nil {
+			return fmt.Errorf("load coupon %s: %w", code, err)
+		}
+		if other != nil && other.Exclusive {
+			return fmt.Errorf("%w: %s", ErrCouponNotCombinable, other.Code)
+		}
+	}
+	return nil
+}
+
+// Price recomputes the discount breakdown for every coupon on the cart.
+// cart.TotalPrice must already hold the subtotal. Coupons that no longer
+// qualify stay on the cart with Applied=false; so do coupons beyond the
+// stacking rules, which carts from before them can hold. The combined
+// discount never exceeds the subtotal.
+func (e *Engine) Price(ctx context.Context, cart *model.Cart, now time.Time) error {
+	discounts := make([]model.AppliedDiscount, 0, len(cart.Coupons))
+	remaining := cart.TotalPrice
+	total := model.MoneyFromMinor(0, model.DefaultCurrency)
+	applied, exclusive := 0, false
+
+	for _, code := range cart.Coupons {
+		line := model.AppliedDiscount{Code: code, Amount: model.MoneyFromMinor(0, model.DefaultCurrency)}
+
+		coupon, err := e.coupons.GetCoupon(ctx, code)
+		if err != nil {
+			return fmt.Errorf("load coupon %s: %w", code, err)
+		}
+		if coupon == nil {
+			line.Reason = ErrCouponNotFound.Error()
+			discounts = append(discounts, line)
+			continue
+		}
+		line.Description = coupon.Description
+
+		amount, err := Discount(coupon, cart.Items, now)
+		switch {
+		case err != nil:
+		case exclusive || (coupon.Exclusive && applied > 0):
+			err = ErrCouponNotCombinable
+		case e.maxPerCart > 0 && applied >= e.maxPerCart:
+			err = ErrTooManyCoupons
+		}
+		if err != nil {
+			line.Reason = err.Error()
+			discounts = append(discounts, line)
+			continue
+		}
+		applied++
+		exclusive = exclusive || coupon.Exclusive
+		if amount.Minor > remaining.Minor {
+			amount = remaining
+		}
+		remaining = remaining.Sub(amount)
+		total = total.Add(amount)
+
+		line.Amount = amount
+		line.Applied = true
+		discounts = append(discounts, line)
+	}
+
+	cart.Discounts = discounts
+	cart.DiscountTotal = total
+	cart.GrandTotal = cart.TotalPrice.Sub(total)
+	return nil
+}
+
+// RecordRedemptions counts every applied coupon on the cart against the user's
+// usage limit as the cart is turned into an order. A coupon whose limit was
+// reached since it was applied fails with ErrUsageLimitReached, and the coupons
+// redeemed before it are taken back. Repeating the call for the same order
+// redeems nothing twice.
+func (e *Engine) RecordRedemptions(ctx context.Context, cart *model.Cart, orderID string, now time.Time) error {
+	var redeemed []string
+	for _, d := range cart.Discounts {
+		if !d.Applied {
+			continue
+		}
+		if err := e.redeem(ctx, cart.UserID, d.Code, orderID, now); err != nil {
+			if undoErr := e.unredeem(ctx, cart.UserID, orderID, redeemed); undoErr != nil {
+				return fmt.Errorf("%w (taking back redeemed coupons: %v)", err, undoErr)
+			}
+			return err
+		}
+		redeemed = append(redeemed, d.Code)
+	}
+	return nil
+}
+
+// ReleaseRedemptions takes back what RecordRedemptions redeemed for the order,
+// when the checkout did not go through after all
+func (e *Engine) ReleaseRedemptions(ctx context.Context, cart *model.Cart, orderID string) error {
+	var codes []string
+	for _, d := range cart.Discounts {
+		if d.Applied {
+			codes = append(codes, d.Code)
+		}
+	}
+	return e.unredeem(ctx, cart.UserID, orderID, codes)
+}
+
+func (e *Engine) redeem(ctx context.Context, userID, code, orderID string, now time.Time) error {
+	coupon, err := e.coupons.GetCoupon(ctx, code)
+	if err != nil {
+		return fmt.Errorf("load coupon %s: %w", code, err)
+	}
+	limit := 0
+	if coupon != nil {
+		limit = coupon.MaxUsesPerUser
+	}
+	redemption := &model.CouponRedemption{Code: code, UserID: userID, OrderRef: orderID, RedeemedAt: now}
+	ok, err := e.coupons.RedeemCoupon(ctx, redemption, limit)
+	if err != nil {
+		return fmt.Errorf("redeem coupon %s: %w", code, err)
+	}
+	if !ok {
+		return fmt.Errorf("redeem coupon %s: %w", code, ErrUsageLimitReached)
+	}
+	return nil
+}
+
+func (e *Engine) unredeem(ctx context.Context, userID, orderID string, codes []string) error {
+	for _, code := range codes {
+		if err := e.coupons.UnredeemCoupon(ctx, code, userID, orderID); err != nil {
+			return fmt.Errorf("take back coupon %s: %w", code, err)
+		}
+	}
+	return nil
+}
+
+// Discount applies a coupon's rules to the cart items and returns the discount amount.
+// It checks activation window, category scope and minimum spend but not usage limits.
+func Discount(coupon *model.Coupon, items []model.CartItem, now time.Time) (model.Money, error) {
+	zero := model.MoneyFromMinor(0, model.DefaultCurrency)
+
+	if !coupon.Active || (coupon.StartsAt != nil && now.Before(*coupon.StartsAt)) {
+		return zero, ErrCouponInactive
+	}
+	if coupon.ExpiresAt != nil && !now.Before(*coupon.ExpiresAt) {
+		return zero, ErrCouponExpired
+	}
+
+	eligible := make([]model.CartItem, 0, len(items))
+	subtotal := zero
+	for _, item := range items {
+		if coupon.Category != "" && !strings.EqualFold(item.Category, coupon.Category) {
+			continue
+		}
+		eligible = append(eligible, item)
+		subtotal = subtotal.Add(item.Price.Mul(item.Quantity))
+	}
+	if len(eligible) == 0 {
+		return zero, ErrCouponNotApplicable
+	}
+	if subtotal.Minor < coupon.MinSpend.Minor {
+		return zero, fmt.Errorf("%w: spend at least %s", ErrMinSpendNotMet, coupon.MinSpend)
+	}
+
+	var amount model.Money
+	switch coupon.Type {
+	case model.DiscountPercentage:
+		if coupon.PercentOff <= 0 || coupon.PercentOff > 100 {
+			return zero, fmt.Errorf("%w: invalid percentage %d", ErrCouponNotApplicable, coupon.PercentOff)
+		}
+		// Round half up to the nearest minor unit
+		amount = model.MoneyFromMinor((subtotal.Minor*int64(coupon.PercentOff)+50)/100, model.DefaultCurrency)
+	case model.DiscountFixed:
+		amount = coupon.AmountOff
+		if amount.Minor > subtotal.Minor {
+			amount = subtotal
+		}
+	case model.DiscountBuyXGetY:
+		amount = buyXGetY(eligible, coupon.BuyQuantity, coupon.GetQuantity)
+	default:
+		return zero, fmt.Errorf("%w: unknown discount type %q", ErrCouponNotApplicable, coupon.Type)
+	}
+
+	if amount.Minor <= 0 {
+		return zero, ErrCouponNotApplicable
+	}
+	return amount, nil
+}
+
+// buyXGetY makes the cheapest units free: for every buy+get units in scope,
+// get of them cost nothing.
+func buyXGetY(items []model.CartItem, buy, get int) model.Money {
+	free := model.MoneyFromMinor(0, model.DefaultCurrency)
+	if buy <= 0 || get <= 0 {
+		return free
+	}
+
+	var units []model.Money
+	for _, item := range items {
+		for i := 0; i < item.Quantity; i++ {
+			units = append(units, item.Price)
+		}
+	}
+	sort.Slice(units, func(i, j int) bool { return units[i].Minor < units[j].Minor })
+
+	freeUnits := len(units) / (buy + get) * get
+	for _, price := range units[:freeUnits] {
+		free = free.Add(price)
+	}
+	return free
+}
@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// primaryRetryInterval is how long the fallback is used after the primary
+// fails, so an unreachable Redis costs one timeout per interval rather than
+// one per request
+const primaryRetryInterval = 5 * time.Second
+
+// FallbackLimiter uses primary (Redis) and switches to secondary (in memory)
+// while primary is failing. Requests are never rejected because Redis is down.
+type FallbackLimiter struct {
+	primary   Limiter
+	secondary Limiter
+	logger    *zap.Logger
+
+	mu        sync.Mutex
+	downUntil time.Time
+	degraded  bool
+}
+
+func NewFallbackLimiter(primary, secondary Limiter, logger *zap.Logger) *FallbackLimiter {
+	return &FallbackLimiter{primary: primary, secondary: secondary, logger: logger}
+}
+
+func (f *FallbackLimiter) Allow(ctx context.Context, key string, rule Rule) (Result, error) {
+	f.mu.Lock()
+	skipPrimary := time.Now().Before(f.downUntil)
+	f.mu.Unlock()
+
+	if !skipPrimary {
+		res, err := f.primary.Allow(ctx, key, rule)
+		if err == nil {
+			f.setDegraded(false, nil)
+			return res, nil
+		}
+		if ctx.Err() != nil {
+			// The request went away; that says nothing about Redis
+			return Result{}, ctx.Err()
+		}
+		f.setDegraded(true, err)
+	}
+	return f.secondary.Allow(ctx, key, rule)
+}
+
+// setDegraded logs only state changes, so an outage is two log lines
+func (f *FallbackLimiter) setDegraded(degraded bool, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if degraded {
+		f.downUntil = time.Now().Add(primaryRetryInterval)
+	}
+	if degraded == f.degraded {
+		return
+	}
+	f.degraded = degraded
+	if degraded {
+		f.logger.Warn("Rate limiter falling back to in-memory buckets", zap.Error(err))
+	} else {
+		f.logger.Info("Rate limiter using Redis again")
+	}
+}
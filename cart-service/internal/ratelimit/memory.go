@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often idle buckets are dropped from memory
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time // When the bucket will be full again; it can be dropped after
+}
+
+// MemoryLimiter keeps buckets in this process. Limits only hold per replica,
+// so it serves as the fallback while Redis is unreachable.
+type MemoryLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{buckets: make(map[string]*bucket), lastSweep: time.Now()}
+}
+
+func (m *MemoryLimiter) Allow(_ context.Context, key string, rule Rule) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(m.lastSweep) >= sweepInterval {
+		// A full bucket behaves exactly like a missing one
+		for k, b := range m.buckets {
+			if now.After(b.full) {
+				delete(m.buckets, k)
+			}
+		}
+		m.lastSweep = now
+	}
+
+	b, found := m.buckets[key]
+	if !found {
+		b = &bucket{tokens: float64(rule.Burst), last: now}
+		m.buckets[key] = b
+	}
+	rate := rule.perMilli()
+	elapsed := float64(now.Sub(b.last)) / float64(time.Millisecond)
+	b.tokens = math.Min(float64(rule.Burst), b.tokens+math.Max(0, elapsed)*rate)
+	b.last = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	b.full = now.Add(time.Duration((float64(rule.Burst)-b.tokens)/rate) * time.Millisecond)
+	return rule.result(allowed, b.tokens), nil
+}
@@ -0,0 +1,130 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emart/cart-service/internal/config"
+)
+
+// Rule is a token bucket: Burst tokens at most, refilled at Limit per Period
+type Rule struct {
+	Limit  int
+	Period time.Duration
+	Burst  int
+}
+
+// perMilli is the refill rate in tokens per millisecond
+func (r Rule) perMilli() float64 {
+	return float64(r.Limit) / float64(r.Period.Milliseconds())
+}
+
+// Result is the outcome of taking one token from a bucket
+type Result struct {
+	Allowed    bool
+	Remaining  int           // Whole tokens left after this request
+	RetryAfter time.Duration // Until the next token, when not allowed
+	Reset      time.Duration // Until the bucket is full again
+}
+
+// result derives the caller-facing numbers from the tokens left in a bucket
+func (r Rule) result(allowed bool, tokens float64) Result {
+	rate := r.perMilli()
+	res := Result{
+		Allowed:   allowed,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     time.Duration((float64(r.Burst)-tokens)/rate) * time.Millisecond,
+	}
+	if !allowed {
+		res.RetryAfter = time.Duration((1-tokens)/rate) * time.Millisecond
+	}
+	return res
+}
+
+// Limiter takes one token from the bucket named key
+type Limiter interface {
+	Allow(ctx context.Context, key string, rule Rule) (Result, error)
+}
+
+// ParseRule reads "<requests>/<period>[:<burst>]", e.g. "30/1m:10". Without a
+// burst the whole minute's allowance may be spent at once. ok is false for
+// "off", which disables limiting.
+func ParseRule(s string) (rule Rule, ok bool, err error) {
+	s = strings.TrimSpace(s)
+	if s == "off" {
+		return Rule{}, false, nil
+	}
+	spec, burst, hasBurst := strings.Cut(s, ":")
+	count, period, found := strings.Cut(spec, "/")
+	if !found {
+		return Rule{}, false, fmt.Errorf("invalid rate limit %q: want <requests>/<period>[:<burst>]", s)
+	}
+	if rule.Limit, err = strconv.Atoi(count); err != nil || rule.Limit <= 0 {
+		return Rule{}, false, fmt.Errorf("invalid rate limit %q: requests must be a positive integer", s)
+	}
+	if rule.Period, err = time.ParseDuration(period); err != nil || rule.Period < time.Millisecond {
+		return Rule{}, false, fmt.Errorf("invalid rate limit %q: period must be a duration such as 1m", s)
+	}
+	rule.Burst = rule.Limit
+	if hasBurst {
+		if rule.Burst, err = strconv.Atoi(burst); err != nil || rule.Burst <= 0 {
+			return Rule{}, false, fmt.Errorf("invalid rate limit %q: burst must be a positive integer", s)
+		}
+	}
+	return rule, true, nil
+}
+
+// Policy picks the rule for a route. Routes with a rule of their own get their
+// own buckets; every other route shares the default bucket.
+type Policy struct {
+	def    *Rule
+	routes map[string]*Rule
+}
+
+// NewPolicy parses the configured rules, so a typo fails startup instead of
+// silently disabling a limit
+func NewPolicy(cfg config.RateLimitConfig) (*Policy, error) {
+	p := &Policy{routes: make(map[string]*Rule, len(cfg.Routes))}
+	parse := func(s string) (*Rule, error) {
+		rule, ok, err := ParseRule(s)
+		if err != nil || !ok {
+			return nil, err
+		}
+		return &rule, nil
+	}
+
+	var err error
+	if p.def, err = parse(cfg.Default); err != nil {
+		return nil, fmt.Errorf("RATE_LIMIT_DEFAULT: %w", err)
+	}
+	for route, s := range cfg.Routes {
+		method, path, found := strings.Cut(strings.TrimSpace(route), " ")
+		if !found || path == "" || method != strings.ToUpper(method) {
+			return nil, fmt.Errorf("RATE_LIMIT_ROUTES: route %q must be \"METHOD /path/template\"", route)
+		}
+		if p.routes[route], err = parse(s); err != nil {
+			return nil, fmt.Errorf("RATE_LIMIT_ROUTES %q: %w", route, err)
+		}
+	}
+	return p, nil
+}
+
+// For returns the bucket name and rule for a request; ok is false when the
+// route is not limited
+func (p *Policy) For(method, route string) (bucket string, rule Rule, ok bool) {
+	name := method + " " + route
+	if r, found := p.routes[name]; found {
+		if r == nil {
+			return "", Rule{}, false
+		}
+		return name, *r, true
+	}
+	if p.def == nil {
+		return "", Rule{}, false
+	}
+	return "default", *p.def, true
+}
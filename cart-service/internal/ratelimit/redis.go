@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const keyPrefix = "ratelimit:"
+
+// takeTokenScript refills and takes from a token bucket in one round trip. It
+// reads the Redis server clock, so replicas with skewed clocks share buckets
+// correctly. The bucket expires once it would be full again.
+//
+//	KEYS[1] = bucket key, ARGV[1] = refill rate in tokens/ms, ARGV[2] = burst
+//	Returns {allowed (0|1), tokens left as a string}
+var takeTokenScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + tonumber(t[2]) / 1000
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+  tokens = burst
+  ts = now
+end
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) / rate) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+// RedisLimiter keeps buckets in Redis so limits hold across replicas
+type RedisLimiter struct {
+	client redis.Scripter
+}
+
+func NewRedisLimiter(client redis.Scripter) *RedisLimiter {
+	return &RedisLimiter{client: client}
+}
+
+func (l *RedisLimiter) Allow(ctx context.Context, key string, rule Rule) (Result, error) {
+	rate := strconv.FormatFloat(rule.perMilli(), 'g', -1, 64)
+	reply, err := takeTokenScript.Run(ctx, l.client, []string{keyPrefix + key}, rate, rule.Burst).Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("rate limit %s: %w", key, err)
+	}
+	if len(reply) != 2 {
+		return Result{}, fmt.Errorf("rate limit %s: unexpected reply %v", key, reply)
+	}
+	allowed, _ := reply[0].(int64)
+	tokensStr, _ := reply[1].(string)
+	tokens, err := strconv.ParseFloat(tokensStr, 64)
+	if err != nil {
+		return Result{}, fmt.Errorf("rate limit %s: parse tokens %q: %w", key, tokensStr, err)
+	}
+	return rule.result(allowed == 1, tokens), nil
+}
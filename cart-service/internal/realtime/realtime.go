@@ -0,0 +1,246 @@
+// Package realtime pushes cart changes to the shopper's open streams.
+package realtime
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/emart/cart-service/internal/model"
+)
+
+var (
+	// ErrClosed is returned by Subscribe once the broker is shutting down
+	ErrClosed = errors.New("realtime broker closed")
+	// ErrTooManyStreams is returned when a cart already has the maximum number of open streams
+	ErrTooManyStreams = errors.New("too many open streams for this cart")
+)
+
+// Publisher announces that a cart changed. Updates carry the whole cart, so a
+// missed one is made good by the next.
+type Publisher interface {
+	PublishCart(ctx context.Context, cart *model.Cart) error
+}
+
+// Subscriber hands out a stream of updates for one cart
+type Subscriber interface {
+	Subscribe(ctx context.Context, userID string) (*Subscription, error)
+}
+
+// Subscription receives the latest state of one cart. A slow reader never
+// blocks the publisher: an unread update is replaced by the newer one.
+type Subscription struct {
+	updates chan *model.Cart
+	done    chan struct{}
+	once    sync.Once
+	release func(*Subscription)
+}
+
+// Updates delivers the cart every time it changes
+func (s *Subscription) Updates() <-chan *model.Cart { return s.updates }
+
+// Done is closed when the subscription ends, either by Close or because the
+// broker is shutting down
+func (s *Subscription) Done() <-chan struct{} { return s.done }
+
+// Close ends the subscription; it is safe to call more than once
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		close(s.done)
+		s.release(s)
+	})
+}
+
+func (s *Subscription) deliver(cart *model.Cart) {
+	for {
+		select {
+		case s.updates <- cart:
+			return
+		default:
+		}
+		// Drop the stale update nobody has read yet
+		select {
+		case <-s.updates:
+		default:
+		}
+	}
+}
+
+// hub fans updates out to the subscriptions on this replica. watch and unwatch
+// run when a cart gains its first subscriber and loses its last one. They are
+// network round trips, so they run without mu, ordered by watchMu.
+type hub struct {
+	mu         sync.Mutex
+	subs       map[string]map[*Subscription]struct{}
+	watches    map[string]*watchState
+	closed     bool
+	maxPerUser int
+	watchMu    sync.Mutex
+	watch      func(ctx context.Context, userID string) error
+	unwatch    func(userID string)
+}
+
+// watchState is the watch a cart's first subscriber started; subscribers
+// arriving while it is in flight wait for its outcome
+type watchState struct {
+	done chan struct{}
+	err  error
+}
+
+func newHub(maxPerUser int) *hub {
+	return &hub{
+		subs:       make(map[string]map[*Subscription]struct{}),
+		watches:    make(map[string]*watchState),
+		maxPerUser: maxPerUser,
+		watch:      func(context.Context, string) error { return nil },
+		unwatch:    func(string) {},
+	}
+}
+
+// subscribe registers the subscription, then makes sure the cart is watched
+// before returning it, so the caller can load the cart without missing an update
+func (h *hub) subscribe(ctx context.Context, userID string) (*Subscription, error) {
+	sub, w, first, err := h.register(userID)
+	if err != nil {
+		return nil, err
+	}
+	if first {
+		h.watchMu.Lock()
+		w.err = h.watch(ctx, userID)
+		h.watchMu.Unlock()
+		close(w.done)
+	}
+	select {
+	case <-w.done:
+	case <-ctx.Done():
+		sub.Close()
+		return nil, ctx.Err()
+	}
+	if w.err != nil {
+		sub.Close()
+		return nil, w.err
+	}
+
+	// close may have ended the subscription while the watch was in flight
+	h.mu.Lock()
+	_, ok := h.subs[userID][sub]
+	h.mu.Unlock()
+	if !ok {
+		return nil, ErrClosed
+	}
+	return sub, nil
+}
+
+// register adds a subscription for userID; first reports whether the caller
+// has to start the cart's watch
+func (h *hub) register(userID string) (sub *Subscription, w *watchState, first bool, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, nil, false, ErrClosed
+	}
+	subs := h.subs[userID]
+	if h.maxPerUser > 0 && len(subs) >= h.maxPerUser {
+		return nil, nil, false, ErrTooManyStreams
+	}
+	if subs == nil {
+		subs = make(map[*Subscription]struct{})
+		h.subs[userID] = subs
+	}
+	w, ok := h.watches[userID]
+	if !ok {
+		w = &watchState{done: make(chan struct{})}
+		h.watches[userID] = w
+	}
+	sub = &Subscription{
+		updates: make(chan *model.Cart, 1),
+		done:    make(chan struct{}),
+	}
+	sub.release = func(s *Subscription) { h.remove(userID, s) }
+	subs[sub] = struct{}{}
+	return sub, w, !ok, nil
+}
+
+func (h *hub) remove(userID string, sub *Subscription) {
+	h.mu.Lock()
+	subs, ok := h.subs[userID]
+	if !ok {
+		h.mu.Unlock()
+		return
+	}
+	delete(subs, sub)
+	last := len(subs) == 0
+	if last {
+		delete(h.subs, userID)
+		delete(h.watches, userID)
+	}
+	closed := h.closed
+	h.mu.Unlock()
+	if !last || closed {
+		return
+	}
+
+	h.watchMu.Lock()
+	defer h.watchMu.Unlock()
+	// A stream opened meanwhile keeps the watch; its own watch call is either
+	// done already or waiting for watchMu
+	h.mu.Lock()
+	_, reopened := h.subs[userID]
+	h.mu.Unlock()
+	if !reopened {
+		h.unwatch(userID)
+	}
+}
+
+func (h *hub) deliver(cart *model.Cart) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subs[cart.UserID] {
+		sub.deliver(cart)
+	}
+}
+
+// close ends every subscription and refuses new ones
+func (h *hub) close() {
+	h.mu.Lock()
+	h.closed = true
+	var subs []*Subscription
+	for _, byUser := range h.subs {
+		for sub := range byUser {
+			subs = append(subs, sub)
+		}
+	}
+	h.mu.Unlock()
+	for _, sub := range subs {
+		sub.Close()
+	}
+}
+
+// MemoryBroker delivers updates within this process only; for a single
+// replica and for tests
+type MemoryBroker struct {
+	hub *hub
+}
+
+func NewMemoryBroker(maxPerUser int) *MemoryBroker {
+	return &MemoryBroker{hub: newHub(maxPerUser)}
+}
+
+func (b *MemoryBroker) PublishCart(ctx context.Context, cart *model.Cart) error {
+	b.hub.deliver(cart)
+	return nil
+}
+
+func (b *MemoryBroker) Subscribe(ctx context.Context, userID string) (*Subscription, error) {
+	return b.hub.subscribe(ctx, userID)
+}
+
+// Close ends every open subscription
+func (b *MemoryBroker) Close() { b.hub.close() }
+
+// noopPublisher drops every update (STREAM_ENABLED=false)
+type noopPublisher struct{}
+
+func NewNoopPublisher() Publisher { return noopPublisher{} }
+
+func (noopPublisher) PublishCart(ctx context.Context, cart *model.Cart) error { return nil }
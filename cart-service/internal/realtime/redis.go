@@ -0,0 +1,91 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/emart/cart-service/internal/model"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const channelPrefix = "cart-updates:"
+
+// RedisBroker fans cart updates out to every replica over Redis pub/sub. Each
+// replica holds one pub/sub connection and only subscribes to the carts that
+// have a stream open on it. Updates published while that connection is being
+// re-established are lost; the next change brings the stream up to date.
+type RedisBroker struct {
+	client *redis.Client
+	pubsub *redis.PubSub
+	hub    *hub
+	logger *zap.Logger
+}
+
+func NewRedisBroker(client *redis.Client, maxPerUser int, logger *zap.Logger) *RedisBroker {
+	b := &RedisBroker{
+		client: client,
+		pubsub: client.Subscribe(context.Background()),
+		hub:    newHub(maxPerUser),
+		logger: logger,
+	}
+	b.hub.watch = func(ctx context.Context, userID string) error {
+		if err := b.pubsub.Subscribe(ctx, channelPrefix+userID); err != nil {
+			return fmt.Errorf("redis subscribe cart updates: %w", err)
+		}
+		return nil
+	}
+	b.hub.unwatch = func(userID string) {
+		if err := b.pubsub.Unsubscribe(context.Background(), channelPrefix+userID); err != nil {
+			b.logger.Warn("Failed to unsubscribe from cart updates", zap.String("userID", userID), zap.Error(err))
+		}
+	}
+	return b
+}
+
+// Start relays updates published by any replica to the local streams until
+// ctx is cancelled or the broker is closed
+func (b *RedisBroker) Start(ctx context.Context) {
+	messages := b.pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			var cart model.Cart
+			if err := json.Unmarshal([]byte(msg.Payload), &cart); err != nil {
+				b.logger.Warn("Dropping malformed cart update", zap.String("channel", msg.Channel), zap.Error(err))
+				continue
+			}
+			b.hub.deliver(&cart)
+		}
+	}
+}
+
+func (b *RedisBroker) PublishCart(ctx context.Context, cart *model.Cart) error {
+	data, err := json.Marshal(cart)
+	if err != nil {
+		return fmt.Errorf("marshal cart update: %w", err)
+	}
+	if err := b.client.Publish(ctx, channelPrefix+cart.UserID, data).Err(); err != nil {
+		return fmt.Errorf("redis publish cart update: %w", err)
+	}
+	return nil
+}
+
+func (b *RedisBroker) Subscribe(ctx context.Context, userID string) (*Subscription, error) {
+	return b.hub.subscribe(ctx, userID)
+}
+
+// Close ends every open stream on this replica so graceful shutdown is not
+// held up by them; clients reconnect to another replica
+func (b *RedisBroker) Close() {
+	b.hub.close()
+	if err := b.pubsub.Close(); err != nil {
+		b.logger.Warn("Failed to close cart updates subscription", zap.Error(err))
+	}
+}
@@ -0,0 +1,132 @@
+package mongorepo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/emart/cart-service/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AbandonedCartMongoRepository finds stale carts for the abandoned cart job.
+// Claiming stamps abandoned_at atomically, so a cart is handed out at most once
+// even with several service instances running the job.
+type AbandonedCartMongoRepository interface {
+	ClaimAbandoned(ctx context.Context, cutoff time.Time, limit int) ([]*model.Cart, error)
+	ReleaseClaim(ctx context.Context, userID string, maxAttempts int) (gaveUp bool, err error)
+	MarkNotified(ctx context.Context, userID string, at time.Time) error
+}
+
+type abandonedCartMongoRepo struct {
+	collection *mongo.Collection
+}
+
+func NewAbandonedCartMongoRepository(db *mongo.Database) AbandonedCartMongoRepository {
+	return &abandonedCartMongoRepo{collection: db.Collection("carts")}
+}
+
+// abandonedFilter matches user carts with items, untouched since cutoff and never
+// claimed before. Guest carts are skipped: there is nobody to remind, and so are
+// carts held by a checkout session. Checked-out and cleared carts are deleted,
+// so they never match.
+func abandonedFilter(cutoff time.Time) bson.M {
+	return bson.M{
+		"updated_at":   bson.M{"$lt": cutoff},
+		"abandoned_at": bson.M{"$exists": false},
+		"items.0":      bson.M{"$exists": true},
+		"user_id":      bson.M{"$not": primitive.Regex{Pattern: "^" + model.GuestCartPrefix}},
+		"locked_until": bson.M{"$not": bson.M{"$gt": time.Now()}},
+	}
+}
+
+// ClaimAbandoned returns up to limit stale carts, each already stamped with abandoned_at
+func (r *abandonedCartMongoRepo) ClaimAbandoned(ctx context.Context, cutoff time.Time, limit int) ([]*model.Cart, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	// Oldest first, served by idx_updated_at
+	findOpts := options.Find().
+		SetSort(bson.D{{Key: "updated_at", Value: 1}}).
+		SetLimit(int64(limit)).
+		SetProjection(bson.M{"user_id": 1})
+	cursor, err := r.collection.Find(ctx, abandonedFilter(cutoff), findOpts)
+	if err != nil {
+		return nil, fmt.Errorf("mongo find abandoned carts: %w", err)
+	}
+	var candidates []struct {
+		UserID string `bson:"user_id"`
+	}
+	if err := cursor.All(ctx, &candidates); err != nil {
+		return nil, fmt.Errorf("mongo decode abandoned carts: %w", err)
+	}
+
+	claimed := make([]*model.Cart, 0, len(candidates))
+	now := time.Now()
+	for _, c := range candidates {
+		// Re-check the filter while claiming: the cart may have been updated,
+		// checked out or claimed by another instance since the Find
+		filter := abandonedFilter(cutoff)
+		filter["user_id"] = c.UserID
+		update := bson.M{"$set": bson.M{"abandoned_at": now}}
+		opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+		var cart model.Cart
+		err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&cart)
+		if err == mongo.ErrNoDocuments {
+			continue
+		}
+		if err != nil {
+			return claimed, fmt.Errorf("mongo claim abandoned cart: %w", err)
+		}
+		claimed = append(claimed, &cart)
+	}
+	return claimed, nil
+}
+
+// ReleaseClaim counts a failed notification and clears abandoned_at so the next
+// run retries. Once the cart has failed maxAttempts times the claim is kept
+// instead, so the job stops resending it, and gaveUp is true.
+func (r *abandonedCartMongoRepo) ReleaseClaim(ctx context.Context, userID string, maxAttempts int) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	attempts := bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$abandon_attempts", 0}}, 1}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"abandon_attempts": attempts}}},
+		{{Key: "$set", Value: bson.M{"abandoned_at": bson.M{"$cond": bson.A{
+			bson.M{"$lt": bson.A{"$abandon_attempts", maxAttempts}}, "$$REMOVE", "$abandoned_at",
+		}}}}},
+	}
+	opts := options.FindOneAndUpdate().
+		SetReturnDocument(options.After).
+		SetProjection(bson.M{"abandon_attempts": 1})
+
+	var after struct {
+		Attempts int `bson:"abandon_attempts"`
+	}
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{"user_id": userID}, pipeline, opts).Decode(&after)
+	if err == mongo.ErrNoDocuments {
+		// Deleted meanwhile (checked out or cleared): nothing left to remind
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("mongo release abandoned cart: %w", err)
+	}
+	return after.Attempts >= maxAttempts, nil
+}
+
+// MarkNotified records when the shopper was reminded
+func (r *abandonedCartMongoRepo) MarkNotified(ctx context.Context, userID string, at time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	_, err := r.collection.UpdateOne(ctx, bson.M{"user_id": userID}, bson.M{"$set": bson.M{"abandon_notified_at": at}})
+	if err != nil {
+		return fmt.Errorf("mongo mark abandoned cart notified: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package mongorepo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/emart/cart-service/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AdminCartMongoRepository answers support staff queries across all carts.
+// It reads MongoDB only, so a cart can lag Redis by up to one sync interval.
+type AdminCartMongoRepository interface {
+	ListCarts(ctx context.Context, filter model.AdminCartFilter) ([]*model.Cart, int64, error)
+	CountActive(ctx context.Context, since time.Time) (int64, error)
+}
+
+type adminCartMongoRepo struct {
+	collection *mongo.Collection
+}
+
+func NewAdminCartMongoRepository(db *mongo.Database) AdminCartMongoRepository {
+	return &adminCartMongoRepo{collection: db.Collection("carts")}
+}
+
+// ListCarts returns one page of carts, most recently updated first, and the
+// total number of carts matching the filter
+func (r *adminCartMongoRepo) ListCarts(ctx context.Context, filter model.AdminCartFilter) ([]*model.Cart, int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	query := bson.M{}
+	switch filter.Status {
+	case model.AdminCartsActive:
+		query["abandoned_at"] = bson.M{"$exists": false}
+	case model.AdminCartsAbandoned:
+		query["abandoned_at"] = bson.M{"$exists": true}
+	}
+	updated := bson.M{}
+	if filter.UpdatedAfter != nil {
+		updated["$gte"] = *filter.UpdatedAfter
+	}
+	if filter.UpdatedBefore != nil {
+		updated["$lt"] = *filter.UpdatedBefore
+	}
+	if len(updated) > 0 {
+		query["updated_at"] = updated
+	}
+
+	total, err := r.collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, fmt.Errorf("mongo count carts: %w", err)
+	}
+
+	// Served by idx_updated_at
+	findOpts := options.Find().
+		SetSort(bson.D{{Key: "updated_at", Value: -1}}).
+		SetSkip(int64(filter.Offset)).
+		SetLimit(int64(filter.Limit))
+	cursor, err := r.collection.Find(ctx, query, findOpts)
+	if err != nil {
+		return nil, 0, fmt.Errorf("mongo list carts: %w", err)
+	}
+	carts := make([]*model.Cart, 0, filter.Limit)
+	if err := cursor.All(ctx, &carts); err != nil {
+		return nil, 0, fmt.Errorf("mongo decode carts: %w", err)
+	}
+	for _, cart := range carts {
+		if err := model.Upcast(cart); err != nil {
+			return nil, 0, fmt.Errorf("upcast cart %s: %w", cart.UserID, err)
+		}
+		cart.Source = "mongodb"
+	}
+	return carts, total, nil
+}
+
+// CountActive counts carts with items that were updated at or after since
+func (r *adminCartMongoRepo) CountActive(ctx context.Context, since time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	n, err := r.collection.CountDocuments(ctx, bson.M{
+		"updated_at": bson.M{"$gte": since},
+		"items.0":    bson.M{"$exists": true},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("mongo count active carts: %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,53 @@
+package mongorepo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/emart/cart-service/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AuditLogMongoRepository is an append-only record of admin actions
+type AuditLogMongoRepository interface {
+	Record(ctx context.Context, entry *model.AuditEntry) error
+	ListForUser(ctx context.Context, targetUserID string, limit int) ([]*model.AuditEntry, error)
+}
+
+type auditLogMongoRepo struct {
+	collection *mongo.Collection
+}
+
+func NewAuditLogMongoRepository(db *mongo.Database) AuditLogMongoRepository {
+	return &auditLogMongoRepo{collection: db.Collection("admin_audit_log")}
+}
+
+func (r *auditLogMongoRepo) Record(ctx context.Context, entry *model.AuditEntry) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if _, err := r.collection.InsertOne(ctx, entry); err != nil {
+		return fmt.Errorf("mongo record audit entry: %w", err)
+	}
+	return nil
+}
+
+// ListForUser returns the latest admin actions on a customer's cart, newest first
+func (r *auditLogMongoRepo) ListForUser(ctx context.Context, targetUserID string, limit int) ([]*model.AuditEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	findOpts := options.Find().SetSort(bson.D{{Key: "at", Value: -1}}).SetLimit(int64(limit))
+	cursor, err := r.collection.Find(ctx, bson.M{"target_user_id": targetUserID}, findOpts)
+	if err != nil {
+		return nil, fmt.Errorf("mongo list audit entries: %w", err)
+	}
+	entries := make([]*model.AuditEntry, 0)
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, fmt.Errorf("mongo decode audit entries: %w", err)
+	}
+	return entries, nil
+}
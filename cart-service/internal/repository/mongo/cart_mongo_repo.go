@@ -3,6 +3,7 @@ package mongorepo
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/emart/cart-service/internal/model"
@@ -16,11 +17,16 @@ type CartMongoRepository interface {
 	GetCart(ctx context.Context, userID string) (*model.Cart, error)
 	UpsertCart(ctx context.Context, cart *model.Cart) error
 	DeleteCart(ctx context.Context, userID string) error
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
 	Ping(ctx context.Context) error
 }
 
 type cartMongoRepo struct {
 	collection *mongo.Collection
+
+	txMu        sync.Mutex
+	txChecked   bool
+	txSupported bool
 }
 
 func NewCartMongoRepository(db *mongo.Database) CartMongoRepository {
@@ -42,40 +48,78 @@ func (r *cartMongoRepo) GetCart(ctx context.Context, userID string) (*model.Cart
 	if err != nil {
 		return nil, fmt.Errorf("mongo get cart: %w", err)
 	}
+	if err := model.Upcast(&cart); err != nil {
+		return nil, fmt.Errorf("upcast cart: %w", err)
+	}
 	cart.Source = "mongodb"
 	return &cart, nil
 }
 
-// UpsertCart inserts or updates a cart in MongoDB
+// UpsertCart inserts or updates a cart in MongoDB.
+// The write only applies when the stored document is older than cart.Version,
+// so a cart can only ever move forward; otherwise model.ErrVersionConflict is returned.
 func (r *cartMongoRepo) UpsertCart(ctx context.Context, cart *model.Cart) error {
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	now := time.Now()
-	cart.SyncedAt = &now
 
-	filter := bson.M{"user_id": cart.UserID}
-	update := bson.M{
-		"$set": bson.M{
-			"user_id":        cart.UserID,
-			"items":          cart.Items,
-			"total_items":    cart.TotalItems,
-			"total_price":    cart.TotalPrice,
-			"updated_at":     cart.UpdatedAt,
-			"synced_at":      now,
-			"schema_version": cart.SchemaVersion,
-			"source":         "mongodb",
+	// Documents written before versioning have no version field at all
+	filter := bson.M{
+		"user_id": cart.UserID,
+		"$or": bson.A{
+			bson.M{"version": bson.M{"$lt": cart.Version}},
+			bson.M{"version": bson.M{"$exists": false}},
 		},
+	}
+	set := bson.M{
+		"user_id":        cart.UserID,
+		"items":          cart.Items,
+		"total_items":    cart.TotalItems,
+		"total_price":    cart.TotalPrice,
+		"coupons":        cart.Coupons,
+		"discounts":      cart.Discounts,
+		"discount_total": cart.DiscountTotal,
+		"grand_total":    cart.GrandTotal,
+		"currency":       cart.Currency,
+		"updated_at":     cart.UpdatedAt,
+		"synced_at":      now,
+		"schema_version": cart.SchemaVersion,
+		"version":        cart.Version,
+		"source":         "mongodb",
+	}
+	if cart.ExpiresAt != nil {
+		set["expires_at"] = cart.ExpiresAt
+	}
+	if cart.Email != "" {
+		// Only requests from logged-in shoppers know it; keep the stored one otherwise
+		set["email"] = cart.Email
+	}
+	update := bson.M{
+		"$set": set,
 		"$setOnInsert": bson.M{
 			"created_at": cart.CreatedAt,
 		},
 	}
+	if cart.CheckoutSessionID != "" {
+		set["checkout_session_id"] = cart.CheckoutSessionID
+		set["locked_until"] = cart.LockedUntil
+	} else {
+		// Unlocking must clear a lock written by an earlier version
+		update["$unset"] = bson.M{"checkout_session_id": "", "locked_until": ""}
+	}
 
 	opts := options.Update().SetUpsert(true)
 	_, err := r.collection.UpdateOne(ctx, filter, update, opts)
+	if mongo.IsDuplicateKeyError(err) {
+		// The filter missed because a newer version exists, so the upsert
+		// tried to insert a second document for the same user_id.
+		return model.ErrVersionConflict
+	}
 	if err != nil {
 		return fmt.Errorf("mongo upsert cart: %w", err)
 	}
+	cart.SyncedAt = &now
 	return nil
 }
 
@@ -91,6 +135,55 @@ func (r *cartMongoRepo) DeleteCart(ctx context.Context, userID string) error {
 	return nil
 }
 
+// WithTransaction runs fn in a multi-document transaction; repository calls made
+// with the ctx passed to fn join it. Standalone servers have no transactions,
+// so there fn simply runs with the caller's ctx.
+func (r *cartMongoRepo) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !r.supportsTransactions(ctx) {
+		return fn(ctx)
+	}
+
+	session, err := r.collection.Database().Client().StartSession()
+	if err != nil {
+		return fmt.Errorf("mongo start session: %w", err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
+
+// supportsTransactions reports whether the server is a replica set member or mongos.
+// The answer is cached once the server responds; until then writes run untransacted.
+func (r *cartMongoRepo) supportsTransactions(ctx context.Context) bool {
+	r.txMu.Lock()
+	defer r.txMu.Unlock()
+	if r.txChecked {
+		return r.txSupported
+	}
+
+	supported, err := SupportsTransactions(ctx, r.collection.Database())
+	if err != nil {
+		return false
+	}
+	r.txChecked = true
+	r.txSupported = supported
+	return r.txSupported
+}
+
+// SupportsTransactions asks the server whether it is a replica set member or
+// mongos; a standalone server has no multi-document transactions
+func SupportsTransactions(ctx context.Context, db *mongo.Database) (bool, error) {
+	var hello bson.M
+	if err := db.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		return false, fmt.Errorf("mongo hello: %w", err)
+	}
+	_, replicaSet := hello["setName"]
+	return replicaSet || hello["msg"] == "isdbgrid", nil
+}
+
 // Ping checks MongoDB connectivity
 func (r *cartMongoRepo) Ping(ctx context.Context) error {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
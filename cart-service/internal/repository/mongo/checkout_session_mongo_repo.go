@@ -0,0 +1,90 @@
+package mongorepo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/emart/cart-service/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CheckoutSessionMongoRepository stores checkout snapshots. State changes are
+// single conditional updates, so a session completes or cancels exactly once.
+type CheckoutSessionMongoRepository interface {
+	Create(ctx context.Context, session *model.CheckoutSession) error
+	Get(ctx context.Context, sessionID string) (*model.CheckoutSession, error)
+	Complete(ctx context.Context, sessionID, userID, orderID string, now time.Time) (*model.CheckoutSession, error)
+	Cancel(ctx context.Context, sessionID, userID string, now time.Time) (*model.CheckoutSession, error)
+}
+
+type checkoutSessionMongoRepo struct {
+	collection *mongo.Collection
+}
+
+func NewCheckoutSessionMongoRepository(db *mongo.Database) CheckoutSessionMongoRepository {
+	return &checkoutSessionMongoRepo{collection: db.Collection("checkout_sessions")}
+}
+
+func (r *checkoutSessionMongoRepo) Create(ctx context.Context, session *model.CheckoutSession) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if _, err := r.collection.InsertOne(ctx, session); err != nil {
+		return fmt.Errorf("mongo create checkout session: %w", err)
+	}
+	return nil
+}
+
+// Get returns the session or nil if it does not exist
+func (r *checkoutSessionMongoRepo) Get(ctx context.Context, sessionID string) (*model.CheckoutSession, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	var session model.CheckoutSession
+	err := r.collection.FindOne(ctx, bson.M{"_id": sessionID}).Decode(&session)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("mongo get checkout session: %w", err)
+	}
+	return &session, nil
+}
+
+// Complete moves an unexpired ACTIVE session to COMPLETED. Returns nil if no such session.
+func (r *checkoutSessionMongoRepo) Complete(ctx context.Context, sessionID, userID, orderID string, now time.Time) (*model.CheckoutSession, error) {
+	filter := bson.M{
+		"_id":        sessionID,
+		"user_id":    userID,
+		"status":     model.CheckoutActive,
+		"expires_at": bson.M{"$gt": now},
+	}
+	update := bson.M{"$set": bson.M{"status": model.CheckoutCompleted, "order_id": orderID, "closed_at": now}}
+	return r.transition(ctx, filter, update)
+}
+
+// Cancel moves an ACTIVE session to CANCELLED. Returns nil if no such session.
+func (r *checkoutSessionMongoRepo) Cancel(ctx context.Context, sessionID, userID string, now time.Time) (*model.CheckoutSession, error) {
+	filter := bson.M{"_id": sessionID, "user_id": userID, "status": model.CheckoutActive}
+	update := bson.M{"$set": bson.M{"status": model.CheckoutCancelled, "closed_at": now}}
+	return r.transition(ctx, filter, update)
+}
+
+func (r *checkoutSessionMongoRepo) transition(ctx context.Context, filter, update bson.M) (*model.CheckoutSession, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	var session model.CheckoutSession
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&session)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("mongo update checkout session: %w", err)
+	}
+	return &session, nil
+}
@@ -0,0 +1,142 @@
+package mongorepo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/emart/cart-service/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CouponMongoRepository interface
+type CouponMongoRepository interface {
+	GetCoupon(ctx context.Context, code string) (*model.Coupon, error)
+	CountRedemptions(ctx context.Context, code, userID string) (int64, error)
+	RedeemCoupon(ctx context.Context, redemption *model.CouponRedemption, limit int) (bool, error)
+	UnredeemCoupon(ctx context.Context, code, userID, orderRef string) error
+}
+
+type couponMongoRepo struct {
+	coupons     *mongo.Collection
+	redemptions *mongo.Collection
+	usage       *mongo.Collection
+}
+
+func NewCouponMongoRepository(db *mongo.Database) CouponMongoRepository {
+	return &couponMongoRepo{
+		coupons:     db.Collection("coupons"),
+		redemptions: db.Collection("coupon_redemptions"),
+		usage:       db.Collection("coupon_usage"),
+	}
+}
+
+// GetCoupon looks a coupon up by code (case-insensitive). Returns nil if unknown.
+func (r *couponMongoRepo) GetCoupon(ctx context.Context, code string) (*model.Coupon, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	var coupon model.Coupon
+	err := r.coupons.FindOne(ctx, bson.M{"_id": strings.ToUpper(code)}).Decode(&coupon)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("mongo get coupon: %w", err)
+	}
+	return &coupon, nil
+}
+
+// usageID keys a user's counter in coupon_usage. It is a document so codes and
+// user IDs cannot run into each other; the field order must stay fixed.
+func usageID(code, userID string) bson.D {
+	return bson.D{{Key: "code", Value: strings.ToUpper(code)}, {Key: "user_id", Value: userID}}
+}
+
+// CountRedemptions returns how often the user already redeemed the coupon
+func (r *couponMongoRepo) CountRedemptions(ctx context.Context, code, userID string) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	var usage struct {
+		Used int64 `bson:"used"`
+	}
+	err := r.usage.FindOne(ctx, bson.M{"_id": usageID(code, userID)}).Decode(&usage)
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("mongo count redemptions: %w", err)
+	}
+	return usage.Used, nil
+}
+
+// RedeemCoupon records a redemption and counts it against the user's usage of
+// the coupon. The record comes first: it is unique per order, so redeeming
+// again for the same order is a no-op that succeeds and a checkout can be
+// retried. The counter is then only incremented while it is below limit
+// (0 = unlimited), so concurrent checkouts cannot both take the last use;
+// false means the limit was reached and the record was deleted again.
+func (r *couponMongoRepo) RedeemCoupon(ctx context.Context, redemption *model.CouponRedemption, limit int) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	redemption.Code = strings.ToUpper(redemption.Code)
+	_, err := r.redemptions.InsertOne(ctx, redemption)
+	if mongo.IsDuplicateKeyError(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("mongo record redemption: %w", err)
+	}
+
+	filter := bson.M{"_id": usageID(redemption.Code, redemption.UserID)}
+	if limit > 0 {
+		filter["used"] = bson.M{"$lt": limit}
+	}
+	// With the limit reached the filter misses and the upsert collides with
+	// the existing counter. Two first redemptions racing to create the counter
+	// collide the same way, so a collision is retried once against the counter.
+	for attempt := 0; attempt < 2; attempt++ {
+		_, err = r.usage.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"used": 1}}, options.Update().SetUpsert(true))
+		if !mongo.IsDuplicateKeyError(err) {
+			break
+		}
+	}
+	if err == nil {
+		return true, nil
+	}
+
+	record := bson.M{"code": redemption.Code, "user_id": redemption.UserID, "order_ref": redemption.OrderRef}
+	if _, undoErr := r.redemptions.DeleteOne(ctx, record); undoErr != nil {
+		return false, fmt.Errorf("mongo count redemption: %w (delete record: %v)", err, undoErr)
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return false, fmt.Errorf("mongo count redemption: %w", err)
+}
+
+// UnredeemCoupon takes back one redemption of the coupon for the order, when
+// the checkout that redeemed it did not go through
+func (r *couponMongoRepo) UnredeemCoupon(ctx context.Context, code, userID, orderRef string) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	code = strings.ToUpper(code)
+	res, err := r.redemptions.DeleteOne(ctx, bson.M{"code": code, "user_id": userID, "order_ref": orderRef})
+	if err != nil {
+		return fmt.Errorf("mongo delete redemption: %w", err)
+	}
+	if res.DeletedCount == 0 {
+		return nil
+	}
+	_, err = r.usage.UpdateOne(ctx, bson.M{"_id": usageID(code, userID), "used": bson.M{"$gt": 0}}, bson.M{"$inc": bson.M{"used": -1}})
+	if err != nil {
+		return fmt.Errorf("mongo uncount redemption: %w", err)
+	}
+	return nil
+}
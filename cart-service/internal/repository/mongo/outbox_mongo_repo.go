@@ -0,0 +1,157 @@
+package mongorepo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/emart/cart-service/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	outboxStatusPending   = "PENDING"
+	outboxStatusPublished = "PUBLISHED"
+
+	// outboxLeaseID is the single lease document; its holder is the only relay
+	outboxLeaseID = "relay"
+)
+
+// OutboxMongoRepository is the transactional outbox for cart events.
+// Enqueue joins the caller's transaction when ctx carries a session, so an
+// event is stored if and only if the cart write it describes is.
+type OutboxMongoRepository interface {
+	Enqueue(ctx context.Context, events ...model.CartEvent) error
+	FetchPending(ctx context.Context, limit int) ([]model.CartEvent, error)
+	MarkPublished(ctx context.Context, eventIDs ...string) error
+	MarkFailed(ctx context.Context, eventID string, cause error) error
+	// AcquireLease makes owner the relay for ttl from now; the holder renews
+	// it by calling again. It reports false while another owner holds it.
+	AcquireLease(ctx context.Context, owner string, ttl time.Duration) (bool, error)
+	// ReleaseLease gives the lease up if owner still holds it
+	ReleaseLease(ctx context.Context, owner string) error
+}
+
+type outboxDocument struct {
+	ID          string          `bson:"_id"`
+	Event       model.CartEvent `bson:"event"`
+	Status      string          `bson:"status"`
+	Attempts    int             `bson:"attempts"`
+	LastError   string          `bson:"last_error,omitempty"`
+	CreatedAt   time.Time       `bson:"created_at"`
+	PublishedAt *time.Time      `bson:"published_at,omitempty"`
+}
+
+type outboxMongoRepo struct {
+	collection *mongo.Collection
+	leases     *mongo.Collection
+}
+
+func NewOutboxMongoRepository(db *mongo.Database) OutboxMongoRepository {
+	return &outboxMongoRepo{collection: db.Collection("cart_outbox"), leases: db.Collection("cart_outbox_lease")}
+}
+
+// Enqueue stores events as PENDING for the relay to pick up
+func (r *outboxMongoRepo) Enqueue(ctx context.Context, events ...model.CartEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	docs := make([]interface{}, 0, len(events))
+	for _, e := range events {
+		docs = append(docs, outboxDocument{ID: e.ID, Event: e, Status: outboxStatusPending, CreatedAt: now})
+	}
+	if _, err := r.collection.InsertMany(ctx, docs); err != nil {
+		return fmt.Errorf("mongo enqueue events: %w", err)
+	}
+	return nil
+}
+
+// FetchPending returns the oldest unpublished events in insertion order
+func (r *outboxMongoRepo) FetchPending(ctx context.Context, limit int) ([]model.CartEvent, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}).SetLimit(int64(limit))
+	cursor, err := r.collection.Find(ctx, bson.M{"status": outboxStatusPending}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("mongo fetch pending events: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var docs []outboxDocument
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("mongo decode pending events: %w", err)
+	}
+	events := make([]model.CartEvent, 0, len(docs))
+	for _, d := range docs {
+		events = append(events, d.Event)
+	}
+	return events, nil
+}
+
+// MarkPublished flags events as delivered; a TTL index removes them later
+func (r *outboxMongoRepo) MarkPublished(ctx context.Context, eventIDs ...string) error {
+	if len(eventIDs) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{"status": outboxStatusPublished, "published_at": time.Now()}}
+	if _, err := r.collection.UpdateMany(ctx, bson.M{"_id": bson.M{"$in": eventIDs}}, update); err != nil {
+		return fmt.Errorf("mongo mark events published: %w", err)
+	}
+	return nil
+}
+
+// MarkFailed records a failed delivery attempt; the event stays PENDING
+func (r *outboxMongoRepo) MarkFailed(ctx context.Context, eventID string, cause error) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	update := bson.M{"$inc": bson.M{"attempts": 1}, "$set": bson.M{"last_error": cause.Error()}}
+	if _, err := r.collection.UpdateByID(ctx, eventID, update); err != nil {
+		return fmt.Errorf("mongo mark event failed: %w", err)
+	}
+	return nil
+}
+
+// AcquireLease takes a free, expired or already owned lease. The upsert
+// collides with the existing document when someone else holds it.
+func (r *outboxMongoRepo) AcquireLease(ctx context.Context, owner string, ttl time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	filter := bson.M{"_id": outboxLeaseID, "$or": bson.A{
+		bson.M{"owner": owner},
+		bson.M{"owner": bson.M{"$exists": false}},
+		bson.M{"expires_at": bson.M{"$lt": now}},
+	}}
+	update := bson.M{"$set": bson.M{"owner": owner, "expires_at": now.Add(ttl)}}
+	_, err := r.leases.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("mongo acquire outbox lease: %w", err)
+	}
+	return true, nil
+}
+
+func (r *outboxMongoRepo) ReleaseLease(ctx context.Context, owner string) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	_, err := r.leases.UpdateOne(ctx, bson.M{"_id": outboxLeaseID, "owner": owner}, bson.M{"$unset": bson.M{"owner": ""}})
+	if err != nil {
+		return fmt.Errorf("mongo release outbox lease: %w", err)
+	}
+	return nil
+}
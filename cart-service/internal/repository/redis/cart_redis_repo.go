@@ -4,20 +4,48 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/emart/cart-service/internal/model"
 	"github.com/redis/go-redis/v9"
 )
 
-const cartKeyPrefix = "cart:"
+const (
+	cartKeyPrefix = "cart:"
+	// dirtySetKey holds user IDs whose cart changed since the last sync to MongoDB.
+	// Deliberately outside the cart: prefix so SCAN never mistakes it for a cart.
+	dirtySetKey = "cart-sync:dirty"
+)
+
+// saveCartScript is a compare-and-set: the cart is only written when the stored
+// copy is missing or carries an older version than the one being saved.
+//
+//	KEYS[1] = cart key, ARGV[1] = cart JSON, ARGV[2] = new version, ARGV[3] = TTL in ms
+var saveCartScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if current then
+  local ok, stored = pcall(cjson.decode, current)
+  if ok and type(stored) == 'table' and tonumber(stored.version) and tonumber(stored.version) >= tonumber(ARGV[2]) then
+    return 0
+  end
+end
+if tonumber(ARGV[3]) > 0 then
+  redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[3])
+else
+  redis.call('SET', KEYS[1], ARGV[1])
+end
+return 1
+`)
 
 // CartRedisRepository interface
 type CartRedisRepository interface {
 	GetCart(ctx context.Context, userID string) (*model.Cart, error)
 	SaveCart(ctx context.Context, cart *model.Cart, ttl time.Duration) error
 	DeleteCart(ctx context.Context, userID string) error
-	GetAllCartKeys(ctx context.Context) ([]string, error)
+	MarkDirty(ctx context.Context, userIDs ...string) error
+	PopDirty(ctx context.Context, count int) ([]string, error)
+	ScanCartUserIDs(ctx context.Context, cursor uint64, count int64) ([]string, uint64, error)
 	Ping(ctx context.Context) error
 }
 
@@ -45,24 +73,73 @@ func (r *cartRedisRepo) GetCart(ctx context.Context, userID string) (*model.Cart
 	if err := json.Unmarshal(data, &cart); err != nil {
 		return nil, fmt.Errorf("unmarshal cart: %w", err)
 	}
+	if err := model.Upcast(&cart); err != nil {
+		return nil, fmt.Errorf("upcast cart: %w", err)
+	}
 	cart.Source = "redis"
 	return &cart, nil
 }
 
+// SaveCart writes the cart if its version is newer than the stored copy.
+// Returns model.ErrVersionConflict when a newer (or equal) version is already cached.
 func (r *cartRedisRepo) SaveCart(ctx context.Context, cart *model.Cart, ttl time.Duration) error {
 	data, err := json.Marshal(cart)
 	if err != nil {
 		return fmt.Errorf("marshal cart: %w", err)
 	}
-	return r.client.Set(ctx, cartKey(cart.UserID), data, ttl).Err()
+	saved, err := saveCartScript.Run(ctx, r.client, []string{cartKey(cart.UserID)},
+		data, cart.Version, ttl.Milliseconds()).Int()
+	if err != nil {
+		return fmt.Errorf("redis save cart: %w", err)
+	}
+	if saved == 0 {
+		return model.ErrVersionConflict
+	}
+	return nil
 }
 
 func (r *cartRedisRepo) DeleteCart(ctx context.Context, userID string) error {
 	return r.client.Del(ctx, cartKey(userID)).Err()
 }
 
-func (r *cartRedisRepo) GetAllCartKeys(ctx context.Context) ([]string, error) {
-	return r.client.Keys(ctx, cartKeyPrefix+"*").Result()
+// MarkDirty records that the given users' carts need to be synced to MongoDB
+func (r *cartRedisRepo) MarkDirty(ctx context.Context, userIDs ...string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	members := make([]interface{}, len(userIDs))
+	for i, id := range userIDs {
+		members[i] = id
+	}
+	return r.client.SAdd(ctx, dirtySetKey, members...).Err()
+}
+
+// PopDirty removes and returns up to count user IDs from the dirty set
+func (r *cartRedisRepo) PopDirty(ctx context.Context, count int) ([]string, error) {
+	ids, err := r.client.SPopN(ctx, dirtySetKey, int64(count)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redis pop dirty carts: %w", err)
+	}
+	return ids, nil
+}
+
+// ScanCartUserIDs walks cart keys incrementally with SCAN (never blocks Redis like KEYS).
+// Pass cursor 0 to start; a returned cursor of 0 means the iteration is complete.
+func (r *cartRedisRepo) ScanCartUserIDs(ctx context.Context, cursor uint64, count int64) ([]string, uint64, error) {
+	keys, next, err := r.client.Scan(ctx, cursor, cartKeyPrefix+"*", count).Result()
+	if err != nil {
+		return nil, 0, fmt.Errorf("redis scan carts: %w", err)
+	}
+	userIDs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if userID := strings.TrimPrefix(key, cartKeyPrefix); userID != "" {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	return userIDs, next, nil
 }
 
 func (r *cartRedisRepo) Ping(ctx context.Context) error {
@@ -0,0 +1,116 @@
+package redisrepo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/emart/cart-service/internal/model"
+	"github.com/redis/go-redis/v9"
+)
+
+const idempotencyKeyPrefix = "idempotency:"
+
+// reserveScript claims a key or returns whoever holds it, in one step.
+//
+//	KEYS[1] = idempotency key, ARGV[1] = reservation JSON, ARGV[2] = TTL in ms
+//	Returns nil when reserved, otherwise the stored record
+var reserveScript = redis.NewScript(`
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+  return false
+end
+return redis.call('GET', KEYS[1])
+`)
+
+// completeScript stores the response only while the key still holds the
+// caller's reservation: once it expired, the key may belong to a retry.
+//
+//	KEYS[1] = idempotency key, ARGV[1] = reservation token, ARGV[2] = record JSON, ARGV[3] = TTL in ms
+//	Returns 1 when stored, 0 when the reservation was lost
+var completeScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if not current then
+  return 0
+end
+local ok, held = pcall(cjson.decode, current)
+if not ok or held.completed or held.token ~= ARGV[1] then
+  return 0
+end
+redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+return 1
+`)
+
+// releaseScript deletes the key only while it holds the caller's reservation.
+//
+//	KEYS[1] = idempotency key, ARGV[1] = reservation token
+var releaseScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if not current then
+  return 0
+end
+local ok, held = pcall(cjson.decode, current)
+if not ok or held.completed or held.token ~= ARGV[1] then
+  return 0
+end
+return redis.call('DEL', KEYS[1])
+`)
+
+// IdempotencyRedisRepository stores request outcomes by Idempotency-Key
+type IdempotencyRedisRepository interface {
+	// Reserve claims key for a request with the given fingerprint until lockTTL
+	// passes; token names the request and must be unique to it. When the key is
+	// already taken it returns the stored record instead.
+	Reserve(ctx context.Context, key, fingerprint, token string, lockTTL time.Duration) (*model.IdempotencyRecord, error)
+	// Complete replaces the token's reservation with the finished response. It
+	// reports false, storing nothing, when the reservation expired meanwhile.
+	Complete(ctx context.Context, key, token string, record *model.IdempotencyRecord, ttl time.Duration) (bool, error)
+	// Release drops the token's reservation so the request can be retried
+	Release(ctx context.Context, key, token string) error
+}
+
+type idempotencyRedisRepo struct {
+	client *redis.Client
+}
+
+func NewIdempotencyRedisRepository(client *redis.Client) IdempotencyRedisRepository {
+	return &idempotencyRedisRepo{client: client}
+}
+
+func (r *idempotencyRedisRepo) Reserve(ctx context.Context, key, fingerprint, token string, lockTTL time.Duration) (*model.IdempotencyRecord, error) {
+	reservation, err := json.Marshal(&model.IdempotencyRecord{Fingerprint: fingerprint, Token: token})
+	if err != nil {
+		return nil, fmt.Errorf("marshal idempotency reservation: %w", err)
+	}
+	data, err := reserveScript.Run(ctx, r.client, []string{idempotencyKeyPrefix + key}, reservation, lockTTL.Milliseconds()).Text()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redis reserve idempotency key: %w", err)
+	}
+	var record model.IdempotencyRecord
+	if err := json.Unmarshal([]byte(data), &record); err != nil {
+		return nil, fmt.Errorf("unmarshal idempotency record: %w", err)
+	}
+	return &record, nil
+}
+
+func (r *idempotencyRedisRepo) Complete(ctx context.Context, key, token string, record *model.IdempotencyRecord, ttl time.Duration) (bool, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return false, fmt.Errorf("marshal idempotency record: %w", err)
+	}
+	stored, err := completeScript.Run(ctx, r.client, []string{idempotencyKeyPrefix + key}, token, data, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, fmt.Errorf("redis save idempotency record: %w", err)
+	}
+	return stored == 1, nil
+}
+
+func (r *idempotencyRedisRepo) Release(ctx context.Context, key, token string) error {
+	if err := releaseScript.Run(ctx, r.client, []string{idempotencyKeyPrefix + key}, token).Err(); err != nil {
+		return fmt.Errorf("redis release idempotency key: %w", err)
+	}
+	return nil
+}
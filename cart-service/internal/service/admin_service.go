@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/emart/cart-service/internal/config"
+	"github.com/emart/cart-service/internal/logging"
+	"github.com/emart/cart-service/internal/model"
+	mongorepo "github.com/emart/cart-service/internal/repository/mongo"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultAdminPageSize = 50
+	maxAdminPageSize     = 200
+	adminAuditLogLimit   = 100
+)
+
+// AdminService lets support staff inspect and repair any customer's cart.
+// Every call is recorded in the audit log with the acting staff member.
+type AdminService interface {
+	GetCart(ctx context.Context, actor model.Actor, userID string) (*model.Cart, error)
+	ListCarts(ctx context.Context, actor model.Actor, filter model.AdminCartFilter) (*model.AdminCartPage, error)
+	UpdateItemQuantity(ctx context.Context, actor model.Actor, userID string, itemID string, quantity int) (*model.Cart, error)
+	RemoveItem(ctx context.Context, actor model.Actor, userID string, itemID string) (*model.Cart, error)
+	ClearCart(ctx context.Context, actor model.Actor, userID string) error
+	AuditLog(ctx context.Context, actor model.Actor, userID string) ([]*model.AuditEntry, error)
+}
+
+type adminService struct {
+	carts     CartService
+	adminRepo mongorepo.AdminCartMongoRepository
+	auditRepo mongorepo.AuditLogMongoRepository
+	cfg       *config.Config
+	logger    *zap.Logger
+}
+
+// NewAdminService changes carts through CartService, so admin edits get the same
+// version checks, checkout locks and events as the shopper's own.
+func NewAdminService(
+	carts CartService,
+	adminRepo mongorepo.AdminCartMongoRepository,
+	auditRepo mongorepo.AuditLogMongoRepository,
+	cfg *config.Config,
+	logger *zap.Logger,
+) AdminService {
+	return &adminService{
+		carts:     carts,
+		adminRepo: adminRepo,
+		auditRepo: auditRepo,
+		cfg:       cfg,
+		logger:    logger,
+	}
+}
+
+func (s *adminService) GetCart(ctx context.Context, actor model.Actor, userID string) (*model.Cart, error) {
+	cart, err := s.carts.GetCart(ctx, userID)
+	s.audit(ctx, actor, model.AuditViewCart, userID, nil, err)
+	return cart, err
+}
+
+// ListCarts pages through carts in MongoDB, most recently updated first
+func (s *adminService) ListCarts(ctx context.Context, actor model.Actor, filter model.AdminCartFilter) (*model.AdminCartPage, error) {
+	switch filter.Status {
+	case model.AdminCartsAll, model.AdminCartsActive, model.AdminCartsAbandoned:
+	default:
+		return nil, fmt.Errorf("%w: unknown status %q", model.ErrInvalidAdminFilter, filter.Status)
+	}
+	if filter.Offset < 0 {
+		return nil, fmt.Errorf("%w: negative offset", model.ErrInvalidAdminFilter)
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAdminPageSize
+	}
+	if filter.Limit > maxAdminPageSize {
+		filter.Limit = maxAdminPageSize
+	}
+
+	carts, total, err := s.adminRepo.ListCarts(ctx, filter)
+	details := map[string]interface{}{"status": string(filter.Status), "limit": filter.Limit, "offset": filter.Offset}
+	if filter.UpdatedAfter != nil {
+		details["updated_after"] = *filter.UpdatedAfter
+	}
+	if filter.UpdatedBefore != nil {
+		details["updated_before"] = *filter.UpdatedBefore
+	}
+	s.audit(ctx, actor, model.AuditListCarts, "", details, err)
+	if err != nil {
+		return nil, err
+	}
+	return &model.AdminCartPage{Carts: carts, Total: total, Limit: filter.Limit, Offset: filter.Offset}, nil
+}
+
+func (s *adminService) UpdateItemQuantity(ctx context.Context, actor model.Actor, userID string, itemID string, quantity int) (*model.Cart, error) {
+	cart, err := s.carts.UpdateItemQuantity(ctx, userID, itemID, quantity)
+	details := map[string]interface{}{"item_id": itemID, "quantity": quantity}
+	if cart != nil {
+		details["version"] = cart.Version
+	}
+	s.audit(ctx, actor, model.AuditUpdateItem, userID, details, err)
+	return cart, err
+}
+
+func (s *adminService) RemoveItem(ctx context.Context, actor model.Actor, userID string, itemID string) (*model.Cart, error) {
+	cart, err := s.carts.RemoveItem(ctx, userID, itemID)
+	details := map[string]interface{}{"item_id": itemID}
+	if cart != nil {
+		details["version"] = cart.Version
+	}
+	s.audit(ctx, actor, model.AuditRemoveItem, userID, details, err)
+	return cart, err
+}
+
+func (s *adminService) ClearCart(ctx context.Context, actor model.Actor, userID string) error {
+	err := s.carts.ClearCart(ctx, userID)
+	s.audit(ctx, actor, model.AuditClearCart, userID, nil, err)
+	return err
+}
+
+// AuditLog returns the latest admin actions on a customer's cart
+func (s *adminService) AuditLog(ctx context.Context, actor model.Actor, userID string) ([]*model.AuditEntry, error) {
+	entries, err := s.auditRepo.ListForUser(ctx, userID, adminAuditLogLimit)
+	s.audit(ctx, actor, model.AuditViewHistory, userID, nil, err)
+	return entries, err
+}
+
+// audit records an admin action and its outcome. The action has already
+// happened, so a failed write is logged with the full entry rather than
+// failing the request.
+func (s *adminService) audit(ctx context.Context, actor model.Actor, action, targetUserID string, details map[string]interface{}, actionErr error) {
+	entry := &model.AuditEntry{
+		ID:           uuid.New().String(),
+		Actor:        actor,
+		Action:       action,
+		TargetUserID: targetUserID,
+		Details:      details,
+		At:           time.Now(),
+	}
+	if actionErr != nil {
+		entry.Error = actionErr.Error()
+	}
+	if err := s.auditRepo.Record(ctx, entry); err != nil {
+		s.log(ctx).Error("Failed to write admin audit entry",
+			zap.String("actor", actor.UserID), zap.String("action", action),
+			zap.String("targetUserID", targetUserID), zap.Any("details", details),
+			zap.String("actionError", entry.Error), zap.Error(err))
+	}
+}
+
+// log returns the request-scoped logger from ctx, or the service logger
+func (s *adminService) log(ctx context.Context) *zap.Logger {
+	return logging.FromContext(ctx, s.logger)
+}
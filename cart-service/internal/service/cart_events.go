@@ -0,0 +1,60 @@
+package service
+
+import (
+	"github.com/emart/cart-service/internal/model"
+	"github.com/google/uuid"
+)
+
+// itemEvents derives ItemAdded / QuantityChanged / ItemRemoved events by
+// comparing the cart lines before and after a mutation, matched on ItemID.
+func itemEvents(before []model.CartItem, cart *model.Cart) []model.CartEvent {
+	previous := make(map[string]model.CartItem, len(before))
+	for _, item := range before {
+		previous[item.ItemID] = item
+	}
+
+	var events []model.CartEvent
+	for _, item := range cart.Items {
+		item := item
+		old, existed := previous[item.ItemID]
+		delete(previous, item.ItemID)
+		switch {
+		case !existed:
+			e := newCartEvent(model.EventItemAdded, cart)
+			e.Item = &item
+			events = append(events, e)
+		case old.Quantity != item.Quantity:
+			e := newCartEvent(model.EventQuantityChanged, cart)
+			e.Item = &item
+			e.PreviousQuantity = old.Quantity
+			events = append(events, e)
+		}
+	}
+
+	// Whatever is left was removed; keep the original line order
+	for _, item := range before {
+		if _, removed := previous[item.ItemID]; !removed {
+			continue
+		}
+		item := item
+		e := newCartEvent(model.EventItemRemoved, cart)
+		e.Item = &item
+		e.PreviousQuantity = item.Quantity
+		events = append(events, e)
+	}
+	return events
+}
+
+// newCartEvent stamps an event with the cart's identity and totals after the change
+func newCartEvent(eventType model.CartEventType, cart *model.Cart) model.CartEvent {
+	return model.CartEvent{
+		ID:          uuid.New().String(),
+		Type:        eventType,
+		UserID:      cart.UserID,
+		CartVersion: cart.Version,
+		TotalItems:  cart.TotalItems,
+		GrandTotal:  cart.GrandTotal,
+		Currency:    cart.Currency,
+		OccurredAt:  cart.UpdatedAt,
+	}
+}
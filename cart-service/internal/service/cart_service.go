@@ -2,10 +2,18 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/emart/cart-service/internal/catalog"
+	"github.com/emart/cart-service/internal/config"
+	"github.com/emart/cart-service/internal/events"
+	"github.com/emart/cart-service/internal/logging"
 	"github.com/emart/cart-service/internal/model"
+	"github.com/emart/cart-service/internal/promotion"
+	"github.com/emart/cart-service/internal/realtime"
 	mongorepo "github.com/emart/cart-service/internal/repository/mongo"
 	redisrepo "github.com/emart/cart-service/internal/repository/redis"
 	"github.com/google/uuid"
@@ -20,26 +28,88 @@ type CartService interface {
 	UpdateItemQuantity(ctx context.Context, userID string, itemID string, quantity int) (*model.Cart, error)
 	RemoveItem(ctx context.Context, userID string, itemID string) (*model.Cart, error)
 	ClearCart(ctx context.Context, userID string) error
+	MergeCart(ctx context.Context, userID string, guestCartID string, strategy model.MergeStrategy) (*model.Cart, error)
+	ApplyCoupon(ctx context.Context, userID string, code string) (*model.Cart, error)
+	RemoveCoupon(ctx context.Context, userID string, code string) (*model.Cart, error)
+	CheckoutCart(ctx context.Context, userID string, orderID string) error
+	CreateCheckoutSession(ctx context.Context, userID string) (*model.CheckoutSession, error)
+	GetCheckoutSession(ctx context.Context, userID string, sessionID string) (*model.CheckoutSession, error)
+	CompleteCheckoutSession(ctx context.Context, userID string, sessionID string, orderID string) (*model.CheckoutSession, error)
+	CancelCheckoutSession(ctx context.Context, userID string, sessionID string) (*model.CheckoutSession, error)
 }
 
+type ifMatchKey struct{}
+
+type shopperEmailKey struct{}
+
+// WithShopperEmail tells cart saves under ctx the logged-in shopper's email,
+// which is kept on the stored cart for the abandoned cart reminder
+func WithShopperEmail(ctx context.Context, email string) context.Context {
+	if email == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, shopperEmailKey{}, email)
+}
+
+// WithIfMatch makes cart mutations under ctx conditional on the cart still
+// having one of the given ETags (the client's If-Match). They fail with
+// model.ErrPreconditionFailed otherwise.
+func WithIfMatch(ctx context.Context, etags []string) context.Context {
+	return context.WithValue(ctx, ifMatchKey{}, etags)
+}
+
+// ifMatchHolds reports whether cart satisfies the If-Match carried by ctx, if any
+func ifMatchHolds(ctx context.Context, cart *model.Cart) bool {
+	etags, ok := ctx.Value(ifMatchKey{}).([]string)
+	if !ok {
+		return true
+	}
+	current := cart.ETag()
+	for _, etag := range etags {
+		if etag == current {
+			return true
+		}
+	}
+	return false
+}
+
+// maxSaveAttempts bounds how often a mutation is re-applied on a fresh copy
+// of the cart when a concurrent writer wins the race.
+const maxSaveAttempts = 3
+
 type cartService struct {
-	redisRepo redisrepo.CartRedisRepository
-	mongoRepo mongorepo.CartMongoRepository
-	redisTTL  time.Duration
-	logger    *zap.Logger
+	redisRepo  redisrepo.CartRedisRepository
+	mongoRepo  mongorepo.CartMongoRepository
+	sessions   mongorepo.CheckoutSessionMongoRepository
+	catalog    catalog.Client
+	promotions *promotion.Engine
+	publisher  events.EventPublisher
+	updates    realtime.Publisher
+	cfg        *config.Config
+	logger     *zap.Logger
 }
 
 func NewCartService(
 	redisRepo redisrepo.CartRedisRepository,
 	mongoRepo mongorepo.CartMongoRepository,
-	redisTTL time.Duration,
+	sessions mongorepo.CheckoutSessionMongoRepository,
+	catalogClient catalog.Client,
+	promotions *promotion.Engine,
+	publisher events.EventPublisher,
+	updates realtime.Publisher,
+	cfg *config.Config,
 	logger *zap.Logger,
 ) CartService {
 	return &cartService{
-		redisRepo: redisRepo,
-		mongoRepo: mongoRepo,
-		redisTTL:  redisTTL,
-		logger:    logger,
+		redisRepo:  redisRepo,
+		mongoRepo:  mongoRepo,
+		sessions:   sessions,
+		catalog:    catalogClient,
+		promotions: promotions,
+		publisher:  publisher,
+		updates:    updates,
+		cfg:        cfg,
+		logger:     logger,
 	}
 }
 
@@ -48,10 +118,10 @@ func (s *cartService) GetCart(ctx context.Context, userID string) (*model.Cart,
 	// Try Redis first (fast path)
 	cart, err := s.redisRepo.GetCart(ctx, userID)
 	if err != nil {
-		s.logger.Warn("Redis get failed, falling back to MongoDB", zap.String("userID", userID), zap.Error(err))
+		s.log(ctx).Warn("Redis get failed, falling back to MongoDB", zap.String("userID", userID), zap.Error(err))
 	}
 	if cart != nil {
-		return cart, nil
+		return s.priced(ctx, cart), nil
 	}
 
 	// Fallback to MongoDB
@@ -62,8 +132,8 @@ func (s *cartService) GetCart(ctx context.Context, userID string) (*model.Cart,
 
 	// Warm Redis cache from MongoDB
 	if cart != nil {
-		if saveErr := s.redisRepo.SaveCart(ctx, cart, s.redisTTL); saveErr != nil {
-			s.logger.Warn("Failed to warm Redis cache", zap.Error(saveErr))
+		if saveErr := s.redisRepo.SaveCart(ctx, cart, s.ttlFor(userID)); saveErr != nil {
+			s.log(ctx).Warn("Failed to warm Redis cache", zap.Error(saveErr))
 		}
 	}
 
@@ -72,7 +142,7 @@ func (s *cartService) GetCart(ctx context.Context, userID string) (*model.Cart,
 		cart = s.newEmptyCart(userID)
 	}
 
-	return cart, nil
+	return s.priced(ctx, cart), nil
 }
 
 // GetCartSummary returns lightweight cart info for header display
@@ -81,72 +151,87 @@ func (s *cartService) GetCartSummary(ctx context.Context, userID string) (*model
 	if err != nil {
 		return nil, err
 	}
-	return &model.CartSummary{
-		UserID:     userID,
-		TotalItems: cart.TotalItems,
-		TotalPrice: cart.TotalPrice,
-	}, nil
+	return cart.Summary(), nil
 }
 
-// AddItem adds a product to the cart
+// AddItem adds a product to the cart.
+// Name, price and image come from the catalog, never from the request.
 func (s *cartService) AddItem(ctx context.Context, userID string, req *model.AddItemRequest) (*model.Cart, error) {
-	cart, err := s.GetCart(ctx, userID)
+	product, err := s.catalog.GetProduct(ctx, req.Category, req.ProductID)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("resolve product %s/%s: %w", req.Category, req.ProductID, err)
+	}
+	if !product.Active {
+		return nil, fmt.Errorf("resolve product %s/%s: %w", req.Category, req.ProductID, catalog.ErrProductInactive)
 	}
 
-	// Check if product already in cart - increase quantity
-	for i, item := range cart.Items {
-		if item.ProductID == req.ProductID {
-			cart.Items[i].Quantity += req.Quantity
-			cart.Items[i].AddedAt = time.Now()
-			return s.saveCart(ctx, cart)
+	return s.mutateCart(ctx, userID, func(cart *model.Cart) error {
+		// Check if product already in cart - increase quantity
+		for i, item := range cart.Items {
+			if item.ProductID == req.ProductID {
+				if err := inStock(product, item.Quantity+req.Quantity); err != nil {
+					return err
+				}
+				cart.Items[i].Quantity += req.Quantity
+				cart.Items[i].ProductName = product.Name
+				cart.Items[i].Price = product.Price
+				cart.Items[i].ImageURL = product.ImageURL
+				cart.Items[i].AddedAt = time.Now()
+				return nil
+			}
 		}
-	}
 
-	// Add new item
-	newItem := model.CartItem{
-		ItemID:      uuid.New().String(),
-		ProductID:   req.ProductID,
-		ProductName: req.ProductName,
-		Category:    req.Category,
-		Price:       req.Price,
-		Quantity:    req.Quantity,
-		ImageURL:    req.ImageURL,
-		AddedAt:     time.Now(),
-	}
-	cart.Items = append(cart.Items, newItem)
-	return s.saveCart(ctx, cart)
+		if err := inStock(product, req.Quantity); err != nil {
+			return err
+		}
+
+		// Add new item
+		newItem := model.CartItem{
+			ItemID:      uuid.New().String(),
+			ProductID:   req.ProductID,
+			ProductName: product.Name,
+			Category:    req.Category,
+			Price:       product.Price,
+			Quantity:    req.Quantity,
+			ImageURL:    product.ImageURL,
+			AddedAt:     time.Now(),
+		}
+		cart.Items = append(cart.Items, newItem)
+		return nil
+	})
 }
 
-// UpdateItemQuantity changes quantity of a specific item (0 = remove)
+// UpdateItemQuantity changes quantity of a specific item (0 = remove).
+// Raising it checks the catalog's stock again.
 func (s *cartService) UpdateItemQuantity(ctx context.Context, userID string, itemID string, quantity int) (*model.Cart, error) {
-	cart, err := s.GetCart(ctx, userID)
-	if err != nil {
-		return nil, err
-	}
-
-	found := false
-	newItems := make([]model.CartItem, 0)
-	for _, item := range cart.Items {
-		if item.ItemID == itemID {
-			found = true
-			if quantity > 0 {
-				item.Quantity = quantity
+	return s.mutateCart(ctx, userID, func(cart *model.Cart) error {
+		found := false
+		newItems := make([]model.CartItem, 0)
+		for _, item := range cart.Items {
+			if item.ItemID == itemID {
+				found = true
+				if quantity > item.Quantity {
+					if err := s.checkStock(ctx, item, quantity); err != nil {
+						return err
+					}
+				}
+				if quantity > 0 {
+					item.Quantity = quantity
+					newItems = append(newItems, item)
+				}
+				// quantity == 0 means remove (don't append)
+			} else {
 				newItems = append(newItems, item)
 			}
-			// quantity == 0 means remove (don't append)
-		} else {
-			newItems = append(newItems, item)
 		}
-	}
 
-	if !found {
-		return nil, fmt.Errorf("item %s not found in cart", itemID)
-	}
+		if !found {
+			return fmt.Errorf("item %s not found in cart", itemID)
+		}
 
-	cart.Items = newItems
-	return s.saveCart(ctx, cart)
+		cart.Items = newItems
+		return nil
+	})
 }
 
 // RemoveItem removes a specific item from the cart
@@ -156,56 +241,410 @@ func (s *cartService) RemoveItem(ctx context.Context, userID string, itemID stri
 
 // ClearCart empties the entire cart
 func (s *cartService) ClearCart(ctx context.Context, userID string) error {
-	if err := s.redisRepo.DeleteCart(ctx, userID); err != nil {
-		s.logger.Warn("Failed to delete cart from Redis", zap.Error(err))
+	cart, err := s.GetCart(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("clear cart: %w", err)
+	}
+	if cart.IsLocked(time.Now()) {
+		return model.ErrCartLocked
+	}
+
+	cleared := newCartEvent(model.EventCartCleared, s.newEmptyCart(userID))
+	if err := s.deleteCart(ctx, userID, cleared); err != nil {
+		return fmt.Errorf("clear cart: %w", err)
 	}
-	if err := s.mongoRepo.DeleteCart(ctx, userID); err != nil {
-		return fmt.Errorf("clear cart from mongo: %w", err)
+	return nil
+}
+
+// CheckoutCart closes the cart once payment-service has turned it into an order:
+// applied coupons are redeemed, the cart is deleted and CartCheckedOut is published.
+// A coupon whose usage limit was reached meanwhile fails it with
+// promotion.ErrUsageLimitReached and leaves the cart as it is.
+func (s *cartService) CheckoutCart(ctx context.Context, userID string, orderID string) error {
+	cart, err := s.GetCart(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if len(cart.Items) == 0 {
+		return model.ErrCartEmpty
+	}
+	now := time.Now()
+	if cart.IsLocked(now) {
+		// The cart is being paid for; payment-service completes the session instead
+		return model.ErrCartLocked
+	}
+
+	if err := s.promotions.RecordRedemptions(ctx, cart, orderID, now); err != nil {
+		return err
+	}
+
+	checkedOut := newCartEvent(model.EventCartCheckedOut, cart)
+	checkedOut.OrderID = orderID
+	checkedOut.OccurredAt = now
+	if err := s.deleteCart(ctx, userID, checkedOut); err != nil {
+		return fmt.Errorf("checkout cart: %w", err)
 	}
 	return nil
 }
 
+// MergeCart folds a guest cart into the user's cart after login and deletes the guest cart.
+// Products present in both carts are resolved by strategy (sum or newest) and capped at
+// the configured maximum quantity per line. The user cart records which guest cart it
+// merged, so when deleting the guest cart fails, merging it again changes nothing.
+func (s *cartService) MergeCart(ctx context.Context, userID string, guestCartID string, strategy model.MergeStrategy) (*model.Cart, error) {
+	if !model.IsGuestCart(guestCartID) || model.IsGuestCart(userID) {
+		return nil, fmt.Errorf("merge requires a guest cart and a user cart")
+	}
+	if strategy == "" {
+		strategy = model.MergeStrategy(s.cfg.Guest.MergeStrategy)
+	}
+
+	guestCart, err := s.GetCart(ctx, guestCartID)
+	if err != nil {
+		return nil, fmt.Errorf("get guest cart: %w", err)
+	}
+	if len(guestCart.Items) == 0 {
+		return s.GetCart(ctx, userID)
+	}
+
+	mark := guestCartID + " " + guestCart.ETag()
+	cart, err := s.mutateCart(ctx, userID, func(cart *model.Cart) error {
+		if cart.MergedGuest == mark {
+			return nil
+		}
+		had := make(map[string]int, len(cart.Items))
+		for _, item := range cart.Items {
+			had[item.ProductID] = item.Quantity
+		}
+		merged := mergeItems(cart.Items, guestCart.Items, strategy, s.cfg.Guest.MaxItemQuantity)
+		// Guest stock was checked when the items were added; what the merge
+		// adds on top is checked now
+		for _, item := range merged {
+			if item.Quantity > had[item.ProductID] {
+				if err := s.checkStock(ctx, item, item.Quantity); err != nil {
+					return err
+				}
+			}
+		}
+		cart.Items = merged
+		cart.MergedGuest = mark
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.ClearCart(ctx, guestCartID); err != nil {
+		// The user cart already holds the items; a leftover guest cart just expires
+		s.log(ctx).Warn("Failed to delete merged guest cart", zap.String("guestCartID", guestCartID), zap.Error(err))
+	}
+	return cart, nil
+}
+
+// ApplyCoupon validates a coupon code against the cart and adds it.
+// Codes are stored upper-cased; applying the same code twice is a no-op.
+func (s *cartService) ApplyCoupon(ctx context.Context, userID string, code string) (*model.Cart, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	return s.mutateCart(ctx, userID, func(cart *model.Cart) error {
+		for _, applied := range cart.Coupons {
+			if applied == code {
+				return nil
+			}
+		}
+		if _, err := s.promotions.Validate(ctx, userID, code, cart.Coupons, cart.Items, time.Now()); err != nil {
+			return err
+		}
+		cart.Coupons = append(cart.Coupons, code)
+		return nil
+	})
+}
+
+// RemoveCoupon takes a coupon code off the cart
+func (s *cartService) RemoveCoupon(ctx context.Context, userID string, code string) (*model.Cart, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	return s.mutateCart(ctx, userID, func(cart *model.Cart) error {
+		coupons := make([]string, 0, len(cart.Coupons))
+		for _, applied := range cart.Coupons {
+			if applied != code {
+				coupons = append(coupons, applied)
+			}
+		}
+		if len(coupons) == len(cart.Coupons) {
+			return fmt.Errorf("coupon %s not applied to cart", code)
+		}
+		cart.Coupons = coupons
+		return nil
+	})
+}
+
 // ============================================================
 // Private helpers
 // ============================================================
 
-func (s *cartService) saveCart(ctx context.Context, cart *model.Cart) (*model.Cart, error) {
+// checkStock resolves the item's product again and checks that quantity of it
+// is available
+func (s *cartService) checkStock(ctx context.Context, item model.CartItem, quantity int) error {
+	product, err := s.catalog.GetProduct(ctx, item.Category, item.ProductID)
+	if err != nil {
+		return fmt.Errorf("resolve product %s/%s: %w", item.Category, item.ProductID, err)
+	}
+	if !product.Active {
+		return fmt.Errorf("resolve product %s/%s: %w", item.Category, item.ProductID, catalog.ErrProductInactive)
+	}
+	return inStock(product, quantity)
+}
+
+func inStock(product *catalog.Product, quantity int) error {
+	if quantity > product.Stock {
+		return fmt.Errorf("%w: %d of %s available", model.ErrInsufficientStock, product.Stock, product.Name)
+	}
+	return nil
+}
+
+// mergeItems combines guest lines into user lines, matching on ProductID
+func mergeItems(userItems, guestItems []model.CartItem, strategy model.MergeStrategy, maxQuantity int) []model.CartItem {
+	merged := make([]model.CartItem, len(userItems))
+	copy(merged, userItems)
+
+	for _, guestItem := range guestItems {
+		matched := false
+		for i := range merged {
+			if merged[i].ProductID != guestItem.ProductID {
+				continue
+			}
+			matched = true
+			switch strategy {
+			case model.MergeKeepNewest:
+				if guestItem.AddedAt.After(merged[i].AddedAt) {
+					guestItem.ItemID = merged[i].ItemID
+					merged[i] = guestItem
+				}
+			default:
+				merged[i].Quantity += guestItem.Quantity
+			}
+			break
+		}
+		if !matched {
+			merged = append(merged, guestItem)
+		}
+	}
+
+	if maxQuantity > 0 {
+		for i := range merged {
+			if merged[i].Quantity > maxQuantity {
+				merged[i].Quantity = maxQuantity
+			}
+		}
+	}
+	return merged
+}
+
+// ttlFor returns the Redis TTL for a cart; guest carts live shorter
+func (s *cartService) ttlFor(userID string) time.Duration {
+	if model.IsGuestCart(userID) {
+		return s.cfg.Guest.TTL
+	}
+	return s.cfg.Redis.TTL
+}
+
+// mutateCart applies a shopper's change to the cart. Carts held by an active
+// checkout session are frozen and return model.ErrCartLocked; a lapsed lock is
+// dropped by the first mutation after it.
+func (s *cartService) mutateCart(ctx context.Context, userID string, mutate func(cart *model.Cart) error) (*model.Cart, error) {
+	return s.applyMutation(ctx, userID, func(cart *model.Cart) error {
+		if cart.IsLocked(time.Now()) {
+			return model.ErrCartLocked
+		}
+		cart.CheckoutSessionID = ""
+		cart.LockedUntil = nil
+		return mutate(cart)
+	})
+}
+
+// applyMutation runs a read-modify-write cycle on the user's cart. When another
+// writer bumps the version first, the mutation is re-applied to a fresh copy
+// up to maxSaveAttempts times before model.ErrVersionConflict is returned.
+// An If-Match precondition is checked on every read, so a writer that wins
+// the race turns into model.ErrPreconditionFailed rather than a retry.
+func (s *cartService) applyMutation(ctx context.Context, userID string, mutate func(cart *model.Cart) error) (*model.Cart, error) {
+	var err error
+	for attempt := 1; attempt <= maxSaveAttempts; attempt++ {
+		var cart *model.Cart
+		cart, err = s.GetCart(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !ifMatchHolds(ctx, cart) {
+			return nil, model.ErrPreconditionFailed
+		}
+		before := append([]model.CartItem(nil), cart.Items...)
+		if err = mutate(cart); err != nil {
+			return nil, err
+		}
+
+		cart, err = s.saveCart(ctx, cart, before)
+		if !errors.Is(err, model.ErrVersionConflict) {
+			return cart, err
+		}
+		s.log(ctx).Info("Cart version conflict, retrying",
+			zap.String("userID", userID), zap.Int("attempt", attempt))
+	}
+	return nil, fmt.Errorf("save cart after %d attempts: %w", maxSaveAttempts, err)
+}
+
+// saveCart persists the cart and publishes the item events derived from
+// comparing it with the lines it had before the mutation.
+func (s *cartService) saveCart(ctx context.Context, cart *model.Cart, before []model.CartItem) (*model.Cart, error) {
+	if cart.SchemaVersion > model.CurrentSchemaVersion {
+		// Upcast leaves these alone; stamping our version would downgrade them
+		return nil, fmt.Errorf("%w: v%d", model.ErrSchemaTooNew, cart.SchemaVersion)
+	}
 	cart.UpdatedAt = time.Now()
+	cart.Version++
+	cart.SchemaVersion = model.CurrentSchemaVersion
+	cart.Currency = model.DefaultCurrency
+	if model.IsGuestCart(cart.UserID) {
+		// Lets the MongoDB TTL index reap abandoned guest carts
+		expiresAt := cart.UpdatedAt.Add(s.cfg.Guest.TTL)
+		cart.ExpiresAt = &expiresAt
+	} else if email, ok := ctx.Value(shopperEmailKey{}).(string); ok {
+		cart.Email = email
+	}
 	cart.TotalItems, cart.TotalPrice = s.recalculate(cart.Items)
+	if err := s.applyDiscounts(ctx, cart); err != nil {
+		return nil, err
+	}
 
 	// Always write to Redis (primary store)
-	if err := s.redisRepo.SaveCart(ctx, cart, s.redisTTL); err != nil {
-		s.logger.Error("Failed to save cart to Redis", zap.Error(err))
+	if err := s.redisRepo.SaveCart(ctx, cart, s.ttlFor(cart.UserID)); err != nil {
+		if errors.Is(err, model.ErrVersionConflict) {
+			return nil, err
+		}
+		s.log(ctx).Error("Failed to save cart to Redis", zap.Error(err))
 		// Don't fail - write to Mongo as safety net
+	} else if err := s.redisRepo.MarkDirty(ctx, cart.UserID); err != nil {
+		// The periodic full reconciliation will still pick the cart up
+		s.log(ctx).Warn("Failed to mark cart dirty", zap.String("userID", cart.UserID), zap.Error(err))
 	}
 
 	// Write-through to MongoDB for durability on every mutation
-	if err := s.mongoRepo.UpsertCart(ctx, cart); err != nil {
-		s.logger.Error("Failed to upsert cart to MongoDB", zap.Error(err))
+	err := s.writeWithEvents(ctx, func(ctx context.Context) error {
+		return s.mongoRepo.UpsertCart(ctx, cart)
+	}, itemEvents(before, cart)...)
+	if err != nil {
+		if errors.Is(err, model.ErrVersionConflict) {
+			// Redis accepted a version MongoDB rejected: drop the cached copy
+			// so the retry re-reads the authoritative cart from MongoDB.
+			if delErr := s.redisRepo.DeleteCart(ctx, cart.UserID); delErr != nil {
+				s.log(ctx).Warn("Failed to evict conflicting cart from Redis", zap.Error(delErr))
+			}
+			return nil, err
+		}
+		s.log(ctx).Error("Failed to upsert cart to MongoDB", zap.Error(err))
 		return nil, err
 	}
 
+	s.publishUpdate(ctx, cart)
 	return cart, nil
 }
 
-func (s *cartService) recalculate(items []model.CartItem) (totalItems int, totalPrice float64) {
+// deleteCart removes the cart from both stores, publishes the closing event and
+// shows open streams the now empty cart
+func (s *cartService) deleteCart(ctx context.Context, userID string, closing model.CartEvent) error {
+	if err := s.redisRepo.DeleteCart(ctx, userID); err != nil {
+		s.log(ctx).Warn("Failed to delete cart from Redis", zap.Error(err))
+	}
+	err := s.writeWithEvents(ctx, func(ctx context.Context) error {
+		return s.mongoRepo.DeleteCart(ctx, userID)
+	}, closing)
+	if err != nil {
+		return err
+	}
+	s.publishUpdate(ctx, s.priced(ctx, s.newEmptyCart(userID)))
+	return nil
+}
+
+// writeWithEvents runs write and records its events. The outbox commits them
+// in the same transaction so it never misses a change; a direct publisher is a
+// Redis round trip that must not hold the transaction open, so it runs once the
+// write has committed and its failures are only logged.
+func (s *cartService) writeWithEvents(ctx context.Context, write func(ctx context.Context) error, evts ...model.CartEvent) error {
+	transactional := events.IsTransactional(s.publisher)
+	err := s.mongoRepo.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := write(ctx); err != nil {
+			return err
+		}
+		if !transactional {
+			return nil
+		}
+		return s.publisher.Publish(ctx, evts...)
+	})
+	if err != nil || transactional || len(evts) == 0 {
+		return err
+	}
+	if err := s.publisher.Publish(ctx, evts...); err != nil {
+		s.log(ctx).Warn("Failed to publish cart events", zap.Int("events", len(evts)), zap.Error(err))
+	}
+	return nil
+}
+
+// publishUpdate pushes the committed cart to the shopper's open streams. A
+// lost update only leaves a stream behind until the next change, so failures
+// are logged and the mutation still succeeds.
+func (s *cartService) publishUpdate(ctx context.Context, cart *model.Cart) {
+	if err := s.updates.PublishCart(ctx, cart); err != nil {
+		s.log(ctx).Warn("Failed to publish cart update", zap.String("userID", cart.UserID), zap.Error(err))
+	}
+}
+
+// applyDiscounts fills in the discount breakdown and grand total from cart.TotalPrice
+func (s *cartService) applyDiscounts(ctx context.Context, cart *model.Cart) error {
+	if len(cart.Coupons) == 0 {
+		cart.Discounts = nil
+		cart.DiscountTotal = model.MoneyFromMinor(0, model.DefaultCurrency)
+		cart.GrandTotal = cart.TotalPrice
+		return nil
+	}
+	if err := s.promotions.Price(ctx, cart, time.Now()); err != nil {
+		return fmt.Errorf("price coupons: %w", err)
+	}
+	return nil
+}
+
+// priced re-evaluates coupons on read so expiry shows up without a mutation.
+// If coupons cannot be loaded the stored breakdown is returned as-is.
+func (s *cartService) priced(ctx context.Context, cart *model.Cart) *model.Cart {
+	if err := s.applyDiscounts(ctx, cart); err != nil {
+		s.log(ctx).Warn("Failed to reprice cart coupons", zap.String("userID", cart.UserID), zap.Error(err))
+	}
+	return cart
+}
+
+func (s *cartService) recalculate(items []model.CartItem) (totalItems int, totalPrice model.Money) {
+	totalPrice = model.MoneyFromMinor(0, model.DefaultCurrency)
 	for _, item := range items {
 		totalItems += item.Quantity
-		totalPrice += item.Price * float64(item.Quantity)
+		totalPrice = totalPrice.Add(item.Price.Mul(item.Quantity))
 	}
 	return
 }
 
+// log returns the request-scoped logger from ctx, or the service logger
+func (s *cartService) log(ctx context.Context) *zap.Logger {
+	return logging.FromContext(ctx, s.logger)
+}
+
 func (s *cartService) newEmptyCart(userID string) *model.Cart {
 	now := time.Now()
 	return &model.Cart{
 		UserID:        userID,
 		Items:         []model.CartItem{},
 		TotalItems:    0,
-		TotalPrice:    0,
+		TotalPrice:    model.MoneyFromMinor(0, model.DefaultCurrency),
+		Currency:      model.DefaultCurrency,
 		CreatedAt:     now,
 		UpdatedAt:     now,
 		Source:        "new",
-		SchemaVersion: 1,
+		SchemaVersion: model.CurrentSchemaVersion,
 	}
 }
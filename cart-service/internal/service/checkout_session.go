@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/emart/cart-service/internal/model"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// CreateCheckoutSession freezes the cart for payment: the priced cart is copied
+// into an immutable session and the live cart is locked until the session is
+// completed, cancelled or expires. While a session is active, calling this
+// again returns that session.
+func (s *cartService) CreateCheckoutSession(ctx context.Context, userID string) (*model.CheckoutSession, error) {
+	now := time.Now()
+	current, err := s.GetCart(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if current.IsLocked(now) {
+		session, err := s.sessions.Get(ctx, current.CheckoutSessionID)
+		if err != nil {
+			return nil, err
+		}
+		if session != nil && session.EffectiveStatus(now) == model.CheckoutActive {
+			return session, nil
+		}
+	}
+
+	sessionID := uuid.New().String()
+	expiresAt := now.Add(s.cfg.Checkout.SessionTTL)
+	cart, err := s.applyMutation(ctx, userID, func(cart *model.Cart) error {
+		if len(cart.Items) == 0 {
+			return model.ErrCartEmpty
+		}
+		if cart.IsLocked(now) && cart.CheckoutSessionID != current.CheckoutSessionID {
+			// Another request started a session since the read above
+			return model.ErrCartLocked
+		}
+		cart.CheckoutSessionID = sessionID
+		cart.LockedUntil = &expiresAt
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	session := &model.CheckoutSession{
+		ID:        sessionID,
+		UserID:    userID,
+		Status:    model.CheckoutActive,
+		Cart:      *cart,
+		CreatedAt: now,
+		ExpiresAt: expiresAt,
+	}
+	if err := s.sessions.Create(ctx, session); err != nil {
+		if unlockErr := s.unlockCart(ctx, userID, sessionID); unlockErr != nil {
+			s.log(ctx).Warn("Failed to unlock cart after session create failed",
+				zap.String("userID", userID), zap.Error(unlockErr))
+		}
+		return nil, fmt.Errorf("create checkout session: %w", err)
+	}
+	return session, nil
+}
+
+// GetCheckoutSession returns one of the user's sessions
+func (s *cartService) GetCheckoutSession(ctx context.Context, userID string, sessionID string) (*model.CheckoutSession, error) {
+	session, err := s.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil || session.UserID != userID {
+		return nil, model.ErrCheckoutSessionNotFound
+	}
+	session.Status = session.EffectiveStatus(time.Now())
+	return session, nil
+}
+
+// CompleteCheckoutSession is called by payment-service once the order is paid.
+// Coupons in the snapshot are redeemed, the live cart is deleted and
+// CartCheckedOut is published with the snapshot totals. Repeating the call with
+// the same order ID succeeds without redeeming twice.
+//
+// Coupons are redeemed before the session is completed, so a coupon whose
+// usage limit was reached meanwhile fails with promotion.ErrUsageLimitReached
+// and leaves the session active.
+func (s *cartService) CompleteCheckoutSession(ctx context.Context, userID string, sessionID string, orderID string) (*model.CheckoutSession, error) {
+	now := time.Now()
+	active, err := s.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	redeemed := active != nil && active.UserID == userID && active.EffectiveStatus(now) == model.CheckoutActive
+	if redeemed {
+		if err := s.promotions.RecordRedemptions(ctx, &active.Cart, orderID, now); err != nil {
+			return nil, err
+		}
+	}
+
+	session, err := s.sessions.Complete(ctx, sessionID, userID, orderID, now)
+	if err != nil {
+		// Left redeemed: a retry for the order redeems nothing twice
+		return nil, err
+	}
+	retry := session == nil
+	if retry {
+		session, err = s.closedSession(ctx, userID, sessionID, now)
+		if err == nil && (session.Status != model.CheckoutCompleted || session.OrderID != orderID) {
+			err = model.ErrCheckoutSessionClosed
+		}
+		if err != nil {
+			if redeemed && (errors.Is(err, model.ErrCheckoutSessionClosed) || errors.Is(err, model.ErrCheckoutSessionExpired)) {
+				// The session ended some other way between the read and the update
+				if releaseErr := s.promotions.ReleaseRedemptions(ctx, &active.Cart, orderID); releaseErr != nil {
+					s.log(ctx).Warn("Failed to take back coupons of an uncompleted checkout",
+						zap.String("userID", userID), zap.String("orderID", orderID), zap.Error(releaseErr))
+				}
+			}
+			return nil, err
+		}
+	}
+
+	checkedOut := newCartEvent(model.EventCartCheckedOut, &session.Cart)
+	checkedOut.OrderID = orderID
+	checkedOut.OccurredAt = now
+	if err := s.closeSessionCart(ctx, userID, sessionID, checkedOut, !retry); err != nil {
+		return nil, fmt.Errorf("complete checkout session: %w", err)
+	}
+	return session, nil
+}
+
+// CancelCheckoutSession abandons checkout and unlocks the cart. Cancelling an
+// already cancelled session is a no-op.
+func (s *cartService) CancelCheckoutSession(ctx context.Context, userID string, sessionID string) (*model.CheckoutSession, error) {
+	now := time.Now()
+	session, err := s.sessions.Cancel(ctx, sessionID, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		session, err = s.closedSession(ctx, userID, sessionID, now)
+		if err != nil {
+			return nil, err
+		}
+		if session.Status != model.CheckoutCancelled {
+			return nil, model.ErrCheckoutSessionClosed
+		}
+	}
+
+	if err := s.unlockCart(ctx, userID, sessionID); err != nil {
+		return nil, fmt.Errorf("cancel checkout session: %w", err)
+	}
+	return session, nil
+}
+
+// closedSession explains why a state change matched no ACTIVE session. It
+// returns the stored session when it was already closed so callers can treat
+// a repeated request as idempotent.
+func (s *cartService) closedSession(ctx context.Context, userID string, sessionID string, now time.Time) (*model.CheckoutSession, error) {
+	session, err := s.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil || session.UserID != userID {
+		return nil, model.ErrCheckoutSessionNotFound
+	}
+	if session.EffectiveStatus(now) == model.CheckoutExpired {
+		return nil, model.ErrCheckoutSessionExpired
+	}
+	return session, nil
+}
+
+// closeSessionCart deletes the live cart if the session still holds it, which
+// publishes checkedOut. A retry only finishes a delete that failed before;
+// a first completion announces the checkout even if the lock had already lapsed.
+func (s *cartService) closeSessionCart(ctx context.Context, userID string, sessionID string, checkedOut model.CartEvent, first bool) error {
+	cart, err := s.GetCart(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if cart.CheckoutSessionID == sessionID {
+		return s.deleteCart(ctx, userID, checkedOut)
+	}
+	if !first {
+		return nil
+	}
+	return s.publisher.Publish(ctx, checkedOut)
+}
+
+// unlockCart releases the cart lock if sessionID still holds it
+func (s *cartService) unlockCart(ctx context.Context, userID string, sessionID string) error {
+	cart, err := s.GetCart(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if cart.CheckoutSessionID != sessionID {
+		return nil
+	}
+	_, err = s.applyMutation(ctx, userID, func(cart *model.Cart) error {
+		if cart.CheckoutSessionID == sessionID {
+			cart.CheckoutSessionID = ""
+			cart.LockedUntil = nil
+		}
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,145 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/emart/cart-service/internal/config"
+	"github.com/emart/cart-service/internal/model"
+	"github.com/emart/cart-service/internal/notify"
+	mongorepo "github.com/emart/cart-service/internal/repository/mongo"
+	"go.uber.org/zap"
+)
+
+// AbandonedCartJob periodically finds carts with items that have not changed
+// for cfg.Abandoned.After, stamps them abandoned and hands them to the notifier.
+// A cart is only ever claimed once, so shoppers are never reminded twice; if the
+// notifier fails the claim is released and the next run retries, up to
+// cfg.Abandoned.MaxAttempts times.
+type AbandonedCartJob struct {
+	repo     mongorepo.AbandonedCartMongoRepository
+	notifier notify.Notifier
+	cfg      *config.Config
+	logger   *zap.Logger
+}
+
+func NewAbandonedCartJob(
+	repo mongorepo.AbandonedCartMongoRepository,
+	notifier notify.Notifier,
+	cfg *config.Config,
+	logger *zap.Logger,
+) *AbandonedCartJob {
+	return &AbandonedCartJob{
+		repo:     repo,
+		notifier: notifier,
+		cfg:      cfg,
+		logger:   logger,
+	}
+}
+
+// Start launches the detection loop; it returns when ctx is cancelled
+func (j *AbandonedCartJob) Start(ctx context.Context) {
+	j.logger.Info("Abandoned cart job started",
+		zap.Duration("interval", j.cfg.Abandoned.Interval),
+		zap.Duration("after", j.cfg.Abandoned.After),
+	)
+
+	ticker := time.NewTicker(j.cfg.Abandoned.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			j.logger.Info("Abandoned cart job stopped")
+			return
+		case <-ticker.C:
+			j.run(ctx)
+		}
+	}
+}
+
+// run claims and notifies stale carts in batches until none are left
+func (j *AbandonedCartJob) run(ctx context.Context) {
+	cutoff := time.Now().Add(-j.cfg.Abandoned.After)
+	var notified, skipped, failed int
+
+	for ctx.Err() == nil {
+		carts, err := j.repo.ClaimAbandoned(ctx, cutoff, j.batchSize())
+		if err != nil {
+			j.logger.Error("Failed to claim abandoned carts", zap.Error(err))
+		}
+
+		batchFailed := 0
+		for _, cart := range carts {
+			err := j.notify(ctx, cart)
+			switch {
+			case errors.Is(err, notify.ErrNoRecipient):
+				skipped++
+			case err != nil:
+				batchFailed++
+			default:
+				notified++
+			}
+		}
+		failed += batchFailed
+
+		// Released carts match again immediately; leave them for the next run
+		if err != nil || batchFailed > 0 || len(carts) < j.batchSize() {
+			break
+		}
+	}
+
+	if notified > 0 || skipped > 0 || failed > 0 {
+		j.logger.Info("Abandoned cart run complete",
+			zap.Int("notified", notified),
+			zap.Int("skipped", skipped),
+			zap.Int("failed", failed),
+		)
+	}
+}
+
+func (j *AbandonedCartJob) notify(ctx context.Context, cart *model.Cart) error {
+	// Carts written before discounts existed carry no grand_total
+	if len(cart.Coupons) == 0 && cart.GrandTotal.IsZero() {
+		cart.GrandTotal = cart.TotalPrice
+	}
+
+	err := j.notifier.NotifyAbandonedCart(ctx, cart)
+	if errors.Is(err, notify.ErrNoRecipient) {
+		// Keep the claim: there is nobody to send it to on a later run either
+		j.logger.Info("Abandoned cart has no email address, not reminding", zap.String("userID", cart.UserID))
+		return err
+	}
+	if err != nil {
+		j.logger.Warn("Failed to notify abandoned cart", zap.String("userID", cart.UserID), zap.Error(err))
+		gaveUp, relErr := j.repo.ReleaseClaim(ctx, cart.UserID, j.maxAttempts())
+		if relErr != nil {
+			j.logger.Error("Failed to release abandoned cart claim", zap.String("userID", cart.UserID), zap.Error(relErr))
+		} else if gaveUp {
+			j.logger.Warn("Giving up on abandoned cart reminder",
+				zap.String("userID", cart.UserID), zap.Int("attempts", j.maxAttempts()))
+		}
+		return err
+	}
+
+	if err := j.repo.MarkNotified(ctx, cart.UserID, time.Now()); err != nil {
+		// The claim already prevents a second reminder
+		j.logger.Warn("Failed to mark abandoned cart notified", zap.String("userID", cart.UserID), zap.Error(err))
+	}
+	return nil
+}
+
+func (j *AbandonedCartJob) maxAttempts() int {
+	if j.cfg.Abandoned.MaxAttempts <= 0 {
+		return 5
+	}
+	return j.cfg.Abandoned.MaxAttempts
+}
+
+func (j *AbandonedCartJob) batchSize() int {
+	if j.cfg.Abandoned.BatchSize <= 0 {
+		return 100
+	}
+	return j.cfg.Abandoned.BatchSize
+}
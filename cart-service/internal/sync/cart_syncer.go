@@ -2,23 +2,51 @@ package sync
 
 import (
 	"context"
-	"strings"
+	"errors"
 	"time"
 
 	"github.com/emart/cart-service/internal/config"
+	"github.com/emart/cart-service/internal/logging"
+	"github.com/emart/cart-service/internal/model"
 	mongorepo "github.com/emart/cart-service/internal/repository/mongo"
 	redisrepo "github.com/emart/cart-service/internal/repository/redis"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
-// CartSyncer periodically syncs Redis cart data to MongoDB
+// CartSyncer periodically syncs Redis cart data to MongoDB.
+// Every tick it drains the dirty set written by the cart service; a slower
+// full reconciliation pass SCANs all cart keys to catch anything missed.
 type CartSyncer struct {
 	redisRepo redisrepo.CartRedisRepository
 	mongoRepo mongorepo.CartMongoRepository
+	observer  Observer
 	cfg       *config.Config
 	logger    *zap.Logger
 }
 
+// Observer is told the outcome of every sync pass; kind is "dirty" or "full"
+type Observer interface {
+	ObserveSync(kind string, duration time.Duration, synced, skipped, failed int)
+}
+
+type noopObserver struct{}
+
+func (noopObserver) ObserveSync(string, time.Duration, int, int, int) {}
+
+// syncResult counts the outcome of a sync pass
+type syncResult struct {
+	synced  int
+	skipped int
+	failed  int
+}
+
+func (r *syncResult) add(o syncResult) {
+	r.synced += o.synced
+	r.skipped += o.skipped
+	r.failed += o.failed
+}
+
 func NewCartSyncer(
 	redisRepo redisrepo.CartRedisRepository,
 	mongoRepo mongorepo.CartMongoRepository,
@@ -28,75 +56,175 @@ func NewCartSyncer(
 	return &CartSyncer{
 		redisRepo: redisRepo,
 		mongoRepo: mongoRepo,
+		observer:  noopObserver{},
 		cfg:       cfg,
 		logger:    logger,
 	}
 }
 
+// SetObserver reports sync passes to o, typically the service's metrics
+func (s *CartSyncer) SetObserver(o Observer) {
+	s.observer = o
+}
+
 // Start launches the background sync goroutine
 func (s *CartSyncer) Start(ctx context.Context) {
 	s.logger.Info("Cart syncer started",
 		zap.Duration("interval", s.cfg.Sync.Interval),
+		zap.Duration("fullSyncInterval", s.cfg.Sync.FullSyncInterval),
 		zap.Int("batchSize", s.cfg.Sync.BatchSize),
 	)
 
 	ticker := time.NewTicker(s.cfg.Sync.Interval)
 	defer ticker.Stop()
 
+	// A nil channel never fires, which disables the full pass
+	var fullSync <-chan time.Time
+	if s.cfg.Sync.FullSyncInterval > 0 {
+		fullTicker := time.NewTicker(s.cfg.Sync.FullSyncInterval)
+		defer fullTicker.Stop()
+		fullSync = fullTicker.C
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			s.logger.Info("Cart syncer stopped")
 			return
 		case <-ticker.C:
+			s.syncDirty(ctx)
+		case <-fullSync:
 			s.syncAll(ctx)
 		}
 	}
 }
 
-// syncAll iterates all Redis cart keys and syncs to MongoDB
-func (s *CartSyncer) syncAll(ctx context.Context) {
-	keys, err := s.redisRepo.GetAllCartKeys(ctx)
-	if err != nil {
-		s.logger.Error("Failed to get cart keys from Redis", zap.Error(err))
-		return
+// syncDirty drains the dirty set in chunks of BatchSize and syncs only those carts
+func (s *CartSyncer) syncDirty(ctx context.Context) {
+	ctx = s.passContext(ctx, "dirty")
+	start := time.Now()
+	var total syncResult
+	defer func() {
+		s.observer.ObserveSync("dirty", time.Since(start), total.synced, total.skipped, total.failed)
+	}()
+	for ctx.Err() == nil {
+		userIDs, err := s.redisRepo.PopDirty(ctx, s.batchSize())
+		if err != nil {
+			s.log(ctx).Error("Failed to pop dirty carts from Redis", zap.Error(err))
+			break
+		}
+		if len(userIDs) == 0 {
+			break
+		}
+
+		res, failedIDs := s.syncCarts(ctx, userIDs)
+		total.add(res)
+
+		// Put failures back so the next tick retries them
+		if len(failedIDs) > 0 {
+			if err := s.redisRepo.MarkDirty(ctx, failedIDs...); err != nil {
+				s.log(ctx).Error("Failed to re-mark carts dirty", zap.Strings("userIDs", failedIDs), zap.Error(err))
+			}
+			break
+		}
+		if len(userIDs) < s.batchSize() {
+			break
+		}
 	}
 
-	synced := 0
-	failed := 0
+	if total.synced > 0 || total.failed > 0 {
+		s.log(ctx).Info("Dirty cart sync complete",
+			zap.Int("synced", total.synced),
+			zap.Int("skipped", total.skipped),
+			zap.Int("failed", total.failed),
+		)
+	}
+}
 
-	for _, key := range keys {
-		// Extract userID from key (format: cart:<userID>)
-		userID := strings.TrimPrefix(key, "cart:")
-		if userID == "" {
-			continue
+// syncAll is the full reconciliation pass: cursor-based SCAN over every cart key
+func (s *CartSyncer) syncAll(ctx context.Context) {
+	ctx = s.passContext(ctx, "full")
+	start := time.Now()
+	var total syncResult
+	defer func() {
+		s.observer.ObserveSync("full", time.Since(start), total.synced, total.skipped, total.failed)
+	}()
+	var cursor uint64
+	for {
+		userIDs, next, err := s.redisRepo.ScanCartUserIDs(ctx, cursor, int64(s.batchSize()))
+		if err != nil {
+			s.log(ctx).Error("Failed to scan cart keys from Redis", zap.Error(err))
+			return
 		}
 
+		res, _ := s.syncCarts(ctx, userIDs)
+		total.add(res)
+
+		cursor = next
+		if cursor == 0 || ctx.Err() != nil {
+			break
+		}
+	}
+
+	s.log(ctx).Info("Full cart sync complete",
+		zap.Int("synced", total.synced),
+		zap.Int("skipped", total.skipped),
+		zap.Int("failed", total.failed),
+	)
+}
+
+// syncCarts copies the given carts from Redis to MongoDB and returns the IDs that failed
+func (s *CartSyncer) syncCarts(ctx context.Context, userIDs []string) (syncResult, []string) {
+	var res syncResult
+	var failedIDs []string
+
+	for _, userID := range userIDs {
 		cart, err := s.redisRepo.GetCart(ctx, userID)
 		if err != nil {
-			s.logger.Warn("Failed to get cart from Redis for sync",
+			s.log(ctx).Warn("Failed to get cart from Redis for sync",
 				zap.String("userID", userID), zap.Error(err))
-			failed++
+			res.failed++
+			failedIDs = append(failedIDs, userID)
 			continue
 		}
 		if cart == nil {
+			// Expired or cleared since it was marked dirty
+			res.skipped++
 			continue
 		}
 
-		if err := s.mongoRepo.UpsertCart(ctx, cart); err != nil {
-			s.logger.Error("Failed to sync cart to MongoDB",
+		err = s.mongoRepo.UpsertCart(ctx, cart)
+		if errors.Is(err, model.ErrVersionConflict) {
+			// MongoDB already holds this version (write-through) or a newer one
+			res.skipped++
+			continue
+		}
+		if err != nil {
+			s.log(ctx).Error("Failed to sync cart to MongoDB",
 				zap.String("userID", userID), zap.Error(err))
-			failed++
+			res.failed++
+			failedIDs = append(failedIDs, userID)
 			continue
 		}
-		synced++
+		res.synced++
 	}
+	return res, failedIDs
+}
 
-	if synced > 0 || failed > 0 {
-		s.logger.Info("Cart sync complete",
-			zap.Int("synced", synced),
-			zap.Int("failed", failed),
-			zap.Int("total", len(keys)),
-		)
+func (s *CartSyncer) batchSize() int {
+	if s.cfg.Sync.BatchSize <= 0 {
+		return 100
 	}
+	return s.cfg.Sync.BatchSize
+}
+
+// passContext gives one sync pass a logger of its own, so every line it writes
+// can be correlated by sync_run_id
+func (s *CartSyncer) passContext(ctx context.Context, kind string) context.Context {
+	return logging.NewContext(ctx, s.logger.With(zap.String("sync_kind", kind), zap.String("sync_run_id", uuid.New().String())))
+}
+
+// log returns the pass logger carried by ctx
+func (s *CartSyncer) log(ctx context.Context) *zap.Logger {
+	return logging.FromContext(ctx, s.logger)
 }
@@ -0,0 +1,127 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"github.com/emart/cart-service/internal/catalog"
+	"github.com/emart/cart-service/internal/model"
+	mongorepo "github.com/emart/cart-service/internal/repository/mongo"
+	redisrepo "github.com/emart/cart-service/internal/repository/redis"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	redisSystem = attribute.String("db.system", "redis")
+	mongoSystem = attribute.String("db.system", "mongodb")
+	cartsColl   = attribute.String("db.mongodb.collection", "carts")
+)
+
+type tracedRedisRepo struct {
+	repo redisrepo.CartRedisRepository
+}
+
+// TraceRedis wraps repo so that every call is a client span
+func TraceRedis(repo redisrepo.CartRedisRepository) redisrepo.CartRedisRepository {
+	return &tracedRedisRepo{repo: repo}
+}
+
+func (r *tracedRedisRepo) GetCart(ctx context.Context, userID string) (cart *model.Cart, err error) {
+	ctx, span := startSpan(ctx, "redis.GetCart", trace.SpanKindClient, redisSystem)
+	defer func() {
+		span.SetAttributes(attribute.Bool("cart.cache_hit", cart != nil))
+		endSpan(span, err)
+	}()
+	return r.repo.GetCart(ctx, userID)
+}
+
+func (r *tracedRedisRepo) SaveCart(ctx context.Context, cart *model.Cart, ttl time.Duration) (err error) {
+	ctx, span := startSpan(ctx, "redis.SaveCart", trace.SpanKindClient, redisSystem, attribute.Int64("cart.version", cart.Version))
+	defer func() { endSpan(span, err) }()
+	return r.repo.SaveCart(ctx, cart, ttl)
+}
+
+func (r *tracedRedisRepo) DeleteCart(ctx context.Context, userID string) (err error) {
+	ctx, span := startSpan(ctx, "redis.DeleteCart", trace.SpanKindClient, redisSystem)
+	defer func() { endSpan(span, err) }()
+	return r.repo.DeleteCart(ctx, userID)
+}
+
+func (r *tracedRedisRepo) MarkDirty(ctx context.Context, userIDs ...string) (err error) {
+	ctx, span := startSpan(ctx, "redis.MarkDirty", trace.SpanKindClient, redisSystem)
+	defer func() { endSpan(span, err) }()
+	return r.repo.MarkDirty(ctx, userIDs...)
+}
+
+func (r *tracedRedisRepo) PopDirty(ctx context.Context, count int) (ids []string, err error) {
+	ctx, span := startSpan(ctx, "redis.PopDirty", trace.SpanKindClient, redisSystem)
+	defer func() { endSpan(span, err) }()
+	return r.repo.PopDirty(ctx, count)
+}
+
+func (r *tracedRedisRepo) ScanCartUserIDs(ctx context.Context, cursor uint64, count int64) (ids []string, next uint64, err error) {
+	ctx, span := startSpan(ctx, "redis.ScanCartUserIDs", trace.SpanKindClient, redisSystem)
+	defer func() { endSpan(span, err) }()
+	return r.repo.ScanCartUserIDs(ctx, cursor, count)
+}
+
+// Ping is left untraced: health probes would drown out real traffic
+func (r *tracedRedisRepo) Ping(ctx context.Context) error {
+	return r.repo.Ping(ctx)
+}
+
+type tracedMongoRepo struct {
+	repo mongorepo.CartMongoRepository
+}
+
+// TraceMongo wraps repo so that every call is a client span
+func TraceMongo(repo mongorepo.CartMongoRepository) mongorepo.CartMongoRepository {
+	return &tracedMongoRepo{repo: repo}
+}
+
+func (r *tracedMongoRepo) GetCart(ctx context.Context, userID string) (cart *model.Cart, err error) {
+	ctx, span := startSpan(ctx, "mongo.GetCart", trace.SpanKindClient, mongoSystem, cartsColl)
+	defer func() { endSpan(span, err) }()
+	return r.repo.GetCart(ctx, userID)
+}
+
+func (r *tracedMongoRepo) UpsertCart(ctx context.Context, cart *model.Cart) (err error) {
+	ctx, span := startSpan(ctx, "mongo.UpsertCart", trace.SpanKindClient, mongoSystem, cartsColl, attribute.Int64("cart.version", cart.Version))
+	defer func() { endSpan(span, err) }()
+	return r.repo.UpsertCart(ctx, cart)
+}
+
+func (r *tracedMongoRepo) DeleteCart(ctx context.Context, userID string) (err error) {
+	ctx, span := startSpan(ctx, "mongo.DeleteCart", trace.SpanKindClient, mongoSystem, cartsColl)
+	defer func() { endSpan(span, err) }()
+	return r.repo.DeleteCart(ctx, userID)
+}
+
+// WithTransaction spans the whole transaction; the writes inside it are its children
+func (r *tracedMongoRepo) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	ctx, span := startSpan(ctx, "mongo.Transaction", trace.SpanKindInternal, mongoSystem)
+	defer func() { endSpan(span, err) }()
+	return r.repo.WithTransaction(ctx, fn)
+}
+
+func (r *tracedMongoRepo) Ping(ctx context.Context) error {
+	return r.repo.Ping(ctx)
+}
+
+type tracedCatalog struct {
+	client catalog.Client
+}
+
+// TraceCatalog wraps client so that product lookups are client spans. The HTTP
+// client forwards the span as a traceparent header to the catalog services.
+func TraceCatalog(client catalog.Client) catalog.Client {
+	return &tracedCatalog{client: client}
+}
+
+func (c *tracedCatalog) GetProduct(ctx context.Context, category, productID string) (p *catalog.Product, err error) {
+	ctx, span := startSpan(ctx, "catalog.GetProduct", trace.SpanKindClient,
+		attribute.String("catalog.category", category), attribute.String("catalog.product_id", productID))
+	defer func() { endSpan(span, err) }()
+	return c.client.GetProduct(ctx, category, productID)
+}
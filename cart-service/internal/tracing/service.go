@@ -0,0 +1,115 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/emart/cart-service/internal/model"
+	"github.com/emart/cart-service/internal/service"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type tracedCartService struct {
+	svc service.CartService
+}
+
+// TraceCartService wraps svc so that each call is a span between the request
+// span and the repository spans
+func TraceCartService(svc service.CartService) service.CartService {
+	return &tracedCartService{svc: svc}
+}
+
+func (t *tracedCartService) start(ctx context.Context, op, userID string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, attribute.Bool("cart.guest", model.IsGuestCart(userID)))
+	return startSpan(ctx, "CartService."+op, trace.SpanKindInternal, attrs...)
+}
+
+func (t *tracedCartService) GetCart(ctx context.Context, userID string) (cart *model.Cart, err error) {
+	ctx, span := t.start(ctx, "GetCart", userID)
+	defer func() { endSpan(span, err) }()
+	return t.svc.GetCart(ctx, userID)
+}
+
+func (t *tracedCartService) GetCartSummary(ctx context.Context, userID string) (summary *model.CartSummary, err error) {
+	ctx, span := t.start(ctx, "GetCartSummary", userID)
+	defer func() { endSpan(span, err) }()
+	return t.svc.GetCartSummary(ctx, userID)
+}
+
+func (t *tracedCartService) AddItem(ctx context.Context, userID string, req *model.AddItemRequest) (cart *model.Cart, err error) {
+	ctx, span := t.start(ctx, "AddItem", userID, attribute.String("catalog.product_id", req.ProductID))
+	defer func() { endSpan(span, err) }()
+	return t.svc.AddItem(ctx, userID, req)
+}
+
+func (t *tracedCartService) UpdateItemQuantity(ctx context.Context, userID string, itemID string, quantity int) (cart *model.Cart, err error) {
+	ctx, span := t.start(ctx, "UpdateItemQuantity", userID, attribute.String("cart.item_id", itemID))
+	defer func() { endSpan(span, err) }()
+	return t.svc.UpdateItemQuantity(ctx, userID, itemID, quantity)
+}
+
+func (t *tracedCartService) RemoveItem(ctx context.Context, userID string, itemID string) (cart *model.Cart, err error) {
+	ctx, span := t.start(ctx, "RemoveItem", userID, attribute.String("cart.item_id", itemID))
+	defer func() { endSpan(span, err) }()
+	return t.svc.RemoveItem(ctx, userID, itemID)
+}
+
+func (t *tracedCartService) ClearCart(ctx context.Context, userID string) (err error) {
+	ctx, span := t.start(ctx, "ClearCart", userID)
+	defer func() { endSpan(span, err) }()
+	return t.svc.ClearCart(ctx, userID)
+}
+
+func (t *tracedCartService) MergeCart(ctx context.Context, userID string, guestCartID string, strategy model.MergeStrategy) (cart *model.Cart, err error) {
+	ctx, span := t.start(ctx, "MergeCart", userID, attribute.String("cart.merge_strategy", string(strategy)))
+	defer func() { endSpan(span, err) }()
+	return t.svc.MergeCart(ctx, userID, guestCartID, strategy)
+}
+
+func (t *tracedCartService) ApplyCoupon(ctx context.Context, userID string, code string) (cart *model.Cart, err error) {
+	ctx, span := t.start(ctx, "ApplyCoupon", userID)
+	defer func() { endSpan(span, err) }()
+	return t.svc.ApplyCoupon(ctx, userID, code)
+}
+
+func (t *tracedCartService) RemoveCoupon(ctx context.Context, userID string, code string) (cart *model.Cart, err error) {
+	ctx, span := t.start(ctx, "RemoveCoupon", userID)
+	defer func() { endSpan(span, err) }()
+	return t.svc.RemoveCoupon(ctx, userID, code)
+}
+
+func (t *tracedCartService) CheckoutCart(ctx context.Context, userID string, orderID string) (err error) {
+	ctx, span := t.start(ctx, "CheckoutCart", userID, attribute.String("order.id", orderID))
+	defer func() { endSpan(span, err) }()
+	return t.svc.CheckoutCart(ctx, userID, orderID)
+}
+
+func (t *tracedCartService) CreateCheckoutSession(ctx context.Context, userID string) (session *model.CheckoutSession, err error) {
+	ctx, span := t.start(ctx, "CreateCheckoutSession", userID)
+	defer func() {
+		if session != nil {
+			span.SetAttributes(attribute.String("checkout.session_id", session.ID))
+		}
+		endSpan(span, err)
+	}()
+	return t.svc.CreateCheckoutSession(ctx, userID)
+}
+
+func (t *tracedCartService) GetCheckoutSession(ctx context.Context, userID string, sessionID string) (session *model.CheckoutSession, err error) {
+	ctx, span := t.start(ctx, "GetCheckoutSession", userID, attribute.String("checkout.session_id", sessionID))
+	defer func() { endSpan(span, err) }()
+	return t.svc.GetCheckoutSession(ctx, userID, sessionID)
+}
+
+func (t *tracedCartService) CompleteCheckoutSession(ctx context.Context, userID string, sessionID string, orderID string) (session *model.CheckoutSession, err error) {
+	ctx, span := t.start(ctx, "CompleteCheckoutSession", userID,
+		attribute.String("checkout.session_id", sessionID), attribute.String("order.id", orderID))
+	defer func() { endSpan(span, err) }()
+	return t.svc.CompleteCheckoutSession(ctx, userID, sessionID, orderID)
+}
+
+func (t *tracedCartService) CancelCheckoutSession(ctx context.Context, userID string, sessionID string) (session *model.CheckoutSession, err error) {
+	ctx, span := t.start(ctx, "CancelCheckoutSession", userID, attribute.String("checkout.session_id", sessionID))
+	defer func() { endSpan(span, err) }()
+	return t.svc.CancelCheckoutSession(ctx, userID, sessionID)
+}
@@ -59,9 +59,16 @@ func (s *CartApiTestSuite) TestH003_GetReadiness_Returns200() {
 }
 
 // ---- Auth Tests ----
-func (s *CartApiTestSuite) TestS001_GetCart_WithoutToken_Returns401() {
+func (s *CartApiTestSuite) TestS001_GetCart_WithoutToken_IssuesGuestCart() {
 	resp, err := s.client.R().Get("/api/v1/cart")
 	s.NoError(err)
+	s.Equal(200, resp.StatusCode())
+	s.NotEmpty(resp.Header().Get("X-Guest-Token"))
+}
+
+func (s *CartApiTestSuite) TestS002_GetCart_WithInvalidToken_Returns401() {
+	resp, err := s.client.R().SetAuthToken("not-a-jwt").Get("/api/v1/cart")
+	s.NoError(err)
 	s.Equal(401, resp.StatusCode())
 }
 
@@ -76,7 +83,8 @@ func (s *CartApiTestSuite) TestC001_GetCart_WithToken_Returns200() {
 }
 
 func (s *CartApiTestSuite) TestC002_AddBookToCart_Returns200() {
-	body := `{"product_id":"book-test-001","product_name":"The Go Programming Language","category":"books","price":39.99,"quantity":1}`
+	// Product IDs must exist in books-service (seeded by V3__Seed_sample_books.sql)
+	body := `{"product_id":"1","category":"books","quantity":1}`
 	resp, err := s.client.R().
 		SetAuthToken(s.token).
 		SetHeader("Content-Type", "application/json").
@@ -85,11 +93,11 @@ func (s *CartApiTestSuite) TestC002_AddBookToCart_Returns200() {
 	s.NoError(err)
 	s.Equal(200, resp.StatusCode())
 	s.Contains(resp.String(), `"success":true`)
-	s.Contains(resp.String(), "book-test-001")
+	s.Contains(resp.String(), `"product_id":"1"`)
 }
 
 func (s *CartApiTestSuite) TestC003_AddCourseToCart_Returns200() {
-	body := `{"product_id":"course-test-001","product_name":"React Mastery","category":"courses","price":79.99,"quantity":1}`
+	body := `{"product_id":"1","category":"courses","quantity":1}`
 	resp, err := s.client.R().
 		SetAuthToken(s.token).
 		SetHeader("Content-Type", "application/json").
@@ -99,15 +107,15 @@ func (s *CartApiTestSuite) TestC003_AddCourseToCart_Returns200() {
 	s.Equal(200, resp.StatusCode())
 }
 
-func (s *CartApiTestSuite) TestC004_AddSoftwareToCart_Returns200() {
-	body := `{"product_id":"sw-test-001","product_name":"IntelliJ IDEA","category":"software","price":499.99,"quantity":1}`
+func (s *CartApiTestSuite) TestC004_AddUnknownProduct_Returns404() {
+	body := `{"product_id":"999999","category":"books","quantity":1}`
 	resp, err := s.client.R().
 		SetAuthToken(s.token).
 		SetHeader("Content-Type", "application/json").
 		SetBody(body).
 		Post("/api/v1/cart/items")
 	s.NoError(err)
-	s.Equal(200, resp.StatusCode())
+	s.Equal(404, resp.StatusCode())
 }
 
 func (s *CartApiTestSuite) TestC005_AddItem_InvalidCategory_Returns400() {
@@ -2,14 +2,22 @@ package integration_test
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/emart/cart-service/internal/catalog"
 	"github.com/emart/cart-service/internal/config"
+	"github.com/emart/cart-service/internal/events"
 	"github.com/emart/cart-service/internal/migration"
 	mongorepo "github.com/emart/cart-service/internal/repository/mongo"
 	redisrepo "github.com/emart/cart-service/internal/repository/redis"
 	"github.com/emart/cart-service/internal/model"
+	"github.com/emart/cart-service/internal/promotion"
+	"github.com/emart/cart-service/internal/ratelimit"
+	"github.com/emart/cart-service/internal/realtime"
 	"github.com/emart/cart-service/internal/service"
 	goredis "github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
@@ -17,6 +25,8 @@ import (
 	"github.com/testcontainers/testcontainers-go"
 	tcmongo "github.com/testcontainers/testcontainers-go/modules/mongodb"
 	tcredis "github.com/testcontainers/testcontainers-go/modules/redis"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.uber.org/zap"
@@ -37,6 +47,12 @@ type CartIntegrationSuite struct {
 	ctx            context.Context
 }
 
+// inr builds a Money value in the default currency
+func inr(amount string) model.Money {
+	m, _ := model.ParseMoney(amount, model.DefaultCurrency)
+	return m
+}
+
 func TestCartIntegrationSuite(t *testing.T) {
 	suite.Run(t, new(CartIntegrationSuite))
 }
@@ -69,14 +85,27 @@ func (s *CartIntegrationSuite) SetupSuite() {
 	// Run migrations
 	db := s.mongoClient.Database("cart_test")
 	logger, _ := zap.NewDevelopment()
-	runner := migration.NewRunner(db, logger)
+	runner := migration.NewRunner(db, &config.Config{}, logger)
 	s.Require().NoError(runner.Run(s.ctx))
 
 	// Build service
 	rRepo := redisrepo.NewCartRedisRepository(s.redisClient)
 	mRepo := mongorepo.NewCartMongoRepository(db)
-	cfg := &config.Config{Redis: config.RedisConfig{TTL: 1 * time.Hour}}
-	s.cartService = service.NewCartService(rRepo, mRepo, cfg.Redis.TTL, logger)
+	products := catalog.NewFakeClient(
+		catalog.Product{ID: "book-001", Category: "books", Name: "Clean Code", Price: inr("35.00"), Stock: 50, Active: true},
+		catalog.Product{ID: "course-001", Category: "courses", Name: "K8s Course", Price: inr("59.00"), Stock: 50, Active: true},
+		catalog.Product{ID: "sw-001", Category: "software", Name: "VS Code", Price: inr("0.00"), Stock: 50, Active: true},
+		catalog.Product{ID: "bk-002", Category: "books", Name: "DDD Book", Price: inr("45.00"), Stock: 50, Active: true},
+	)
+	cfg := &config.Config{
+		Redis: config.RedisConfig{TTL: 1 * time.Hour},
+		Guest: config.GuestConfig{TTL: 10 * time.Minute, MergeStrategy: "sum", MaxItemQuantity: 100},
+		Checkout: config.CheckoutConfig{SessionTTL: 15 * time.Minute},
+		Promotion: config.PromotionConfig{MaxCouponsPerCart: 2},
+	}
+	promotions := promotion.NewEngine(mongorepo.NewCouponMongoRepository(db), cfg.Promotion.MaxCouponsPerCart)
+	publisher := events.NewOutboxPublisher(mongorepo.NewOutboxMongoRepository(db))
+	s.cartService = service.NewCartService(rRepo, mRepo, mongorepo.NewCheckoutSessionMongoRepository(db), products, promotions, publisher, realtime.NewNoopPublisher(), cfg, logger)
 }
 
 func (s *CartIntegrationSuite) TearDownSuite() {
@@ -104,7 +133,7 @@ func (s *CartIntegrationSuite) TestINT001_AddItem_PersistedInBothStores() {
 	s.NotNil(cart)
 	s.Len(cart.Items, 1)
 	s.Equal(1, cart.TotalItems)
-	s.InDelta(35.0, cart.TotalPrice, 0.001)
+	s.Equal("35.00", cart.TotalPrice.String())
 }
 
 // INT-002: Get cart falls back to MongoDB when Redis is cleared
@@ -157,9 +186,431 @@ func (s *CartIntegrationSuite) TestINT004_Migrations_CreateIndexes() {
 func (s *CartIntegrationSuite) TestINT005_Migrations_AreIdempotent() {
 	db := s.mongoClient.Database("cart_test")
 	logger, _ := zap.NewDevelopment()
-	runner := migration.NewRunner(db, logger)
+	runner := migration.NewRunner(db, &config.Config{}, logger)
 	
 	// Running migrations again should not fail
 	err := runner.Run(s.ctx)
 	s.NoError(err, "Re-running migrations should be idempotent")
 }
+
+// INT-006: The latest migration can be rolled back and re-applied; dry runs change nothing
+func (s *CartIntegrationSuite) TestINT006_Migrations_DownThenUp() {
+	db := s.mongoClient.Database("cart_test")
+	logger, _ := zap.NewDevelopment()
+	runner := migration.NewRunner(db, &config.Config{}, logger)
+
+	stateOf := func(id string) string {
+		statuses, err := runner.Status(s.ctx)
+		s.Require().NoError(err)
+		for _, st := range statuses {
+			if st.ID == id {
+				return st.State
+			}
+		}
+		return ""
+	}
+
+	s.Require().NoError(runner.Down(s.ctx, migration.Options{DryRun: true}))
+	s.Equal("EXECUTED", stateOf("V011_BackfillSchemaVersion"))
+
+	s.Require().NoError(runner.Down(s.ctx, migration.Options{}))
+	s.Equal("ROLLED_BACK", stateOf("V011_BackfillSchemaVersion"))
+	s.Equal("EXECUTED", stateOf("V010_CreateCouponUsage"))
+
+	s.Require().NoError(runner.Up(s.ctx, migration.Options{}))
+	s.Equal("EXECUTED", stateOf("V011_BackfillSchemaVersion"))
+}
+
+// INT-007: An edited migration stops the runner until repair re-baselines it
+func (s *CartIntegrationSuite) TestINT007_Migrations_DetectChecksumDrift() {
+	db := s.mongoClient.Database("cart_test")
+	logger, _ := zap.NewDevelopment()
+	runner := migration.NewRunner(db, &config.Config{}, logger)
+
+	_, err := db.Collection("mongockChangeLog").UpdateOne(s.ctx,
+		bson.M{"changeId": "V002_AddCartIndexes"}, bson.M{"$set": bson.M{"checksum": "tok2:edited-elsewhere"}})
+	s.Require().NoError(err)
+
+	s.ErrorIs(runner.Run(s.ctx), migration.ErrChecksumMismatch)
+
+	warnOnly := migration.NewRunner(db, &config.Config{Migration: config.MigrationConfig{AllowDrift: true}}, logger)
+	s.NoError(warnOnly.Run(s.ctx))
+
+	s.Require().NoError(runner.Repair(s.ctx))
+	s.NoError(runner.Run(s.ctx))
+
+	// A checksum of the old raw-file scheme cannot be compared; it is re-baselined
+	legacy := strings.Repeat("0", 64)
+	_, err = db.Collection("mongockChangeLog").UpdateOne(s.ctx,
+		bson.M{"changeId": "V002_AddCartIndexes"}, bson.M{"$set": bson.M{"checksum": legacy}})
+	s.Require().NoError(err)
+	s.NoError(runner.Run(s.ctx))
+	var rec struct {
+		Checksum string `bson:"checksum"`
+	}
+	s.Require().NoError(db.Collection("mongockChangeLog").FindOne(s.ctx, bson.M{"changeId": "V002_AddCartIndexes"}).Decode(&rec))
+	s.True(strings.HasPrefix(rec.Checksum, "tok2:"), rec.Checksum)
+}
+
+// INT-008: A live lock held by another pod blocks the runner; an expired one is taken over
+func (s *CartIntegrationSuite) TestINT008_Migrations_RespectOtherOwnersLock() {
+	db := s.mongoClient.Database("cart_test")
+	logger, _ := zap.NewDevelopment()
+	cfg := &config.Config{Migration: config.MigrationConfig{LockWait: 200 * time.Millisecond, LockRetryInterval: 50 * time.Millisecond}}
+	runner := migration.NewRunner(db, cfg, logger)
+	locks := db.Collection("mongockLock")
+
+	_, err := locks.UpdateOne(s.ctx, bson.M{"_id": "migration-lock"},
+		bson.M{"$set": bson.M{"locked": true, "owner": "other-pod:1:abc", "expiresAt": time.Now().Add(time.Minute)}},
+		options.Update().SetUpsert(true))
+	s.Require().NoError(err)
+
+	err = runner.Run(s.ctx)
+	s.Error(err)
+	s.Contains(err.Error(), "other-pod:1:abc")
+
+	_, err = locks.UpdateOne(s.ctx, bson.M{"_id": "migration-lock"},
+		bson.M{"$set": bson.M{"expiresAt": time.Now().Add(-time.Second)}})
+	s.Require().NoError(err)
+	s.NoError(runner.Run(s.ctx))
+
+	var lock bson.M
+	s.Require().NoError(locks.FindOne(s.ctx, bson.M{"_id": "migration-lock"}).Decode(&lock))
+	s.Equal(false, lock["locked"])
+	s.NotContains(lock, "owner")
+}
+
+// INT-009: Batched rewrites checkpoint their progress and resume after a failure
+func (s *CartIntegrationSuite) TestINT009_RewriteInBatches_ResumesFromCheckpoint() {
+	db := s.mongoClient.Database("cart_test")
+	coll := db.Collection("batch_rewrite_test")
+	defer coll.Drop(s.ctx)
+	for i := 1; i <= 12; i++ {
+		_, err := coll.InsertOne(s.ctx, bson.M{"n": i, "schema_version": 1, "version": int64(i)})
+		s.Require().NoError(err)
+	}
+	opts := migration.BatchOptions{Collection: "batch_rewrite_test", Filter: bson.M{"schema_version": 1}, BatchSize: 5}
+
+	visited := 0
+	_, err := migration.RewriteInBatches(s.ctx, db, "TEST_BatchRewrite", opts, func(doc bson.M) (bool, error) {
+		visited++
+		if doc["n"] == int32(8) {
+			return false, assert.AnError // crash halfway through the second batch
+		}
+		doc["schema_version"] = 2
+		return true, nil
+	})
+	s.ErrorIs(err, assert.AnError)
+
+	visited = 0
+	rewritten, err := migration.RewriteInBatches(s.ctx, db, "TEST_BatchRewrite", opts, func(doc bson.M) (bool, error) {
+		visited++
+		doc["schema_version"] = 2
+		return true, nil
+	})
+	s.NoError(err)
+	// Batch one was checkpointed; 6 and 7 were rewritten before the crash and no
+	// longer match (the test server is standalone, so no batch transaction undid them)
+	s.Equal(5, visited)
+	s.Equal(int64(5), rewritten)
+
+	remaining, err := coll.CountDocuments(s.ctx, bson.M{"schema_version": 1})
+	s.NoError(err)
+	s.Zero(remaining)
+}
+
+// INT-010: Redis token buckets are shared by every replica and expire once full
+func (s *CartIntegrationSuite) TestINT010_RedisRateLimit_SharedAcrossReplicas() {
+	replicaA := ratelimit.NewRedisLimiter(s.redisClient)
+	replicaB := ratelimit.NewRedisLimiter(s.redisClient)
+	rule := ratelimit.Rule{Limit: 3, Period: time.Minute, Burst: 3}
+
+	for i, limiter := range []*ratelimit.RedisLimiter{replicaA, replicaB, replicaA} {
+		res, err := limiter.Allow(s.ctx, "POST /api/v1/cart/items:user:int-010", rule)
+		s.Require().NoError(err)
+		s.True(res.Allowed)
+		s.Equal(2-i, res.Remaining)
+	}
+	res, err := replicaB.Allow(s.ctx, "POST /api/v1/cart/items:user:int-010", rule)
+	s.Require().NoError(err)
+	s.False(res.Allowed)
+	s.InDelta(20*time.Second, res.RetryAfter, float64(time.Second))
+
+	ttl, err := s.redisClient.PTTL(s.ctx, "ratelimit:POST /api/v1/cart/items:user:int-010").Result()
+	s.NoError(err)
+	s.Greater(ttl, 50*time.Second)
+	s.LessOrEqual(ttl, 61*time.Second)
+}
+
+// INT-011: An idempotency key is reserved once, replays its stored response and can be released
+func (s *CartIntegrationSuite) TestINT011_IdempotencyKeys_ReserveCompleteRelease() {
+	repo := redisrepo.NewIdempotencyRedisRepository(s.redisClient)
+
+	existing, err := repo.Reserve(s.ctx, "user-1:int-011", "fp-1", "token-1", time.Minute)
+	s.Require().NoError(err)
+	s.Nil(existing, "the first request takes the reservation")
+
+	existing, err = repo.Reserve(s.ctx, "user-1:int-011", "fp-1", "token-2", time.Minute)
+	s.Require().NoError(err)
+	s.Require().NotNil(existing)
+	s.Equal("fp-1", existing.Fingerprint)
+	s.False(existing.Completed)
+
+	stored, err := repo.Complete(s.ctx, "user-1:int-011", "token-1", &model.IdempotencyRecord{
+		Fingerprint: "fp-1", Completed: true, Status: 201,
+		Header: map[string]string{"ETag": `"v1"`}, Body: []byte(`{"ok":true}`),
+	}, time.Hour)
+	s.Require().NoError(err)
+	s.True(stored)
+	existing, err = repo.Reserve(s.ctx, "user-1:int-011", "fp-1", "token-3", time.Minute)
+	s.Require().NoError(err)
+	s.Require().NotNil(existing)
+	s.True(existing.Completed)
+	s.Equal(201, existing.Status)
+	s.Equal(`{"ok":true}`, string(existing.Body))
+	ttl, err := s.redisClient.PTTL(s.ctx, "idempotency:user-1:int-011").Result()
+	s.NoError(err)
+	s.Greater(ttl, 59*time.Minute)
+
+	existing, err = repo.Reserve(s.ctx, "user-1:int-011b", "fp-2", "token-4", time.Minute)
+	s.Require().NoError(err)
+	s.Nil(existing)
+	s.Require().NoError(repo.Release(s.ctx, "user-1:int-011b", "token-4"))
+	existing, err = repo.Reserve(s.ctx, "user-1:int-011b", "fp-2", "token-5", time.Minute)
+	s.NoError(err)
+	s.Nil(existing, "a released key can be reserved again")
+}
+
+// INT-015: A request whose reservation expired cannot overwrite or release the retry's
+func (s *CartIntegrationSuite) TestINT015_IdempotencyKeys_ExpiredReservationLosesTheKey() {
+	repo := redisrepo.NewIdempotencyRedisRepository(s.redisClient)
+
+	existing, err := repo.Reserve(s.ctx, "user-1:int-015", "fp-1", "slow", 50*time.Millisecond)
+	s.Require().NoError(err)
+	s.Nil(existing)
+	time.Sleep(100 * time.Millisecond) // the slow request outlives its lock
+	existing, err = repo.Reserve(s.ctx, "user-1:int-015", "fp-1", "retry", time.Minute)
+	s.Require().NoError(err)
+	s.Nil(existing, "the retry takes the expired key")
+
+	stored, err := repo.Complete(s.ctx, "user-1:int-015", "slow", &model.IdempotencyRecord{
+		Fingerprint: "fp-1", Completed: true, Status: 201, Body: []byte(`{"from":"slow"}`),
+	}, time.Hour)
+	s.Require().NoError(err)
+	s.False(stored)
+	s.Require().NoError(repo.Release(s.ctx, "user-1:int-015", "slow"))
+
+	existing, err = repo.Reserve(s.ctx, "user-1:int-015", "fp-1", "third", time.Minute)
+	s.Require().NoError(err)
+	s.Require().NotNil(existing, "the retry still holds its reservation")
+	s.False(existing.Completed)
+	s.Equal("retry", existing.Token)
+
+	stored, err = repo.Complete(s.ctx, "user-1:int-015", "retry", &model.IdempotencyRecord{
+		Fingerprint: "fp-1", Completed: true, Status: 201, Body: []byte(`{"from":"retry"}`),
+	}, time.Hour)
+	s.Require().NoError(err)
+	s.True(stored)
+}
+
+// INT-016: Concurrent checkouts cannot both redeem a coupon's last use
+func (s *CartIntegrationSuite) TestINT016_CouponRedemption_EnforcesLimitUnderConcurrency() {
+	repo := mongorepo.NewCouponMongoRepository(s.mongoClient.Database("cart_test"))
+
+	const racers = 8
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	won := 0
+	for i := 0; i < racers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ok, err := repo.RedeemCoupon(s.ctx, &model.CouponRedemption{
+				Code: "int016", UserID: "user-int-016", OrderRef: fmt.Sprintf("order-%d", i), RedeemedAt: time.Now(),
+			}, 2)
+			s.NoError(err)
+			if ok {
+				mu.Lock()
+				won++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	s.Equal(2, won)
+	used, err := repo.CountRedemptions(s.ctx, "INT016", "user-int-016")
+	s.Require().NoError(err)
+	s.Equal(int64(2), used)
+
+	// Retrying a winning order does not count it again; taking one back frees a use
+	var rec model.CouponRedemption
+	s.Require().NoError(s.mongoClient.Database("cart_test").Collection("coupon_redemptions").
+		FindOne(s.ctx, bson.M{"code": "INT016", "user_id": "user-int-016"}).Decode(&rec))
+	winner := rec.OrderRef
+	ok, err := repo.RedeemCoupon(s.ctx, &model.CouponRedemption{Code: "INT016", UserID: "user-int-016", OrderRef: winner}, 2)
+	s.Require().NoError(err)
+	s.True(ok)
+	s.Require().NoError(repo.UnredeemCoupon(s.ctx, "INT016", "user-int-016", winner))
+	ok, err = repo.RedeemCoupon(s.ctx, &model.CouponRedemption{Code: "INT016", UserID: "user-int-016", OrderRef: "order-late"}, 2)
+	s.Require().NoError(err)
+	s.True(ok)
+	used, err = repo.CountRedemptions(s.ctx, "INT016", "user-int-016")
+	s.Require().NoError(err)
+	s.Equal(int64(2), used)
+
+	// Concurrent retries of one checkout consume one use between them
+	for i := 0; i < racers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := repo.RedeemCoupon(s.ctx, &model.CouponRedemption{
+				Code: "INT016B", UserID: "user-int-016", OrderRef: "order-retried", RedeemedAt: time.Now(),
+			}, 5)
+			s.NoError(err)
+			s.True(ok)
+		}()
+	}
+	wg.Wait()
+	used, err = repo.CountRedemptions(s.ctx, "INT016B", "user-int-016")
+	s.Require().NoError(err)
+	s.Equal(int64(1), used)
+}
+
+// INT-012: A cart update published on one replica reaches the streams open on another
+func (s *CartIntegrationSuite) TestINT012_RedisBroker_FansOutAcrossReplicas() {
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+	replicaA := realtime.NewRedisBroker(s.redisClient, 0, zap.NewNop())
+	replicaB := realtime.NewRedisBroker(s.redisClient, 0, zap.NewNop())
+	defer replicaA.Close()
+	defer replicaB.Close()
+	go replicaB.Start(ctx)
+
+	sub, err := replicaB.Subscribe(ctx, "int-012")
+	s.Require().NoError(err)
+	cart := &model.Cart{UserID: "int-012", Version: 7, TotalItems: 2}
+
+	// SUBSCRIBE is asynchronous; publish until replica B is listening
+	s.Eventually(func() bool {
+		s.Require().NoError(replicaA.PublishCart(ctx, cart))
+		select {
+		case got := <-sub.Updates():
+			return got.Version == 7 && got.TotalItems == 2
+		case <-time.After(100 * time.Millisecond):
+			return false
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+
+	replicaB.Close()
+	select {
+	case <-sub.Done():
+	case <-time.After(time.Second):
+		s.Fail("stream not ended by Close")
+	}
+}
+
+// failingMigration runs the wrapped migration and then fails, like a later step breaking
+type failingMigration struct {
+	migration.Migration
+}
+
+func (f failingMigration) Execute(ctx context.Context, db *mongo.Database) error {
+	if err := f.Migration.Execute(ctx, db); err != nil {
+		return err
+	}
+	return assert.AnError
+}
+
+// failingDataMigration is a failingMigration the runner resumes instead of rolling back
+type failingDataMigration struct {
+	failingMigration
+}
+
+func (failingDataMigration) Transactional() bool { return true }
+
+// INT-013: Rolling back a failed migration drops only collections that run created
+func (s *CartIntegrationSuite) TestINT013_FailedMigration_KeepsPreexistingCollection() {
+	logger, _ := zap.NewDevelopment()
+	failing := failingMigration{migration.NewV007CreateCartOutbox()}
+
+	existing := s.mongoClient.Database("cart_int013_existing")
+	defer existing.Drop(s.ctx)
+	_, err := existing.Collection("cart_outbox").InsertOne(s.ctx, bson.M{"status": "PENDING"})
+	s.Require().NoError(err)
+
+	runner := migration.NewRunnerWith(existing, &config.Config{}, logger, failing)
+	s.ErrorIs(runner.Run(s.ctx), assert.AnError)
+	events, err := existing.Collection("cart_outbox").CountDocuments(s.ctx, bson.M{})
+	s.NoError(err)
+	s.Equal(int64(1), events, "the pre-existing outbox survives the rollback")
+	statuses, err := runner.Status(s.ctx)
+	s.Require().NoError(err)
+	s.Equal("ROLLED_BACK", statuses[0].State)
+
+	fresh := s.mongoClient.Database("cart_int013_fresh")
+	defer fresh.Drop(s.ctx)
+	runner = migration.NewRunnerWith(fresh, &config.Config{}, logger, failing)
+	s.ErrorIs(runner.Run(s.ctx), assert.AnError)
+	names, err := fresh.ListCollectionNames(s.ctx, bson.M{"name": "cart_outbox"})
+	s.NoError(err)
+	s.Empty(names, "a collection the failed run created is dropped again")
+}
+
+// INT-014: A failed data migration keeps its checkpoint and the next run finishes it
+func (s *CartIntegrationSuite) TestINT014_FailedDataMigration_KeepsCheckpointAndResumes() {
+	logger, _ := zap.NewDevelopment()
+	db := s.mongoClient.Database("cart_int014")
+	defer db.Drop(s.ctx)
+	_, err := db.Collection("carts").InsertOne(s.ctx, bson.M{
+		"user_id": "legacy-user", "total_items": 1, "total_price": 19.990000000000002, "version": int64(1),
+		"items": bson.A{bson.M{"product_id": "p1", "quantity": 1, "price": 19.990000000000002}},
+	})
+	s.Require().NoError(err)
+
+	failing := failingDataMigration{failingMigration{migration.NewV005ConvertPricesToDecimal()}}
+	runner := migration.NewRunnerWith(db, &config.Config{}, logger, failing)
+	s.ErrorIs(runner.Run(s.ctx), assert.AnError)
+	var entry bson.M
+	s.Require().NoError(db.Collection("mongockChangeLog").FindOne(s.ctx, bson.M{"changeId": failing.ID()}).Decode(&entry))
+	s.Equal("FAILED", entry["state"])
+	s.Contains(entry, "checkpoint", "a failed run keeps its progress")
+
+	runner = migration.NewRunnerWith(db, &config.Config{}, logger, migration.NewV005ConvertPricesToDecimal())
+	s.Require().NoError(runner.Run(s.ctx))
+	entry = bson.M{}
+	s.Require().NoError(db.Collection("mongockChangeLog").FindOne(s.ctx, bson.M{"changeId": failing.ID()}).Decode(&entry))
+	s.Equal("EXECUTED", entry["state"])
+	s.NotContains(entry, "checkpoint")
+
+	var cart bson.M
+	s.Require().NoError(db.Collection("carts").FindOne(s.ctx, bson.M{"user_id": "legacy-user"}).Decode(&cart))
+	s.Equal("19.99", cart["total_price"].(primitive.Decimal128).String())
+	s.Equal("19.99", cart["items"].(bson.A)[0].(bson.M)["price"].(primitive.Decimal128).String())
+	s.Equal(model.DefaultCurrency, cart["currency"])
+	s.EqualValues(2, cart["schema_version"])
+}
+
+// INT-017: An entry executed before created objects were tracked is only rolled back with Force
+func (s *CartIntegrationSuite) TestINT017_MigrateDown_RefusesUntrackedEntry() {
+	logger, _ := zap.NewDevelopment()
+	db := s.mongoClient.Database("cart_int017")
+	defer db.Drop(s.ctx)
+	runner := migration.NewRunnerWith(db, &config.Config{}, logger, migration.NewV007CreateCartOutbox())
+	s.Require().NoError(runner.Run(s.ctx))
+
+	// What a changelog written before tracking looks like
+	_, err := db.Collection("mongockChangeLog").UpdateOne(s.ctx,
+		bson.M{"changeId": "V007_CreateCartOutbox"}, bson.M{"$unset": bson.M{"created": ""}})
+	s.Require().NoError(err)
+
+	s.ErrorIs(runner.Down(s.ctx, migration.Options{}), migration.ErrUntracked)
+	s.ErrorIs(runner.Down(s.ctx, migration.Options{DryRun: true}), migration.ErrUntracked)
+	statuses, err := runner.Status(s.ctx)
+	s.Require().NoError(err)
+	s.Equal("EXECUTED", statuses[0].State, "a refused rollback records nothing")
+
+	s.Require().NoError(runner.Down(s.ctx, migration.Options{Force: true}))
+	statuses, err = runner.Status(s.ctx)
+	s.Require().NoError(err)
+	s.Equal("ROLLED_BACK", statuses[0].State)
+}
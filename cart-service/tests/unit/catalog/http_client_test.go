@@ -0,0 +1,81 @@
+package catalog_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/emart/cart-service/internal/catalog"
+	"github.com/stretchr/testify/assert"
+)
+
+func newCatalogServer(t *testing.T, status int, body string) (*httptest.Server, *http.Request) {
+	t.Helper()
+	var seen http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = *r
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &seen
+}
+
+func TestHTTPClient_ParsesBooksServiceResponse(t *testing.T) {
+	// node-postgres returns BIGSERIAL and DECIMAL columns as strings
+	srv, seen := newCatalogServer(t, http.StatusOK, `{"success":true,"message":"Book retrieved","data":{
+		"id":"7","name":"Clean Code","author":"Robert C. Martin","cost":"699.00","stock":50,
+		"image_url":"https://img/clean-code.png","is_active":true}}`)
+	client := catalog.NewHTTPClient(catalog.Endpoints{Books: srv.URL}, "", time.Second)
+
+	ctx := catalog.WithBearerToken(context.Background(), "user-jwt")
+	product, err := client.GetProduct(ctx, "books", "7")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "/api/v1/books/7", seen.URL.Path)
+	assert.Equal(t, "Bearer user-jwt", seen.Header.Get("Authorization"))
+	assert.Equal(t, "Clean Code", product.Name)
+	assert.Equal(t, "699.00", product.Price.String())
+	assert.Equal(t, 50, product.Stock)
+	assert.True(t, product.Active)
+}
+
+func TestHTTPClient_ParsesNumericCourseCost(t *testing.T) {
+	srv, seen := newCatalogServer(t, http.StatusOK, `{"success":true,"data":{
+		"id":3,"name":"Python Bootcamp","cost":499,"stock":200,"image_url":null,"is_active":true}}`)
+	client := catalog.NewHTTPClient(catalog.Endpoints{Courses: srv.URL}, "svc-token", time.Second)
+
+	product, err := client.GetProduct(context.Background(), "courses", "3")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "/api/v1/courses/3", seen.URL.Path)
+	assert.Equal(t, "Bearer svc-token", seen.Header.Get("Authorization"))
+	assert.Equal(t, "499.00", product.Price.String())
+	assert.Empty(t, product.ImageURL)
+}
+
+func TestHTTPClient_MapsNotFound(t *testing.T) {
+	srv, _ := newCatalogServer(t, http.StatusNotFound, `{"success":false,"message":"Book with ID 9 not found"}`)
+	client := catalog.NewHTTPClient(catalog.Endpoints{Books: srv.URL}, "", time.Second)
+
+	_, err := client.GetProduct(context.Background(), "books", "9")
+	assert.ErrorIs(t, err, catalog.ErrProductNotFound)
+}
+
+func TestHTTPClient_UnconfiguredCategoryIsUnavailable(t *testing.T) {
+	client := catalog.NewHTTPClient(catalog.Endpoints{}, "", time.Second)
+
+	_, err := client.GetProduct(context.Background(), "software", "1")
+	assert.ErrorIs(t, err, catalog.ErrCatalogUnavailable)
+}
+
+func TestHTTPClient_ServerErrorIsUnavailable(t *testing.T) {
+	srv, _ := newCatalogServer(t, http.StatusBadGateway, `{}`)
+	client := catalog.NewHTTPClient(catalog.Endpoints{Books: srv.URL}, "", time.Second)
+
+	_, err := client.GetProduct(context.Background(), "books", "1")
+	assert.ErrorIs(t, err, catalog.ErrCatalogUnavailable)
+}
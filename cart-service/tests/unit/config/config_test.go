@@ -0,0 +1,29 @@
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/emart/cart-service/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	assert.Equal(t, 15*time.Second, cfg.Stream.Heartbeat)
+}
+
+func TestLoad_RejectsNonPositiveIntervals(t *testing.T) {
+	for _, key := range []string{"EVENTS_RELAY_INTERVAL", "ABANDONED_CART_INTERVAL", "STREAM_HEARTBEAT"} {
+		for _, val := range []string{"0s", "-5s"} {
+			t.Run(key+"="+val, func(t *testing.T) {
+				t.Setenv(key, val)
+				_, err := config.Load()
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), key)
+			})
+		}
+	}
+}
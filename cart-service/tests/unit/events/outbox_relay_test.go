@@ -0,0 +1,138 @@
+package events_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/emart/cart-service/internal/config"
+	"github.com/emart/cart-service/internal/events"
+	"github.com/emart/cart-service/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+// ============================================================
+// Mocks
+// ============================================================
+
+type MockOutboxRepo struct{ mock.Mock }
+
+func (m *MockOutboxRepo) Enqueue(ctx context.Context, events ...model.CartEvent) error {
+	return m.Called(ctx, events).Error(0)
+}
+func (m *MockOutboxRepo) FetchPending(ctx context.Context, limit int) ([]model.CartEvent, error) {
+	args := m.Called(ctx, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.CartEvent), args.Error(1)
+}
+func (m *MockOutboxRepo) MarkPublished(ctx context.Context, eventIDs ...string) error {
+	return m.Called(ctx, eventIDs).Error(0)
+}
+func (m *MockOutboxRepo) MarkFailed(ctx context.Context, eventID string, cause error) error {
+	return m.Called(ctx, eventID, cause).Error(0)
+}
+func (m *MockOutboxRepo) AcquireLease(ctx context.Context, owner string, ttl time.Duration) (bool, error) {
+	args := m.Called(ctx, owner, ttl)
+	return args.Bool(0), args.Error(1)
+}
+func (m *MockOutboxRepo) ReleaseLease(ctx context.Context, owner string) error {
+	return m.Called(ctx, owner).Error(0)
+}
+
+// holdLease makes the relay under test the lease holder
+func (m *MockOutboxRepo) holdLease(held bool) {
+	m.On("AcquireLease", mock.Anything, mock.Anything, mock.Anything).Return(held, nil)
+	m.On("ReleaseLease", mock.Anything, mock.Anything).Return(nil)
+}
+
+type MockPublisher struct{ mock.Mock }
+
+func (m *MockPublisher) Publish(ctx context.Context, events ...model.CartEvent) error {
+	return m.Called(ctx, events).Error(0)
+}
+
+// ============================================================
+// Unit Tests
+// ============================================================
+
+func runRelay(t *testing.T, outbox *MockOutboxRepo, publisher *MockPublisher) {
+	t.Helper()
+	logger, _ := zap.NewDevelopment()
+	cfg := &config.Config{Events: config.EventsConfig{RelayInterval: 10 * time.Millisecond, RelayBatchSize: 10}}
+	relay := events.NewOutboxRelay(outbox, publisher, cfg, logger)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	relay.Start(ctx)
+}
+
+func TestOutboxRelay_PublishesPendingAndMarksThem(t *testing.T) {
+	outbox := new(MockOutboxRepo)
+	outbox.holdLease(true)
+	publisher := new(MockPublisher)
+
+	pending := []model.CartEvent{
+		{ID: "e1", Type: model.EventItemAdded, UserID: "u1"},
+		{ID: "e2", Type: model.EventItemRemoved, UserID: "u1"},
+	}
+	outbox.On("FetchPending", mock.Anything, 10).Return(pending, nil).Once()
+	outbox.On("FetchPending", mock.Anything, 10).Return(nil, nil)
+	outbox.On("MarkPublished", mock.Anything, []string{"e1", "e2"}).Return(nil)
+	publisher.On("Publish", mock.Anything, mock.Anything).Return(nil)
+
+	runRelay(t, outbox, publisher)
+
+	publisher.AssertNumberOfCalls(t, "Publish", 2)
+	outbox.AssertCalled(t, "MarkPublished", mock.Anything, []string{"e1", "e2"})
+}
+
+func TestOutboxRelay_StopsAtFirstFailureToKeepOrder(t *testing.T) {
+	outbox := new(MockOutboxRepo)
+	outbox.holdLease(true)
+	publisher := new(MockPublisher)
+
+	pending := []model.CartEvent{{ID: "e1"}, {ID: "e2"}, {ID: "e3"}}
+	outbox.On("FetchPending", mock.Anything, 10).Return(pending, nil)
+	outbox.On("MarkPublished", mock.Anything, mock.Anything).Return(nil)
+	outbox.On("MarkFailed", mock.Anything, "e2", assert.AnError).Return(nil)
+	publisher.On("Publish", mock.Anything, []model.CartEvent{{ID: "e1"}}).Return(nil)
+	publisher.On("Publish", mock.Anything, []model.CartEvent{{ID: "e2"}}).Return(assert.AnError) // Redis down
+
+	runRelay(t, outbox, publisher)
+
+	outbox.AssertCalled(t, "MarkPublished", mock.Anything, []string{"e1"})
+	outbox.AssertCalled(t, "MarkFailed", mock.Anything, "e2", assert.AnError)
+	publisher.AssertNotCalled(t, "Publish", mock.Anything, []model.CartEvent{{ID: "e3"}})
+}
+
+func TestOutboxRelay_LeavesTheOutboxToTheLeaseHolder(t *testing.T) {
+	outbox := new(MockOutboxRepo)
+	outbox.holdLease(false) // another replica is relaying
+	publisher := new(MockPublisher)
+
+	runRelay(t, outbox, publisher)
+
+	outbox.AssertCalled(t, "AcquireLease", mock.Anything, mock.Anything, 30*time.Second)
+	outbox.AssertNotCalled(t, "FetchPending", mock.Anything, mock.Anything)
+	publisher.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything)
+}
+
+func TestOutboxRelay_ReleasesTheLeaseOnStop(t *testing.T) {
+	outbox := new(MockOutboxRepo)
+	outbox.holdLease(true)
+	outbox.On("FetchPending", mock.Anything, 10).Return(nil, nil)
+
+	runRelay(t, outbox, new(MockPublisher))
+
+	outbox.AssertCalled(t, "ReleaseLease", mock.Anything, mock.Anything)
+}
+
+func TestIsTransactional_OnlyForTheOutbox(t *testing.T) {
+	assert.True(t, events.IsTransactional(events.NewOutboxPublisher(new(MockOutboxRepo))))
+	assert.False(t, events.IsTransactional(events.NewNoopPublisher()))
+	assert.False(t, events.IsTransactional(new(MockPublisher)))
+}
@@ -0,0 +1,117 @@
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/emart/cart-service/internal/handler"
+	"github.com/emart/cart-service/internal/model"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+type MockAdminService struct{ mock.Mock }
+
+func (m *MockAdminService) GetCart(ctx context.Context, actor model.Actor, userID string) (*model.Cart, error) {
+	args := m.Called(ctx, actor, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Cart), args.Error(1)
+}
+func (m *MockAdminService) ListCarts(ctx context.Context, actor model.Actor, filter model.AdminCartFilter) (*model.AdminCartPage, error) {
+	args := m.Called(ctx, actor, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.AdminCartPage), args.Error(1)
+}
+func (m *MockAdminService) UpdateItemQuantity(ctx context.Context, actor model.Actor, userID, itemID string, quantity int) (*model.Cart, error) {
+	args := m.Called(ctx, actor, userID, itemID, quantity)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Cart), args.Error(1)
+}
+func (m *MockAdminService) RemoveItem(ctx context.Context, actor model.Actor, userID, itemID string) (*model.Cart, error) {
+	args := m.Called(ctx, actor, userID, itemID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Cart), args.Error(1)
+}
+func (m *MockAdminService) ClearCart(ctx context.Context, actor model.Actor, userID string) error {
+	return m.Called(ctx, actor, userID).Error(0)
+}
+func (m *MockAdminService) AuditLog(ctx context.Context, actor model.Actor, userID string) ([]*model.AuditEntry, error) {
+	args := m.Called(ctx, actor, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.AuditEntry), args.Error(1)
+}
+
+func setupAdminRouter(svc *MockAdminService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	// Inject the staff identity as if JWT and role middleware ran
+	r.Use(func(c *gin.Context) { c.Set("user_id", "staff-1"); c.Set("email", "agent@emart.example"); c.Next() })
+	h := handler.NewAdminHandler(svc, zap.NewNop())
+	h.RegisterRoutes(r.Group("/api/v1"))
+	return r
+}
+
+// staff matches the actor built from the injected identity
+var staff = mock.MatchedBy(func(a model.Actor) bool { return a.UserID == "staff-1" && a.Email == "agent@emart.example" })
+
+func TestAdminListCarts_BindsFilterFromQuery(t *testing.T) {
+	svc := new(MockAdminService)
+	after := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	svc.On("ListCarts", mock.Anything, staff, mock.MatchedBy(func(f model.AdminCartFilter) bool {
+		return f.Status == model.AdminCartsAbandoned && f.Limit == 20 && f.UpdatedAfter != nil && f.UpdatedAfter.Equal(after)
+	})).Return(&model.AdminCartPage{Carts: []*model.Cart{}, Limit: 20}, nil)
+
+	w := httptest.NewRecorder()
+	setupAdminRouter(svc).ServeHTTP(w, httptest.NewRequest(http.MethodGet,
+		"/api/v1/admin/carts?status=abandoned&limit=20&updated_after=2026-01-02T00:00:00Z", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	svc.AssertExpectations(t)
+}
+
+func TestAdminListCarts_Returns400_ForUnknownStatus(t *testing.T) {
+	svc := new(MockAdminService)
+	svc.On("ListCarts", mock.Anything, staff, mock.Anything).Return(nil, model.ErrInvalidAdminFilter)
+
+	w := httptest.NewRecorder()
+	setupAdminRouter(svc).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/carts?status=gone", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestAdminUpdateItem_ActsOnCustomerFromPath(t *testing.T) {
+	svc := new(MockAdminService)
+	svc.On("UpdateItemQuantity", mock.Anything, staff, "customer-1", "item-a", 3).Return(&model.Cart{UserID: "customer-1"}, nil)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/admin/carts/customer-1/items/item-a", bytes.NewBufferString(`{"quantity":3}`))
+	req.Header.Set("Content-Type", "application/json")
+	setupAdminRouter(svc).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	svc.AssertExpectations(t)
+}
+
+func TestAdminClearCart_Returns423_WhenLocked(t *testing.T) {
+	svc := new(MockAdminService)
+	svc.On("ClearCart", mock.Anything, staff, "customer-2").Return(model.ErrCartLocked)
+
+	w := httptest.NewRecorder()
+	setupAdminRouter(svc).ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/v1/admin/carts/customer-2", nil))
+	assert.Equal(t, http.StatusLocked, w.Code)
+}
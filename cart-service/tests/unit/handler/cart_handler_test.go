@@ -4,12 +4,15 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/emart/cart-service/internal/catalog"
 	"github.com/emart/cart-service/internal/handler"
 	"github.com/emart/cart-service/internal/model"
+	"github.com/emart/cart-service/internal/promotion"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -47,6 +50,58 @@ func (m *MockCartService) ClearCart(ctx context.Context, userID string) error {
 	return m.Called(ctx, userID).Error(0)
 }
 
+func (m *MockCartService) MergeCart(ctx context.Context, userID, guestCartID string, strategy model.MergeStrategy) (*model.Cart, error) {
+	args := m.Called(ctx, userID, guestCartID, strategy)
+	if args.Get(0) == nil { return nil, args.Error(1) }
+	return args.Get(0).(*model.Cart), args.Error(1)
+}
+
+func (m *MockCartService) ApplyCoupon(ctx context.Context, userID, code string) (*model.Cart, error) {
+	args := m.Called(ctx, userID, code)
+	if args.Get(0) == nil { return nil, args.Error(1) }
+	return args.Get(0).(*model.Cart), args.Error(1)
+}
+
+func (m *MockCartService) RemoveCoupon(ctx context.Context, userID, code string) (*model.Cart, error) {
+	args := m.Called(ctx, userID, code)
+	if args.Get(0) == nil { return nil, args.Error(1) }
+	return args.Get(0).(*model.Cart), args.Error(1)
+}
+
+func (m *MockCartService) CheckoutCart(ctx context.Context, userID, orderID string) error {
+	return m.Called(ctx, userID, orderID).Error(0)
+}
+
+func (m *MockCartService) CreateCheckoutSession(ctx context.Context, userID string) (*model.CheckoutSession, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil { return nil, args.Error(1) }
+	return args.Get(0).(*model.CheckoutSession), args.Error(1)
+}
+
+func (m *MockCartService) GetCheckoutSession(ctx context.Context, userID, sessionID string) (*model.CheckoutSession, error) {
+	args := m.Called(ctx, userID, sessionID)
+	if args.Get(0) == nil { return nil, args.Error(1) }
+	return args.Get(0).(*model.CheckoutSession), args.Error(1)
+}
+
+func (m *MockCartService) CompleteCheckoutSession(ctx context.Context, userID, sessionID, orderID string) (*model.CheckoutSession, error) {
+	args := m.Called(ctx, userID, sessionID, orderID)
+	if args.Get(0) == nil { return nil, args.Error(1) }
+	return args.Get(0).(*model.CheckoutSession), args.Error(1)
+}
+
+func (m *MockCartService) CancelCheckoutSession(ctx context.Context, userID, sessionID string) (*model.CheckoutSession, error) {
+	args := m.Called(ctx, userID, sessionID)
+	if args.Get(0) == nil { return nil, args.Error(1) }
+	return args.Get(0).(*model.CheckoutSession), args.Error(1)
+}
+
+// inr builds a Money value in the default currency
+func inr(amount string) model.Money {
+	m, _ := model.ParseMoney(amount, model.DefaultCurrency)
+	return m
+}
+
 func setupRouter(svc *MockCartService) *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
@@ -75,9 +130,43 @@ func TestGetCartHandler_Returns200(t *testing.T) {
 	assert.True(t, resp["success"].(bool))
 }
 
+func TestGetCartHandler_Returns304_WhenETagMatches(t *testing.T) {
+	svc := new(MockCartService)
+	cart := &model.Cart{UserID: "test-user-123", Items: []model.CartItem{}, Version: 2}
+	svc.On("GetCart", mock.Anything, "test-user-123").Return(cart, nil)
+	r := setupRouter(svc)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/cart", nil))
+	etag := w.Header().Get("ETag")
+	assert.Equal(t, cart.ETag(), etag)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/cart", nil)
+	req.Header.Set("If-None-Match", `"older", W/`+etag)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+}
+
+func TestGetCartSummaryHandler_Returns200_WhenETagIsStale(t *testing.T) {
+	svc := new(MockCartService)
+	svc.On("GetCartSummary", mock.Anything, "test-user-123").Return(&model.CartSummary{UserID: "test-user-123", ETag: `"current"`}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/cart/summary", nil)
+	req.Header.Set("If-None-Match", `"older"`)
+	w := httptest.NewRecorder()
+	setupRouter(svc).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"current"`, w.Header().Get("ETag"))
+}
+
 func TestAddItemHandler_Returns200_WithValidRequest(t *testing.T) {
 	svc := new(MockCartService)
-	cart := &model.Cart{UserID: "test-user-123", TotalItems: 1, TotalPrice: 29.99}
+	cart := &model.Cart{UserID: "test-user-123", TotalItems: 1, TotalPrice: inr("29.99")}
 	svc.On("AddItem", mock.Anything, "test-user-123", mock.Anything).Return(cart, nil)
 
 	body, _ := json.Marshal(map[string]interface{}{
@@ -142,6 +231,31 @@ func TestRemoveItemHandler_Returns200(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 }
 
+func TestUpdateItemQuantityHandler_Returns422_WhenOutOfStock(t *testing.T) {
+	svc := new(MockCartService)
+	svc.On("UpdateItemQuantity", mock.Anything, "test-user-123", "item-abc", 50).
+		Return((*model.Cart)(nil), fmt.Errorf("%w: 10 of Go Programming available", model.ErrInsufficientStock))
+
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/cart/items/item-abc", bytes.NewBufferString(`{"quantity":50}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	setupRouter(svc).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
+
+func TestRemoveItemHandler_Returns412_WhenIfMatchIsStale(t *testing.T) {
+	svc := new(MockCartService)
+	svc.On("RemoveItem", mock.Anything, "test-user-123", "item-abc").Return((*model.Cart)(nil), model.ErrPreconditionFailed)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/cart/items/item-abc", nil)
+	req.Header.Set("If-Match", `"stale"`)
+	w := httptest.NewRecorder()
+	setupRouter(svc).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+}
+
 func TestClearCartHandler_Returns200(t *testing.T) {
 	svc := new(MockCartService)
 	svc.On("ClearCart", mock.Anything, "test-user-123").Return(nil)
@@ -153,3 +267,305 @@ func TestClearCartHandler_Returns200(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, w.Code)
 }
+
+func TestAddItemHandler_Returns409_OnVersionConflict(t *testing.T) {
+	svc := new(MockCartService)
+	svc.On("AddItem", mock.Anything, "test-user-123", mock.Anything).Return(nil, model.ErrVersionConflict)
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"product_id": "book-001", "product_name": "Go Book",
+		"category": "books", "price": 29.99, "quantity": 1,
+	})
+
+	r := setupRouter(svc)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/cart/items", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestAddItemHandler_Returns404_UnknownProduct(t *testing.T) {
+	svc := new(MockCartService)
+	svc.On("AddItem", mock.Anything, "test-user-123", mock.Anything).Return(nil, catalog.ErrProductNotFound)
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"product_id": "book-999", "category": "books", "quantity": 1,
+	})
+
+	r := setupRouter(svc)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/cart/items", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestMergeCartHandler_Returns200(t *testing.T) {
+	svc := new(MockCartService)
+	cart := &model.Cart{UserID: "test-user-123", Items: []model.CartItem{}}
+	svc.On("MergeCart", mock.Anything, "test-user-123", "guest:g1", model.MergeKeepNewest).Return(cart, nil)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("user_id", "test-user-123")
+		c.Set("guest_cart_id", "guest:g1")
+		c.Next()
+	})
+	logger, _ := zap.NewDevelopment()
+	handler.NewCartHandler(svc, logger).RegisterRoutes(r.Group("/api/v1"))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/cart/merge", bytes.NewBufferString(`{"strategy":"newest"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	svc.AssertExpectations(t)
+}
+
+func TestMergeCartHandler_Returns422_WhenOutOfStock(t *testing.T) {
+	svc := new(MockCartService)
+	svc.On("MergeCart", mock.Anything, "test-user-123", "guest:g1", model.MergeStrategy("")).
+		Return((*model.Cart)(nil), fmt.Errorf("%w: 10 of Go Programming available", model.ErrInsufficientStock))
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("user_id", "test-user-123")
+		c.Set("guest_cart_id", "guest:g1")
+		c.Next()
+	})
+	logger, _ := zap.NewDevelopment()
+	handler.NewCartHandler(svc, logger).RegisterRoutes(r.Group("/api/v1"))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/cart/merge", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
+
+func TestMergeCartHandler_Returns400_WithoutGuestToken(t *testing.T) {
+	svc := new(MockCartService)
+
+	r := setupRouter(svc)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/cart/merge", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	svc.AssertNotCalled(t, "MergeCart", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestApplyCouponHandler_Returns200(t *testing.T) {
+	svc := new(MockCartService)
+	cart := &model.Cart{
+		UserID: "test-user-123", TotalPrice: inr("100.00"), Coupons: []string{"SAVE10"},
+		DiscountTotal: inr("10.00"), GrandTotal: inr("90.00"),
+	}
+	svc.On("ApplyCoupon", mock.Anything, "test-user-123", "save10").Return(cart, nil)
+
+	r := setupRouter(svc)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/cart/coupons", bytes.NewBufferString(`{"code":"save10"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"grand_total":"90.00"`)
+}
+
+func TestApplyCouponHandler_Returns422_WhenMinSpendNotMet(t *testing.T) {
+	svc := new(MockCartService)
+	svc.On("ApplyCoupon", mock.Anything, "test-user-123", "BIG500").Return(nil, promotion.ErrMinSpendNotMet)
+
+	r := setupRouter(svc)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/cart/coupons", bytes.NewBufferString(`{"code":"BIG500"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
+
+func TestApplyCouponHandler_Returns404_UnknownCoupon(t *testing.T) {
+	svc := new(MockCartService)
+	svc.On("ApplyCoupon", mock.Anything, "test-user-123", "NOPE").Return(nil, promotion.ErrCouponNotFound)
+
+	r := setupRouter(svc)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/cart/coupons", bytes.NewBufferString(`{"code":"NOPE"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRemoveCouponHandler_Returns200(t *testing.T) {
+	svc := new(MockCartService)
+	svc.On("RemoveCoupon", mock.Anything, "test-user-123", "SAVE10").Return(&model.Cart{UserID: "test-user-123"}, nil)
+
+	r := setupRouter(svc)
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/cart/coupons/SAVE10", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	svc.AssertExpectations(t)
+}
+
+func TestCheckoutCartHandler_Returns200(t *testing.T) {
+	svc := new(MockCartService)
+	svc.On("CheckoutCart", mock.Anything, "test-user-123", "order-1").Return(nil)
+
+	r := setupRouter(svc)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/cart/checkout", bytes.NewBufferString(`{"order_id":"order-1"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	svc.AssertExpectations(t)
+}
+
+func TestCheckoutCartHandler_Returns422_WhenCartEmpty(t *testing.T) {
+	svc := new(MockCartService)
+	svc.On("CheckoutCart", mock.Anything, "test-user-123", "order-2").Return(model.ErrCartEmpty)
+
+	r := setupRouter(svc)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/cart/checkout", bytes.NewBufferString(`{"order_id":"order-2"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
+
+func TestCheckoutCartHandler_Returns409_WhenCouponUsedUp(t *testing.T) {
+	svc := new(MockCartService)
+	svc.On("CheckoutCart", mock.Anything, "test-user-123", "order-3").
+		Return(fmt.Errorf("redeem coupon ONCE: %w", promotion.ErrUsageLimitReached))
+
+	r := setupRouter(svc)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/cart/checkout", bytes.NewBufferString(`{"order_id":"order-3"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestAddItemHandler_Returns423_WhenCartLocked(t *testing.T) {
+	svc := new(MockCartService)
+	svc.On("AddItem", mock.Anything, "test-user-123", mock.Anything).Return(nil, model.ErrCartLocked)
+
+	body := `{"product_id":"book-001","category":"books","quantity":1}`
+	r := setupRouter(svc)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/cart/items", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusLocked, w.Code)
+}
+
+func TestCreateCheckoutSessionHandler_Returns201(t *testing.T) {
+	svc := new(MockCartService)
+	session := &model.CheckoutSession{ID: "sess-1", UserID: "test-user-123", Status: model.CheckoutActive}
+	svc.On("CreateCheckoutSession", mock.Anything, "test-user-123").Return(session, nil)
+
+	r := setupRouter(svc)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/cart/checkout-session", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var resp map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.Equal(t, "sess-1", resp["data"].(map[string]interface{})["id"])
+}
+
+// setupServiceRouter serves the payment-service routes as if JWT and role middleware ran
+func setupServiceRouter(svc *MockCartService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("user_id", "payment-service"); c.Next() })
+	handler.NewCartHandler(svc, zap.NewNop()).RegisterServiceRoutes(r.Group("/api/v1"))
+	return r
+}
+
+func TestCompleteCheckoutSessionHandler_CompletesForTheNamedShopper(t *testing.T) {
+	svc := new(MockCartService)
+	session := &model.CheckoutSession{ID: "sess-1", UserID: "shopper-1", Status: model.CheckoutCompleted, OrderID: "order-1"}
+	svc.On("CompleteCheckoutSession", mock.Anything, "shopper-1", "sess-1", "order-1").Return(session, nil)
+
+	r := setupServiceRouter(svc)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/cart/checkout-session/sess-1/complete", bytes.NewBufferString(`{"order_id":"order-1","user_id":"shopper-1"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	svc.AssertExpectations(t)
+}
+
+func TestCompleteCheckoutSessionHandler_RequiresShopper(t *testing.T) {
+	r := setupServiceRouter(new(MockCartService))
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/cart/checkout-session/sess-1/complete", bytes.NewBufferString(`{"order_id":"order-1"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCompleteCheckoutSessionHandler_NotOnShopperRoutes(t *testing.T) {
+	r := setupRouter(new(MockCartService))
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/cart/checkout-session/sess-1/complete", bytes.NewBufferString(`{"order_id":"order-1"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code, "a shopper's token must not complete its own checkout")
+}
+
+func TestCompleteCheckoutSessionHandler_MapsSessionErrors(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{model.ErrCheckoutSessionNotFound, http.StatusNotFound},
+		{model.ErrCheckoutSessionExpired, http.StatusGone},
+		{model.ErrCheckoutSessionClosed, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		svc := new(MockCartService)
+		svc.On("CompleteCheckoutSession", mock.Anything, "shopper-1", "sess-1", "order-1").Return(nil, tt.err)
+
+		r := setupServiceRouter(svc)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/cart/checkout-session/sess-1/complete", bytes.NewBufferString(`{"order_id":"order-1","user_id":"shopper-1"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, tt.want, w.Code, tt.err.Error())
+	}
+}
+
+func TestCancelCheckoutSessionHandler_Returns200(t *testing.T) {
+	svc := new(MockCartService)
+	session := &model.CheckoutSession{ID: "sess-1", UserID: "test-user-123", Status: model.CheckoutCancelled}
+	svc.On("CancelCheckoutSession", mock.Anything, "test-user-123", "sess-1").Return(session, nil)
+
+	r := setupRouter(svc)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/cart/checkout-session/sess-1/cancel", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	svc.AssertExpectations(t)
+}
@@ -0,0 +1,149 @@
+package handler_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emart/cart-service/internal/handler"
+	"github.com/emart/cart-service/internal/model"
+	"github.com/emart/cart-service/internal/realtime"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type sseEvent struct {
+	id, event, data string
+}
+
+// startStreamServer serves the stream over a real connection; events are
+// read from the response as they are flushed
+func startStreamServer(t *testing.T, svc *MockCartService, broker *realtime.MemoryBroker, heartbeat time.Duration) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("user_id", "test-user-123"); c.Next() })
+	handler.NewStreamHandler(svc, broker, heartbeat, zap.NewNop()).RegisterRoutes(r.Group("/api/v1"))
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func openStream(t *testing.T, srv *httptest.Server, lastEventID string) (*http.Response, chan sseEvent) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/v1/cart/stream", nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+
+	events := make(chan sseEvent, 16)
+	go func() {
+		defer close(events)
+		var ev sseEvent
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				if ev != (sseEvent{}) {
+					events <- ev
+				}
+				ev = sseEvent{}
+			case strings.HasPrefix(line, ":"):
+				events <- sseEvent{event: "comment"}
+			case strings.HasPrefix(line, "id: "):
+				ev.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				ev.event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				ev.data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}()
+	return resp, events
+}
+
+func nextEvent(t *testing.T, events chan sseEvent) sseEvent {
+	t.Helper()
+	select {
+	case ev, ok := <-events:
+		require.True(t, ok, "stream ended")
+		return ev
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event within 2s")
+		return sseEvent{}
+	}
+}
+
+func TestStreamCart_SendsCurrentCartThenChanges(t *testing.T) {
+	svc := new(MockCartService)
+	cart := &model.Cart{UserID: "test-user-123", Version: 1, UpdatedAt: time.Now(), TotalItems: 1}
+	svc.On("GetCart", mock.Anything, "test-user-123").Return(cart, nil)
+	broker := realtime.NewMemoryBroker(0)
+	resp, events := openStream(t, startStreamServer(t, svc, broker, time.Minute), "")
+
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	first := nextEvent(t, events)
+	assert.Equal(t, "cart", first.event)
+	assert.Equal(t, strings.Trim(cart.ETag(), `"`), first.id)
+	summary := nextEvent(t, events)
+	assert.Equal(t, "summary", summary.event)
+	var got model.CartSummary
+	require.NoError(t, json.Unmarshal([]byte(summary.data), &got))
+	assert.Equal(t, 1, got.TotalItems)
+
+	changed := &model.Cart{UserID: "test-user-123", Version: 2, UpdatedAt: cart.UpdatedAt.Add(time.Second), TotalItems: 3}
+	broker.PublishCart(context.Background(), changed)
+	update := nextEvent(t, events)
+	assert.Equal(t, "cart", update.event)
+	assert.Equal(t, strings.Trim(changed.ETag(), `"`), update.id)
+}
+
+func TestStreamCart_ReconnectWithCurrentIDSkipsSnapshot(t *testing.T) {
+	svc := new(MockCartService)
+	cart := &model.Cart{UserID: "test-user-123", Version: 4, UpdatedAt: time.Now()}
+	svc.On("GetCart", mock.Anything, "test-user-123").Return(cart, nil)
+	_, events := openStream(t, startStreamServer(t, svc, realtime.NewMemoryBroker(0), 50*time.Millisecond), strings.Trim(cart.ETag(), `"`))
+
+	assert.Equal(t, "comment", nextEvent(t, events).event, "only heartbeats until the cart changes")
+}
+
+func TestStreamCart_EndsWhenBrokerCloses(t *testing.T) {
+	svc := new(MockCartService)
+	svc.On("GetCart", mock.Anything, "test-user-123").Return(&model.Cart{UserID: "test-user-123"}, nil)
+	broker := realtime.NewMemoryBroker(0)
+	_, events := openStream(t, startStreamServer(t, svc, broker, time.Minute), "")
+	nextEvent(t, events)
+	nextEvent(t, events)
+
+	broker.Close()
+
+	select {
+	case _, ok := <-events:
+		assert.False(t, ok)
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream still open after shutdown")
+	}
+}
+
+func TestStreamCart_Returns429_WhenTooManyTabsAreOpen(t *testing.T) {
+	svc := new(MockCartService)
+	broker := realtime.NewMemoryBroker(1)
+	sub, _ := broker.Subscribe(context.Background(), "test-user-123")
+	defer sub.Close()
+
+	resp, _ := openStream(t, startStreamServer(t, svc, broker, time.Minute), "")
+
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+}
@@ -0,0 +1,153 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/emart/cart-service/internal/metrics"
+	"github.com/emart/cart-service/internal/migration"
+	"github.com/emart/cart-service/internal/model"
+	mongorepo "github.com/emart/cart-service/internal/repository/mongo"
+	redisrepo "github.com/emart/cart-service/internal/repository/redis"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+// Only the methods the wrappers instrument are mocked; the rest are promoted
+// from the nil embedded interface and must not be called.
+type MockRedisRepo struct {
+	redisrepo.CartRedisRepository
+	mock.Mock
+}
+
+func (m *MockRedisRepo) GetCart(ctx context.Context, userID string) (*model.Cart, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Cart), args.Error(1)
+}
+
+type MockMongoRepo struct {
+	mongorepo.CartMongoRepository
+	mock.Mock
+}
+
+func (m *MockMongoRepo) GetCart(ctx context.Context, userID string) (*model.Cart, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Cart), args.Error(1)
+}
+func (m *MockMongoRepo) UpsertCart(ctx context.Context, cart *model.Cart) error {
+	return m.Called(ctx, cart).Error(0)
+}
+
+// scrape returns the exposition text served by the metrics handler
+func scrape(t *testing.T, m *metrics.Metrics) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	return w.Body.String()
+}
+
+func TestInstrumentRedis_CountsHitsMissesAndErrors(t *testing.T) {
+	m := metrics.New()
+	redisRepo := new(MockRedisRepo)
+	redisRepo.On("GetCart", mock.Anything, "hit").Return(&model.Cart{UserID: "hit"}, nil)
+	redisRepo.On("GetCart", mock.Anything, "miss").Return(nil, nil)
+	redisRepo.On("GetCart", mock.Anything, "down").Return(nil, errors.New("redis down"))
+	repo := m.InstrumentRedis(redisRepo)
+
+	for _, id := range []string{"hit", "hit", "miss", "down"} {
+		repo.GetCart(context.Background(), id)
+	}
+
+	out := scrape(t, m)
+	assert.Contains(t, out, `cart_redis_cache_lookups_total{result="hit"} 2`)
+	assert.Contains(t, out, `cart_redis_cache_lookups_total{result="miss"} 1`)
+	assert.Contains(t, out, `cart_redis_cache_lookups_total{result="error"} 1`)
+}
+
+func TestInstrumentMongo_TimesUpsertsAndSeparatesConflicts(t *testing.T) {
+	m := metrics.New()
+	mongoRepo := new(MockMongoRepo)
+	mongoRepo.On("UpsertCart", mock.Anything, mock.MatchedBy(func(c *model.Cart) bool { return c.UserID == "ok" })).Return(nil)
+	mongoRepo.On("UpsertCart", mock.Anything, mock.MatchedBy(func(c *model.Cart) bool { return c.UserID == "stale" })).Return(model.ErrVersionConflict)
+	mongoRepo.On("UpsertCart", mock.Anything, mock.MatchedBy(func(c *model.Cart) bool { return c.UserID == "down" })).Return(errors.New("mongo down"))
+	mongoRepo.On("GetCart", mock.Anything, "u1").Return(&model.Cart{UserID: "u1"}, nil)
+	repo := m.InstrumentMongo(mongoRepo)
+
+	for _, id := range []string{"ok", "stale", "down"} {
+		repo.UpsertCart(context.Background(), &model.Cart{UserID: id})
+	}
+	repo.GetCart(context.Background(), "u1")
+
+	out := scrape(t, m)
+	assert.Contains(t, out, `cart_mongo_operation_duration_seconds_count{operation="upsert_cart"} 3`)
+	assert.Contains(t, out, `cart_mongo_operation_errors_total{error="conflict",operation="upsert_cart"} 1`)
+	assert.Contains(t, out, `cart_mongo_operation_errors_total{error="error",operation="upsert_cart"} 1`)
+	assert.Contains(t, out, `cart_mongo_fallback_reads_total{result="found"} 1`)
+}
+
+func TestMiddleware_LabelsByRouteTemplate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := metrics.New()
+	r := gin.New()
+	r.Use(m.Middleware())
+	r.DELETE("/api/v1/cart/items/:itemId", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for _, path := range []string{"/api/v1/cart/items/a", "/api/v1/cart/items/b", "/nope"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, path, nil))
+	}
+
+	out := scrape(t, m)
+	assert.Contains(t, out, `cart_http_requests_total{method="DELETE",route="/api/v1/cart/items/:itemId",status="200"} 2`)
+	assert.Contains(t, out, `cart_http_requests_total{method="DELETE",route="unmatched",status="404"} 1`)
+	assert.NotContains(t, out, "/api/v1/cart/items/a")
+}
+
+func TestSyncAndMigrationMetrics(t *testing.T) {
+	m := metrics.New()
+	m.ObserveSync("dirty", 20*time.Millisecond, 3, 1, 2)
+	m.RecordMigrations([]migration.Status{
+		{ID: "V001_CreateCartsCollection", State: "EXECUTED"},
+		{ID: "V009_CreateAdminAuditLog", State: "PENDING"},
+	})
+
+	out := scrape(t, m)
+	assert.Contains(t, out, `cart_sync_carts_total{kind="dirty",result="synced"} 3`)
+	assert.Contains(t, out, `cart_sync_carts_total{kind="dirty",result="failed"} 2`)
+	assert.Contains(t, out, `cart_sync_run_duration_seconds_count{kind="dirty"} 1`)
+	assert.Contains(t, out, `cart_migration_state{id="V001_CreateCartsCollection",state="EXECUTED"} 1`)
+	assert.Contains(t, out, `cart_migration_state{id="V009_CreateAdminAuditLog",state="EXECUTED"} 0`)
+	assert.Contains(t, out, `cart_migration_state{id="V009_CreateAdminAuditLog",state="PENDING"} 1`)
+}
+
+func TestTrackActiveCarts_RefreshesUntilCancelled(t *testing.T) {
+	m := metrics.New()
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	done := make(chan struct{})
+	go func() {
+		m.TrackActiveCarts(ctx, 10*time.Millisecond, func(context.Context) (int64, error) {
+			calls++
+			return 42, nil
+		}, zap.NewNop())
+		close(done)
+	}()
+	time.Sleep(35 * time.Millisecond)
+	cancel()
+	<-done
+
+	assert.GreaterOrEqual(t, calls, 2)
+	assert.Contains(t, scrape(t, m), "cart_active_carts 42")
+}
@@ -0,0 +1,129 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/emart/cart-service/internal/config"
+	"github.com/emart/cart-service/internal/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func corsConfig(origins ...string) config.CORSConfig {
+	return config.CORSConfig{
+		AllowedOrigins:   origins,
+		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type"},
+		ExposedHeaders:   []string{"X-Guest-Token"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+}
+
+func setupCORSRouter(t *testing.T, cfg config.CORSConfig) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	cors, err := middleware.CORSMiddleware(cfg)
+	require.NoError(t, err)
+	r := gin.New()
+	r.Use(cors)
+	r.GET("/api/v1/cart", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return r
+}
+
+func corsRequest(router *gin.Engine, method, origin string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/api/v1/cart", nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	if method == http.MethodOptions {
+		req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCORS_EchoesAllowedOrigin(t *testing.T) {
+	router := setupCORSRouter(t, corsConfig("https://emart.com"))
+
+	w := corsRequest(router, http.MethodGet, "https://emart.com")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "https://emart.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "X-Guest-Token", w.Header().Get("Access-Control-Expose-Headers"))
+	assert.Contains(t, w.Header().Values("Vary"), "Origin")
+}
+
+func TestCORS_PreflightForAllowedOrigin(t *testing.T) {
+	router := setupCORSRouter(t, corsConfig("https://emart.com"))
+
+	w := corsRequest(router, http.MethodOptions, "https://emart.com")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://emart.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "GET,POST,PUT,DELETE,OPTIONS", w.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "Authorization,Content-Type", w.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+	assert.Contains(t, w.Header().Values("Vary"), "Origin")
+}
+
+func TestCORS_OtherOriginsGetNoCORSHeaders(t *testing.T) {
+	router := setupCORSRouter(t, corsConfig("https://emart.com"))
+
+	w := corsRequest(router, http.MethodOptions, "https://evil.example")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	// Simple requests are still served; the browser withholds the response
+	w = corsRequest(router, http.MethodGet, "https://evil.example")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	// Same-origin and non-browser callers send no Origin
+	w = corsRequest(router, http.MethodGet, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Values("Vary"), "Origin")
+}
+
+func TestCORS_WildcardSubdomains(t *testing.T) {
+	router := setupCORSRouter(t, corsConfig("https://*.emart.com"))
+
+	for origin, ok := range map[string]bool{
+		"https://shop.emart.com":      true,
+		"https://eu.shop.emart.com":   true,
+		"https://SHOP.emart.com":      true,
+		"https://emart.com":           false,
+		"http://shop.emart.com":       false,
+		"https://shop.emart.com.evil": false,
+		"https://evil.com/.emart.com": false,
+	} {
+		got := corsRequest(router, http.MethodGet, origin).Header().Get("Access-Control-Allow-Origin")
+		if ok {
+			assert.Equal(t, origin, got, origin)
+		} else {
+			assert.Empty(t, got, origin)
+		}
+	}
+}
+
+func TestCORS_AnyOriginWithoutCredentials(t *testing.T) {
+	cfg := corsConfig("*")
+	cfg.AllowCredentials = false
+	router := setupCORSRouter(t, cfg)
+
+	w := corsRequest(router, http.MethodGet, "https://anywhere.example")
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+}
+
+func TestCORS_RejectsInvalidPolicies(t *testing.T) {
+	_, err := middleware.CORSMiddleware(corsConfig("*"))
+	assert.Error(t, err, "wildcard origin with credentials")
+
+	_, err = middleware.CORSMiddleware(corsConfig("https://shop*.emart.com"))
+	assert.Error(t, err)
+}
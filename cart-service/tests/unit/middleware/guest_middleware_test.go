@@ -0,0 +1,172 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emart/cart-service/internal/config"
+	"github.com/emart/cart-service/internal/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+const testSecret = "test-secret-at-least-32-characters-long"
+
+func setupIdentityRouter(guests *middleware.GuestTokens) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	verifier, _ := middleware.NewTokenVerifier(&config.Config{JWT: config.JWTConfig{Secret: testSecret}}, zap.NewNop())
+	r.Use(middleware.CartIdentityMiddleware(verifier, guests))
+	whoami := func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"user_id":       c.GetString("user_id"),
+			"is_guest":      c.GetBool("is_guest"),
+			"guest_cart_id": c.GetString("guest_cart_id"),
+		})
+	}
+	r.GET("/whoami", whoami)
+	r.POST("/whoami", whoami)
+	return r
+}
+
+func newGuestTokens() *middleware.GuestTokens {
+	return middleware.NewGuestTokens(testSecret, "emart_guest", time.Hour, false)
+}
+
+func TestGuestTokens_VerifyRoundTrip(t *testing.T) {
+	guests := newGuestTokens()
+	guestID, token := guests.Issue()
+
+	got, err := guests.Verify(token)
+	assert.NoError(t, err)
+	assert.Equal(t, guestID, got)
+}
+
+func TestGuestTokens_RejectsTamperedToken(t *testing.T) {
+	guests := newGuestTokens()
+	_, token := guests.Issue()
+	_, sig, _ := strings.Cut(token, ".")
+
+	_, err := guests.Verify("someone-elses-id." + sig)
+	assert.Error(t, err)
+}
+
+func TestGuestTokens_RejectsExpiredToken(t *testing.T) {
+	expired := middleware.NewGuestTokens(testSecret, "emart_guest", -time.Minute, false)
+	_, token := expired.Issue()
+
+	_, err := newGuestTokens().Verify(token)
+	assert.Error(t, err)
+}
+
+func TestGuestTokens_RejectsExtendedExpiry(t *testing.T) {
+	guests := newGuestTokens()
+	guestID, token := guests.Issue()
+	parts := strings.Split(token, ".")
+	assert.Len(t, parts, 3)
+
+	later := strconv.FormatInt(time.Now().Add(365*24*time.Hour).Unix(), 10)
+	_, err := guests.Verify(guestID + "." + later + "." + parts[2])
+	assert.Error(t, err)
+}
+
+func TestCartIdentity_IssuesGuestTokenWhenAnonymous(t *testing.T) {
+	r := setupIdentityRouter(newGuestTokens())
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/whoami", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, w.Header().Get(middleware.GuestTokenHeader))
+	assert.Contains(t, w.Header().Get("Set-Cookie"), "emart_guest=")
+	assert.Contains(t, w.Body.String(), `"user_id":"guest:`)
+	assert.Contains(t, w.Body.String(), `"is_guest":true`)
+}
+
+func TestCartIdentity_ReusesValidGuestToken(t *testing.T) {
+	guests := newGuestTokens()
+	guestID, token := guests.Issue()
+	r := setupIdentityRouter(guests)
+
+	req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+	req.Header.Set(middleware.GuestTokenHeader, token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Contains(t, w.Body.String(), `"user_id":"guest:`+guestID+`"`)
+	assert.Empty(t, w.Header().Get("Set-Cookie"))
+}
+
+func TestCartIdentity_ExpiredGuestTokenGetsFreshIdentity(t *testing.T) {
+	guestID, token := middleware.NewGuestTokens(testSecret, "emart_guest", -time.Minute, false).Issue()
+	r := setupIdentityRouter(newGuestTokens())
+
+	req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+	req.Header.Set(middleware.GuestTokenHeader, token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), guestID)
+	assert.NotEmpty(t, w.Header().Get(middleware.GuestTokenHeader))
+}
+
+func TestCartIdentity_MutationExtendsGuestToken(t *testing.T) {
+	guests := newGuestTokens()
+	guestID, token := guests.Issue()
+	r := setupIdentityRouter(guests)
+
+	read := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+	read.Header.Set(middleware.GuestTokenHeader, token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, read)
+	assert.Empty(t, w.Header().Get(middleware.GuestTokenHeader), "a read does not extend the cart")
+
+	write := httptest.NewRequest(http.MethodPost, "/whoami", nil)
+	write.Header.Set(middleware.GuestTokenHeader, token)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, write)
+	refreshed := w.Header().Get(middleware.GuestTokenHeader)
+	assert.True(t, strings.HasPrefix(refreshed, guestID+"."), refreshed)
+	got, err := guests.Verify(refreshed)
+	assert.NoError(t, err)
+	assert.Equal(t, guestID, got)
+}
+
+func TestCartIdentity_AuthenticatedUserKeepsGuestCartForMerge(t *testing.T) {
+	guests := newGuestTokens()
+	guestID, guestToken := guests.Issue()
+	r := setupIdentityRouter(guests)
+
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"userId": "user-42", "sub": "u@example.com", "exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(testSecret))
+
+	req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.AddCookie(&http.Cookie{Name: "emart_guest", Value: guestToken})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"user_id":"user-42"`)
+	assert.Contains(t, w.Body.String(), `"is_guest":false`)
+	assert.Contains(t, w.Body.String(), `"guest_cart_id":"guest:`+guestID+`"`)
+}
+
+func TestCartIdentity_InvalidBearerReturns401(t *testing.T) {
+	r := setupIdentityRouter(newGuestTokens())
+
+	req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+	req.Header.Set("Authorization", "Bearer garbage")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,195 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emart/cart-service/internal/config"
+	"github.com/emart/cart-service/internal/middleware"
+	"github.com/emart/cart-service/internal/model"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+type MockIdempotencyStore struct{ mock.Mock }
+
+func (m *MockIdempotencyStore) Reserve(ctx context.Context, key, fingerprint, token string, lockTTL time.Duration) (*model.IdempotencyRecord, error) {
+	args := m.Called(ctx, key, fingerprint, token, lockTTL)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.IdempotencyRecord), args.Error(1)
+}
+func (m *MockIdempotencyStore) Complete(ctx context.Context, key, token string, record *model.IdempotencyRecord, ttl time.Duration) (bool, error) {
+	args := m.Called(ctx, key, token, record, ttl)
+	return args.Bool(0), args.Error(1)
+}
+func (m *MockIdempotencyStore) Release(ctx context.Context, key, token string) error {
+	return m.Called(ctx, key, token).Error(0)
+}
+
+var idempotencyCfg = config.IdempotencyConfig{TTL: time.Hour, LockTTL: time.Minute}
+
+// setupIdempotentRouter counts handler runs; POST /items answers 201 with the
+// request body, POST /fail answers 500
+func setupIdempotentRouter(store *MockIdempotencyStore, runs *int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("user_id", "user-1") })
+	r.Use(middleware.IdempotencyMiddleware(store, idempotencyCfg, zap.NewNop()))
+	r.POST("/items", func(c *gin.Context) {
+		*runs++
+		var req map[string]interface{}
+		c.ShouldBindJSON(&req)
+		c.Header("ETag", `"v1"`)
+		c.JSON(http.StatusCreated, req)
+	})
+	r.POST("/fail", func(c *gin.Context) {
+		*runs++
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse("boom"))
+	})
+	return r
+}
+
+func idempotentRequest(router *gin.Engine, path, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotency_StoresFirstResponse(t *testing.T) {
+	store := new(MockIdempotencyStore)
+	runs := 0
+	store.On("Reserve", mock.Anything, "user-1:k1", mock.Anything, mock.Anything, time.Minute).Return(nil, nil)
+	store.On("Complete", mock.Anything, "user-1:k1", mock.Anything, mock.MatchedBy(func(r *model.IdempotencyRecord) bool {
+		return r.Completed && r.Status == http.StatusCreated && string(r.Body) == `{"qty":1}` && r.Header["ETag"] == `"v1"`
+	}), time.Hour).Return(true, nil)
+
+	w := idempotentRequest(setupIdempotentRouter(store, &runs), "/items", "k1", `{"qty":1}`)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, `{"qty":1}`, w.Body.String(), "the handler still sees the body")
+	assert.Equal(t, 1, runs)
+	store.AssertExpectations(t)
+}
+
+func TestIdempotency_ReplaysStoredResponse(t *testing.T) {
+	store := new(MockIdempotencyStore)
+	runs := 0
+	var fingerprint string
+	store.On("Reserve", mock.Anything, "user-1:k1", mock.Anything, mock.Anything, time.Minute).Return(nil, nil).Once().
+		Run(func(args mock.Arguments) { fingerprint = args.String(2) })
+	store.On("Complete", mock.Anything, "user-1:k1", mock.Anything, mock.Anything, time.Hour).Return(true, nil).Once()
+	router := setupIdempotentRouter(store, &runs)
+	first := idempotentRequest(router, "/items", "k1", `{"qty":1}`)
+
+	store.On("Reserve", mock.Anything, "user-1:k1", mock.Anything, mock.Anything, time.Minute).Return(&model.IdempotencyRecord{
+		Fingerprint: fingerprint, Completed: true, Status: http.StatusCreated,
+		Header: map[string]string{"Content-Type": "application/json; charset=utf-8", "ETag": `"v1"`},
+		Body:   first.Body.Bytes(),
+	}, nil)
+	retry := idempotentRequest(router, "/items", "k1", `{"qty":1}`)
+
+	assert.Equal(t, 1, runs, "the handler must not run again")
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, `"v1"`, retry.Header().Get("ETag"))
+	assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+}
+
+func TestIdempotency_RejectsKeyReusedWithDifferentBody(t *testing.T) {
+	store := new(MockIdempotencyStore)
+	runs := 0
+	store.On("Reserve", mock.Anything, "user-1:k1", mock.Anything, mock.Anything, time.Minute).
+		Return(&model.IdempotencyRecord{Fingerprint: "other-request", Completed: true, Status: http.StatusCreated}, nil)
+
+	w := idempotentRequest(setupIdempotentRouter(store, &runs), "/items", "k1", `{"qty":2}`)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Zero(t, runs)
+}
+
+func TestIdempotency_RejectsDuplicateWhileInProgress(t *testing.T) {
+	store := new(MockIdempotencyStore)
+	runs := 0
+	router := setupIdempotentRouter(store, &runs)
+	var fingerprint string
+	store.On("Reserve", mock.Anything, "user-1:k1", mock.Anything, mock.Anything, time.Minute).Return(nil, nil).Once().
+		Run(func(args mock.Arguments) { fingerprint = args.String(2) })
+	store.On("Complete", mock.Anything, "user-1:k1", mock.Anything, mock.Anything, time.Hour).Return(true, nil).Once()
+	idempotentRequest(router, "/items", "k1", `{"qty":1}`)
+
+	// A retry arriving while the first request still holds the reservation
+	store.On("Reserve", mock.Anything, "user-1:k1", fingerprint, mock.Anything, time.Minute).
+		Return(&model.IdempotencyRecord{Fingerprint: fingerprint}, nil)
+	w := idempotentRequest(router, "/items", "k1", `{"qty":1}`)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Equal(t, 1, runs)
+}
+
+func TestIdempotency_CompletesOnlyItsOwnReservation(t *testing.T) {
+	store := new(MockIdempotencyStore)
+	runs := 0
+	var token string
+	store.On("Reserve", mock.Anything, "user-1:k1", mock.Anything, mock.Anything, time.Minute).Return(nil, nil).
+		Run(func(args mock.Arguments) { token = args.String(3) })
+	// The reservation expired while the handler ran and a retry took the key
+	store.On("Complete", mock.Anything, "user-1:k1", mock.Anything, mock.Anything, time.Hour).Return(false, nil)
+
+	w := idempotentRequest(setupIdempotentRouter(store, &runs), "/items", "k1", `{"qty":1}`)
+
+	assert.Equal(t, http.StatusCreated, w.Code, "the caller still gets its response")
+	assert.NotEmpty(t, token)
+	store.AssertCalled(t, "Complete", mock.Anything, "user-1:k1", token, mock.Anything, time.Hour)
+}
+
+func TestIdempotency_ReleasesKeyOnServerError(t *testing.T) {
+	store := new(MockIdempotencyStore)
+	runs := 0
+	store.On("Reserve", mock.Anything, "user-1:k1", mock.Anything, mock.Anything, time.Minute).Return(nil, nil)
+	store.On("Release", mock.Anything, "user-1:k1", mock.Anything).Return(nil)
+
+	w := idempotentRequest(setupIdempotentRouter(store, &runs), "/fail", "k1", `{}`)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	store.AssertCalled(t, "Release", mock.Anything, "user-1:k1", mock.Anything)
+	store.AssertNotCalled(t, "Complete", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestIdempotency_IgnoresRequestsWithoutKey(t *testing.T) {
+	store := new(MockIdempotencyStore)
+	runs := 0
+	router := setupIdempotentRouter(store, &runs)
+
+	idempotentRequest(router, "/items", "", `{"qty":1}`)
+	idempotentRequest(router, "/items", "", `{"qty":1}`)
+
+	assert.Equal(t, 2, runs)
+	store.AssertNotCalled(t, "Reserve", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestIdempotency_RunsUnprotectedWhenStoreIsDown(t *testing.T) {
+	store := new(MockIdempotencyStore)
+	runs := 0
+	store.On("Reserve", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("connection refused"))
+
+	w := idempotentRequest(setupIdempotentRouter(store, &runs), "/items", "k1", `{"qty":1}`)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, 1, runs)
+	store.AssertNotCalled(t, "Complete", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,206 @@
+package middleware_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/emart/cart-service/internal/config"
+	"github.com/emart/cart-service/internal/middleware"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newVerifier(t *testing.T, jc config.JWTConfig) *middleware.TokenVerifier {
+	t.Helper()
+	v, err := middleware.NewTokenVerifier(&config.Config{JWT: jc}, zap.NewNop())
+	require.NoError(t, err)
+	return v
+}
+
+func claimsFor(userID string) jwt.MapClaims {
+	return jwt.MapClaims{"userId": userID, "exp": time.Now().Add(time.Hour).Unix()}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	tok := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		tok.Header["kid"] = kid
+	}
+	s, err := tok.SignedString(key)
+	require.NoError(t, err)
+	return s
+}
+
+func b64(i *big.Int) string { return base64.RawURLEncoding.EncodeToString(i.Bytes()) }
+
+func TestTokenVerifier_HMAC(t *testing.T) {
+	v := newVerifier(t, config.JWTConfig{Secret: testSecret})
+
+	claims, err := v.Verify(sign(t, jwt.SigningMethodHS512, []byte(testSecret), "", claimsFor("u1")))
+	assert.NoError(t, err)
+	assert.Equal(t, "u1", claims["userId"])
+
+	_, err = v.Verify(sign(t, jwt.SigningMethodHS256, []byte("another-secret"), "", claimsFor("u1")))
+	assert.Error(t, err)
+}
+
+func TestTokenVerifier_RS256FromPEM_RejectsAlgorithmConfusion(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	path := filepath.Join(t.TempDir(), "login.pub")
+	require.NoError(t, os.WriteFile(path, pemBytes, 0o600))
+
+	v := newVerifier(t, config.JWTConfig{Algorithm: "RS256", PublicKeyFile: path})
+
+	_, err := v.Verify(sign(t, jwt.SigningMethodRS256, key, "", claimsFor("u1")))
+	assert.NoError(t, err)
+
+	// An HS256 token keyed with the public key must not pass as RS256
+	_, err = v.Verify(sign(t, jwt.SigningMethodHS256, pemBytes, "", claimsFor("u1")))
+	assert.Error(t, err)
+}
+
+// jwksServer publishes one EC key and counts fetches. A fetch made while hold
+// is set waits until hold is closed.
+type jwksServer struct {
+	*httptest.Server
+	fetches atomic.Int32
+
+	mu   sync.Mutex
+	kid  string
+	key  *ecdsa.PrivateKey
+	hold chan struct{}
+}
+
+func newJWKSServer(t *testing.T, kid string, key *ecdsa.PrivateKey) *jwksServer {
+	s := &jwksServer{kid: kid, key: key}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.fetches.Add(1)
+		s.mu.Lock()
+		kid, key, hold := s.kid, s.key, s.hold
+		s.mu.Unlock()
+		if hold != nil {
+			<-hold
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "EC", "crv": "P-256", "kid": kid, "use": "sig",
+			"x": b64(key.X), "y": b64(key.Y),
+		}}})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksServer) publish(kid string, key *ecdsa.PrivateKey, hold chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.kid, s.key, s.hold = kid, key, hold
+}
+
+func TestTokenVerifier_JWKSPicksUpRotatedKey(t *testing.T) {
+	oldKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	newKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	srv := newJWKSServer(t, "k1", oldKey)
+	v := newVerifier(t, config.JWTConfig{Algorithm: "ES256", JWKSURL: srv.URL, JWKSRefresh: time.Hour})
+
+	_, err := v.Verify(sign(t, jwt.SigningMethodES256, oldKey, "k1", claimsFor("u1")))
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), srv.fetches.Load(), "known kid is served from cache")
+
+	srv.publish("k2", newKey, nil)
+	claims, err := v.Verify(sign(t, jwt.SigningMethodES256, newKey, "k2", claimsFor("u2")))
+	require.NoError(t, err)
+	assert.Equal(t, "u2", claims["userId"])
+	assert.Equal(t, int32(2), srv.fetches.Load(), "an unknown kid fetches the set again")
+
+	_, err = v.Verify(sign(t, jwt.SigningMethodES256, oldKey, "k1", claimsFor("u1")))
+	assert.Error(t, err, "retired key is no longer trusted")
+	assert.Equal(t, int32(2), srv.fetches.Load(), "unknown kid right after a fetch is rate limited")
+}
+
+func TestTokenVerifier_JWKSRefetchFloorHoldsWithoutRefresh(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	srv := newJWKSServer(t, "k1", key)
+	v := newVerifier(t, config.JWTConfig{Algorithm: "ES256", JWKSURL: srv.URL, JWKSRefresh: 0})
+
+	for i := 0; i < 10; i++ {
+		_, err := v.Verify(sign(t, jwt.SigningMethodES256, key, "k1", claimsFor("u1")))
+		assert.NoError(t, err)
+		_, err = v.Verify(sign(t, jwt.SigningMethodES256, key, "made-up", claimsFor("u1")))
+		assert.Error(t, err)
+	}
+	assert.Equal(t, int32(2), srv.fetches.Load(), "JWT_JWKS_REFRESH=0 does not lift the throttle")
+}
+
+func TestTokenVerifier_JWKSFetchesOnceWithoutBlockingCachedKeys(t *testing.T) {
+	oldKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	newKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	srv := newJWKSServer(t, "k1", oldKey)
+	v := newVerifier(t, config.JWTConfig{Algorithm: "ES256", JWKSURL: srv.URL, JWKSRefresh: time.Hour})
+
+	hold := make(chan struct{})
+	srv.publish("k2", newKey, hold)
+	errs := make([]error, 5)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = v.Verify(sign(t, jwt.SigningMethodES256, newKey, "k2", claimsFor("u2")))
+		}(i)
+	}
+	require.Eventually(t, func() bool { return srv.fetches.Load() == 2 }, time.Second, time.Millisecond)
+
+	_, err := v.Verify(sign(t, jwt.SigningMethodES256, oldKey, "k1", claimsFor("u1")))
+	assert.NoError(t, err, "a cached key is served while a fetch is in flight")
+
+	close(hold)
+	wg.Wait()
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int32(2), srv.fetches.Load(), "concurrent callers share one fetch")
+}
+
+func TestTokenVerifier_ChecksIssuerAudienceWithLeeway(t *testing.T) {
+	v := newVerifier(t, config.JWTConfig{Secret: testSecret, Issuer: "emart-login", Audience: "emart-cart", Leeway: time.Minute})
+
+	good := jwt.MapClaims{"userId": "u1", "iss": "emart-login", "aud": "emart-cart",
+		"exp": time.Now().Add(-30 * time.Second).Unix()} // expired, but within leeway
+	_, err := v.Verify(sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", good))
+	assert.NoError(t, err)
+
+	wrongAud := jwt.MapClaims{"userId": "u1", "iss": "emart-login", "aud": "emart-payment", "exp": time.Now().Add(time.Hour).Unix()}
+	_, err = v.Verify(sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", wrongAud))
+	assert.ErrorIs(t, err, jwt.ErrTokenInvalidAudience)
+
+	wrongIss := jwt.MapClaims{"userId": "u1", "iss": "someone", "aud": "emart-cart", "exp": time.Now().Add(time.Hour).Unix()}
+	_, err = v.Verify(sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", wrongIss))
+	assert.ErrorIs(t, err, jwt.ErrTokenInvalidIssuer)
+}
+
+func TestNewTokenVerifier_RejectsIncompleteConfig(t *testing.T) {
+	_, err := middleware.NewTokenVerifier(&config.Config{JWT: config.JWTConfig{Algorithm: "RS256"}}, zap.NewNop())
+	assert.Error(t, err)
+	_, err = middleware.NewTokenVerifier(&config.Config{JWT: config.JWTConfig{Algorithm: "none"}}, zap.NewNop())
+	assert.Error(t, err)
+}
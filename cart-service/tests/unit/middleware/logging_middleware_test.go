@@ -0,0 +1,101 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/emart/cart-service/internal/config"
+	"github.com/emart/cart-service/internal/logging"
+	"github.com/emart/cart-service/internal/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// setupLoggedRouter mounts one authenticated route that logs through the
+// request-scoped logger, the way services do
+func setupLoggedRouter(logs zapcore.Core) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	verifier, _ := middleware.NewTokenVerifier(&config.Config{JWT: config.JWTConfig{Secret: testSecret}}, zap.NewNop())
+	r.Use(middleware.RequestLogger(zap.New(logs)))
+	r.GET("/cart", middleware.JWTAuthMiddleware(verifier), func(c *gin.Context) {
+		logging.FromContext(c.Request.Context(), zap.NewNop()).Info("in handler")
+		c.Status(http.StatusOK)
+	})
+	r.GET("/health", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return r
+}
+
+func TestRequestLogger_KeepsCallerRequestID(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	req := httptest.NewRequest(http.MethodGet, "/cart", nil)
+	req.Header.Set("X-Request-ID", "req-123")
+	req.Header.Set("Authorization", "Bearer "+sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", claimsFor("user-1")))
+	w := httptest.NewRecorder()
+	setupLoggedRouter(core).ServeHTTP(w, req)
+
+	assert.Equal(t, "req-123", w.Header().Get("X-Request-ID"))
+	entries := logs.All()
+	require.Len(t, entries, 2)
+
+	// The service-side line carries the request ID and the authenticated user
+	assert.Equal(t, "in handler", entries[0].Message)
+	assert.Equal(t, "req-123", entries[0].ContextMap()["request_id"])
+	assert.Equal(t, "user-1", entries[0].ContextMap()["user_id"])
+
+	access := entries[1].ContextMap()
+	assert.Equal(t, "HTTP request", entries[1].Message)
+	assert.Equal(t, "req-123", access["request_id"])
+	assert.Equal(t, "user-1", access["user_id"])
+	assert.Equal(t, int64(http.StatusOK), access["status"])
+	assert.Equal(t, "/cart", access["route"])
+	assert.Contains(t, access, "latency")
+}
+
+func TestRequestLogger_ReplacesMissingOrUnsafeRequestID(t *testing.T) {
+	core, _ := observer.New(zap.InfoLevel)
+	router := setupLoggedRouter(core)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	generated := w.Header().Get("X-Request-ID")
+	assert.Len(t, generated, 36)
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	req.Header.Set("X-Request-ID", "bad id\nforged=log")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.NotEqual(t, "bad id\nforged=log", w.Header().Get("X-Request-ID"))
+	assert.NotEqual(t, generated, w.Header().Get("X-Request-ID"))
+}
+
+func TestRequestLogger_RedactsCredentials(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	req := httptest.NewRequest(http.MethodGet, "/cart", nil)
+	req.Header.Set("Authorization", "Bearer not-a-jwt")
+	req.Header.Set("Cookie", "emart_guest=secret")
+	req.Header.Set("X-Guest-Token", "guest-secret")
+	req.Header.Set("Accept", "application/json")
+	setupLoggedRouter(core).ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := logs.FilterMessage("HTTP request").All()
+	require.Len(t, entries, 1)
+	assert.Equal(t, zapcore.WarnLevel, entries[0].Level)
+	headers := entries[0].ContextMap()["headers"].(map[string]string)
+	assert.Equal(t, "[REDACTED]", headers["Authorization"])
+	assert.Equal(t, "[REDACTED]", headers["Cookie"])
+	assert.Equal(t, "[REDACTED]", headers["X-Guest-Token"])
+	assert.Equal(t, "application/json", headers["Accept"])
+}
+
+func TestRequestLogger_ProbesLogAtDebug(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	setupLoggedRouter(core).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Zero(t, logs.Len())
+}
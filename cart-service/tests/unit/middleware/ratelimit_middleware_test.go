@@ -0,0 +1,124 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/emart/cart-service/internal/config"
+	"github.com/emart/cart-service/internal/middleware"
+	"github.com/emart/cart-service/internal/ratelimit"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// setupLimitedRouter limits POST /items to two requests per minute. The fake
+// identity middleware sets user_id from X-User (and is_guest from X-Guest).
+func setupLimitedRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	policy, err := ratelimit.NewPolicy(config.RateLimitConfig{
+		Default: "off",
+		Routes:  map[string]string{"POST /items": "2/1m"},
+	})
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if id := c.GetHeader("X-User"); id != "" {
+			c.Set("user_id", id)
+			c.Set("is_guest", c.GetHeader("X-Guest") != "")
+		}
+	})
+	r.Use(middleware.RateLimitMiddleware(ratelimit.NewMemoryLimiter(), policy, zap.NewNop()))
+	r.POST("/items", func(c *gin.Context) { c.Status(http.StatusCreated) })
+	r.GET("/items", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return r
+}
+
+func limitedRequest(router *gin.Engine, method, user, ip string, guest bool) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/items", nil)
+	req.RemoteAddr = ip + ":40000"
+	if user != "" {
+		req.Header.Set("X-User", user)
+	}
+	if guest {
+		req.Header.Set("X-Guest", "1")
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimit_RejectsWith429AndHeaders(t *testing.T) {
+	router := setupLimitedRouter(t)
+
+	w := limitedRequest(router, http.MethodPost, "user-1", "10.0.0.1", false)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "30", w.Header().Get("RateLimit-Reset"))
+
+	limitedRequest(router, http.MethodPost, "user-1", "10.0.0.1", false)
+	w = limitedRequest(router, http.MethodPost, "user-1", "10.0.0.1", false)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+}
+
+func TestRateLimit_KeysUsersByIDAndOthersByIP(t *testing.T) {
+	router := setupLimitedRouter(t)
+
+	// Users are keyed by ID: another user on the same IP is unaffected, and
+	// changing IP does not reset the first user's bucket
+	for i := 0; i < 2; i++ {
+		limitedRequest(router, http.MethodPost, "user-1", "10.0.0.1", false)
+	}
+	assert.Equal(t, http.StatusCreated, limitedRequest(router, http.MethodPost, "user-2", "10.0.0.1", false).Code)
+	assert.Equal(t, http.StatusTooManyRequests, limitedRequest(router, http.MethodPost, "user-1", "10.0.0.2", false).Code)
+
+	// Guests can mint new IDs, so they share their IP's bucket
+	assert.Equal(t, http.StatusCreated, limitedRequest(router, http.MethodPost, "guest:a", "10.0.0.3", true).Code)
+	assert.Equal(t, http.StatusCreated, limitedRequest(router, http.MethodPost, "guest:b", "10.0.0.3", true).Code)
+	assert.Equal(t, http.StatusTooManyRequests, limitedRequest(router, http.MethodPost, "guest:c", "10.0.0.3", true).Code)
+	assert.Equal(t, http.StatusTooManyRequests, limitedRequest(router, http.MethodPost, "", "10.0.0.3", false).Code)
+}
+
+func TestRateLimit_UnlimitedRoutesPassThrough(t *testing.T) {
+	router := setupLimitedRouter(t)
+	for i := 0; i < 5; i++ {
+		w := limitedRequest(router, http.MethodGet, "user-1", "10.0.0.1", false)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+	}
+}
+
+func TestRateLimit_IgnoresForwardedForFromUntrustedPeers(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "192.168.1.10")
+	router := setupLimitedRouter(t)
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	require.NoError(t, router.SetTrustedProxies(cfg.Server.TrustedProxies))
+
+	spoofed := func(peer, forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodPost, "/items", nil)
+		req.RemoteAddr = peer + ":40000"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// A guest rotating X-Forwarded-For still shares its real address's bucket
+	assert.Equal(t, http.StatusCreated, spoofed("10.0.0.9", "1.1.1.1"))
+	assert.Equal(t, http.StatusCreated, spoofed("10.0.0.9", "2.2.2.2"))
+	assert.Equal(t, http.StatusTooManyRequests, spoofed("10.0.0.9", "3.3.3.3"))
+
+	// Behind the trusted proxy each forwarded client has its own bucket
+	assert.Equal(t, http.StatusCreated, spoofed("192.168.1.10", "4.4.4.4"))
+	assert.Equal(t, http.StatusCreated, spoofed("192.168.1.10", "5.5.5.5"))
+	assert.Equal(t, http.StatusCreated, spoofed("192.168.1.10", "5.5.5.5"))
+	assert.Equal(t, http.StatusTooManyRequests, spoofed("192.168.1.10", "5.5.5.5"))
+}
@@ -0,0 +1,53 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/emart/cart-service/internal/config"
+	"github.com/emart/cart-service/internal/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func setupAdminRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	verifier, _ := middleware.NewTokenVerifier(&config.Config{JWT: config.JWTConfig{Secret: testSecret}}, zap.NewNop())
+	r.Use(middleware.JWTAuthMiddleware(verifier), middleware.RequireRole("roles", "cart-support"))
+	r.GET("/admin", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return r
+}
+
+func requestWithRoles(t *testing.T, roles interface{}) int {
+	t.Helper()
+	claims := claimsFor("staff-1")
+	if roles != nil {
+		claims["roles"] = roles
+	}
+	req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+	req.Header.Set("Authorization", "Bearer "+sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", claims))
+	w := httptest.NewRecorder()
+	setupAdminRouter().ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestRequireRole_AcceptsRoleInArrayOrString(t *testing.T) {
+	assert.Equal(t, http.StatusOK, requestWithRoles(t, []string{"customer", "cart-support"}))
+	assert.Equal(t, http.StatusOK, requestWithRoles(t, "customer cart-support"))
+}
+
+func TestRequireRole_ForbidsOtherUsers(t *testing.T) {
+	assert.Equal(t, http.StatusForbidden, requestWithRoles(t, nil))
+	assert.Equal(t, http.StatusForbidden, requestWithRoles(t, []string{"customer"}))
+	assert.Equal(t, http.StatusForbidden, requestWithRoles(t, "cart-support-readonly"))
+}
+
+func TestRequireRole_RequiresAuthentication(t *testing.T) {
+	w := httptest.NewRecorder()
+	setupAdminRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,149 @@
+package migration_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/emart/cart-service/internal/migration"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// standalone answers the hello that decides whether batches run in transactions
+func standalone() bson.D {
+	return mtest.CreateSuccessResponse()
+}
+
+func batch(docs ...bson.D) bson.D {
+	return mtest.CreateCursorResponse(0, "db.carts", mtest.FirstBatch, docs...)
+}
+
+func cart(id int32, version int64) bson.D {
+	return bson.D{{Key: "_id", Value: id}, {Key: "version", Value: version}}
+}
+
+// markMigrated sets migrated on every document it has not seen it on
+func markMigrated(doc bson.M) (bool, error) {
+	if doc["migrated"] == true {
+		return false, nil
+	}
+	doc["migrated"] = true
+	return true, nil
+}
+
+// sentFilters lists the filters of the finds the helper sent, in order
+func sentFilters(mt *mtest.T) []bson.M {
+	var filters []bson.M
+	for _, evt := range mt.GetAllStartedEvents() {
+		if evt.CommandName != "find" {
+			continue
+		}
+		var cmd struct {
+			Collection string `bson:"find"`
+			Filter     bson.M `bson:"filter"`
+		}
+		require.NoError(mt, bson.Unmarshal(evt.Command, &cmd))
+		if cmd.Collection == "carts" {
+			filters = append(filters, cmd.Filter)
+		}
+	}
+	return filters
+}
+
+// checkpoints lists the checkpoints the helper saved, in order
+func checkpoints(mt *mtest.T) []bson.M {
+	var saved []bson.M
+	for _, upd := range sentUpdates(mt) {
+		// Replacements have no $set
+		set, _ := upd["u"].(bson.M)["$set"].(bson.M)
+		if cp, ok := set["checkpoint"]; ok {
+			saved = append(saved, cp.(bson.M))
+		}
+	}
+	return saved
+}
+
+func TestRewriteInBatches(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("checkpoints after every batch", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			found(nil), // no checkpoint yet
+			standalone(), batch(cart(1, 1), cart(2, 1)), matched(1), matched(1), matched(1),
+			standalone(), batch(), // done
+		)
+
+		rewritten, err := migration.RewriteInBatches(context.Background(), mt.DB, "V999_Test", migration.BatchOptions{BatchSize: 2}, markMigrated)
+		require.NoError(mt, err)
+		assert.Equal(mt, int64(2), rewritten)
+
+		saved := checkpoints(mt)
+		require.Len(mt, saved, 1)
+		assert.EqualValues(mt, 2, saved[0]["lastId"])
+		assert.EqualValues(mt, 2, saved[0]["processed"])
+		assert.EqualValues(mt, 2, saved[0]["rewritten"])
+		filters := sentFilters(mt)
+		require.Len(mt, filters, 2)
+		assert.Equal(mt, bson.M{}, filters[0])
+		assert.Equal(mt, bson.M{"$and": bson.A{bson.M{}, bson.M{"_id": bson.M{"$gt": int32(2)}}}}, filters[1], "the next batch starts after the last one")
+	})
+
+	mt.Run("resumes after the saved checkpoint", func(mt *mtest.T) {
+		checkpoint := bson.D{{Key: "lastId", Value: int32(2)}, {Key: "processed", Value: int64(2)}, {Key: "rewritten", Value: int64(1)}}
+		mt.AddMockResponses(
+			found(bson.D{{Key: "changeId", Value: "V999_Test"}, {Key: "checkpoint", Value: checkpoint}}),
+			standalone(), batch(cart(3, 1)), matched(1), matched(1),
+		)
+
+		rewritten, err := migration.RewriteInBatches(context.Background(), mt.DB, "V999_Test",
+			migration.BatchOptions{BatchSize: 2, Filter: bson.M{"schema_version": 1}}, markMigrated)
+		require.NoError(mt, err)
+		assert.Equal(mt, int64(1), rewritten, "only this run's rewrites are reported")
+
+		filters := sentFilters(mt)
+		require.Len(mt, filters, 1)
+		assert.Equal(mt, bson.M{"$and": bson.A{bson.M{"schema_version": int32(1)}, bson.M{"_id": bson.M{"$gt": int32(2)}}}}, filters[0])
+		saved := checkpoints(mt)
+		require.Len(mt, saved, 1)
+		assert.EqualValues(mt, 3, saved[0]["lastId"])
+		assert.EqualValues(mt, 3, saved[0]["processed"], "totals carry over from the checkpoint")
+		assert.EqualValues(mt, 2, saved[0]["rewritten"])
+	})
+
+	mt.Run("re-reads a document a live writer changed", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			found(nil),
+			standalone(), batch(cart(1, 1)),
+			matched(0),        // version moved on
+			found(cart(1, 2)), // re-read
+			matched(1),
+			matched(1), // checkpoint
+		)
+
+		rewritten, err := migration.RewriteInBatches(context.Background(), mt.DB, "V999_Test", migration.BatchOptions{BatchSize: 2}, markMigrated)
+		require.NoError(mt, err)
+		assert.Equal(mt, int64(1), rewritten)
+
+		var guards []interface{}
+		for _, upd := range sentUpdates(mt) {
+			if q := upd["q"].(bson.M); q["version"] != nil {
+				guards = append(guards, q["version"])
+			}
+		}
+		assert.Equal(mt, []interface{}{int64(1), int64(2)}, guards, "each write is guarded by the version it read")
+	})
+
+	mt.Run("stops without a checkpoint when a batch fails", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			found(nil),
+			standalone(), batch(cart(1, 1)),
+			mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 2, Message: "bad value", Name: "BadValue"}),
+		)
+
+		_, err := migration.RewriteInBatches(context.Background(), mt.DB, "V999_Test", migration.BatchOptions{}, markMigrated)
+		assert.Error(mt, err)
+		assert.Empty(mt, checkpoints(mt))
+	})
+}
@@ -0,0 +1,123 @@
+package migration_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/emart/cart-service/internal/config"
+	"github.com/emart/cart-service/internal/migration"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.uber.org/zap"
+)
+
+// sentUpdates lists the update statements the runner sent, in order
+func sentUpdates(mt *mtest.T) []bson.M {
+	var sent []bson.M
+	for _, evt := range mt.GetAllStartedEvents() {
+		if evt.CommandName != "update" {
+			continue
+		}
+		var cmd struct {
+			Updates []bson.M `bson:"updates"`
+		}
+		require.NoError(mt, bson.Unmarshal(evt.Command, &cmd))
+		sent = append(sent, cmd.Updates...)
+	}
+	return sent
+}
+
+func duplicateKey() bson.D {
+	return mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "E11000 duplicate key error"})
+}
+
+func heldBy(owner string) bson.D {
+	return found(bson.D{{Key: "_id", Value: "migration-lock"}, {Key: "owner", Value: owner}, {Key: "expiresAt", Value: time.Now().Add(time.Minute)}})
+}
+
+// blockingMigration runs until its context ends
+type blockingMigration struct {
+	fakeMigration
+}
+
+func (blockingMigration) Execute(ctx context.Context, db *mongo.Database) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestRunner_Lock(t *testing.T) {
+	// Repair with no migrations does nothing but take and release the lock
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("takes the lock as its owner and releases only its own", func(mt *mtest.T) {
+		runner := migration.NewRunnerWith(mt.DB, &config.Config{}, zap.NewNop())
+		mt.AddMockResponses(matched(1), matched(1))
+
+		require.NoError(mt, runner.Repair(context.Background()))
+		sent := sentUpdates(mt)
+		require.Len(mt, sent, 2)
+		owner := sent[0]["u"].(bson.M)["$set"].(bson.M)["owner"]
+		assert.NotEmpty(mt, owner)
+		assert.Contains(mt, sent[0]["q"].(bson.M)["$or"], bson.M{"owner": owner}, "a lock it already owns is taken again")
+		assert.Equal(mt, owner, sent[1]["q"].(bson.M)["owner"], "release is owner-checked")
+	})
+
+	mt.Run("each runner has its own owner", func(mt *mtest.T) {
+		mt.AddMockResponses(matched(1), matched(1), matched(1), matched(1))
+		require.NoError(mt, migration.NewRunnerWith(mt.DB, &config.Config{}, zap.NewNop()).Repair(context.Background()))
+		require.NoError(mt, migration.NewRunnerWith(mt.DB, &config.Config{}, zap.NewNop()).Repair(context.Background()))
+
+		sent := sentUpdates(mt)
+		require.Len(mt, sent, 4)
+		assert.NotEqual(mt, sent[0]["u"].(bson.M)["$set"].(bson.M)["owner"], sent[2]["u"].(bson.M)["$set"].(bson.M)["owner"])
+	})
+
+	mt.Run("reports the holder of a busy lock once the wait is over", func(mt *mtest.T) {
+		runner := migration.NewRunnerWith(mt.DB, &config.Config{}, zap.NewNop())
+		mt.AddMockResponses(duplicateKey(), heldBy("other-pod:1:abc"))
+
+		err := runner.Repair(context.Background())
+		require.Error(mt, err)
+		assert.Contains(mt, err.Error(), "other-pod:1:abc")
+	})
+
+	mt.Run("retries a busy lock until it is free", func(mt *mtest.T) {
+		cfg := &config.Config{Migration: config.MigrationConfig{LockWait: time.Second, LockRetryInterval: time.Millisecond}}
+		runner := migration.NewRunnerWith(mt.DB, cfg, zap.NewNop())
+		mt.AddMockResponses(
+			duplicateKey(), heldBy("other-pod:1:abc"),
+			matched(1), // freed
+			matched(1), // unlock
+		)
+
+		assert.NoError(mt, runner.Repair(context.Background()))
+	})
+
+	mt.Run("heartbeat extends the lock while a migration runs and stops it once the lock is lost", func(mt *mtest.T) {
+		var log []string
+		cfg := &config.Config{Migration: config.MigrationConfig{LockTTL: 60 * time.Millisecond}}
+		runner := migration.NewRunnerWith(mt.DB, cfg, zap.NewNop(), blockingMigration{fakeMigration{id: "A", log: &log}})
+		mt.AddMockResponses(
+			matched(1),             // lock
+			found(nil), found(nil), // checksums, A pending
+			matched(1),             // A tracked
+			matched(1),             // heartbeat extends
+			matched(0),             // heartbeat finds the lock taken over
+			matched(1), matched(1), // rollback recorded, unlock
+		)
+
+		err := runner.Up(context.Background(), migration.Options{})
+		assert.ErrorIs(mt, err, context.Canceled)
+		sent := sentUpdates(mt)
+		require.GreaterOrEqual(mt, len(sent), 4)
+		owner := sent[0]["u"].(bson.M)["$set"].(bson.M)["owner"]
+		for _, beat := range sent[2:4] {
+			assert.Equal(mt, owner, beat["q"].(bson.M)["owner"], "only the owner's lock is extended")
+			assert.Contains(mt, beat["u"].(bson.M)["$set"], "expiresAt")
+		}
+	})
+}
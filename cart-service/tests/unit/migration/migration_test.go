@@ -0,0 +1,65 @@
+package migration_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/emart/cart-service/internal/config"
+	"github.com/emart/cart-service/internal/migration"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+func TestMigrations_HaveStableDistinctChecksums(t *testing.T) {
+	runner := migration.NewRunner(nil, &config.Config{}, zap.NewNop())
+
+	seen := map[string]string{}
+	for _, m := range runner.Migrations() {
+		sum, err := m.Checksum()
+		require.NoError(t, err, m.ID())
+		assert.True(t, strings.HasPrefix(sum, "tok2:"), m.ID())
+		assert.Len(t, sum, len("tok2:")+64, m.ID())
+		again, _ := m.Checksum()
+		assert.Equal(t, sum, again, "checksum must be deterministic: %s", m.ID())
+		if other, dup := seen[sum]; dup {
+			t.Errorf("%s and %s share a checksum", m.ID(), other)
+		}
+		seen[sum] = m.ID()
+	}
+}
+
+func TestMigrations_AreInOrder(t *testing.T) {
+	runner := migration.NewRunner(nil, &config.Config{}, zap.NewNop())
+
+	prev := ""
+	for _, m := range runner.Migrations() {
+		assert.Greater(t, m.Order(), prev, m.ID())
+		prev = m.Order()
+	}
+}
+
+// unreadableMigration stands in for a migration whose source cannot be fingerprinted
+type unreadableMigration struct {
+	migration.Migration
+}
+
+func (unreadableMigration) ID() string { return "V999_Unreadable" }
+
+func (unreadableMigration) Checksum() (string, error) { return "", assert.AnError }
+
+func (unreadableMigration) Execute(ctx context.Context, db *mongo.Database) error {
+	panic("must not run")
+}
+
+func TestRunner_FailsBeforeRunningWhenAChecksumFails(t *testing.T) {
+	// No database: the checksum error has to surface before it is touched
+	runner := migration.NewRunnerWith(nil, &config.Config{}, zap.NewNop(), unreadableMigration{})
+
+	assert.ErrorIs(t, runner.Up(context.Background(), migration.Options{}), assert.AnError)
+	_, err := runner.Status(context.Background())
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.ErrorIs(t, runner.Repair(context.Background()), assert.AnError)
+}
@@ -0,0 +1,221 @@
+package migration_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/emart/cart-service/internal/config"
+	"github.com/emart/cart-service/internal/migration"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.uber.org/zap"
+)
+
+// The runner is driven against mtest's mock deployment, which answers each
+// command with the next queued response. A test queues one per command the
+// runner is expected to send, in order.
+
+const changeLogNS = "db.mongockChangeLog"
+
+// fakeMigration notes in log when it is executed or rolled back
+type fakeMigration struct {
+	id  string
+	log *[]string
+}
+
+func (f fakeMigration) ID() string                { return f.id }
+func (f fakeMigration) Order() string             { return f.id }
+func (f fakeMigration) Author() string            { return "test" }
+func (f fakeMigration) Checksum() (string, error) { return "tok2:" + f.id, nil }
+
+func (f fakeMigration) Execute(ctx context.Context, db *mongo.Database) error {
+	*f.log = append(*f.log, "up "+f.id)
+	return nil
+}
+
+func (f fakeMigration) Rollback(ctx context.Context, db *mongo.Database) error {
+	*f.log = append(*f.log, "down "+f.id)
+	return nil
+}
+
+func fakeMigrations(log *[]string, ids ...string) []migration.Migration {
+	ms := make([]migration.Migration, 0, len(ids))
+	for _, id := range ids {
+		ms = append(ms, fakeMigration{id: id, log: log})
+	}
+	return ms
+}
+
+// matched answers an update that matched n documents
+func matched(n int) bson.D {
+	return mtest.CreateSuccessResponse(bson.E{Key: "n", Value: n}, bson.E{Key: "nModified", Value: n})
+}
+
+// found answers a FindOne with doc, or with nothing when doc is nil
+func found(doc bson.D) bson.D {
+	if doc == nil {
+		return mtest.CreateCursorResponse(0, changeLogNS, mtest.FirstBatch)
+	}
+	return mtest.CreateCursorResponse(0, changeLogNS, mtest.FirstBatch, doc)
+}
+
+// entry is the changelog entry of an executed fake migration, checksum unchanged
+func entry(id string) bson.D {
+	return bson.D{{Key: "changeId", Value: id}, {Key: "state", Value: "EXECUTED"}, {Key: "checksum", Value: "tok2:" + id}}
+}
+
+func TestRunner_Up(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("applies pending migrations up to the target", func(mt *mtest.T) {
+		var log []string
+		runner := migration.NewRunnerWith(mt.DB, &config.Config{}, zap.NewNop(), fakeMigrations(&log, "A", "B", "C")...)
+		mt.AddMockResponses(
+			matched(1),                                // lock
+			found(entry("A")), found(nil), found(nil), // checksums
+			found(entry("A")), found(nil), // A executed, B pending
+			matched(1), matched(1), // B tracked, recorded EXECUTED
+			matched(1), // unlock
+		)
+
+		assert.NoError(mt, runner.Up(context.Background(), migration.Options{Target: "B"}))
+		assert.Equal(mt, []string{"up B"}, log)
+	})
+
+	mt.Run("dry run executes nothing", func(mt *mtest.T) {
+		var log []string
+		runner := migration.NewRunnerWith(mt.DB, &config.Config{}, zap.NewNop(), fakeMigrations(&log, "A", "B")...)
+		mt.AddMockResponses(
+			found(entry("A")), found(nil),
+			found(entry("A")), found(nil),
+		)
+
+		assert.NoError(mt, runner.Up(context.Background(), migration.Options{DryRun: true}))
+		assert.Empty(mt, log)
+		assert.Len(mt, mt.GetAllStartedEvents(), 4)
+		for _, evt := range mt.GetAllStartedEvents() {
+			assert.Equal(mt, "find", evt.CommandName, "a dry run only reads")
+		}
+	})
+
+	mt.Run("rejects an unknown target before touching the database", func(mt *mtest.T) {
+		var log []string
+		runner := migration.NewRunnerWith(mt.DB, &config.Config{}, zap.NewNop(), fakeMigrations(&log, "A")...)
+
+		assert.Error(mt, runner.Up(context.Background(), migration.Options{Target: "Z"}))
+		assert.Error(mt, runner.Down(context.Background(), migration.Options{Target: "Z"}))
+		assert.Empty(mt, mt.GetAllStartedEvents())
+	})
+}
+
+func TestRunner_Status(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("reports each migration in order, unrecorded ones pending", func(mt *mtest.T) {
+		var log []string
+		runner := migration.NewRunnerWith(mt.DB, &config.Config{}, zap.NewNop(), fakeMigrations(&log, "A", "B", "C")...)
+		edited := bson.D{{Key: "changeId", Value: "C"}, {Key: "state", Value: "EXECUTED"}, {Key: "checksum", Value: "tok2:edited"}}
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, changeLogNS, mtest.FirstBatch, edited, entry("A")))
+
+		statuses, err := runner.Status(context.Background())
+		assert.NoError(mt, err)
+		if assert.Len(mt, statuses, 3) {
+			assert.Equal(mt, []string{"A", "B", "C"}, []string{statuses[0].ID, statuses[1].ID, statuses[2].ID})
+			assert.Equal(mt, "EXECUTED", statuses[0].State)
+			assert.Equal(mt, "PENDING", statuses[1].State)
+			assert.Nil(mt, statuses[1].ExecutedAt)
+			assert.False(mt, statuses[0].Drifted)
+			assert.True(mt, statuses[2].Drifted)
+		}
+	})
+}
+
+func TestRunner_Down(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("rolls back only the latest migration by default", func(mt *mtest.T) {
+		var log []string
+		runner := migration.NewRunnerWith(mt.DB, &config.Config{}, zap.NewNop(), fakeMigrations(&log, "A", "B")...)
+		mt.AddMockResponses(
+			matched(1),        // lock
+			found(entry("B")), // B executed
+			found(entry("B")), // B tracks what it created
+			matched(1),        // B recorded ROLLED_BACK
+			matched(1),        // unlock
+		)
+
+		assert.NoError(mt, runner.Down(context.Background(), migration.Options{}))
+		assert.Equal(mt, []string{"down B"}, log)
+	})
+
+	mt.Run("rolls back everything after the target, newest first", func(mt *mtest.T) {
+		var log []string
+		runner := migration.NewRunnerWith(mt.DB, &config.Config{}, zap.NewNop(), fakeMigrations(&log, "A", "B", "C")...)
+		mt.AddMockResponses(
+			matched(1),
+			found(entry("C")), found(entry("B")), // executed
+			found(entry("C")), found(entry("B")), // tracked
+			matched(1), matched(1),
+			matched(1),
+		)
+
+		assert.NoError(mt, runner.Down(context.Background(), migration.Options{Target: "A"}))
+		assert.Equal(mt, []string{"down C", "down B"}, log)
+	})
+
+	mt.Run("skips migrations that are not executed", func(mt *mtest.T) {
+		var log []string
+		runner := migration.NewRunnerWith(mt.DB, &config.Config{}, zap.NewNop(), fakeMigrations(&log, "A", "B")...)
+		mt.AddMockResponses(
+			matched(1),
+			found(nil), found(entry("A")),
+			found(entry("A")),
+			matched(1),
+			matched(1),
+		)
+
+		assert.NoError(mt, runner.Down(context.Background(), migration.Options{Target: migration.Base}))
+		assert.Equal(mt, []string{"down A"}, log)
+	})
+
+	mt.Run("refuses an untracked entry and rolls back nothing", func(mt *mtest.T) {
+		var log []string
+		runner := migration.NewRunnerWith(mt.DB, &config.Config{}, zap.NewNop(), fakeMigrations(&log, "A", "B")...)
+		mt.AddMockResponses(
+			matched(1),
+			found(entry("B")), found(entry("A")),
+			found(entry("B")), found(nil), // A ran before tracking
+			matched(1),
+		)
+
+		err := runner.Down(context.Background(), migration.Options{Target: migration.Base})
+		assert.ErrorIs(mt, err, migration.ErrUntracked)
+		assert.Contains(mt, err.Error(), "created: A (")
+		assert.Empty(mt, log, "B must not be rolled back when the run would stop at A")
+	})
+
+	mt.Run("rolls back an untracked entry with Force", func(mt *mtest.T) {
+		var log []string
+		runner := migration.NewRunnerWith(mt.DB, &config.Config{}, zap.NewNop(), fakeMigrations(&log, "A")...)
+		mt.AddMockResponses(
+			matched(1),
+			found(entry("A")),
+			matched(1),
+			matched(1),
+		)
+
+		assert.NoError(mt, runner.Down(context.Background(), migration.Options{Force: true}))
+		assert.Equal(mt, []string{"down A"}, log)
+	})
+
+	mt.Run("dry run reports an untracked entry too", func(mt *mtest.T) {
+		var log []string
+		runner := migration.NewRunnerWith(mt.DB, &config.Config{}, zap.NewNop(), fakeMigrations(&log, "A")...)
+		mt.AddMockResponses(found(entry("A")), found(nil))
+
+		assert.ErrorIs(mt, runner.Down(context.Background(), migration.Options{DryRun: true}), migration.ErrUntracked)
+		assert.Empty(mt, log)
+	})
+}
@@ -0,0 +1,36 @@
+package model_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/emart/cart-service/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCartETag_ChangesWithEverySave(t *testing.T) {
+	saved := time.Date(2026, 3, 1, 10, 0, 0, 123456789, time.UTC)
+	cart := &model.Cart{UserID: "u1", Version: 3, UpdatedAt: saved, GrandTotal: model.MoneyFromMinor(1000, model.DefaultCurrency)}
+	etag := cart.ETag()
+
+	assert.Regexp(t, `^"[0-9a-f]+"$`, etag, "a strong, quoted tag")
+	fromMongo := *cart
+	fromMongo.UpdatedAt = saved.Truncate(time.Millisecond)
+	assert.Equal(t, etag, fromMongo.ETag(), "stable across stores")
+
+	bumped := *cart
+	bumped.Version++
+	assert.NotEqual(t, etag, bumped.ETag())
+	recreated := *cart
+	recreated.UpdatedAt = saved.Add(time.Hour)
+	assert.NotEqual(t, etag, recreated.ETag(), "a cart recreated after a clear reuses versions")
+	repriced := *cart
+	repriced.GrandTotal = model.MoneyFromMinor(1200, model.DefaultCurrency)
+	assert.NotEqual(t, etag, repriced.ETag(), "a lapsed coupon changes the totals")
+}
+
+func TestCartETag_UnsavedCartsShareOneTag(t *testing.T) {
+	a := &model.Cart{UserID: "u1", UpdatedAt: time.Now()}
+	b := &model.Cart{UserID: "u1", UpdatedAt: time.Now().Add(time.Second)}
+	assert.Equal(t, a.ETag(), b.ETag())
+}
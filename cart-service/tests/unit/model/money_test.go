@@ -0,0 +1,81 @@
+package model_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/emart/cart-service/internal/model"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParseMoney(t *testing.T) {
+	cases := map[string]int64{
+		"19.99":              1999,
+		"699":                69900,
+		"0.5":                50,
+		"-5.25":              -525,
+		"59.970000000000006": 5997, // float drift from pre-decimal documents
+		"0.995":              100,  // rounds half away from zero
+	}
+	for in, want := range cases {
+		m, err := model.ParseMoney(in, model.DefaultCurrency)
+		assert.NoError(t, err, in)
+		assert.Equal(t, want, m.Minor, in)
+	}
+
+	_, err := model.ParseMoney("12,50", model.DefaultCurrency)
+	assert.Error(t, err)
+}
+
+func TestMoney_NoFloatDrift(t *testing.T) {
+	price, _ := model.ParseMoney("19.99", model.DefaultCurrency)
+	assert.Equal(t, "59.97", price.Mul(3).String())
+
+	total := model.MoneyFromMinor(0, model.DefaultCurrency)
+	for i := 0; i < 10; i++ {
+		total = total.Add(model.MoneyFromFloat(0.1, model.DefaultCurrency))
+	}
+	assert.Equal(t, "1.00", total.String())
+}
+
+func TestMoney_AddPanicsOnCurrencyMismatch(t *testing.T) {
+	assert.Panics(t, func() {
+		model.MoneyFromMinor(100, "INR").Add(model.MoneyFromMinor(100, "USD"))
+	})
+}
+
+func TestMoney_JSONRoundTrip(t *testing.T) {
+	item := model.CartItem{ProductID: "1", Price: model.MoneyFromMinor(69900, model.DefaultCurrency), Quantity: 1}
+	data, err := json.Marshal(item)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"price":"699.00"`)
+
+	var decoded model.CartItem
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, item.Price, decoded.Price)
+
+	// Carts cached in Redis before the switch hold plain numbers
+	assert.NoError(t, json.Unmarshal([]byte(`{"price":29.99}`), &decoded))
+	assert.Equal(t, int64(2999), decoded.Price.Minor)
+}
+
+func TestMoney_BSONUsesDecimal128(t *testing.T) {
+	item := model.CartItem{ProductID: "1", Price: model.MoneyFromMinor(1999, model.DefaultCurrency)}
+	data, err := bson.Marshal(item)
+	assert.NoError(t, err)
+
+	var raw bson.M
+	assert.NoError(t, bson.Unmarshal(data, &raw))
+	assert.IsType(t, primitive.Decimal128{}, raw["price"])
+
+	var decoded model.CartItem
+	assert.NoError(t, bson.Unmarshal(data, &decoded))
+	assert.Equal(t, int64(1999), decoded.Price.Minor)
+
+	// Documents not yet migrated by V005 store doubles
+	legacy, _ := bson.Marshal(bson.M{"price": 19.99})
+	assert.NoError(t, bson.Unmarshal(legacy, &decoded))
+	assert.Equal(t, int64(1999), decoded.Price.Minor)
+}
@@ -0,0 +1,44 @@
+package model_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/emart/cart-service/internal/model"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestUpcast_V1JSONCartGetsCurrentShape(t *testing.T) {
+	// As cached in Redis before Money: float prices, no currency
+	raw := `{"user_id":"u1","items":[{"item_id":"i1","price":29.99,"quantity":2}],"total_price":59.98,"schema_version":1}`
+	var cart model.Cart
+	assert.NoError(t, json.Unmarshal([]byte(raw), &cart))
+
+	assert.NoError(t, model.Upcast(&cart))
+
+	assert.Equal(t, model.CurrentSchemaVersion, cart.SchemaVersion)
+	assert.Equal(t, model.DefaultCurrency, cart.Currency)
+	assert.Equal(t, "59.98", cart.TotalPrice.String())
+}
+
+func TestUpcast_UnversionedBSONCartIsTreatedAsV1(t *testing.T) {
+	raw, _ := bson.Marshal(bson.M{"user_id": "u1", "total_price": 10.5, "items": bson.A{}})
+	var cart model.Cart
+	assert.NoError(t, bson.Unmarshal(raw, &cart))
+
+	assert.NoError(t, model.Upcast(&cart))
+
+	assert.Equal(t, model.CurrentSchemaVersion, cart.SchemaVersion)
+	assert.Equal(t, model.DefaultCurrency, cart.Currency)
+}
+
+func TestUpcast_LeavesCurrentAndNewerCartsAlone(t *testing.T) {
+	current := model.Cart{SchemaVersion: model.CurrentSchemaVersion, Currency: "INR"}
+	assert.NoError(t, model.Upcast(&current))
+	assert.Equal(t, model.CurrentSchemaVersion, current.SchemaVersion)
+
+	newer := model.Cart{SchemaVersion: model.CurrentSchemaVersion + 1}
+	assert.NoError(t, model.Upcast(&newer))
+	assert.Equal(t, model.CurrentSchemaVersion+1, newer.SchemaVersion)
+}
@@ -0,0 +1,66 @@
+package notify_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/emart/cart-service/internal/model"
+	"github.com/emart/cart-service/internal/notify"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookNotifier_PostsCartWithServiceSecret(t *testing.T) {
+	var gotPath, gotSecret string
+	var body map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotSecret = r.Header.Get("X-Service-Secret")
+		json.NewDecoder(r.Body).Decode(&body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	n := notify.NewWebhookNotifier(srv.URL+"/", "s3cret", time.Second)
+	cart := &model.Cart{
+		UserID: "user-1", Email: "shopper@example.com", TotalItems: 1, GrandTotal: model.MoneyFromMinor(2999, model.DefaultCurrency),
+		Items: []model.CartItem{{ProductID: "book-001", ProductName: "Go Programming", Quantity: 1}},
+	}
+	err := n.NotifyAbandonedCart(context.Background(), cart)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "/api/v1/notify/cart-abandoned", gotPath)
+	assert.Equal(t, "s3cret", gotSecret)
+	assert.Equal(t, "user-1", body["user_id"])
+	assert.Equal(t, "shopper@example.com", body["user_email"])
+	assert.Equal(t, "29.99", body["grand_total"])
+}
+
+func TestWebhookNotifier_ErrorsOnNon2xx(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	n := notify.NewWebhookNotifier(srv.URL, "wrong", time.Second)
+	err := n.NotifyAbandonedCart(context.Background(), &model.Cart{UserID: "user-1", Email: "shopper@example.com"})
+
+	assert.Error(t, err)
+}
+
+func TestWebhookNotifier_RefusesCartWithoutEmail(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer srv.Close()
+
+	n := notify.NewWebhookNotifier(srv.URL, "s3cret", time.Second)
+	err := n.NotifyAbandonedCart(context.Background(), &model.Cart{UserID: "user-1"})
+
+	assert.ErrorIs(t, err, notify.ErrNoRecipient)
+	assert.False(t, called)
+}
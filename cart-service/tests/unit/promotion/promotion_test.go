@@ -0,0 +1,78 @@
+package promotion_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/emart/cart-service/internal/model"
+	"github.com/emart/cart-service/internal/promotion"
+	"github.com/stretchr/testify/assert"
+)
+
+// inr builds a Money value in the default currency
+func inr(amount string) model.Money {
+	m, _ := model.ParseMoney(amount, model.DefaultCurrency)
+	return m
+}
+
+func sampleItems() []model.CartItem {
+	return []model.CartItem{
+		{ProductID: "book-001", Category: "books", Price: inr("30.00"), Quantity: 2},
+		{ProductID: "book-002", Category: "books", Price: inr("10.00"), Quantity: 1},
+		{ProductID: "course-001", Category: "courses", Price: inr("50.00"), Quantity: 1},
+	}
+}
+
+func TestDiscount_PercentageOffWholeCart(t *testing.T) {
+	coupon := &model.Coupon{Type: model.DiscountPercentage, PercentOff: 15, Active: true}
+
+	amount, err := promotion.Discount(coupon, sampleItems(), time.Now())
+
+	assert.NoError(t, err)
+	assert.Equal(t, "18.00", amount.String()) // 15% of 120.00
+}
+
+func TestDiscount_FixedAmountCappedAtCategorySubtotal(t *testing.T) {
+	coupon := &model.Coupon{Type: model.DiscountFixed, AmountOff: inr("80.00"), Category: "courses", Active: true}
+
+	amount, err := promotion.Discount(coupon, sampleItems(), time.Now())
+
+	assert.NoError(t, err)
+	assert.Equal(t, "50.00", amount.String())
+}
+
+func TestDiscount_BuyTwoGetOneMakesCheapestFree(t *testing.T) {
+	coupon := &model.Coupon{Type: model.DiscountBuyXGetY, BuyQuantity: 2, GetQuantity: 1, Category: "books", Active: true}
+
+	amount, err := promotion.Discount(coupon, sampleItems(), time.Now())
+
+	assert.NoError(t, err)
+	assert.Equal(t, "10.00", amount.String()) // 3 books in scope, the 10.00 one is free
+}
+
+func TestDiscount_MinSpendAppliesToScope(t *testing.T) {
+	coupon := &model.Coupon{Type: model.DiscountFixed, AmountOff: inr("5.00"), Category: "courses", MinSpend: inr("60.00"), Active: true}
+
+	_, err := promotion.Discount(coupon, sampleItems(), time.Now())
+
+	assert.ErrorIs(t, err, promotion.ErrMinSpendNotMet)
+}
+
+func TestDiscount_RejectsExpiredAndNotYetStarted(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+
+	_, err := promotion.Discount(&model.Coupon{Type: model.DiscountPercentage, PercentOff: 10, Active: true, ExpiresAt: &past}, sampleItems(), now)
+	assert.ErrorIs(t, err, promotion.ErrCouponExpired)
+
+	_, err = promotion.Discount(&model.Coupon{Type: model.DiscountPercentage, PercentOff: 10, Active: true, StartsAt: &future}, sampleItems(), now)
+	assert.ErrorIs(t, err, promotion.ErrCouponInactive)
+}
+
+func TestDiscount_CategoryWithoutItemsIsNotApplicable(t *testing.T) {
+	coupon := &model.Coupon{Type: model.DiscountPercentage, PercentOff: 10, Category: "software", Active: true}
+
+	_, err := promotion.Discount(coupon, sampleItems(), time.Now())
+
+	assert.ErrorIs(t, err, promotion.ErrCouponNotApplicable)
+}
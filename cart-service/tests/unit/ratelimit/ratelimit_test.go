@@ -0,0 +1,129 @@
+package ratelimit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/emart/cart-service/internal/config"
+	"github.com/emart/cart-service/internal/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type MockLimiter struct{ mock.Mock }
+
+func (m *MockLimiter) Allow(ctx context.Context, key string, rule ratelimit.Rule) (ratelimit.Result, error) {
+	args := m.Called(ctx, key, rule)
+	return args.Get(0).(ratelimit.Result), args.Error(1)
+}
+
+func TestParseRule(t *testing.T) {
+	rule, ok, err := ratelimit.ParseRule("30/1m:10")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, ratelimit.Rule{Limit: 30, Period: time.Minute, Burst: 10}, rule)
+
+	rule, ok, err = ratelimit.ParseRule("5/1s")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 5, rule.Burst, "burst defaults to the limit")
+
+	_, ok, err = ratelimit.ParseRule("off")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	for _, bad := range []string{"", "30", "30/minute", "0/1m", "30/1m:0", "x/1m"} {
+		_, _, err := ratelimit.ParseRule(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestPolicy_RouteRulesOverrideDefault(t *testing.T) {
+	policy, err := ratelimit.NewPolicy(config.RateLimitConfig{
+		Default: "100/1m",
+		Routes: map[string]string{
+			"POST /api/v1/cart/items": "10/1m:2",
+			"GET /api/v1/cart":        "off",
+		},
+	})
+	require.NoError(t, err)
+
+	bucket, rule, ok := policy.For("POST", "/api/v1/cart/items")
+	assert.True(t, ok)
+	assert.Equal(t, "POST /api/v1/cart/items", bucket)
+	assert.Equal(t, 2, rule.Burst)
+
+	bucket, rule, ok = policy.For("DELETE", "/api/v1/cart")
+	assert.True(t, ok)
+	assert.Equal(t, "default", bucket)
+	assert.Equal(t, 100, rule.Limit)
+
+	_, _, ok = policy.For("GET", "/api/v1/cart")
+	assert.False(t, ok)
+}
+
+func TestPolicy_RejectsBadConfig(t *testing.T) {
+	_, err := ratelimit.NewPolicy(config.RateLimitConfig{Default: "lots"})
+	assert.Error(t, err)
+
+	_, err = ratelimit.NewPolicy(config.RateLimitConfig{Default: "off", Routes: map[string]string{"/api/v1/cart": "10/1m"}})
+	assert.Error(t, err, "route without a method")
+}
+
+func TestMemoryLimiter_BurstThenRefill(t *testing.T) {
+	limiter := ratelimit.NewMemoryLimiter()
+	rule := ratelimit.Rule{Limit: 20, Period: time.Second, Burst: 2}
+	ctx := context.Background()
+
+	first, _ := limiter.Allow(ctx, "k", rule)
+	second, _ := limiter.Allow(ctx, "k", rule)
+	third, _ := limiter.Allow(ctx, "k", rule)
+	assert.True(t, first.Allowed)
+	assert.Equal(t, 1, first.Remaining)
+	assert.True(t, second.Allowed)
+	assert.False(t, third.Allowed)
+	assert.Greater(t, third.RetryAfter, time.Duration(0))
+	assert.LessOrEqual(t, third.RetryAfter, 50*time.Millisecond)
+
+	other, _ := limiter.Allow(ctx, "other", rule)
+	assert.True(t, other.Allowed, "buckets are per key")
+
+	time.Sleep(60 * time.Millisecond) // one token at 20/s
+	refilled, _ := limiter.Allow(ctx, "k", rule)
+	assert.True(t, refilled.Allowed)
+}
+
+func TestFallbackLimiter_UsesMemoryWhileRedisIsDown(t *testing.T) {
+	primary := new(MockLimiter)
+	secondary := new(MockLimiter)
+	rule := ratelimit.Rule{Limit: 10, Period: time.Minute, Burst: 10}
+	primary.On("Allow", mock.Anything, "k", rule).Return(ratelimit.Result{}, errors.New("dial tcp: connection refused")).Once()
+	secondary.On("Allow", mock.Anything, "k", rule).Return(ratelimit.Result{Allowed: true, Remaining: 9}, nil)
+
+	limiter := ratelimit.NewFallbackLimiter(primary, secondary, zap.NewNop())
+	for i := 0; i < 3; i++ {
+		res, err := limiter.Allow(context.Background(), "k", rule)
+		require.NoError(t, err)
+		assert.True(t, res.Allowed)
+	}
+
+	// Redis is not retried on every request while it is down
+	primary.AssertNumberOfCalls(t, "Allow", 1)
+	secondary.AssertNumberOfCalls(t, "Allow", 3)
+}
+
+func TestFallbackLimiter_PrefersRedis(t *testing.T) {
+	primary := new(MockLimiter)
+	secondary := new(MockLimiter)
+	rule := ratelimit.Rule{Limit: 10, Period: time.Minute, Burst: 10}
+	primary.On("Allow", mock.Anything, "k", rule).Return(ratelimit.Result{Allowed: false, RetryAfter: time.Second}, nil)
+
+	res, err := ratelimit.NewFallbackLimiter(primary, secondary, zap.NewNop()).Allow(context.Background(), "k", rule)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	secondary.AssertNotCalled(t, "Allow", mock.Anything, mock.Anything, mock.Anything)
+}
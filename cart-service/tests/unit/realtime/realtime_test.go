@@ -0,0 +1,128 @@
+package realtime_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/emart/cart-service/internal/model"
+	"github.com/emart/cart-service/internal/realtime"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestMemoryBroker_DeliversToThatCartsSubscribersOnly(t *testing.T) {
+	broker := realtime.NewMemoryBroker(0)
+	ctx := context.Background()
+	tab1, err := broker.Subscribe(ctx, "user-1")
+	require.NoError(t, err)
+	tab2, _ := broker.Subscribe(ctx, "user-1")
+	other, _ := broker.Subscribe(ctx, "user-2")
+
+	broker.PublishCart(ctx, &model.Cart{UserID: "user-1", Version: 1})
+
+	assert.Equal(t, int64(1), (<-tab1.Updates()).Version)
+	assert.Equal(t, int64(1), (<-tab2.Updates()).Version)
+	assert.Empty(t, other.Updates())
+}
+
+func TestMemoryBroker_SlowReaderGetsLatestCart(t *testing.T) {
+	broker := realtime.NewMemoryBroker(0)
+	ctx := context.Background()
+	sub, _ := broker.Subscribe(ctx, "user-1")
+
+	for v := int64(1); v <= 3; v++ {
+		broker.PublishCart(ctx, &model.Cart{UserID: "user-1", Version: v})
+	}
+
+	assert.Equal(t, int64(3), (<-sub.Updates()).Version)
+	assert.Empty(t, sub.Updates())
+}
+
+func TestMemoryBroker_LimitsStreamsPerCart(t *testing.T) {
+	broker := realtime.NewMemoryBroker(2)
+	ctx := context.Background()
+	first, _ := broker.Subscribe(ctx, "user-1")
+	broker.Subscribe(ctx, "user-1")
+
+	_, err := broker.Subscribe(ctx, "user-1")
+	assert.ErrorIs(t, err, realtime.ErrTooManyStreams)
+
+	first.Close()
+	first.Close() // idempotent
+	_, err = broker.Subscribe(ctx, "user-1")
+	assert.NoError(t, err, "a closed stream frees its slot")
+}
+
+func TestMemoryBroker_CloseEndsStreams(t *testing.T) {
+	broker := realtime.NewMemoryBroker(0)
+	sub, _ := broker.Subscribe(context.Background(), "user-1")
+
+	broker.Close()
+
+	select {
+	case <-sub.Done():
+	default:
+		t.Fatal("subscription still open after Close")
+	}
+	_, err := broker.Subscribe(context.Background(), "user-1")
+	assert.ErrorIs(t, err, realtime.ErrClosed)
+}
+
+// silentRedis accepts connections and never answers, so every round trip
+// hangs; accepted signals each connection
+func silentRedis(t *testing.T) (addr string, accepted <-chan struct{}) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	signal := make(chan struct{}, 8)
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, conn := range conns {
+				conn.Close()
+			}
+		}()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, conn)
+			signal <- struct{}{}
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return ln.Addr().String(), signal
+}
+
+func TestRedisBroker_SlowSubscribeDoesNotBlockOtherStreams(t *testing.T) {
+	addr, accepted := silentRedis(t)
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	defer client.Close()
+	broker := realtime.NewRedisBroker(client, 1, zap.NewNop())
+
+	stuckCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go broker.Subscribe(stuckCtx, "user-1") // ends once the cleanup drops the connection
+	select {
+	case <-accepted: // the first stream is now waiting on Redis
+	case <-time.After(2 * time.Second):
+		t.Fatal("broker never dialled Redis")
+	}
+
+	second := make(chan error, 1)
+	go func() {
+		_, err := broker.Subscribe(context.Background(), "user-1")
+		second <- err
+	}()
+	select {
+	case err := <-second:
+		assert.ErrorIs(t, err, realtime.ErrTooManyStreams, "the slot is taken by the stream still subscribing")
+	case <-time.After(time.Second):
+		t.Fatal("Subscribe waited for another stream's Redis round trip")
+	}
+}
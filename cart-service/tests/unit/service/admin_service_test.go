@@ -0,0 +1,150 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/emart/cart-service/internal/config"
+	"github.com/emart/cart-service/internal/model"
+	"github.com/emart/cart-service/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+type MockAdminCartRepo struct{ mock.Mock }
+
+func (m *MockAdminCartRepo) ListCarts(ctx context.Context, filter model.AdminCartFilter) ([]*model.Cart, int64, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]*model.Cart), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *MockAdminCartRepo) CountActive(ctx context.Context, since time.Time) (int64, error) {
+	args := m.Called(ctx, since)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+type MockAuditRepo struct{ mock.Mock }
+
+func (m *MockAuditRepo) Record(ctx context.Context, entry *model.AuditEntry) error {
+	return m.Called(ctx, entry).Error(0)
+}
+func (m *MockAuditRepo) ListForUser(ctx context.Context, targetUserID string, limit int) ([]*model.AuditEntry, error) {
+	args := m.Called(ctx, targetUserID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.AuditEntry), args.Error(1)
+}
+
+// recorded returns the audit entries passed to Record, in order
+func (m *MockAuditRepo) recorded() []*model.AuditEntry {
+	var entries []*model.AuditEntry
+	for _, call := range m.Calls {
+		if call.Method == "Record" {
+			entries = append(entries, call.Arguments.Get(1).(*model.AuditEntry))
+		}
+	}
+	return entries
+}
+
+var supportAgent = model.Actor{UserID: "staff-1", Email: "agent@emart.example", IP: "10.0.0.7"}
+
+func setupAdminService(t *testing.T) (service.AdminService, *testDeps, *MockAdminCartRepo, *MockAuditRepo) {
+	t.Helper()
+	cartSvc, deps := setupServiceDeps(t)
+	adminRepo := new(MockAdminCartRepo)
+	auditRepo := new(MockAuditRepo)
+	auditRepo.On("Record", mock.Anything, mock.Anything).Return(nil).Maybe()
+	svc := service.NewAdminService(*cartSvc, adminRepo, auditRepo, &config.Config{}, zap.NewNop())
+	return svc, deps, adminRepo, auditRepo
+}
+
+func TestAdminUpdateItemQuantity_ChangesCustomerCartAndAudits(t *testing.T) {
+	svc, deps, _, auditRepo := setupAdminService(t)
+
+	deps.redisRepo.On("GetCart", mock.Anything, "customer-1").Return(&model.Cart{UserID: "customer-1", Version: 3, Items: []model.CartItem{
+		{ItemID: "item-a", ProductID: "p1", Category: "books", Price: inr("10.00"), Quantity: 1},
+	}}, nil)
+	deps.redisRepo.On("SaveCart", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	deps.mongoRepo.On("UpsertCart", mock.Anything, mock.Anything).Return(nil)
+
+	cart, err := svc.UpdateItemQuantity(context.Background(), supportAgent, "customer-1", "item-a", 5)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, cart.Items[0].Quantity)
+
+	entries := auditRepo.recorded()
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, supportAgent, entries[0].Actor)
+		assert.Equal(t, model.AuditUpdateItem, entries[0].Action)
+		assert.Equal(t, "customer-1", entries[0].TargetUserID)
+		assert.Equal(t, map[string]interface{}{"item_id": "item-a", "quantity": 5, "version": int64(4)}, entries[0].Details)
+		assert.Empty(t, entries[0].Error)
+	}
+}
+
+func TestAdminClearCart_AuditsFailedAttempt(t *testing.T) {
+	svc, deps, _, auditRepo := setupAdminService(t)
+
+	deps.redisRepo.On("GetCart", mock.Anything, "customer-2").Return(lockedCart("customer-2", "sess-9", time.Minute), nil)
+
+	err := svc.ClearCart(context.Background(), supportAgent, "customer-2")
+	assert.ErrorIs(t, err, model.ErrCartLocked)
+
+	entries := auditRepo.recorded()
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, model.AuditClearCart, entries[0].Action)
+		assert.Equal(t, model.ErrCartLocked.Error(), entries[0].Error)
+	}
+}
+
+func TestAdminGetCart_SucceedsWhenAuditWriteFails(t *testing.T) {
+	cartSvc, deps := setupServiceDeps(t)
+	auditRepo := new(MockAuditRepo)
+	auditRepo.On("Record", mock.Anything, mock.Anything).Return(errors.New("mongo down"))
+	svc := service.NewAdminService(*cartSvc, new(MockAdminCartRepo), auditRepo, &config.Config{}, zap.NewNop())
+
+	deps.redisRepo.On("GetCart", mock.Anything, "customer-3").Return(&model.Cart{UserID: "customer-3", Items: []model.CartItem{}}, nil)
+
+	cart, err := svc.GetCart(context.Background(), supportAgent, "customer-3")
+	assert.NoError(t, err)
+	assert.Equal(t, "customer-3", cart.UserID)
+	auditRepo.AssertNumberOfCalls(t, "Record", 1)
+}
+
+func TestAdminListCarts_DefaultsAndCapsPageSize(t *testing.T) {
+	svc, _, adminRepo, auditRepo := setupAdminService(t)
+
+	adminRepo.On("ListCarts", mock.Anything, mock.MatchedBy(func(f model.AdminCartFilter) bool { return f.Limit == 50 })).
+		Return([]*model.Cart{{UserID: "customer-4"}}, int64(1), nil).Once()
+	adminRepo.On("ListCarts", mock.Anything, mock.MatchedBy(func(f model.AdminCartFilter) bool { return f.Limit == 200 })).
+		Return([]*model.Cart{}, int64(0), nil).Once()
+
+	page, err := svc.ListCarts(context.Background(), supportAgent, model.AdminCartFilter{Status: model.AdminCartsAbandoned})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), page.Total)
+	assert.Equal(t, 50, page.Limit)
+
+	_, err = svc.ListCarts(context.Background(), supportAgent, model.AdminCartFilter{Limit: 5000})
+	assert.NoError(t, err)
+	adminRepo.AssertExpectations(t)
+
+	entries := auditRepo.recorded()
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, model.AuditListCarts, entries[0].Action)
+		assert.Equal(t, "abandoned", entries[0].Details["status"])
+	}
+}
+
+func TestAdminListCarts_RejectsUnknownStatus(t *testing.T) {
+	svc, _, adminRepo, _ := setupAdminService(t)
+
+	_, err := svc.ListCarts(context.Background(), supportAgent, model.AdminCartFilter{Status: "deleted"})
+	assert.ErrorIs(t, err, model.ErrInvalidAdminFilter)
+	adminRepo.AssertNotCalled(t, "ListCarts", mock.Anything, mock.Anything)
+}
@@ -6,7 +6,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/emart/cart-service/internal/catalog"
+	"github.com/emart/cart-service/internal/config"
 	"github.com/emart/cart-service/internal/model"
+	"github.com/emart/cart-service/internal/promotion"
+	"github.com/emart/cart-service/internal/realtime"
 	"github.com/emart/cart-service/internal/service"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -30,10 +34,18 @@ func (m *MockRedisRepo) SaveCart(ctx context.Context, cart *model.Cart, ttl time
 func (m *MockRedisRepo) DeleteCart(ctx context.Context, userID string) error {
 	return m.Called(ctx, userID).Error(0)
 }
-func (m *MockRedisRepo) GetAllCartKeys(ctx context.Context) ([]string, error) {
-	args := m.Called(ctx)
+func (m *MockRedisRepo) MarkDirty(ctx context.Context, userIDs ...string) error {
+	return m.Called(ctx, userIDs).Error(0)
+}
+func (m *MockRedisRepo) PopDirty(ctx context.Context, count int) ([]string, error) {
+	args := m.Called(ctx, count)
+	if args.Get(0) == nil { return nil, args.Error(1) }
 	return args.Get(0).([]string), args.Error(1)
 }
+func (m *MockRedisRepo) ScanCartUserIDs(ctx context.Context, cursor uint64, count int64) ([]string, uint64, error) {
+	args := m.Called(ctx, cursor, count)
+	return args.Get(0).([]string), args.Get(1).(uint64), args.Error(2)
+}
 func (m *MockRedisRepo) Ping(ctx context.Context) error { return m.Called(ctx).Error(0) }
 
 type MockMongoRepo struct{ mock.Mock }
@@ -49,19 +61,120 @@ func (m *MockMongoRepo) UpsertCart(ctx context.Context, cart *model.Cart) error
 func (m *MockMongoRepo) DeleteCart(ctx context.Context, userID string) error {
 	return m.Called(ctx, userID).Error(0)
 }
+func (m *MockMongoRepo) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
 func (m *MockMongoRepo) Ping(ctx context.Context) error { return m.Called(ctx).Error(0) }
 
+type MockCouponRepo struct{ mock.Mock }
+
+func (m *MockCouponRepo) GetCoupon(ctx context.Context, code string) (*model.Coupon, error) {
+	args := m.Called(ctx, code)
+	if args.Get(0) == nil { return nil, args.Error(1) }
+	return args.Get(0).(*model.Coupon), args.Error(1)
+}
+func (m *MockCouponRepo) CountRedemptions(ctx context.Context, code, userID string) (int64, error) {
+	args := m.Called(ctx, code, userID)
+	return args.Get(0).(int64), args.Error(1)
+}
+func (m *MockCouponRepo) RedeemCoupon(ctx context.Context, redemption *model.CouponRedemption, limit int) (bool, error) {
+	args := m.Called(ctx, redemption, limit)
+	return args.Bool(0), args.Error(1)
+}
+func (m *MockCouponRepo) UnredeemCoupon(ctx context.Context, code, userID, orderRef string) error {
+	return m.Called(ctx, code, userID, orderRef).Error(0)
+}
+
+type MockSessionRepo struct{ mock.Mock }
+
+func (m *MockSessionRepo) Create(ctx context.Context, session *model.CheckoutSession) error {
+	return m.Called(ctx, session).Error(0)
+}
+func (m *MockSessionRepo) Get(ctx context.Context, sessionID string) (*model.CheckoutSession, error) {
+	args := m.Called(ctx, sessionID)
+	if args.Get(0) == nil { return nil, args.Error(1) }
+	return args.Get(0).(*model.CheckoutSession), args.Error(1)
+}
+func (m *MockSessionRepo) Complete(ctx context.Context, sessionID, userID, orderID string, now time.Time) (*model.CheckoutSession, error) {
+	args := m.Called(ctx, sessionID, userID, orderID, now)
+	if args.Get(0) == nil { return nil, args.Error(1) }
+	return args.Get(0).(*model.CheckoutSession), args.Error(1)
+}
+func (m *MockSessionRepo) Cancel(ctx context.Context, sessionID, userID string, now time.Time) (*model.CheckoutSession, error) {
+	args := m.Called(ctx, sessionID, userID, now)
+	if args.Get(0) == nil { return nil, args.Error(1) }
+	return args.Get(0).(*model.CheckoutSession), args.Error(1)
+}
+
+type MockPublisher struct{ mock.Mock }
+
+func (m *MockPublisher) Publish(ctx context.Context, events ...model.CartEvent) error {
+	return m.Called(ctx, events).Error(0)
+}
+
+// publishedTypes lists the event types passed to every Publish call, in order
+func (m *MockPublisher) publishedTypes() []model.CartEventType {
+	var types []model.CartEventType
+	for _, call := range m.Calls {
+		for _, e := range call.Arguments.Get(1).([]model.CartEvent) {
+			types = append(types, e.Type)
+		}
+	}
+	return types
+}
+
+// inr builds a Money value in the default currency
+func inr(amount string) model.Money {
+	m, _ := model.ParseMoney(amount, model.DefaultCurrency)
+	return m
+}
+
 // ============================================================
 // Unit Tests
 // ============================================================
 
+// testDeps exposes every collaborator of the service under test
+type testDeps struct {
+	redisRepo  *MockRedisRepo
+	mongoRepo  *MockMongoRepo
+	couponRepo *MockCouponRepo
+	sessionRepo *MockSessionRepo
+	publisher  *MockPublisher
+	updates    *realtime.MemoryBroker
+}
+
 func setupService(t *testing.T) (*service.CartService, *MockRedisRepo, *MockMongoRepo) {
+	t.Helper()
+	svc, deps := setupServiceDeps(t)
+	return svc, deps.redisRepo, deps.mongoRepo
+}
+
+func setupServiceDeps(t *testing.T) (*service.CartService, *testDeps) {
 	t.Helper()
 	redisRepo := new(MockRedisRepo)
 	mongoRepo := new(MockMongoRepo)
 	logger, _ := zap.NewDevelopment()
-	svc := service.NewCartService(redisRepo, mongoRepo, 7*24*time.Hour, logger)
-	return &svc, redisRepo, mongoRepo
+	products := catalog.NewFakeClient(
+		catalog.Product{ID: "book-001", Category: "books", Name: "Go Programming", Price: inr("29.99"), Stock: 10, Active: true},
+		catalog.Product{ID: "course-001", Category: "courses", Name: "React Course", Price: inr("49.99"), Stock: 10, Active: true},
+		catalog.Product{ID: "p1", Category: "books", Name: "Test", Price: inr("10.00"), Stock: 10, Active: true},
+		catalog.Product{ID: "retired", Category: "books", Name: "Old Edition", Price: inr("5.00"), Stock: 10, Active: false},
+		catalog.Product{ID: "bulk-001", Category: "books", Name: "Exercise Book", Price: inr("10.00"), Stock: 500, Active: true},
+	)
+	cfg := &config.Config{
+		Redis: config.RedisConfig{TTL: 7 * 24 * time.Hour},
+		Guest: config.GuestConfig{TTL: 48 * time.Hour, MergeStrategy: "sum", MaxItemQuantity: 100},
+		Checkout: config.CheckoutConfig{SessionTTL: 15 * time.Minute},
+		Promotion: config.PromotionConfig{MaxCouponsPerCart: 2},
+	}
+	couponRepo := new(MockCouponRepo)
+	sessionRepo := new(MockSessionRepo)
+	publisher := new(MockPublisher)
+	updates := realtime.NewMemoryBroker(0)
+	svc := service.NewCartService(redisRepo, mongoRepo, sessionRepo, products, promotion.NewEngine(couponRepo, cfg.Promotion.MaxCouponsPerCart), publisher, updates, cfg, logger)
+	redisRepo.On("MarkDirty", mock.Anything, mock.Anything).Return(nil).Maybe()
+	publisher.On("Publish", mock.Anything, mock.Anything).Return(nil).Maybe()
+	return &svc, &testDeps{redisRepo: redisRepo, mongoRepo: mongoRepo, couponRepo: couponRepo, sessionRepo: sessionRepo, publisher: publisher, updates: updates}
 }
 
 func TestGetCart_FromRedis(t *testing.T) {
@@ -121,7 +234,7 @@ func TestAddItem_AddsNewProduct(t *testing.T) {
 	assert.Len(t, cart.Items, 1)
 	assert.Equal(t, "book-001", cart.Items[0].ProductID)
 	assert.Equal(t, 1, cart.TotalItems)
-	assert.InDelta(t, 29.99, cart.TotalPrice, 0.001)
+	assert.Equal(t, "29.99", cart.TotalPrice.String())
 }
 
 func TestAddItem_IncreasesQuantity_WhenProductExists(t *testing.T) {
@@ -131,7 +244,7 @@ func TestAddItem_IncreasesQuantity_WhenProductExists(t *testing.T) {
 		UserID: "user4",
 		Items: []model.CartItem{
 			{ItemID: "item-1", ProductID: "course-001", ProductName: "React Course",
-			 Category: "courses", Price: 49.99, Quantity: 1},
+			 Category: "courses", Price: inr("49.99"), Quantity: 1},
 		},
 	}
 	redisRepo.On("GetCart", mock.Anything, "user4").Return(existingCart, nil)
@@ -155,8 +268,8 @@ func TestRemoveItem_RemovesCorrectItem(t *testing.T) {
 	existingCart := &model.Cart{
 		UserID: "user5",
 		Items: []model.CartItem{
-			{ItemID: "item-a", ProductID: "sw-001", Category: "software", Price: 99.99, Quantity: 1},
-			{ItemID: "item-b", ProductID: "bk-002", Category: "books", Price: 19.99, Quantity: 2},
+			{ItemID: "item-a", ProductID: "sw-001", Category: "software", Price: inr("99.99"), Quantity: 1},
+			{ItemID: "item-b", ProductID: "bk-002", Category: "books", Price: inr("19.99"), Quantity: 2},
 		},
 	}
 	redisRepo.On("GetCart", mock.Anything, "user5").Return(existingCart, nil)
@@ -184,9 +297,62 @@ func TestRemoveItem_ErrorWhenItemNotFound(t *testing.T) {
 	assert.Contains(t, err.Error(), "not found")
 }
 
+func TestUpdateItemQuantity_RejectsStaleIfMatch(t *testing.T) {
+	svc, redisRepo, _ := setupService(t)
+	existingCart := &model.Cart{
+		UserID: "user8", Version: 4, UpdatedAt: time.Now(),
+		Items:  []model.CartItem{{ItemID: "item-a", ProductID: "book-001", Category: "books", Price: inr("29.99"), Quantity: 1}},
+	}
+	redisRepo.On("GetCart", mock.Anything, "user8").Return(existingCart, nil)
+
+	ctx := service.WithIfMatch(context.Background(), []string{`"stale"`})
+	_, err := (*svc).UpdateItemQuantity(ctx, "user8", "item-a", 3)
+
+	assert.ErrorIs(t, err, model.ErrPreconditionFailed)
+	redisRepo.AssertNotCalled(t, "SaveCart", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpdateItemQuantity_AppliesWhenIfMatchHolds(t *testing.T) {
+	svc, redisRepo, mongoRepo := setupService(t)
+	existingCart := &model.Cart{
+		UserID: "user9", Version: 4, UpdatedAt: time.Now(),
+		Items:  []model.CartItem{{ItemID: "item-a", ProductID: "book-001", Category: "books", Price: inr("29.99"), Quantity: 1}},
+	}
+	redisRepo.On("GetCart", mock.Anything, "user9").Return(existingCart, nil)
+	redisRepo.On("SaveCart", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mongoRepo.On("UpsertCart", mock.Anything, mock.Anything).Return(nil)
+	current, err := (*svc).GetCart(context.Background(), "user9")
+	assert.NoError(t, err)
+	etag := current.ETag()
+
+	ctx := service.WithIfMatch(context.Background(), []string{`"other"`, etag})
+	cart, err := (*svc).UpdateItemQuantity(ctx, "user9", "item-a", 3)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, cart.Items[0].Quantity)
+	assert.NotEqual(t, etag, cart.ETag(), "the save moves the cart to a new tag")
+}
+
+func TestUpdateItemQuantity_RejectsIncreaseBeyondStock(t *testing.T) {
+	svc, redisRepo, mongoRepo := setupService(t)
+	existingCart := &model.Cart{UserID: "user9b", Items: []model.CartItem{
+		{ItemID: "item-a", ProductID: "book-001", Category: "books", Price: inr("29.99"), Quantity: 12},
+	}}
+	redisRepo.On("GetCart", mock.Anything, "user9b").Return(existingCart, nil)
+
+	_, err := (*svc).UpdateItemQuantity(context.Background(), "user9b", "item-a", 13)
+	assert.ErrorIs(t, err, model.ErrInsufficientStock)
+
+	redisRepo.On("SaveCart", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mongoRepo.On("UpsertCart", mock.Anything, mock.Anything).Return(nil)
+	_, err = (*svc).UpdateItemQuantity(context.Background(), "user9b", "item-a", 11)
+	assert.NoError(t, err, "lowering a line beyond stock is always allowed")
+}
+
 func TestClearCart_DeletesFromBothStores(t *testing.T) {
 	svc, redisRepo, mongoRepo := setupService(t)
 
+	redisRepo.On("GetCart", mock.Anything, "user7").Return(&model.Cart{UserID: "user7", Items: []model.CartItem{}}, nil)
 	redisRepo.On("DeleteCart", mock.Anything, "user7").Return(nil)
 	mongoRepo.On("DeleteCart", mock.Anything, "user7").Return(nil)
 
@@ -196,6 +362,54 @@ func TestClearCart_DeletesFromBothStores(t *testing.T) {
 	mongoRepo.AssertCalled(t, "DeleteCart", mock.Anything, "user7")
 }
 
+func TestSaveCart_PushesCommittedCartToStreams(t *testing.T) {
+	svc, deps := setupServiceDeps(t)
+	sub, err := deps.updates.Subscribe(context.Background(), "user10")
+	assert.NoError(t, err)
+	defer sub.Close()
+	deps.redisRepo.On("GetCart", mock.Anything, "user10").Return(&model.Cart{UserID: "user10", Items: []model.CartItem{}}, nil)
+	deps.redisRepo.On("SaveCart", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	deps.mongoRepo.On("UpsertCart", mock.Anything, mock.Anything).Return(nil)
+
+	cart, err := (*svc).AddItem(context.Background(), "user10", &model.AddItemRequest{ProductID: "book-001", Category: "books", Quantity: 1})
+	assert.NoError(t, err)
+
+	select {
+	case pushed := <-sub.Updates():
+		assert.Equal(t, cart.ETag(), pushed.ETag())
+	default:
+		t.Fatal("no update pushed")
+	}
+}
+
+func TestSaveCart_PushesNothingWhenMongoFails(t *testing.T) {
+	svc, deps := setupServiceDeps(t)
+	sub, _ := deps.updates.Subscribe(context.Background(), "user11")
+	defer sub.Close()
+	deps.redisRepo.On("GetCart", mock.Anything, "user11").Return(&model.Cart{UserID: "user11", Items: []model.CartItem{}}, nil)
+	deps.redisRepo.On("SaveCart", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	deps.mongoRepo.On("UpsertCart", mock.Anything, mock.Anything).Return(assert.AnError)
+
+	_, err := (*svc).AddItem(context.Background(), "user11", &model.AddItemRequest{ProductID: "book-001", Category: "books", Quantity: 1})
+	assert.Error(t, err)
+	assert.Empty(t, sub.Updates())
+}
+
+func TestClearCart_PushesEmptyCartToStreams(t *testing.T) {
+	svc, deps := setupServiceDeps(t)
+	sub, _ := deps.updates.Subscribe(context.Background(), "user12")
+	defer sub.Close()
+	deps.redisRepo.On("GetCart", mock.Anything, "user12").Return(&model.Cart{UserID: "user12", Version: 3, Items: []model.CartItem{{ItemID: "a", Quantity: 1}}}, nil)
+	deps.redisRepo.On("DeleteCart", mock.Anything, "user12").Return(nil)
+	deps.mongoRepo.On("DeleteCart", mock.Anything, "user12").Return(nil)
+
+	assert.NoError(t, (*svc).ClearCart(context.Background(), "user12"))
+
+	pushed := <-sub.Updates()
+	assert.Empty(t, pushed.Items)
+	assert.Zero(t, pushed.Version)
+}
+
 func TestAddItem_ReturnsError_WhenMongoFails(t *testing.T) {
 	svc, redisRepo, mongoRepo := setupService(t)
 
@@ -214,7 +428,7 @@ func TestGetCartSummary_ReturnsTotals(t *testing.T) {
 	cart := &model.Cart{
 		UserID:     "user9",
 		TotalItems: 5,
-		TotalPrice: 149.95,
+		TotalPrice: inr("149.95"),
 		Items:      []model.CartItem{},
 	}
 	redisRepo.On("GetCart", mock.Anything, "user9").Return(cart, nil)
@@ -222,5 +436,703 @@ func TestGetCartSummary_ReturnsTotals(t *testing.T) {
 	summary, err := (*svc).GetCartSummary(context.Background(), "user9")
 	assert.NoError(t, err)
 	assert.Equal(t, 5, summary.TotalItems)
-	assert.InDelta(t, 149.95, summary.TotalPrice, 0.001)
+	assert.Equal(t, "149.95", summary.TotalPrice.String())
+}
+
+func TestAddItem_RetriesOnVersionConflict(t *testing.T) {
+	svc, redisRepo, mongoRepo := setupService(t)
+
+	redisRepo.On("GetCart", mock.Anything, "user10").Return(&model.Cart{UserID: "user10", Items: []model.CartItem{}, Version: 4}, nil)
+	redisRepo.On("SaveCart", mock.Anything, mock.Anything, mock.Anything).Return(model.ErrVersionConflict).Once()
+	redisRepo.On("SaveCart", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mongoRepo.On("UpsertCart", mock.Anything, mock.Anything).Return(nil)
+
+	req := &model.AddItemRequest{ProductID: "p1", ProductName: "Test", Category: "books", Price: 10.0, Quantity: 1}
+	cart, err := (*svc).AddItem(context.Background(), "user10", req)
+
+	assert.NoError(t, err)
+	assert.Len(t, cart.Items, 1)
+	redisRepo.AssertNumberOfCalls(t, "GetCart", 2)
+	redisRepo.AssertNumberOfCalls(t, "SaveCart", 2)
+}
+
+func TestAddItem_ReturnsConflict_WhenRetriesExhausted(t *testing.T) {
+	svc, redisRepo, mongoRepo := setupService(t)
+
+	redisRepo.On("GetCart", mock.Anything, "user11").Return(&model.Cart{UserID: "user11", Items: []model.CartItem{}, Version: 2}, nil)
+	redisRepo.On("SaveCart", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	redisRepo.On("DeleteCart", mock.Anything, "user11").Return(nil)
+	mongoRepo.On("UpsertCart", mock.Anything, mock.Anything).Return(model.ErrVersionConflict)
+
+	req := &model.AddItemRequest{ProductID: "p1", ProductName: "Test", Category: "books", Price: 10.0, Quantity: 1}
+	_, err := (*svc).AddItem(context.Background(), "user11", req)
+
+	assert.ErrorIs(t, err, model.ErrVersionConflict)
+	mongoRepo.AssertNumberOfCalls(t, "UpsertCart", 3)
+	redisRepo.AssertCalled(t, "DeleteCart", mock.Anything, "user11") // stale Redis copy evicted
+}
+
+func TestSaveCart_BumpsVersion(t *testing.T) {
+	svc, redisRepo, mongoRepo := setupService(t)
+
+	redisRepo.On("GetCart", mock.Anything, "user12").Return(&model.Cart{UserID: "user12", Items: []model.CartItem{}, Version: 7}, nil)
+	redisRepo.On("SaveCart", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mongoRepo.On("UpsertCart", mock.Anything, mock.Anything).Return(nil)
+
+	req := &model.AddItemRequest{ProductID: "p1", ProductName: "Test", Category: "books", Price: 10.0, Quantity: 1}
+	cart, err := (*svc).AddItem(context.Background(), "user12", req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(8), cart.Version)
+}
+
+func TestAddItem_MarksCartDirty(t *testing.T) {
+	svc, redisRepo, mongoRepo := setupService(t)
+
+	redisRepo.On("GetCart", mock.Anything, "user13").Return(&model.Cart{UserID: "user13", Items: []model.CartItem{}}, nil)
+	redisRepo.On("SaveCart", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mongoRepo.On("UpsertCart", mock.Anything, mock.Anything).Return(nil)
+
+	req := &model.AddItemRequest{ProductID: "p1", ProductName: "Test", Category: "books", Price: 10.0, Quantity: 1}
+	_, err := (*svc).AddItem(context.Background(), "user13", req)
+
+	assert.NoError(t, err)
+	redisRepo.AssertCalled(t, "MarkDirty", mock.Anything, []string{"user13"})
+}
+
+func TestAddItem_RefusesToDowngradeNewerSchema(t *testing.T) {
+	svc, redisRepo, mongoRepo := setupService(t)
+
+	newer := &model.Cart{UserID: "user-newer", Items: []model.CartItem{}, SchemaVersion: model.CurrentSchemaVersion + 1}
+	redisRepo.On("GetCart", mock.Anything, "user-newer").Return(newer, nil)
+
+	req := &model.AddItemRequest{ProductID: "p1", ProductName: "Test", Category: "books", Price: 10.0, Quantity: 1}
+	_, err := (*svc).AddItem(context.Background(), "user-newer", req)
+
+	assert.ErrorIs(t, err, model.ErrSchemaTooNew)
+	redisRepo.AssertNotCalled(t, "SaveCart", mock.Anything, mock.Anything, mock.Anything)
+	mongoRepo.AssertNotCalled(t, "UpsertCart", mock.Anything, mock.Anything)
+}
+
+func TestAddItem_KeepsShopperEmailForReminders(t *testing.T) {
+	svc, redisRepo, mongoRepo := setupService(t)
+
+	redisRepo.On("GetCart", mock.Anything, "user-mail").Return(&model.Cart{UserID: "user-mail", Items: []model.CartItem{}}, nil)
+	redisRepo.On("SaveCart", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mongoRepo.On("UpsertCart", mock.Anything, mock.MatchedBy(func(c *model.Cart) bool {
+		return c.Email == "shopper@example.com"
+	})).Return(nil)
+
+	ctx := service.WithShopperEmail(context.Background(), "shopper@example.com")
+	req := &model.AddItemRequest{ProductID: "p1", ProductName: "Test", Category: "books", Price: 10.0, Quantity: 1}
+	_, err := (*svc).AddItem(ctx, "user-mail", req)
+
+	assert.NoError(t, err)
+	mongoRepo.AssertExpectations(t)
+}
+
+func TestAddItem_UsesCatalogPriceAndName(t *testing.T) {
+	svc, redisRepo, mongoRepo := setupService(t)
+
+	redisRepo.On("GetCart", mock.Anything, "user14").Return(&model.Cart{UserID: "user14", Items: []model.CartItem{}}, nil)
+	redisRepo.On("SaveCart", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mongoRepo.On("UpsertCart", mock.Anything, mock.Anything).Return(nil)
+
+	// Client tries to buy a $29.99 book for a cent
+	req := &model.AddItemRequest{ProductID: "book-001", ProductName: "Cheap", Category: "books", Price: 0.01, Quantity: 2}
+	cart, err := (*svc).AddItem(context.Background(), "user14", req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Go Programming", cart.Items[0].ProductName)
+	assert.Equal(t, "29.99", cart.Items[0].Price.String())
+	assert.Equal(t, "59.98", cart.TotalPrice.String())
+}
+
+func TestAddItem_RejectsUnknownProduct(t *testing.T) {
+	svc, _, _ := setupService(t)
+
+	req := &model.AddItemRequest{ProductID: "nope", Category: "books", Quantity: 1}
+	_, err := (*svc).AddItem(context.Background(), "user15", req)
+
+	assert.ErrorIs(t, err, catalog.ErrProductNotFound)
+}
+
+func TestAddItem_RejectsInactiveProduct(t *testing.T) {
+	svc, _, _ := setupService(t)
+
+	req := &model.AddItemRequest{ProductID: "retired", Category: "books", Quantity: 1}
+	_, err := (*svc).AddItem(context.Background(), "user16", req)
+
+	assert.ErrorIs(t, err, catalog.ErrProductInactive)
+}
+
+func TestAddItem_RejectsQuantityAboveStock(t *testing.T) {
+	svc, redisRepo, _ := setupService(t)
+
+	existingCart := &model.Cart{
+		UserID: "user17",
+		Items:  []model.CartItem{{ItemID: "item-1", ProductID: "p1", Category: "books", Price: inr("10.00"), Quantity: 8}},
+	}
+	redisRepo.On("GetCart", mock.Anything, "user17").Return(existingCart, nil)
+
+	req := &model.AddItemRequest{ProductID: "p1", Category: "books", Quantity: 3}
+	_, err := (*svc).AddItem(context.Background(), "user17", req)
+
+	assert.ErrorIs(t, err, model.ErrInsufficientStock)
+}
+
+func TestAddItem_GuestCartUsesShortTTLAndExpiry(t *testing.T) {
+	svc, redisRepo, mongoRepo := setupService(t)
+
+	redisRepo.On("GetCart", mock.Anything, "guest:abc").Return(&model.Cart{UserID: "guest:abc", Items: []model.CartItem{}}, nil)
+	redisRepo.On("SaveCart", mock.Anything, mock.Anything, 48*time.Hour).Return(nil)
+	mongoRepo.On("UpsertCart", mock.Anything, mock.Anything).Return(nil)
+
+	req := &model.AddItemRequest{ProductID: "p1", Category: "books", Quantity: 1}
+	cart, err := (*svc).AddItem(context.Background(), "guest:abc", req)
+
+	assert.NoError(t, err)
+	assert.NotNil(t, cart.ExpiresAt)
+	redisRepo.AssertCalled(t, "SaveCart", mock.Anything, mock.Anything, 48*time.Hour)
+}
+
+func TestMergeCart_SumsQuantitiesAndCaps(t *testing.T) {
+	svc, redisRepo, mongoRepo := setupService(t)
+
+	guestCart := &model.Cart{UserID: "guest:g1", Items: []model.CartItem{
+		{ItemID: "g-a", ProductID: "bulk-001", Category: "books", Price: inr("10.00"), Quantity: 60},
+		{ItemID: "g-b", ProductID: "course-001", Category: "courses", Price: inr("5.00"), Quantity: 1},
+	}}
+	userCart := &model.Cart{UserID: "user18", Items: []model.CartItem{
+		{ItemID: "u-a", ProductID: "bulk-001", Category: "books", Price: inr("10.00"), Quantity: 50},
+	}}
+	redisRepo.On("GetCart", mock.Anything, "guest:g1").Return(guestCart, nil)
+	redisRepo.On("GetCart", mock.Anything, "user18").Return(userCart, nil)
+	redisRepo.On("SaveCart", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mongoRepo.On("UpsertCart", mock.Anything, mock.Anything).Return(nil)
+	redisRepo.On("DeleteCart", mock.Anything, "guest:g1").Return(nil)
+	mongoRepo.On("DeleteCart", mock.Anything, "guest:g1").Return(nil)
+
+	cart, err := (*svc).MergeCart(context.Background(), "user18", "guest:g1", model.MergeSumQuantities)
+
+	assert.NoError(t, err)
+	assert.Len(t, cart.Items, 2)
+	assert.Equal(t, "u-a", cart.Items[0].ItemID)
+	assert.Equal(t, 100, cart.Items[0].Quantity) // 50 + 60 capped at 100
+	assert.Equal(t, 1, cart.Items[1].Quantity)
+	mongoRepo.AssertCalled(t, "DeleteCart", mock.Anything, "guest:g1")
+}
+
+func TestMergeCart_RetryAfterFailedGuestDeleteAddsNothing(t *testing.T) {
+	svc, redisRepo, mongoRepo := setupService(t)
+
+	guestCart := &model.Cart{UserID: "guest:g5", Version: 2, UpdatedAt: time.Now(), Items: []model.CartItem{
+		{ItemID: "g-a", ProductID: "p1", Category: "books", Price: inr("10.00"), Quantity: 2},
+	}}
+	userCart := &model.Cart{UserID: "user23", Items: []model.CartItem{
+		{ItemID: "u-a", ProductID: "p1", Category: "books", Price: inr("10.00"), Quantity: 1},
+	}}
+	redisRepo.On("GetCart", mock.Anything, "guest:g5").Return(guestCart, nil)
+	redisRepo.On("GetCart", mock.Anything, "user23").Return(userCart, nil)
+	redisRepo.On("SaveCart", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mongoRepo.On("UpsertCart", mock.Anything, mock.Anything).Return(nil)
+	redisRepo.On("DeleteCart", mock.Anything, "guest:g5").Return(nil)
+	mongoRepo.On("DeleteCart", mock.Anything, "guest:g5").Return(assert.AnError)
+
+	cart, err := (*svc).MergeCart(context.Background(), "user23", "guest:g5", model.MergeSumQuantities)
+	assert.NoError(t, err, "a guest cart left behind does not fail the merge")
+	assert.Equal(t, 3, cart.Items[0].Quantity)
+
+	cart, err = (*svc).MergeCart(context.Background(), "user23", "guest:g5", model.MergeSumQuantities)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, cart.Items[0].Quantity, "the same guest cart is not merged twice")
+}
+
+func TestMergeCart_KeepNewest(t *testing.T) {
+	svc, redisRepo, mongoRepo := setupService(t)
+
+	older := time.Now().Add(-time.Hour)
+	guestCart := &model.Cart{UserID: "guest:g2", Items: []model.CartItem{
+		{ItemID: "g-a", ProductID: "p1", Category: "books", Price: inr("10.00"), Quantity: 3, AddedAt: time.Now()},
+	}}
+	userCart := &model.Cart{UserID: "user19", Items: []model.CartItem{
+		{ItemID: "u-a", ProductID: "p1", Category: "books", Price: inr("10.00"), Quantity: 1, AddedAt: older},
+	}}
+	redisRepo.On("GetCart", mock.Anything, "guest:g2").Return(guestCart, nil)
+	redisRepo.On("GetCart", mock.Anything, "user19").Return(userCart, nil)
+	redisRepo.On("SaveCart", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mongoRepo.On("UpsertCart", mock.Anything, mock.Anything).Return(nil)
+	redisRepo.On("DeleteCart", mock.Anything, "guest:g2").Return(nil)
+	mongoRepo.On("DeleteCart", mock.Anything, "guest:g2").Return(nil)
+
+	cart, err := (*svc).MergeCart(context.Background(), "user19", "guest:g2", model.MergeKeepNewest)
+
+	assert.NoError(t, err)
+	assert.Len(t, cart.Items, 1)
+	assert.Equal(t, "u-a", cart.Items[0].ItemID) // user's line ID is kept
+	assert.Equal(t, 3, cart.Items[0].Quantity)
+}
+
+func TestMergeCart_RejectsSumBeyondStock(t *testing.T) {
+	svc, redisRepo, _ := setupService(t)
+
+	guestCart := &model.Cart{UserID: "guest:g3", Items: []model.CartItem{
+		{ItemID: "g-a", ProductID: "p1", Category: "books", Price: inr("10.00"), Quantity: 6},
+	}}
+	userCart := &model.Cart{UserID: "user19b", Items: []model.CartItem{
+		{ItemID: "u-a", ProductID: "p1", Category: "books", Price: inr("10.00"), Quantity: 5},
+	}}
+	redisRepo.On("GetCart", mock.Anything, "guest:g3").Return(guestCart, nil)
+	redisRepo.On("GetCart", mock.Anything, "user19b").Return(userCart, nil)
+
+	_, err := (*svc).MergeCart(context.Background(), "user19b", "guest:g3", model.MergeSumQuantities)
+
+	assert.ErrorIs(t, err, model.ErrInsufficientStock) // 11 of p1, 10 in stock
+	redisRepo.AssertNotCalled(t, "SaveCart", mock.Anything, mock.Anything, mock.Anything)
+	redisRepo.AssertNotCalled(t, "DeleteCart", mock.Anything, "guest:g3")
+}
+
+func TestMergeCart_RejectsNonGuestSource(t *testing.T) {
+	svc, _, _ := setupService(t)
+
+	_, err := (*svc).MergeCart(context.Background(), "user20", "user21", model.MergeSumQuantities)
+	assert.Error(t, err)
+}
+
+func TestApplyCoupon_AddsDiscountBreakdown(t *testing.T) {
+	svc, deps := setupServiceDeps(t)
+	redisRepo, mongoRepo, couponRepo := deps.redisRepo, deps.mongoRepo, deps.couponRepo
+
+	existingCart := &model.Cart{UserID: "user22", Items: []model.CartItem{
+		{ItemID: "item-1", ProductID: "book-001", Category: "books", Price: inr("29.99"), Quantity: 2},
+		{ItemID: "item-2", ProductID: "course-001", Category: "courses", Price: inr("49.99"), Quantity: 1},
+	}}
+	coupon := &model.Coupon{Code: "BOOKS10", Type: model.DiscountPercentage, PercentOff: 10, Category: "books", Active: true, MaxUsesPerUser: 1}
+	redisRepo.On("GetCart", mock.Anything, "user22").Return(existingCart, nil)
+	redisRepo.On("SaveCart", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mongoRepo.On("UpsertCart", mock.Anything, mock.Anything).Return(nil)
+	couponRepo.On("GetCoupon", mock.Anything, "BOOKS10").Return(coupon, nil)
+	couponRepo.On("CountRedemptions", mock.Anything, "BOOKS10", "user22").Return(int64(0), nil)
+
+	cart, err := (*svc).ApplyCoupon(context.Background(), "user22", " books10 ")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"BOOKS10"}, cart.Coupons)
+	assert.Equal(t, "109.97", cart.TotalPrice.String())
+	assert.Equal(t, "6.00", cart.DiscountTotal.String()) // 10% of 59.98, rounded
+	assert.Equal(t, "103.97", cart.GrandTotal.String())
+	assert.True(t, cart.Discounts[0].Applied)
+}
+
+func TestApplyCoupon_RejectsWhenUsageLimitReached(t *testing.T) {
+	svc, deps := setupServiceDeps(t)
+	redisRepo, couponRepo := deps.redisRepo, deps.couponRepo
+
+	existingCart := &model.Cart{UserID: "user23", Items: []model.CartItem{
+		{ItemID: "item-1", ProductID: "p1", Category: "books", Price: inr("10.00"), Quantity: 1},
+	}}
+	coupon := &model.Coupon{Code: "ONCE", Type: model.DiscountFixed, AmountOff: inr("5.00"), Active: true, MaxUsesPerUser: 1}
+	redisRepo.On("GetCart", mock.Anything, "user23").Return(existingCart, nil)
+	couponRepo.On("GetCoupon", mock.Anything, "ONCE").Return(coupon, nil)
+	couponRepo.On("CountRedemptions", mock.Anything, "ONCE", "user23").Return(int64(1), nil)
+
+	_, err := (*svc).ApplyCoupon(context.Background(), "user23", "ONCE")
+
+	assert.ErrorIs(t, err, promotion.ErrUsageLimitReached)
+	redisRepo.AssertNotCalled(t, "SaveCart", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestApplyCoupon_EnforcesStackingRules(t *testing.T) {
+	save5 := &model.Coupon{Code: "SAVE5", Type: model.DiscountFixed, AmountOff: inr("5.00"), Active: true}
+	solo := &model.Coupon{Code: "SOLO", Type: model.DiscountFixed, AmountOff: inr("8.00"), Active: true, Exclusive: true}
+	books := &model.Coupon{Code: "BOOKS10", Type: model.DiscountPercentage, PercentOff: 10, Active: true}
+	tests := []struct {
+		name    string
+		applied []string
+		code    string
+		wantErr error
+	}{
+		{"exclusive onto others", []string{"SAVE5"}, "SOLO", promotion.ErrCouponNotCombinable},
+		{"onto an exclusive one", []string{"SOLO"}, "SAVE5", promotion.ErrCouponNotCombinable},
+		{"beyond the limit", []string{"SAVE5", "BOOKS10"}, "SOLO", promotion.ErrTooManyCoupons},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps := setupServiceDeps(t)
+			existingCart := &model.Cart{UserID: "user60", Coupons: tt.applied, Items: []model.CartItem{
+				{ItemID: "item-1", ProductID: "p1", Category: "books", Price: inr("10.00"), Quantity: 2},
+			}}
+			deps.redisRepo.On("GetCart", mock.Anything, "user60").Return(existingCart, nil)
+			for _, c := range []*model.Coupon{save5, solo, books} {
+				deps.couponRepo.On("GetCoupon", mock.Anything, c.Code).Return(c, nil)
+			}
+
+			_, err := (*svc).ApplyCoupon(context.Background(), "user60", tt.code)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			deps.redisRepo.AssertNotCalled(t, "SaveCart", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestRemoveItem_KeepsCouponButMarksItNotApplied(t *testing.T) {
+	svc, deps := setupServiceDeps(t)
+	redisRepo, mongoRepo, couponRepo := deps.redisRepo, deps.mongoRepo, deps.couponRepo
+
+	existingCart := &model.Cart{UserID: "user24", Coupons: []string{"BIG50"}, Items: []model.CartItem{
+		{ItemID: "item-a", ProductID: "p1", Category: "books", Price: inr("40.00"), Quantity: 1},
+		{ItemID: "item-b", ProductID: "p2", Category: "books", Price: inr("20.00"), Quantity: 1},
+	}}
+	coupon := &model.Coupon{Code: "BIG50", Type: model.DiscountFixed, AmountOff: inr("5.00"), MinSpend: inr("50.00"), Active: true}
+	redisRepo.On("GetCart", mock.Anything, "user24").Return(existingCart, nil)
+	redisRepo.On("SaveCart", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mongoRepo.On("UpsertCart", mock.Anything, mock.Anything).Return(nil)
+	couponRepo.On("GetCoupon", mock.Anything, "BIG50").Return(coupon, nil)
+
+	cart, err := (*svc).RemoveItem(context.Background(), "user24", "item-b")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"BIG50"}, cart.Coupons)
+	assert.False(t, cart.Discounts[0].Applied)
+	assert.NotEmpty(t, cart.Discounts[0].Reason)
+	assert.Equal(t, "40.00", cart.GrandTotal.String())
+}
+
+func TestRemoveCoupon_ErrorWhenNotApplied(t *testing.T) {
+	svc, redisRepo, _ := setupService(t)
+
+	redisRepo.On("GetCart", mock.Anything, "user25").Return(&model.Cart{UserID: "user25", Items: []model.CartItem{}}, nil)
+
+	_, err := (*svc).RemoveCoupon(context.Background(), "user25", "SAVE10")
+	assert.Error(t, err)
+}
+
+func TestAddItem_PublishesItemAdded(t *testing.T) {
+	svc, deps := setupServiceDeps(t)
+
+	deps.redisRepo.On("GetCart", mock.Anything, "user26").Return(&model.Cart{UserID: "user26", Items: []model.CartItem{}, Version: 3}, nil)
+	deps.redisRepo.On("SaveCart", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	deps.mongoRepo.On("UpsertCart", mock.Anything, mock.Anything).Return(nil)
+
+	req := &model.AddItemRequest{ProductID: "p1", Category: "books", Quantity: 2}
+	_, err := (*svc).AddItem(context.Background(), "user26", req)
+
+	assert.NoError(t, err)
+	deps.publisher.AssertCalled(t, "Publish", mock.Anything, mock.MatchedBy(func(events []model.CartEvent) bool {
+		return len(events) == 1 && events[0].Type == model.EventItemAdded &&
+			events[0].Item.ProductID == "p1" && events[0].CartVersion == 4 && events[0].GrandTotal.String() == "20.00"
+	}))
+}
+
+func TestUpdateItemQuantity_PublishesQuantityChangedAndItemRemoved(t *testing.T) {
+	svc, deps := setupServiceDeps(t)
+
+	existingCart := &model.Cart{UserID: "user27", Items: []model.CartItem{
+		{ItemID: "item-a", ProductID: "p1", Category: "books", Price: inr("10.00"), Quantity: 1},
+		{ItemID: "item-b", ProductID: "p2", Category: "books", Price: inr("20.00"), Quantity: 1},
+	}}
+	deps.redisRepo.On("GetCart", mock.Anything, "user27").Return(existingCart, nil)
+	deps.redisRepo.On("SaveCart", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	deps.mongoRepo.On("UpsertCart", mock.Anything, mock.Anything).Return(nil)
+
+	_, err := (*svc).UpdateItemQuantity(context.Background(), "user27", "item-a", 4)
+	assert.NoError(t, err)
+	_, err = (*svc).RemoveItem(context.Background(), "user27", "item-b")
+	assert.NoError(t, err)
+
+	assert.Equal(t, []model.CartEventType{model.EventQuantityChanged, model.EventItemRemoved}, deps.publisher.publishedTypes())
+}
+
+func TestAddItem_DoesNotPublishWhenMongoFails(t *testing.T) {
+	svc, deps := setupServiceDeps(t)
+
+	deps.redisRepo.On("GetCart", mock.Anything, "user28").Return(&model.Cart{UserID: "user28", Items: []model.CartItem{}}, nil)
+	deps.redisRepo.On("SaveCart", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	deps.mongoRepo.On("UpsertCart", mock.Anything, mock.Anything).Return(errors.New("mongo down"))
+
+	req := &model.AddItemRequest{ProductID: "p1", Category: "books", Quantity: 1}
+	_, err := (*svc).AddItem(context.Background(), "user28", req)
+
+	assert.Error(t, err)
+	deps.publisher.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything)
+}
+
+func TestAddItem_DirectPublishFailureKeepsTheSave(t *testing.T) {
+	svc, deps := setupServiceDeps(t)
+	deps.publisher.ExpectedCalls = nil
+	deps.publisher.On("Publish", mock.Anything, mock.Anything).Return(errors.New("redis down"))
+
+	deps.redisRepo.On("GetCart", mock.Anything, "user28b").Return(&model.Cart{UserID: "user28b", Items: []model.CartItem{}}, nil)
+	deps.redisRepo.On("SaveCart", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	deps.mongoRepo.On("UpsertCart", mock.Anything, mock.Anything).Return(nil)
+
+	req := &model.AddItemRequest{ProductID: "p1", Category: "books", Quantity: 1}
+	_, err := (*svc).AddItem(context.Background(), "user28b", req)
+
+	// Published after the commit: the cart is saved either way
+	assert.NoError(t, err)
+	deps.publisher.AssertNumberOfCalls(t, "Publish", 1)
+}
+
+func TestClearCart_PublishesCartCleared(t *testing.T) {
+	svc, deps := setupServiceDeps(t)
+
+	deps.redisRepo.On("GetCart", mock.Anything, "user29").Return(&model.Cart{UserID: "user29", Items: []model.CartItem{}}, nil)
+	deps.redisRepo.On("DeleteCart", mock.Anything, "user29").Return(nil)
+	deps.mongoRepo.On("DeleteCart", mock.Anything, "user29").Return(nil)
+
+	err := (*svc).ClearCart(context.Background(), "user29")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []model.CartEventType{model.EventCartCleared}, deps.publisher.publishedTypes())
+}
+
+func TestCheckoutCart_RedeemsCouponsAndPublishesCheckedOut(t *testing.T) {
+	svc, deps := setupServiceDeps(t)
+
+	existingCart := &model.Cart{UserID: "user30", Coupons: []string{"SAVE5"}, Items: []model.CartItem{
+		{ItemID: "item-a", ProductID: "p1", Category: "books", Price: inr("10.00"), Quantity: 2},
+	}, TotalItems: 2, TotalPrice: inr("20.00")}
+	coupon := &model.Coupon{Code: "SAVE5", Type: model.DiscountFixed, AmountOff: inr("5.00"), Active: true}
+	deps.redisRepo.On("GetCart", mock.Anything, "user30").Return(existingCart, nil)
+	deps.redisRepo.On("DeleteCart", mock.Anything, "user30").Return(nil)
+	deps.mongoRepo.On("DeleteCart", mock.Anything, "user30").Return(nil)
+	deps.couponRepo.On("GetCoupon", mock.Anything, "SAVE5").Return(coupon, nil)
+	deps.couponRepo.On("RedeemCoupon", mock.Anything, mock.Anything, 0).Return(true, nil)
+
+	err := (*svc).CheckoutCart(context.Background(), "user30", "order-77")
+
+	assert.NoError(t, err)
+	deps.couponRepo.AssertCalled(t, "RedeemCoupon", mock.Anything, mock.MatchedBy(func(r *model.CouponRedemption) bool {
+		return r.Code == "SAVE5" && r.UserID == "user30" && r.OrderRef == "order-77"
+	}), 0)
+	deps.publisher.AssertCalled(t, "Publish", mock.Anything, mock.MatchedBy(func(events []model.CartEvent) bool {
+		return len(events) == 1 && events[0].Type == model.EventCartCheckedOut &&
+			events[0].OrderID == "order-77" && events[0].GrandTotal.String() == "15.00"
+	}))
+}
+
+func TestCheckoutCart_FailsWhenUsageLimitWasReachedSinceApplying(t *testing.T) {
+	svc, deps := setupServiceDeps(t)
+
+	existingCart := &model.Cart{UserID: "user61", Coupons: []string{"SAVE5", "ONCE"}, Items: []model.CartItem{
+		{ItemID: "item-a", ProductID: "p1", Category: "books", Price: inr("10.00"), Quantity: 2},
+	}, TotalItems: 2, TotalPrice: inr("20.00")}
+	deps.redisRepo.On("GetCart", mock.Anything, "user61").Return(existingCart, nil)
+	deps.couponRepo.On("GetCoupon", mock.Anything, "SAVE5").Return(&model.Coupon{Code: "SAVE5", Type: model.DiscountFixed, AmountOff: inr("5.00"), Active: true}, nil)
+	deps.couponRepo.On("GetCoupon", mock.Anything, "ONCE").Return(&model.Coupon{Code: "ONCE", Type: model.DiscountFixed, AmountOff: inr("2.00"), Active: true, MaxUsesPerUser: 1}, nil)
+	deps.couponRepo.On("RedeemCoupon", mock.Anything, mock.MatchedBy(func(r *model.CouponRedemption) bool { return r.Code == "SAVE5" }), 0).Return(true, nil)
+	// Another checkout took the last use after ONCE was applied
+	deps.couponRepo.On("RedeemCoupon", mock.Anything, mock.MatchedBy(func(r *model.CouponRedemption) bool { return r.Code == "ONCE" }), 1).Return(false, nil)
+	deps.couponRepo.On("UnredeemCoupon", mock.Anything, "SAVE5", "user61", "order-79").Return(nil)
+
+	err := (*svc).CheckoutCart(context.Background(), "user61", "order-79")
+
+	assert.ErrorIs(t, err, promotion.ErrUsageLimitReached)
+	deps.couponRepo.AssertCalled(t, "UnredeemCoupon", mock.Anything, "SAVE5", "user61", "order-79")
+	deps.mongoRepo.AssertNotCalled(t, "DeleteCart", mock.Anything, mock.Anything)
+	deps.publisher.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything)
+}
+
+func TestCheckoutCart_RejectsEmptyCart(t *testing.T) {
+	svc, redisRepo, _ := setupService(t)
+
+	redisRepo.On("GetCart", mock.Anything, "user31").Return(&model.Cart{UserID: "user31", Items: []model.CartItem{}}, nil)
+
+	err := (*svc).CheckoutCart(context.Background(), "user31", "order-78")
+	assert.ErrorIs(t, err, model.ErrCartEmpty)
+}
+
+// lockedCart returns a cart held by checkout session sessionID for the next ttl
+func lockedCart(userID, sessionID string, ttl time.Duration) *model.Cart {
+	until := time.Now().Add(ttl)
+	return &model.Cart{UserID: userID, CheckoutSessionID: sessionID, LockedUntil: &until, Items: []model.CartItem{
+		{ItemID: "item-a", ProductID: "p1", Category: "books", Price: inr("10.00"), Quantity: 2},
+	}, TotalItems: 2, TotalPrice: inr("20.00"), Version: 4}
+}
+
+func TestAddItem_RejectedWhileCheckoutSessionHoldsCart(t *testing.T) {
+	svc, redisRepo, _ := setupService(t)
+
+	redisRepo.On("GetCart", mock.Anything, "user40").Return(lockedCart("user40", "sess-1", time.Minute), nil)
+
+	_, err := (*svc).AddItem(context.Background(), "user40", &model.AddItemRequest{ProductID: "p1", Category: "books", Quantity: 1})
+	assert.ErrorIs(t, err, model.ErrCartLocked)
+	redisRepo.AssertNotCalled(t, "SaveCart", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAddItem_ClearsLapsedLock(t *testing.T) {
+	svc, redisRepo, mongoRepo := setupService(t)
+
+	redisRepo.On("GetCart", mock.Anything, "user41").Return(lockedCart("user41", "sess-2", -time.Minute), nil)
+	redisRepo.On("SaveCart", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mongoRepo.On("UpsertCart", mock.Anything, mock.Anything).Return(nil)
+
+	cart, err := (*svc).AddItem(context.Background(), "user41", &model.AddItemRequest{ProductID: "p1", Category: "books", Quantity: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, cart.Items[0].Quantity)
+	assert.Empty(t, cart.CheckoutSessionID)
+	assert.Nil(t, cart.LockedUntil)
+}
+
+func TestClearCart_RejectedWhileCheckoutSessionHoldsCart(t *testing.T) {
+	svc, redisRepo, mongoRepo := setupService(t)
+
+	redisRepo.On("GetCart", mock.Anything, "user42").Return(lockedCart("user42", "sess-3", time.Minute), nil)
+
+	err := (*svc).ClearCart(context.Background(), "user42")
+	assert.ErrorIs(t, err, model.ErrCartLocked)
+	mongoRepo.AssertNotCalled(t, "DeleteCart", mock.Anything, mock.Anything)
+}
+
+func TestCreateCheckoutSession_LocksCartAndStoresSnapshot(t *testing.T) {
+	svc, deps := setupServiceDeps(t)
+
+	existingCart := &model.Cart{UserID: "user43", Items: []model.CartItem{
+		{ItemID: "item-a", ProductID: "p1", Category: "books", Price: inr("10.00"), Quantity: 2},
+	}, Version: 1}
+	deps.redisRepo.On("GetCart", mock.Anything, "user43").Return(existingCart, nil)
+	deps.redisRepo.On("SaveCart", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	deps.mongoRepo.On("UpsertCart", mock.Anything, mock.Anything).Return(nil)
+	deps.sessionRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+
+	session, err := (*svc).CreateCheckoutSession(context.Background(), "user43")
+
+	assert.NoError(t, err)
+	assert.Equal(t, model.CheckoutActive, session.Status)
+	assert.Equal(t, "20.00", session.Cart.GrandTotal.String())
+	assert.WithinDuration(t, time.Now().Add(15*time.Minute), session.ExpiresAt, 5*time.Second)
+	assert.Equal(t, session.ID, existingCart.CheckoutSessionID)
+	assert.True(t, existingCart.IsLocked(time.Now()))
+}
+
+func TestCreateCheckoutSession_ReturnsActiveSession(t *testing.T) {
+	svc, deps := setupServiceDeps(t)
+
+	cart := lockedCart("user44", "sess-4", 10*time.Minute)
+	active := &model.CheckoutSession{ID: "sess-4", UserID: "user44", Status: model.CheckoutActive, ExpiresAt: *cart.LockedUntil}
+	deps.redisRepo.On("GetCart", mock.Anything, "user44").Return(cart, nil)
+	deps.sessionRepo.On("Get", mock.Anything, "sess-4").Return(active, nil)
+
+	session, err := (*svc).CreateCheckoutSession(context.Background(), "user44")
+
+	assert.NoError(t, err)
+	assert.Same(t, active, session)
+	deps.sessionRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestCreateCheckoutSession_RejectsEmptyCart(t *testing.T) {
+	svc, redisRepo, _ := setupService(t)
+
+	redisRepo.On("GetCart", mock.Anything, "user45").Return(&model.Cart{UserID: "user45", Items: []model.CartItem{}}, nil)
+
+	_, err := (*svc).CreateCheckoutSession(context.Background(), "user45")
+	assert.ErrorIs(t, err, model.ErrCartEmpty)
+}
+
+func TestCompleteCheckoutSession_ChargesSnapshotAndDeletesCart(t *testing.T) {
+	svc, deps := setupServiceDeps(t)
+
+	snapshot := *lockedCart("user46", "sess-5", 10*time.Minute)
+	snapshot.Discounts = []model.AppliedDiscount{{Code: "SAVE5", Amount: inr("5.00"), Applied: true}}
+	snapshot.GrandTotal = inr("15.00")
+	completed := &model.CheckoutSession{ID: "sess-5", UserID: "user46", Status: model.CheckoutCompleted, OrderID: "order-90", Cart: snapshot}
+	active := &model.CheckoutSession{ID: "sess-5", UserID: "user46", Status: model.CheckoutActive, Cart: snapshot,
+		ExpiresAt: time.Now().Add(10 * time.Minute)}
+	deps.sessionRepo.On("Get", mock.Anything, "sess-5").Return(active, nil)
+	deps.sessionRepo.On("Complete", mock.Anything, "sess-5", "user46", "order-90", mock.Anything).Return(completed, nil)
+	deps.couponRepo.On("GetCoupon", mock.Anything, "SAVE5").Return(&model.Coupon{Code: "SAVE5", MaxUsesPerUser: 3}, nil)
+	deps.couponRepo.On("RedeemCoupon", mock.Anything, mock.Anything, 3).Return(true, nil)
+	deps.redisRepo.On("GetCart", mock.Anything, "user46").Return(lockedCart("user46", "sess-5", 10*time.Minute), nil)
+	deps.redisRepo.On("DeleteCart", mock.Anything, "user46").Return(nil)
+	deps.mongoRepo.On("DeleteCart", mock.Anything, "user46").Return(nil)
+
+	session, err := (*svc).CompleteCheckoutSession(context.Background(), "user46", "sess-5", "order-90")
+
+	assert.NoError(t, err)
+	assert.Equal(t, model.CheckoutCompleted, session.Status)
+	deps.couponRepo.AssertNumberOfCalls(t, "RedeemCoupon", 1)
+	deps.mongoRepo.AssertCalled(t, "DeleteCart", mock.Anything, "user46")
+	deps.publisher.AssertCalled(t, "Publish", mock.Anything, mock.MatchedBy(func(events []model.CartEvent) bool {
+		return len(events) == 1 && events[0].Type == model.EventCartCheckedOut &&
+			events[0].OrderID == "order-90" && events[0].GrandTotal.String() == "15.00"
+	}))
+}
+
+func TestCompleteCheckoutSession_UsageLimitReachedLeavesSessionActive(t *testing.T) {
+	svc, deps := setupServiceDeps(t)
+
+	snapshot := *lockedCart("user62", "sess-9", 10*time.Minute)
+	snapshot.Discounts = []model.AppliedDiscount{{Code: "ONCE", Amount: inr("5.00"), Applied: true}}
+	active := &model.CheckoutSession{ID: "sess-9", UserID: "user62", Status: model.CheckoutActive, Cart: snapshot,
+		ExpiresAt: time.Now().Add(10 * time.Minute)}
+	deps.sessionRepo.On("Get", mock.Anything, "sess-9").Return(active, nil)
+	deps.couponRepo.On("GetCoupon", mock.Anything, "ONCE").Return(&model.Coupon{Code: "ONCE", MaxUsesPerUser: 1}, nil)
+	deps.couponRepo.On("RedeemCoupon", mock.Anything, mock.Anything, 1).Return(false, nil)
+
+	_, err := (*svc).CompleteCheckoutSession(context.Background(), "user62", "sess-9", "order-92")
+
+	assert.ErrorIs(t, err, promotion.ErrUsageLimitReached)
+	deps.sessionRepo.AssertNotCalled(t, "Complete", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	deps.mongoRepo.AssertNotCalled(t, "DeleteCart", mock.Anything, mock.Anything)
+}
+
+func TestCompleteCheckoutSession_RepeatWithSameOrderIsIdempotent(t *testing.T) {
+	svc, deps := setupServiceDeps(t)
+
+	completed := &model.CheckoutSession{ID: "sess-6", UserID: "user47", Status: model.CheckoutCompleted, OrderID: "order-91",
+		ExpiresAt: time.Now().Add(10 * time.Minute)}
+	deps.sessionRepo.On("Complete", mock.Anything, "sess-6", "user47", "order-91", mock.Anything).Return(nil, nil)
+	deps.sessionRepo.On("Get", mock.Anything, "sess-6").Return(completed, nil)
+	deps.redisRepo.On("GetCart", mock.Anything, "user47").Return(&model.Cart{UserID: "user47", Items: []model.CartItem{}}, nil)
+
+	session, err := (*svc).CompleteCheckoutSession(context.Background(), "user47", "sess-6", "order-91")
+
+	assert.NoError(t, err)
+	assert.Same(t, completed, session)
+	deps.couponRepo.AssertNotCalled(t, "RedeemCoupon", mock.Anything, mock.Anything, mock.Anything)
+	deps.publisher.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything)
+}
+
+func TestCompleteCheckoutSession_ClassifiesMisses(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	tests := []struct {
+		name    string
+		stored  *model.CheckoutSession
+		wantErr error
+	}{
+		{"unknown", nil, model.ErrCheckoutSessionNotFound},
+		{"other user", &model.CheckoutSession{ID: "s", UserID: "someone-else", Status: model.CheckoutActive}, model.ErrCheckoutSessionNotFound},
+		{"expired", &model.CheckoutSession{ID: "s", UserID: "user48", Status: model.CheckoutActive, ExpiresAt: past}, model.ErrCheckoutSessionExpired},
+		{"cancelled", &model.CheckoutSession{ID: "s", UserID: "user48", Status: model.CheckoutCancelled}, model.ErrCheckoutSessionClosed},
+		{"other order", &model.CheckoutSession{ID: "s", UserID: "user48", Status: model.CheckoutCompleted, OrderID: "order-1"}, model.ErrCheckoutSessionClosed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps := setupServiceDeps(t)
+			deps.sessionRepo.On("Complete", mock.Anything, "s", "user48", "order-2", mock.Anything).Return(nil, nil)
+			deps.sessionRepo.On("Get", mock.Anything, "s").Return(tt.stored, nil)
+
+			_, err := (*svc).CompleteCheckoutSession(context.Background(), "user48", "s", "order-2")
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestCancelCheckoutSession_UnlocksCart(t *testing.T) {
+	svc, deps := setupServiceDeps(t)
+
+	cart := lockedCart("user49", "sess-7", 10*time.Minute)
+	cancelled := &model.CheckoutSession{ID: "sess-7", UserID: "user49", Status: model.CheckoutCancelled}
+	deps.sessionRepo.On("Cancel", mock.Anything, "sess-7", "user49", mock.Anything).Return(cancelled, nil)
+	deps.redisRepo.On("GetCart", mock.Anything, "user49").Return(cart, nil)
+	deps.redisRepo.On("SaveCart", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	deps.mongoRepo.On("UpsertCart", mock.Anything, mock.Anything).Return(nil)
+
+	_, err := (*svc).CancelCheckoutSession(context.Background(), "user49", "sess-7")
+
+	assert.NoError(t, err)
+	assert.Empty(t, cart.CheckoutSessionID)
+	assert.Nil(t, cart.LockedUntil)
+	assert.Equal(t, 2, cart.Items[0].Quantity)
 }
@@ -0,0 +1,127 @@
+package sync_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/emart/cart-service/internal/config"
+	"github.com/emart/cart-service/internal/model"
+	"github.com/emart/cart-service/internal/notify"
+	cartsync "github.com/emart/cart-service/internal/sync"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+type MockAbandonedRepo struct{ mock.Mock }
+
+func (m *MockAbandonedRepo) ClaimAbandoned(ctx context.Context, cutoff time.Time, limit int) ([]*model.Cart, error) {
+	args := m.Called(ctx, cutoff, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.Cart), args.Error(1)
+}
+func (m *MockAbandonedRepo) ReleaseClaim(ctx context.Context, userID string, maxAttempts int) (bool, error) {
+	args := m.Called(ctx, userID, maxAttempts)
+	return args.Bool(0), args.Error(1)
+}
+func (m *MockAbandonedRepo) MarkNotified(ctx context.Context, userID string, at time.Time) error {
+	return m.Called(ctx, userID, at).Error(0)
+}
+
+type MockNotifier struct{ mock.Mock }
+
+func (m *MockNotifier) NotifyAbandonedCart(ctx context.Context, cart *model.Cart) error {
+	return m.Called(ctx, cart).Error(0)
+}
+
+func runAbandonedJob(t *testing.T, repo *MockAbandonedRepo, notifier *MockNotifier) {
+	t.Helper()
+	logger, _ := zap.NewDevelopment()
+	cfg := &config.Config{Abandoned: config.AbandonedConfig{
+		After: 24 * time.Hour, Interval: 10 * time.Millisecond, BatchSize: 10, MaxAttempts: 3,
+	}}
+	job := cartsync.NewAbandonedCartJob(repo, notifier, cfg, logger)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	job.Start(ctx)
+}
+
+func TestAbandonedCartJob_NotifiesClaimedCartsOnce(t *testing.T) {
+	repo := new(MockAbandonedRepo)
+	notifier := new(MockNotifier)
+
+	cart := &model.Cart{UserID: "u1", TotalItems: 2, TotalPrice: model.MoneyFromMinor(5998, model.DefaultCurrency)}
+	repo.On("ClaimAbandoned", mock.Anything, mock.Anything, 10).Return([]*model.Cart{cart}, nil).Once()
+	repo.On("ClaimAbandoned", mock.Anything, mock.Anything, 10).Return(nil, nil) // already claimed
+	repo.On("MarkNotified", mock.Anything, "u1", mock.Anything).Return(nil)
+	notifier.On("NotifyAbandonedCart", mock.Anything, cart).Return(nil)
+
+	runAbandonedJob(t, repo, notifier)
+
+	notifier.AssertNumberOfCalls(t, "NotifyAbandonedCart", 1)
+	repo.AssertCalled(t, "MarkNotified", mock.Anything, "u1", mock.Anything)
+	assert.Equal(t, "59.98", cart.GrandTotal.String()) // pre-discount carts fall back to the subtotal
+}
+
+func TestAbandonedCartJob_ClaimUsesConfiguredWindow(t *testing.T) {
+	repo := new(MockAbandonedRepo)
+	notifier := new(MockNotifier)
+
+	repo.On("ClaimAbandoned", mock.Anything, mock.MatchedBy(func(cutoff time.Time) bool {
+		age := time.Since(cutoff)
+		return age >= 24*time.Hour && age < 25*time.Hour
+	}), 10).Return(nil, nil)
+
+	runAbandonedJob(t, repo, notifier)
+
+	repo.AssertExpectations(t)
+}
+
+func TestAbandonedCartJob_ReleasesClaimWhenNotifierFails(t *testing.T) {
+	repo := new(MockAbandonedRepo)
+	notifier := new(MockNotifier)
+
+	cart := &model.Cart{UserID: "u2"}
+	repo.On("ClaimAbandoned", mock.Anything, mock.Anything, 10).Return([]*model.Cart{cart}, nil)
+	repo.On("ReleaseClaim", mock.Anything, "u2", 3).Return(false, nil)
+	notifier.On("NotifyAbandonedCart", mock.Anything, cart).Return(assert.AnError)
+
+	runAbandonedJob(t, repo, notifier)
+
+	repo.AssertCalled(t, "ReleaseClaim", mock.Anything, "u2", 3)
+	repo.AssertNotCalled(t, "MarkNotified", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAbandonedCartJob_StopsRetryingOnceTheRepoGivesUp(t *testing.T) {
+	repo := new(MockAbandonedRepo)
+	notifier := new(MockNotifier)
+
+	cart := &model.Cart{UserID: "u3", Email: "u3@example.com"}
+	repo.On("ClaimAbandoned", mock.Anything, mock.Anything, 10).Return([]*model.Cart{cart}, nil).Once()
+	repo.On("ClaimAbandoned", mock.Anything, mock.Anything, 10).Return(nil, nil) // the claim was kept
+	repo.On("ReleaseClaim", mock.Anything, "u3", 3).Return(true, nil)
+	notifier.On("NotifyAbandonedCart", mock.Anything, cart).Return(assert.AnError)
+
+	runAbandonedJob(t, repo, notifier)
+
+	notifier.AssertNumberOfCalls(t, "NotifyAbandonedCart", 1)
+}
+
+func TestAbandonedCartJob_KeepsClaimWhenCartHasNoEmail(t *testing.T) {
+	repo := new(MockAbandonedRepo)
+	notifier := new(MockNotifier)
+
+	cart := &model.Cart{UserID: "u4"}
+	repo.On("ClaimAbandoned", mock.Anything, mock.Anything, 10).Return([]*model.Cart{cart}, nil).Once()
+	repo.On("ClaimAbandoned", mock.Anything, mock.Anything, 10).Return(nil, nil)
+	notifier.On("NotifyAbandonedCart", mock.Anything, cart).Return(notify.ErrNoRecipient)
+
+	runAbandonedJob(t, repo, notifier)
+
+	repo.AssertNotCalled(t, "ReleaseClaim", mock.Anything, mock.Anything, mock.Anything)
+	repo.AssertNotCalled(t, "MarkNotified", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,204 @@
+package sync_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/emart/cart-service/internal/config"
+	"github.com/emart/cart-service/internal/model"
+	cartsync "github.com/emart/cart-service/internal/sync"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// ============================================================
+// Mock Repositories
+// ============================================================
+
+type MockRedisRepo struct{ mock.Mock }
+
+func (m *MockRedisRepo) GetCart(ctx context.Context, userID string) (*model.Cart, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Cart), args.Error(1)
+}
+func (m *MockRedisRepo) SaveCart(ctx context.Context, cart *model.Cart, ttl time.Duration) error {
+	return m.Called(ctx, cart, ttl).Error(0)
+}
+func (m *MockRedisRepo) DeleteCart(ctx context.Context, userID string) error {
+	return m.Called(ctx, userID).Error(0)
+}
+func (m *MockRedisRepo) MarkDirty(ctx context.Context, userIDs ...string) error {
+	return m.Called(ctx, userIDs).Error(0)
+}
+func (m *MockRedisRepo) PopDirty(ctx context.Context, count int) ([]string, error) {
+	args := m.Called(ctx, count)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+func (m *MockRedisRepo) ScanCartUserIDs(ctx context.Context, cursor uint64, count int64) ([]string, uint64, error) {
+	args := m.Called(ctx, cursor, count)
+	return args.Get(0).([]string), args.Get(1).(uint64), args.Error(2)
+}
+func (m *MockRedisRepo) Ping(ctx context.Context) error { return m.Called(ctx).Error(0) }
+
+type MockMongoRepo struct{ mock.Mock }
+
+func (m *MockMongoRepo) GetCart(ctx context.Context, userID string) (*model.Cart, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Cart), args.Error(1)
+}
+func (m *MockMongoRepo) UpsertCart(ctx context.Context, cart *model.Cart) error {
+	return m.Called(ctx, cart).Error(0)
+}
+func (m *MockMongoRepo) DeleteCart(ctx context.Context, userID string) error {
+	return m.Called(ctx, userID).Error(0)
+}
+func (m *MockMongoRepo) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+func (m *MockMongoRepo) Ping(ctx context.Context) error { return m.Called(ctx).Error(0) }
+
+// ============================================================
+// Unit Tests
+// ============================================================
+
+func runSyncer(t *testing.T, redisRepo *MockRedisRepo, mongoRepo *MockMongoRepo, cfg *config.Config) {
+	t.Helper()
+	logger, _ := zap.NewDevelopment()
+	syncer := cartsync.NewCartSyncer(redisRepo, mongoRepo, cfg, logger)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	syncer.Start(ctx)
+}
+
+func TestSyncer_DrainsDirtyCartsInBatches(t *testing.T) {
+	redisRepo := new(MockRedisRepo)
+	mongoRepo := new(MockMongoRepo)
+	cfg := &config.Config{Sync: config.SyncConfig{Interval: 10 * time.Millisecond, BatchSize: 2}}
+
+	redisRepo.On("PopDirty", mock.Anything, 2).Return([]string{"u1", "u2"}, nil).Once()
+	redisRepo.On("PopDirty", mock.Anything, 2).Return([]string{"u3"}, nil).Once()
+	redisRepo.On("PopDirty", mock.Anything, 2).Return(nil, nil)
+	for _, id := range []string{"u1", "u2", "u3"} {
+		redisRepo.On("GetCart", mock.Anything, id).Return(&model.Cart{UserID: id, Version: 1}, nil)
+	}
+	mongoRepo.On("UpsertCart", mock.Anything, mock.Anything).Return(nil)
+
+	runSyncer(t, redisRepo, mongoRepo, cfg)
+
+	mongoRepo.AssertNumberOfCalls(t, "UpsertCart", 3)
+	redisRepo.AssertNotCalled(t, "ScanCartUserIDs", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestSyncer_ReMarksFailedCartsDirty(t *testing.T) {
+	redisRepo := new(MockRedisRepo)
+	mongoRepo := new(MockMongoRepo)
+	cfg := &config.Config{Sync: config.SyncConfig{Interval: 10 * time.Millisecond, BatchSize: 10}}
+
+	redisRepo.On("PopDirty", mock.Anything, 10).Return([]string{"u1", "u2"}, nil).Once()
+	redisRepo.On("PopDirty", mock.Anything, 10).Return(nil, nil)
+	redisRepo.On("GetCart", mock.Anything, "u1").Return(&model.Cart{UserID: "u1", Version: 3}, nil)
+	redisRepo.On("GetCart", mock.Anything, "u2").Return(&model.Cart{UserID: "u2", Version: 5}, nil)
+	redisRepo.On("MarkDirty", mock.Anything, []string{"u2"}).Return(nil)
+	mongoRepo.On("UpsertCart", mock.Anything, mock.MatchedBy(func(c *model.Cart) bool { return c.UserID == "u1" })).
+		Return(model.ErrVersionConflict) // already synced by write-through
+	mongoRepo.On("UpsertCart", mock.Anything, mock.MatchedBy(func(c *model.Cart) bool { return c.UserID == "u2" })).
+		Return(assert.AnError)
+
+	runSyncer(t, redisRepo, mongoRepo, cfg)
+
+	redisRepo.AssertCalled(t, "MarkDirty", mock.Anything, []string{"u2"})
+	redisRepo.AssertNotCalled(t, "MarkDirty", mock.Anything, []string{"u1"})
+}
+
+func TestSyncer_FullPassScansWithCursor(t *testing.T) {
+	redisRepo := new(MockRedisRepo)
+	mongoRepo := new(MockMongoRepo)
+	cfg := &config.Config{Sync: config.SyncConfig{
+		Interval: time.Hour, FullSyncInterval: 10 * time.Millisecond, BatchSize: 50,
+	}}
+
+	redisRepo.On("ScanCartUserIDs", mock.Anything, uint64(0), int64(50)).Return([]string{"u1"}, uint64(42), nil)
+	redisRepo.On("ScanCartUserIDs", mock.Anything, uint64(42), int64(50)).Return([]string{"u2"}, uint64(0), nil)
+	redisRepo.On("GetCart", mock.Anything, "u1").Return(&model.Cart{UserID: "u1", Version: 1}, nil)
+	redisRepo.On("GetCart", mock.Anything, "u2").Return(nil, nil) // expired meanwhile
+	mongoRepo.On("UpsertCart", mock.Anything, mock.Anything).Return(nil)
+
+	runSyncer(t, redisRepo, mongoRepo, cfg)
+
+	redisRepo.AssertCalled(t, "ScanCartUserIDs", mock.Anything, uint64(42), int64(50))
+	mongoRepo.AssertCalled(t, "UpsertCart", mock.Anything, mock.Anything)
+}
+
+// recordingObserver keeps every pass reported by the syncer
+type recordingObserver struct{ passes []string }
+
+func (o *recordingObserver) ObserveSync(kind string, _ time.Duration, synced, skipped, failed int) {
+	o.passes = append(o.passes, fmt.Sprintf("%s synced=%d skipped=%d failed=%d", kind, synced, skipped, failed))
+}
+
+func TestSyncer_ReportsPassesToObserver(t *testing.T) {
+	redisRepo := new(MockRedisRepo)
+	mongoRepo := new(MockMongoRepo)
+	cfg := &config.Config{Sync: config.SyncConfig{Interval: 10 * time.Millisecond, BatchSize: 10}}
+
+	redisRepo.On("PopDirty", mock.Anything, 10).Return([]string{"u1", "u2", "u3"}, nil).Once()
+	redisRepo.On("PopDirty", mock.Anything, 10).Return(nil, nil)
+	redisRepo.On("GetCart", mock.Anything, "u1").Return(&model.Cart{UserID: "u1", Version: 1}, nil)
+	redisRepo.On("GetCart", mock.Anything, "u2").Return(nil, nil)
+	redisRepo.On("GetCart", mock.Anything, "u3").Return(&model.Cart{UserID: "u3", Version: 1}, nil)
+	redisRepo.On("MarkDirty", mock.Anything, []string{"u3"}).Return(nil)
+	mongoRepo.On("UpsertCart", mock.Anything, mock.MatchedBy(func(c *model.Cart) bool { return c.UserID == "u1" })).Return(nil)
+	mongoRepo.On("UpsertCart", mock.Anything, mock.MatchedBy(func(c *model.Cart) bool { return c.UserID == "u3" })).Return(assert.AnError)
+
+	observer := &recordingObserver{}
+	syncer := cartsync.NewCartSyncer(redisRepo, mongoRepo, cfg, zap.NewNop())
+	syncer.SetObserver(observer)
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Millisecond)
+	defer cancel()
+	syncer.Start(ctx)
+
+	if assert.NotEmpty(t, observer.passes) {
+		assert.Equal(t, "dirty synced=1 skipped=1 failed=1", observer.passes[0])
+	}
+}
+
+func TestSyncer_TagsPassLogsWithRunID(t *testing.T) {
+	redisRepo := new(MockRedisRepo)
+	mongoRepo := new(MockMongoRepo)
+	cfg := &config.Config{Sync: config.SyncConfig{Interval: 10 * time.Millisecond, BatchSize: 10}}
+
+	redisRepo.On("PopDirty", mock.Anything, 10).Return([]string{"u1"}, nil).Once()
+	redisRepo.On("PopDirty", mock.Anything, 10).Return(nil, nil)
+	redisRepo.On("GetCart", mock.Anything, "u1").Return(&model.Cart{UserID: "u1", Version: 1}, nil)
+	redisRepo.On("MarkDirty", mock.Anything, []string{"u1"}).Return(nil)
+	mongoRepo.On("UpsertCart", mock.Anything, mock.Anything).Return(assert.AnError)
+
+	core, logs := observer.New(zap.InfoLevel)
+	syncer := cartsync.NewCartSyncer(redisRepo, mongoRepo, cfg, zap.New(core))
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Millisecond)
+	defer cancel()
+	syncer.Start(ctx)
+
+	failed := logs.FilterMessage("Failed to sync cart to MongoDB").All()
+	done := logs.FilterMessage("Dirty cart sync complete").All()
+	if assert.NotEmpty(t, failed) && assert.NotEmpty(t, done) {
+		runID := failed[0].ContextMap()["sync_run_id"]
+		assert.NotEmpty(t, runID)
+		assert.Equal(t, runID, done[0].ContextMap()["sync_run_id"])
+		assert.Equal(t, "dirty", done[0].ContextMap()["sync_kind"])
+	}
+}
@@ -0,0 +1,211 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/emart/cart-service/internal/catalog"
+	"github.com/emart/cart-service/internal/config"
+	"github.com/emart/cart-service/internal/logging"
+	"github.com/emart/cart-service/internal/model"
+	mongorepo "github.com/emart/cart-service/internal/repository/mongo"
+	redisrepo "github.com/emart/cart-service/internal/repository/redis"
+	"github.com/emart/cart-service/internal/service"
+	"github.com/emart/cart-service/internal/tracing"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+const (
+	incomingTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	incomingSpanID  = "00f067aa0ba902b7"
+	traceparent     = "00-" + incomingTraceID + "-" + incomingSpanID + "-01"
+)
+
+// recordSpans installs a tracer provider that keeps finished spans in memory
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(resetGlobals)
+	return recorder
+}
+
+func resetGlobals() {
+	otel.SetTracerProvider(noop.NewTracerProvider())
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+}
+
+func spanNamed(t *testing.T, recorder *tracetest.SpanRecorder, name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+	for _, s := range recorder.Ended() {
+		if s.Name() == name {
+			return s
+		}
+	}
+	t.Fatalf("no span named %q", name)
+	return nil
+}
+
+// Only the methods under test are stubbed; the rest are promoted from the nil
+// embedded interfaces and must not be called.
+type stubRedisRepo struct {
+	redisrepo.CartRedisRepository
+	cart *model.Cart
+}
+
+func (r *stubRedisRepo) GetCart(ctx context.Context, userID string) (*model.Cart, error) {
+	return r.cart, nil
+}
+
+type stubMongoRepo struct {
+	mongorepo.CartMongoRepository
+	err error
+}
+
+func (r *stubMongoRepo) UpsertCart(ctx context.Context, cart *model.Cart) error {
+	return r.err
+}
+
+// cacheOnlyService reads carts straight from the cache, like the real
+// service does on a hit
+type cacheOnlyService struct {
+	service.CartService
+	cache redisrepo.CartRedisRepository
+}
+
+func (s *cacheOnlyService) GetCart(ctx context.Context, userID string) (*model.Cart, error) {
+	return s.cache.GetCart(ctx, userID)
+}
+
+func TestMiddleware_ContinuesIncomingTrace(t *testing.T) {
+	recorder := recordSpans(t)
+	gin.SetMode(gin.TestMode)
+
+	var handlerTraceID string
+	router := gin.New()
+	router.Use(tracing.Middleware())
+	router.GET("/api/v1/cart/items/:itemId", func(c *gin.Context) {
+		handlerTraceID = trace.SpanContextFromContext(c.Request.Context()).TraceID().String()
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/cart/items/42", nil)
+	req.Header.Set("traceparent", traceparent)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	span := spanNamed(t, recorder, "GET /api/v1/cart/items/:itemId")
+	assert.Equal(t, incomingTraceID, span.SpanContext().TraceID().String())
+	assert.Equal(t, incomingSpanID, span.Parent().SpanID().String())
+	assert.Equal(t, trace.SpanKindServer, span.SpanKind())
+	assert.Equal(t, incomingTraceID, handlerTraceID)
+}
+
+func TestMiddleware_MarksServerErrors(t *testing.T) {
+	recorder := recordSpans(t)
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(tracing.Middleware())
+	router.GET("/boom", func(c *gin.Context) { c.Status(http.StatusServiceUnavailable) })
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/boom", nil))
+
+	span := spanNamed(t, recorder, "GET /boom")
+	assert.Equal(t, codes.Error, span.Status().Code)
+}
+
+func TestTraceCartService_RepositorySpansAreChildren(t *testing.T) {
+	recorder := recordSpans(t)
+	cache := tracing.TraceRedis(&stubRedisRepo{cart: &model.Cart{UserID: "user-1"}})
+	svc := tracing.TraceCartService(&cacheOnlyService{cache: cache})
+
+	ctx, root := otel.Tracer("test").Start(context.Background(), "request")
+	_, err := svc.GetCart(ctx, "user-1")
+	root.End()
+	require.NoError(t, err)
+
+	svcSpan := spanNamed(t, recorder, "CartService.GetCart")
+	redisSpan := spanNamed(t, recorder, "redis.GetCart")
+	assert.Equal(t, root.SpanContext().SpanID(), svcSpan.Parent().SpanID())
+	assert.Equal(t, svcSpan.SpanContext().SpanID(), redisSpan.Parent().SpanID())
+	assert.Equal(t, root.SpanContext().TraceID(), redisSpan.SpanContext().TraceID())
+}
+
+func TestTraceMongo_RecordsErrors(t *testing.T) {
+	recorder := recordSpans(t)
+	repo := tracing.TraceMongo(&stubMongoRepo{err: model.ErrVersionConflict})
+
+	err := repo.UpsertCart(context.Background(), &model.Cart{UserID: "user-1", Version: 3})
+	assert.True(t, errors.Is(err, model.ErrVersionConflict))
+
+	span := spanNamed(t, recorder, "mongo.UpsertCart")
+	assert.Equal(t, codes.Error, span.Status().Code)
+	assert.Equal(t, trace.SpanKindClient, span.SpanKind())
+}
+
+func TestCatalogClient_ForwardsTraceparent(t *testing.T) {
+	recordSpans(t)
+	var header string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get("traceparent")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"success":true,"data":{"id":"7","name":"Clean Code","cost":"699.00","stock":5}}`))
+	}))
+	defer srv.Close()
+	client := tracing.TraceCatalog(catalog.NewHTTPClient(catalog.Endpoints{Books: srv.URL}, "", time.Second))
+
+	ctx, root := otel.Tracer("test").Start(context.Background(), "request")
+	_, err := client.GetProduct(ctx, "books", "7")
+	root.End()
+	require.NoError(t, err)
+
+	require.NotEmpty(t, header)
+	assert.Contains(t, header, root.SpanContext().TraceID().String())
+	assert.NotContains(t, header, root.SpanContext().SpanID().String(), "the catalog call's own span should be the parent")
+}
+
+func TestWithTrace_AddsTraceFields(t *testing.T) {
+	recordSpans(t)
+	core, logs := observer.New(zap.InfoLevel)
+	logger := zap.New(core)
+
+	logging.WithTrace(context.Background(), logger).Info("no span")
+	ctx, span := otel.Tracer("test").Start(context.Background(), "request")
+	logging.WithTrace(ctx, logger).Info("in span")
+	span.End()
+
+	entries := logs.All()
+	require.Len(t, entries, 2)
+	assert.NotContains(t, entries[0].ContextMap(), "trace_id")
+	assert.Equal(t, span.SpanContext().TraceID().String(), entries[1].ContextMap()["trace_id"])
+	assert.Equal(t, span.SpanContext().SpanID().String(), entries[1].ContextMap()["span_id"])
+}
+
+func TestSetup_Exporters(t *testing.T) {
+	t.Cleanup(resetGlobals)
+	cfg := &config.Config{Tracing: config.TracingConfig{Exporter: "none", SampleRatio: 1}}
+
+	shutdown, err := tracing.Setup(context.Background(), cfg, zap.NewNop())
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+
+	cfg.Tracing.Exporter = "jaeger"
+	_, err = tracing.Setup(context.Background(), cfg, zap.NewNop())
+	assert.Error(t, err)
+}
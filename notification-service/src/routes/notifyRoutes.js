@@ -40,6 +40,22 @@ router.post('/welcome', async (req, res, next) => {
   } catch (err) { next(err); }
 });
 
+// POST /api/v1/notify/cart-abandoned  — called by Cart Service's abandoned cart job.
+// A failed send answers 502 so the job counts the attempt and retries later.
+router.post('/cart-abandoned', async (req, res, next) => {
+  try {
+    const cart = req.body;
+    if (!cart?.user_email || !Array.isArray(cart?.items) || cart.items.length === 0)
+      return res.status(400).json({ success: false, message: 'user_email and items required' });
+
+    logger.info('Sending abandoned cart reminder', { userId: cart.user_id, to: cart.user_email });
+    const result = await mailer.sendCartAbandoned(cart);
+    if (!result.success)
+      return res.status(502).json({ success: false, message: 'Cart reminder email failed', data: result });
+    res.json({ success: true, message: 'Cart reminder email sent', data: result });
+  } catch (err) { next(err); }
+});
+
 // POST /api/v1/notify/test  — dev/QA only
 router.post('/test', async (req, res, next) => {
   try {
@@ -108,6 +108,28 @@ async function sendWelcome(user) {
   });
 }
 
+// cart mirrors Cart Service's payload: snake_case, prices as decimal strings
+async function sendCartAbandoned(cart) {
+  const format = (n) => new Intl.NumberFormat('en-IN', {
+    style: 'currency', currency: cart.currency || 'INR', maximumFractionDigits: 2
+  }).format(Number(n) || 0);
+
+  return sendMail({
+    to:       cart.user_email,
+    subject:  '🛒 You left something in your cart',
+    template: 'cart-abandoned',
+    data: {
+      userName:   cart.user_name || 'there',
+      items:      (cart.items || []).map(i => ({
+        productName: i.product_name, quantity: i.quantity, price: format(i.price)
+      })),
+      totalItems: cart.total_items,
+      grandTotal: format(cart.grand_total),
+      year:       new Date().getFullYear(),
+    }
+  });
+}
+
 // ── Inline fallback templates (used if template files missing) ─
 function getFallbackTemplate(name) {
   const base = `<!DOCTYPE html><html><body style="font-family:Arial,sans-serif;max-width:600px;margin:0 auto;padding:24px">`;
@@ -141,9 +163,21 @@ function getFallbackTemplate(name) {
         <p>Discover Books, Courses, and Software — all in one place.</p>
         <a href="https://emart.com" style="background:#3B82F6;color:#fff;padding:12px 24px;border-radius:8px;text-decoration:none">Start Shopping</a>
       ` + foot;
+    case 'cart-abandoned':
+      return base + `
+        <h1 style="color:#1D4ED8">🛒 Still thinking it over?</h1>
+        <p>Hi {{userName}}, you left {{totalItems}} item(s) in your cart.</p>
+        {{#each items}}
+        <div style="border:1px solid #E5E7EB;padding:12px;border-radius:8px;margin-bottom:8px">
+          <strong>{{productName}}</strong> × {{quantity}} — {{price}}
+        </div>
+        {{/each}}
+        <h3>Total: <span style="color:#1D4ED8">{{grandTotal}}</span></h3>
+        <a href="https://emart.com/cart" style="background:#3B82F6;color:#fff;padding:12px 24px;border-radius:8px;text-decoration:none">Back to Cart</a>
+      ` + foot;
     default:
       return base + `<p>Notification from Emart</p>` + foot;
   }
 }
 
-module.exports = { sendOrderConfirmation, sendOrderFailed, sendWelcome, sendMail };
+module.exports = { sendOrderConfirmation, sendOrderFailed, sendWelcome, sendCartAbandoned, sendMail };
@@ -46,6 +46,22 @@ describe('Notification API', () => {
     });
   });
 
+  describe('POST /api/v1/notify/cart-abandoned', () => {
+    it('requires auth', async () => {
+      const res = await request(app).post('/api/v1/notify/cart-abandoned').send({});
+      expect(res.status).toBe(401);
+    });
+
+    it('rejects a cart without an email address', async () => {
+      if (JWT === 'missing-jwt') return;
+      const res = await request(app)
+        .post('/api/v1/notify/cart-abandoned')
+        .set('Authorization', `Bearer ${JWT}`)
+        .send({ user_id: 'user-1', items: [{ product_name: 'Clean Code', quantity: 1, price: '499.00' }] });
+      expect(res.status).toBe(400);
+    });
+  });
+
   describe('POST /api/v1/notify/test', () => {
     it('sends test email', async () => {
       if (JWT === 'missing-jwt') return;
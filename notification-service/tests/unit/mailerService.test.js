@@ -48,6 +48,16 @@ describe('mailerService.sendWelcome', () => {
   });
 });
 
+describe('mailerService.sendCartAbandoned', () => {
+  it('sends cart reminder email', async () => {
+    const result = await mailer.sendCartAbandoned({
+      user_id: 'user-1', user_email: 'shopper@emart.com', total_items: 2, grand_total: '998.00', currency: 'INR',
+      items: [{ product_name: 'Clean Code', quantity: 2, price: '499.00' }]
+    });
+    expect(result.success).toBe(true);
+  });
+});
+
 describe('mailerService error handling', () => {
   it('returns success:false on transport error', async () => {
     const nodemailer = require('nodemailer');